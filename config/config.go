@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 )
 
 // AppConfig holds all application configurations.
@@ -10,6 +11,18 @@ type AppConfig struct {
 	DB        *DBConfig
 	JWTSecret string
 	Admin     *AdminConfig // <-- RENAMED FOR CLARITY
+
+	// TrashRetentionDays is how long deleted projects, user stories and tasks stay in the trash.
+	TrashRetentionDays int
+
+	// RiskStaleDays is how long a task may stay in progress or in review without a status
+	// change before the at-risk work detection flags it.
+	RiskStaleDays int
+
+	// WSBackplane is how WebSocket events reach the clients of every API instance:
+	// "memory" for a single instance, or "postgres" to share them through the database
+	// with LISTEN/NOTIFY when running several replicas.
+	WSBackplane string
 }
 
 // AdminConfig holds the default admin user configuration.
@@ -52,6 +65,9 @@ func LoadConfig() *AppConfig {
 			Password: getEnv("ADMIN_PASSWORD", "admin123"),
 			Nombre:   getEnv("ADMIN_NAME", "Admin"),
 		},
+		TrashRetentionDays: getEnvInt("TRASH_RETENTION_DAYS", 30),
+		RiskStaleDays:      getEnvInt("RISK_STALE_DAYS", 5),
+		WSBackplane:        getEnv("WS_BACKPLANE", "memory"),
 	}
 }
 
@@ -62,3 +78,14 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt retrieves an integer environment variable or returns a default value
+// when it is unset or not a valid integer.
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
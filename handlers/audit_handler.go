@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// AuditHandler handles HTTP requests for the audit log.
+type AuditHandler struct {
+	Service *services.AuditService
+}
+
+// NewAuditHandler creates a new instance of AuditHandler.
+func NewAuditHandler(service *services.AuditService) *AuditHandler {
+	return &AuditHandler{Service: service}
+}
+
+// GetAuditLogs handles querying the audit log. Supported query parameters:
+// entityType, entityId, actorId, requestId, from and to (RFC 3339 or YYYY-MM-DD), and the
+// list parameters limit, offset and sort.
+func (h *AuditHandler) GetAuditLogs(c echo.Context) error {
+	filter := storage.AuditLogFilter{
+		EntityType: c.QueryParam("entityType"),
+		RequestID:  c.QueryParam("requestId"),
+	}
+
+	uintParams := map[string]*uint{"entityId": &filter.EntityID, "actorId": &filter.ActorID}
+	for name, target := range uintParams {
+		if raw := c.QueryParam(name); raw != "" {
+			value, err := strconv.ParseUint(raw, 10, 32)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid " + name})
+			}
+			*target = uint(value)
+		}
+	}
+
+	query, err := parseListQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	if filter.From, err = parseAuditTime(c.QueryParam("from"), false); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid 'from' date, use RFC 3339 or YYYY-MM-DD"})
+	}
+	if filter.To, err = parseAuditTime(c.QueryParam("to"), true); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid 'to' date, use RFC 3339 or YYYY-MM-DD"})
+	}
+
+	userRole, _ := c.Get("userRole").(string)
+
+	page, err := h.Service.GetAuditLogs(filter, query, userRole)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "forbidden"):
+			return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+		case strings.Contains(err.Error(), "invalid audit log query"), strings.Contains(err.Error(), "invalid list query"):
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// parseAuditTime parses an RFC 3339 timestamp or a plain date. A plain date used as
+// the end of a range covers the whole day.
+func parseAuditTime(raw string, endOfDay bool) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+)
+
+// BulkHandler handles HTTP requests for bulk operations on tasks and user stories.
+type BulkHandler struct {
+	Service *services.BulkService
+}
+
+// NewBulkHandler creates a new instance of BulkHandler.
+func NewBulkHandler(service *services.BulkService) *BulkHandler {
+	return &BulkHandler{Service: service}
+}
+
+// bulkErrorStatus maps bulk service errors to HTTP status codes.
+func bulkErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "forbidden"):
+		return http.StatusForbidden
+	case strings.Contains(msg, "invalid bulk request"):
+		return http.StatusBadRequest
+	case strings.Contains(msg, "bulk operation rejected"):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// BulkUpdateTasks applies one action to many tasks of a project.
+func (h *BulkHandler) BulkUpdateTasks(c echo.Context) error {
+	return h.bulkUpdate(c, (*services.BulkService).BulkUpdateTasks)
+}
+
+// BulkUpdateUserStories applies one action to many user stories of a project.
+func (h *BulkHandler) BulkUpdateUserStories(c echo.Context) error {
+	return h.bulkUpdate(c, (*services.BulkService).BulkUpdateUserStories)
+}
+
+// bulkUpdate binds the request, runs op on the request-scoped service and broadcasts
+// the changed items as one WebSocket event. When any item fails, the per-item result
+// is returned with 422 and nothing is changed.
+func (h *BulkHandler) bulkUpdate(c echo.Context, op func(s *services.BulkService, projectID uint, req services.BulkRequest, userID uint, role string) (*services.BulkResult, error)) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+	var req services.BulkRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	result, err := op(h.Service.WithContext(c.Request().Context()), uint(projectID), req, uint(userID), userRole)
+	if err != nil {
+		if result != nil {
+			return c.JSON(bulkErrorStatus(err), echo.Map{"error": err.Error(), "result": result})
+		}
+		return c.JSON(bulkErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+)
+
+// ContributionHandler handles HTTP requests for per-member contribution analytics.
+type ContributionHandler struct {
+	Service *services.ContributionService
+}
+
+// NewContributionHandler creates a new instance of ContributionHandler.
+func NewContributionHandler(service *services.ContributionService) *ContributionHandler {
+	return &ContributionHandler{Service: service}
+}
+
+// GetProjectContributions returns what each member contributed over the whole project.
+func (h *ContributionHandler) GetProjectContributions(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	report, err := h.Service.WithContext(c.Request().Context()).GetProjectContributions(uint(projectID), uint(userID), userRole)
+	if err != nil {
+		return contributionError(c, err)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// GetSprintContributions returns what each member contributed to the tasks of a sprint.
+func (h *ContributionHandler) GetSprintContributions(c echo.Context) error {
+	sprintID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid sprint ID"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	report, err := h.Service.WithContext(c.Request().Context()).GetSprintContributions(uint(sprintID), uint(userID), userRole)
+	if err != nil {
+		return contributionError(c, err)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// contributionError maps contribution service errors to HTTP responses.
+func contributionError(c echo.Context, err error) error {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case strings.Contains(err.Error(), "forbidden"):
+		return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Could not compute contributions"})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/buga/API_wrkf/middleware"
 	"github.com/buga/API_wrkf/services"
@@ -37,9 +38,9 @@ func (h *EvaluationHandler) CreateEvaluation(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
 	}
 
-	evaluation, err := h.Service.CreateEvaluation(uint(taskID), evaluatorID, req)
+	evaluation, err := h.Service.WithContext(c.Request().Context()).CreateEvaluation(uint(taskID), evaluatorID, req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
 	}
 
 	return c.JSON(http.StatusCreated, evaluation)
@@ -52,9 +53,14 @@ func (h *EvaluationHandler) GetEvaluationsByTaskID(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid task ID"})
 	}
 
-	evaluations, err := h.Service.GetEvaluationsByTaskID(uint(taskID))
+	userID, err := middleware.GetUserIDFromContext(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	evaluations, err := h.Service.GetEvaluationsByTaskID(uint(taskID), userID)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
 	}
 
 	if len(evaluations) == 0 {
@@ -63,3 +69,255 @@ func (h *EvaluationHandler) GetEvaluationsByTaskID(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, evaluations)
 }
+
+// GetEvaluationByID handles fetching a single evaluation.
+func (h *EvaluationHandler) GetEvaluationByID(c echo.Context) error {
+	evaluationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid evaluation ID"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	evaluation, err := h.Service.GetEvaluationByID(uint(evaluationID), userID)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, evaluation)
+}
+
+// UpdateEvaluation handles editing a draft evaluation.
+func (h *EvaluationHandler) UpdateEvaluation(c echo.Context) error {
+	evaluationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid evaluation ID"})
+	}
+
+	var req services.UpdateEvaluationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request payload"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	evaluation, err := h.Service.WithContext(c.Request().Context()).UpdateEvaluation(uint(evaluationID), userID, req)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, evaluation)
+}
+
+// SubmitEvaluation handles moving a draft evaluation to the submitted state.
+func (h *EvaluationHandler) SubmitEvaluation(c echo.Context) error {
+	evaluationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid evaluation ID"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	evaluation, err := h.Service.WithContext(c.Request().Context()).SubmitEvaluation(uint(evaluationID), userID)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, evaluation)
+}
+
+// PublishEvaluation handles publishing a submitted evaluation.
+func (h *EvaluationHandler) PublishEvaluation(c echo.Context) error {
+	evaluationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid evaluation ID"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	evaluation, err := h.Service.WithContext(c.Request().Context()).PublishEvaluation(uint(evaluationID), userID)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, evaluation)
+}
+
+// CreateEvaluationRound handles opening a peer evaluation round for a sprint.
+func (h *EvaluationHandler) CreateEvaluationRound(c echo.Context) error {
+	sprintID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid sprint ID"})
+	}
+
+	var req services.CreateEvaluationRoundRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request payload"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	round, err := h.Service.WithContext(c.Request().Context()).CreateEvaluationRound(uint(sprintID), userID, req)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, round)
+}
+
+// GetEvaluationRoundsBySprintID handles listing the evaluation rounds of a sprint.
+func (h *EvaluationHandler) GetEvaluationRoundsBySprintID(c echo.Context) error {
+	sprintID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid sprint ID"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	rounds, err := h.Service.GetEvaluationRoundsBySprintID(uint(sprintID), userID)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, rounds)
+}
+
+// GetEvaluationRound handles fetching a single evaluation round.
+func (h *EvaluationHandler) GetEvaluationRound(c echo.Context) error {
+	roundID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid evaluation round ID"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	round, err := h.Service.GetEvaluationRound(uint(roundID), userID)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, round)
+}
+
+// CreateRoundEvaluation handles starting a peer evaluation or self-assessment within a round.
+func (h *EvaluationHandler) CreateRoundEvaluation(c echo.Context) error {
+	roundID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid evaluation round ID"})
+	}
+
+	var req services.CreateRoundEvaluationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request payload"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	evaluation, err := h.Service.WithContext(c.Request().Context()).CreateRoundEvaluation(uint(roundID), userID, req)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, evaluation)
+}
+
+// CloseEvaluationRound handles closing a round and publishing its submitted evaluations.
+func (h *EvaluationHandler) CloseEvaluationRound(c echo.Context) error {
+	roundID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid evaluation round ID"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	round, err := h.Service.WithContext(c.Request().Context()).CloseEvaluationRound(uint(roundID), userID)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, round)
+}
+
+// GetReceivedEvaluations handles fetching the evaluations the current user received in a round.
+func (h *EvaluationHandler) GetReceivedEvaluations(c echo.Context) error {
+	roundID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid evaluation round ID"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	evaluations, err := h.Service.GetReceivedEvaluations(uint(roundID), userID)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, evaluations)
+}
+
+// GetEvaluationRoundResults handles fetching the per-member aggregation of a round.
+func (h *EvaluationHandler) GetEvaluationRoundResults(c echo.Context) error {
+	roundID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid evaluation round ID"})
+	}
+
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Could not get user from token"})
+	}
+
+	results, err := h.Service.GetEvaluationRoundResults(uint(roundID), userID)
+	if err != nil {
+		return c.JSON(evaluationErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
+
+// evaluationErrorStatus maps evaluation service errors to HTTP status codes.
+func evaluationErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "forbidden"):
+		return http.StatusForbidden
+	case strings.Contains(msg, "invalid evaluation"):
+		return http.StatusBadRequest
+	case strings.Contains(msg, "invalid transition"):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
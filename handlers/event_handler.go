@@ -38,7 +38,7 @@ func (h *EventHandler) CreateEvent(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Could not get user from token"})
 	}
 
-	createdEvent, err := h.Service.CreateEvent(&event, uint(projectID), creatorID)
+	createdEvent, err := h.Service.WithContext(c.Request().Context()).CreateEvent(&event, uint(projectID), creatorID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -97,7 +97,7 @@ func (h *EventHandler) UpdateEvent(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
 	}
 
-	updatedEvent, err := h.Service.UpdateEvent(uint(eventID), userID, updates)
+	updatedEvent, err := h.Service.WithContext(c.Request().Context()).UpdateEvent(uint(eventID), userID, updates)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -117,7 +117,7 @@ func (h *EventHandler) DeleteEvent(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Could not get user from token"})
 	}
 
-	if err := h.Service.DeleteEvent(uint(eventID), userID); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).DeleteEvent(uint(eventID), userID); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
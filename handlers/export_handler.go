@@ -4,9 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
 	"github.com/labstack/echo/v4"
 )
 
@@ -20,23 +21,81 @@ func NewExportHandler(service *services.ExportService) *ExportHandler {
 	return &ExportHandler{Service: service}
 }
 
-// ExportProject handles the request to export a project's data to CSV.
+// ExportProject handles the request to export a project's data. The "format" query
+// parameter selects the writer (csv by default; see services.ExportFormats). The export
+// can be narrowed with "sprintId", "status" (task status) and "assigneeId" (a user ID or
+// "none"); CSV columns are chosen with "columns", a comma-separated list of keys.
+// CSV is streamed straight to the response as rows are read.
 func (h *ExportHandler) ExportProject(c echo.Context) error {
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
 	}
 
-	csvData, err := h.Service.ExportProjectToCSV(uint(projectID))
+	filter, err := exportFilterFromQuery(c)
 	if err != nil {
-		// Differentiate between not found and other errors if needed
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" || format == "csv" {
+		var columns []string
+		if c.QueryParam("columns") != "" {
+			columns = strings.Split(c.QueryParam("columns"), ",")
+		}
+		export, err := h.Service.WithContext(c.Request().Context()).NewCSVExport(uint(projectID), columns, filter)
+		if err != nil {
+			return c.JSON(exportErrorStatus(err), map[string]string{"error": err.Error()})
+		}
+
+		// Set headers to prompt file download
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"%s\"", export.FileName))
+		c.Response().WriteHeader(http.StatusOK)
+		if err := export.Write(c.Response()); err != nil {
+			// The status is already sent; the truncated file is all the client gets.
+			c.Logger().Errorf("CSV export of project %d failed: %v", projectID, err)
+		}
+		return nil
+	}
+
+	file, err := h.Service.WithContext(c.Request().Context()).ExportProject(uint(projectID), format, filter)
+	if err != nil {
+		return c.JSON(exportErrorStatus(err), map[string]string{"error": err.Error()})
 	}
 
 	// Set headers to prompt file download
-	fileName := fmt.Sprintf("project_%d_export_%s.csv", projectID, time.Now().Format("20060102"))
-	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
-	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"%s\"", file.FileName))
 
-	return c.Blob(http.StatusOK, "text/csv", csvData)
+	return c.Blob(http.StatusOK, file.ContentType, file.Data)
+}
+
+// exportFilterFromQuery reads the sprintId, status and assigneeId query parameters.
+func exportFilterFromQuery(c echo.Context) (storage.ExportFilter, error) {
+	filter := storage.ExportFilter{TaskStatus: c.QueryParam("status")}
+	if value := c.QueryParam("sprintId"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("invalid sprintId")
+		}
+		sprintID := uint(id)
+		filter.SprintID = &sprintID
+	}
+	var err error
+	filter.AssigneeID, filter.Unassigned, err = parseIDFilter(c, "assigneeId")
+	return filter, err
+}
+
+// exportErrorStatus maps export service errors to HTTP status codes.
+func exportErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "unsupported export format"),
+		strings.Contains(err.Error(), "unknown export column"),
+		strings.Contains(err.Error(), "invalid task status"):
+		return http.StatusBadRequest
+	case strings.Contains(err.Error(), "project not found"):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
 }
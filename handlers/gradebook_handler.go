@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// GradebookHandler handles HTTP requests for project gradebooks.
+type GradebookHandler struct {
+	Service *services.GradebookService
+}
+
+// NewGradebookHandler creates a new instance of GradebookHandler.
+func NewGradebookHandler(service *services.GradebookService) *GradebookHandler {
+	return &GradebookHandler{Service: service}
+}
+
+// GetGradebook handles fetching the gradebook of a project.
+func (h *GradebookHandler) GetGradebook(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	gradebook, err := h.Service.GetGradebook(uint(projectID), uint(userID), userRole)
+	if err != nil {
+		return c.JSON(gradebookErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, gradebook)
+}
+
+// ExportGradebook handles downloading the gradebook as CSV (default) or XLSX (?format=xlsx).
+func (h *GradebookHandler) ExportGradebook(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	format := strings.ToLower(c.QueryParam("format"))
+	var (
+		data        []byte
+		contentType string
+	)
+	switch format {
+	case "", "csv":
+		format, contentType = "csv", "text/csv"
+		data, err = h.Service.ExportGradebookToCSV(uint(projectID), uint(userID), userRole)
+	case "xlsx":
+		contentType = xlsxContentType
+		data, err = h.Service.ExportGradebookToXLSX(uint(projectID), uint(userID), userRole)
+	default:
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Unsupported export format, use csv or xlsx"})
+	}
+	if err != nil {
+		return c.JSON(gradebookErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	// Set headers to prompt file download
+	fileName := fmt.Sprintf("project_%d_gradebook_%s.%s", projectID, time.Now().Format("20060102"), format)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+
+	return c.Blob(http.StatusOK, contentType, data)
+}
+
+// gradebookErrorStatus maps gradebook service errors to HTTP status codes.
+func gradebookErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "forbidden"):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
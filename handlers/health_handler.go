@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+)
+
+// HealthHandler handles HTTP requests for project health scores.
+type HealthHandler struct {
+	Service *services.HealthService
+}
+
+// NewHealthHandler creates a new instance of HealthHandler.
+func NewHealthHandler(service *services.HealthService) *HealthHandler {
+	return &HealthHandler{Service: service}
+}
+
+// GetProjectHealth computes the project's health score, returns it with its factors and
+// the score history, and stores it as today's score.
+func (h *HealthHandler) GetProjectHealth(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	health, err := h.Service.WithContext(c.Request().Context()).GetProjectHealth(uint(projectID), uint(userID), userRole)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "forbidden"):
+			return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+		case strings.Contains(err.Error(), "not found"):
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Could not compute the project health"})
+	}
+	return c.JSON(http.StatusOK, health)
+}
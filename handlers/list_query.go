@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// parseListQuery reads the limit, offset and sort query parameters shared by every
+// list endpoint.
+func parseListQuery(c echo.Context) (services.ListQuery, error) {
+	query := services.ListQuery{Sort: c.QueryParam("sort")}
+	intParams := map[string]*int{"limit": &query.Limit, "offset": &query.Offset}
+	for name, target := range intParams {
+		if raw := c.QueryParam(name); raw != "" {
+			value, err := strconv.Atoi(raw)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s", name)
+			}
+			*target = value
+		}
+	}
+	return query, nil
+}
+
+// parseIDFilter reads an optional ID query parameter. The value "none" selects the
+// items without one, reported through none.
+func parseIDFilter(c echo.Context, name string) (id *uint, none bool, err error) {
+	switch raw := c.QueryParam(name); raw {
+	case "":
+		return nil, false, nil
+	case "none":
+		return nil, true, nil
+	default:
+		value, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid %s", name)
+		}
+		parsed := uint(value)
+		return &parsed, false, nil
+	}
+}
+
+// parseTaskListQuery reads the list parameters and the filters shared by the task lists:
+// status, assigneeId (an ID or "none") and q.
+func parseTaskListQuery(c echo.Context) (services.ListQuery, storage.TaskFilter, error) {
+	filter := storage.TaskFilter{Status: c.QueryParam("status"), Search: c.QueryParam("q")}
+	query, err := parseListQuery(c)
+	if err != nil {
+		return query, filter, err
+	}
+	filter.AssigneeID, filter.Unassigned, err = parseIDFilter(c, "assigneeId")
+	return query, filter, err
+}
+
+// listErrorStatus maps the errors of list services to HTTP status codes.
+func listErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "invalid list query"),
+		strings.Contains(err.Error(), "invalid project status"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
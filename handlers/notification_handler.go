@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
 	"github.com/buga/API_wrkf/utils"
 	"github.com/labstack/echo/v4"
 )
@@ -19,19 +20,35 @@ func NewNotificationHandler(service *services.NotificationService) *Notification
 	return &NotificationHandler{service: service}
 }
 
-// GetUserNotifications handles the request to get all notifications for the current user.
+// GetUserNotifications handles the request to get one page of the current user's
+// notifications, optionally only the read or unread ones (?read=true|false).
 func (h *NotificationHandler) GetUserNotifications(c echo.Context) error {
 	userID, err := utils.GetUserIDFromContext(c)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID"})
 	}
+	query, err := parseListQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	var filter storage.NotificationFilter
+	if raw := c.QueryParam("read"); raw != "" {
+		read, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid read"})
+		}
+		filter.Read = &read
+	}
 
-	notifications, err := h.service.GetUserNotifications(userID)
+	page, err := h.service.ListUserNotifications(userID, filter, query)
 	if err != nil {
+		if status := listErrorStatus(err); status != http.StatusInternalServerError {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve notifications"})
 	}
 
-	return c.JSON(http.StatusOK, notifications)
+	return c.JSON(http.StatusOK, page)
 }
 
 // MarkAsRead handles the request to mark a notification as read.
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+)
+
+// ProjectArchiveHandler handles HTTP requests to export and import whole projects.
+type ProjectArchiveHandler struct {
+	Service *services.ProjectArchiveService
+}
+
+// NewProjectArchiveHandler creates a new instance of ProjectArchiveHandler.
+func NewProjectArchiveHandler(service *services.ProjectArchiveService) *ProjectArchiveHandler {
+	return &ProjectArchiveHandler{Service: service}
+}
+
+// ExportProjectArchive handles GET requests to download a project as a portable JSON archive.
+func (h *ProjectArchiveHandler) ExportProjectArchive(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	archive, err := h.Service.ExportProjectArchive(uint(projectID), uint(userID), userRole)
+	if err != nil {
+		return c.JSON(projectArchiveErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not encode project archive"})
+	}
+	fileName := fmt.Sprintf("project_%d_archive.json", projectID)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, data)
+}
+
+// ImportProjectArchive handles POST requests to create a project from an archive. The
+// archive is read from the "file" multipart field or, failing that, from the raw body.
+// By default this is a dry run that only reports what would be imported; "dryRun=false"
+// creates the project. "name" overrides the project name found in the archive.
+func (h *ProjectArchiveHandler) ImportProjectArchive(c echo.Context) error {
+	req := services.ProjectArchiveImportRequest{
+		Name:   c.QueryParam("name"),
+		DryRun: c.QueryParam("dryRun") != "false",
+	}
+
+	var err error
+	if fileHeader, ferr := c.FormFile("file"); ferr == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Could not read uploaded file"})
+		}
+		defer file.Close()
+		if req.Data, err = io.ReadAll(file); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Could not read uploaded file"})
+		}
+	} else if req.Data, err = io.ReadAll(c.Request().Body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Could not read request body"})
+	}
+
+	userID, _ := c.Get("userID").(float64)
+
+	result, err := h.Service.WithContext(c.Request().Context()).ImportProjectArchive(req, uint(userID))
+	if err != nil {
+		if result != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error(), "issues": result.Issues})
+		}
+		return c.JSON(projectArchiveErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	if result.DryRun {
+		return c.JSON(http.StatusOK, result)
+	}
+	return c.JSON(http.StatusCreated, result)
+}
+
+// projectArchiveErrorStatus maps project archive service errors to HTTP status codes.
+func projectArchiveErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "invalid project archive"):
+		return http.StatusBadRequest
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "forbidden"):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
 
 	"github.com/labstack/echo/v4"
 )
@@ -49,7 +50,7 @@ func (h *ProjectHandler) CreateProject(c echo.Context) error {
 
 	userID, _ := c.Get("userID").(float64)
 
-	if err := h.Service.CreateProject(project, uint(userID)); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).CreateProject(project, uint(userID)); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Could not create project: %v", err)})
 	}
 
@@ -68,7 +69,7 @@ func (h *ProjectHandler) AddMemberToProject(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
-	member, err := h.Service.AddMemberToProject(uint(projectID), req.UserID, req.Role)
+	member, err := h.Service.WithContext(c.Request().Context()).AddMemberToProject(uint(projectID), req.UserID, req.Role)
 	if err != nil {
 		if strings.Contains(err.Error(), "user is already a member") {
 			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
@@ -82,13 +83,52 @@ func (h *ProjectHandler) AddMemberToProject(c echo.Context) error {
 	return c.JSON(http.StatusCreated, member)
 }
 
-// GetAllProjects gestiona la solicitud HTTP para recuperar todos los proyectos.
+// RemoveMemberFromProject gestiona la solicitud HTTP para quitar a un usuario de un proyecto.
+func (h *ProjectHandler) RemoveMemberFromProject(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	if err := h.Service.WithContext(c.Request().Context()).RemoveMemberFromProject(uint(projectID), uint(userID)); err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "not a member") {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetAllProjects gestiona la solicitud HTTP para recuperar los proyectos, paginados
+// con limit, offset y sort, y filtrados por status y q (texto en el nombre).
+// Los proyectos archivados se omiten salvo con ?includeArchived=true o ?status=archived.
 func (h *ProjectHandler) GetAllProjects(c echo.Context) error {
-	projects, err := h.Service.GetProjects()
+	query, err := parseListQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	filter := storage.ProjectFilter{Status: c.QueryParam("status"), Search: c.QueryParam("q")}
+	if raw := c.QueryParam("includeArchived"); raw != "" {
+		includeArchived, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid includeArchived"})
+		}
+		filter.IncludeArchived = includeArchived
+	}
+
+	page, err := h.Service.ListProjects(filter, query)
 	if err != nil {
+		if status := listErrorStatus(err); status != http.StatusInternalServerError {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not retrieve projects"})
 	}
-	return c.JSON(http.StatusOK, projects)
+	return c.JSON(http.StatusOK, page)
 }
 
 // GetProjectByID handles the HTTP request to retrieve a single project by its ID.
@@ -121,10 +161,83 @@ func (h *ProjectHandler) UpdateProject(c echo.Context) error {
 	userID, _ := c.Get("userID").(float64)
 	userRole, _ := c.Get("userRole").(string)
 
-	updatedProject, err := h.Service.UpdateProject(uint(projectID), updates, uint(userID), userRole)
+	updatedProject, err := h.Service.WithContext(c.Request().Context()).UpdateProject(uint(projectID), updates, uint(userID), userRole)
 	if err != nil {
-		if strings.Contains(err.Error(), "forbidden") {
+		return c.JSON(projectStatusErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, updatedProject)
+}
+
+// UnarchiveProject handles the HTTP request to make an archived project writable again (admin only).
+// An optional body {"status": "planning" | "active" | "on_hold"} picks the status it returns to.
+func (h *ProjectHandler) UnarchiveProject(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if c.Request().ContentLength > 0 {
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		}
+	}
+
+	userRole, _ := c.Get("userRole").(string)
+
+	project, err := h.Service.WithContext(c.Request().Context()).UnarchiveProject(uint(projectID), req.Status, userRole)
+	if err != nil {
+		return c.JSON(projectStatusErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, project)
+}
+
+// projectStatusErrorStatus maps project update and lifecycle errors to HTTP status codes.
+func projectStatusErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "forbidden"):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), "invalid project status"):
+		return http.StatusBadRequest
+	case strings.Contains(err.Error(), "invalid status transition"):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// UpdateEvaluationPolicy handles the HTTP request to change who may evaluate a project's deliverables.
+func (h *ProjectHandler) UpdateEvaluationPolicy(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	var req struct {
+		Policy string `json:"policy"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	updatedProject, err := h.Service.WithContext(c.Request().Context()).UpdateEvaluationPolicy(uint(projectID), req.Policy, uint(userID), userRole)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case strings.Contains(err.Error(), "forbidden"):
 			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		case strings.Contains(err.Error(), "invalid evaluation policy"):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -142,7 +255,7 @@ func (h *ProjectHandler) DeleteProject(c echo.Context) error {
 	userID, _ := c.Get("userID").(float64)
 	userRole, _ := c.Get("userRole").(string)
 
-	if err := h.Service.DeleteProject(uint(projectID), uint(userID), userRole); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).DeleteProject(uint(projectID), uint(userID), userRole); err != nil {
 		if strings.Contains(err.Error(), "forbidden") {
 			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
 		}
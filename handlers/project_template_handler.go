@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+)
+
+// ProjectTemplateHandler handles HTTP requests for project templates and project cloning.
+type ProjectTemplateHandler struct {
+	Service *services.ProjectTemplateService
+}
+
+// NewProjectTemplateHandler creates a new instance of ProjectTemplateHandler.
+func NewProjectTemplateHandler(service *services.ProjectTemplateService) *ProjectTemplateHandler {
+	return &ProjectTemplateHandler{Service: service}
+}
+
+// newProjectRequest is the body of the requests that create a project from a template or a clone.
+type newProjectRequest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	StartDate   string                 `json:"startDate"` // RFC 3339 or YYYY-MM-DD
+	Include     *services.ProjectParts `json:"include"`   // Clone only; nil copies everything but members
+}
+
+// toServiceRequest converts the body into a services.NewProjectRequest.
+func (r *newProjectRequest) toServiceRequest() (services.NewProjectRequest, error) {
+	req := services.NewProjectRequest{Name: r.Name, Description: r.Description}
+	if r.StartDate == "" {
+		return req, nil
+	}
+	if t, err := time.Parse(time.RFC3339, r.StartDate); err == nil {
+		req.StartDate = &t
+		return req, nil
+	}
+	t, err := time.Parse("2006-01-02", r.StartDate)
+	if err != nil {
+		return req, err
+	}
+	req.StartDate = &t
+	return req, nil
+}
+
+// projectTemplateErrorStatus maps project template service errors to HTTP status codes.
+func projectTemplateErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "forbidden"):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// SaveProjectAsTemplate handles saving a project's structure as a template.
+func (h *ProjectTemplateHandler) SaveProjectAsTemplate(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	template, err := h.Service.WithContext(c.Request().Context()).SaveProjectAsTemplate(uint(projectID), req.Name, req.Description, uint(userID), userRole)
+	if err != nil {
+		return c.JSON(projectTemplateErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, template)
+}
+
+// GetTemplates lists the project templates. The optional "q" query parameter filters by name.
+func (h *ProjectTemplateHandler) GetTemplates(c echo.Context) error {
+	templates, err := h.Service.GetTemplates(c.QueryParam("q"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Could not retrieve project templates"})
+	}
+	return c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplate retrieves a single project template with its content.
+func (h *ProjectTemplateHandler) GetTemplate(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid template ID"})
+	}
+	template, err := h.Service.GetTemplate(uint(id))
+	if err != nil {
+		return c.JSON(projectTemplateErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate removes a project template.
+func (h *ProjectTemplateHandler) DeleteTemplate(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid template ID"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	if err := h.Service.WithContext(c.Request().Context()).DeleteTemplate(uint(id), uint(userID), userRole); err != nil {
+		return c.JSON(projectTemplateErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// CreateProjectFromTemplate handles creating a new project from a template.
+func (h *ProjectTemplateHandler) CreateProjectFromTemplate(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid template ID"})
+	}
+	var body newProjectRequest
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+	req, err := body.toServiceRequest()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid startDate, use RFC 3339 or YYYY-MM-DD"})
+	}
+	userID, _ := c.Get("userID").(float64)
+
+	project, err := h.Service.WithContext(c.Request().Context()).CreateProjectFromTemplate(uint(id), req, uint(userID))
+	if err != nil {
+		return c.JSON(projectTemplateErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, project)
+}
+
+// CloneProject handles copying a project, or the parts of it selected in "include", into a new one.
+func (h *ProjectTemplateHandler) CloneProject(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+	var body newProjectRequest
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+	req, err := body.toServiceRequest()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid startDate, use RFC 3339 or YYYY-MM-DD"})
+	}
+	parts := services.ProjectParts{Sprints: true, UserStories: true, Tasks: true, Rubrics: true, Events: true}
+	if body.Include != nil {
+		parts = *body.Include
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	project, err := h.Service.WithContext(c.Request().Context()).CloneProject(uint(projectID), req, parts, uint(userID), userRole)
+	if err != nil {
+		return c.JSON(projectTemplateErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, project)
+}
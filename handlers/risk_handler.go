@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+)
+
+// RiskHandler handles HTTP requests for stale and at-risk work findings.
+type RiskHandler struct {
+	Service *services.RiskService
+}
+
+// NewRiskHandler creates a new instance of RiskHandler.
+func NewRiskHandler(service *services.RiskService) *RiskHandler {
+	return &RiskHandler{Service: service}
+}
+
+// GetProjectRisks lists the open findings of a project (?includeResolved=true adds the
+// resolved ones).
+func (h *RiskHandler) GetProjectRisks(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+	includeResolved := false
+	if raw := c.QueryParam("includeResolved"); raw != "" {
+		if includeResolved, err = strconv.ParseBool(raw); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid includeResolved"})
+		}
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	findings, err := h.Service.WithContext(c.Request().Context()).ListProjectFindings(uint(projectID), includeResolved, uint(userID), userRole)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "forbidden"):
+			return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+		case strings.Contains(err.Error(), "not found"):
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Could not retrieve findings"})
+	}
+	return c.JSON(http.StatusOK, findings)
+}
+
+// ScanRisks runs the at-risk work detection right away. The server also runs it every hour.
+func (h *RiskHandler) ScanRisks(c echo.Context) error {
+	userRole, _ := c.Get("userRole").(string)
+	if userRole != string(models.RoleAdmin) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "forbidden: only admins can run the detection"})
+	}
+
+	result, err := h.Service.WithContext(c.Request().Context()).Detect(time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
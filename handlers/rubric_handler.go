@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/services"
@@ -57,8 +62,11 @@ func (h *RubricHandler) CreateRubric(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
-	if err := h.service.CreateRubric(&rubric); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	if err := h.service.WithContext(c.Request().Context()).CreateRubric(&rubric, uint(userID), userRole); err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
 	}
 	return c.JSON(http.StatusCreated, rubric)
 }
@@ -76,8 +84,11 @@ func (h *RubricHandler) UpdateRubric(c echo.Context) error {
 	}
 	rubric.ID = uint(id) // Ensure the ID from the URL is used
 
-	if err := h.service.UpdateRubric(&rubric); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	if err := h.service.WithContext(c.Request().Context()).UpdateRubric(&rubric, uint(userID), userRole); err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
 	}
 	return c.JSON(http.StatusOK, rubric)
 }
@@ -89,8 +100,11 @@ func (h *RubricHandler) DeleteRubric(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
 	}
 
-	if err := h.service.DeleteRubric(uint(id)); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	if err := h.service.WithContext(c.Request().Context()).DeleteRubric(uint(id), uint(userID), userRole); err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
 	}
 	return c.NoContent(http.StatusNoContent)
 }
@@ -102,9 +116,247 @@ func (h *RubricHandler) DuplicateRubric(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
 	}
 
-	newRubric, err := h.service.DuplicateRubric(uint(id))
+	newRubric, err := h.service.WithContext(c.Request().Context()).DuplicateRubric(uint(id))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 	return c.JSON(http.StatusCreated, newRubric)
 }
+
+// GetRubricVersions handles GET requests for the version history of a rubric.
+func (h *RubricHandler) GetRubricVersions(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	}
+
+	versions, err := h.service.GetRubricVersions(uint(id))
+	if err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, versions)
+}
+
+// DiffRubricVersions handles GET requests comparing two versions of a rubric.
+// The "from" and "to" query parameters default to the two latest versions.
+func (h *RubricHandler) DiffRubricVersions(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	}
+
+	versions, err := h.service.GetRubricVersions(uint(id))
+	if err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	to := versions[len(versions)-1].Version
+	if param := c.QueryParam("to"); param != "" {
+		if to, err = strconv.Atoi(param); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid 'to' version"})
+		}
+	}
+	from := to - 1
+	if param := c.QueryParam("from"); param != "" {
+		if from, err = strconv.Atoi(param); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid 'from' version"})
+		}
+	}
+
+	diff, err := h.service.DiffRubricVersions(uint(id), from, to)
+	if err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, diff)
+}
+
+// SearchTemplates handles GET requests to browse the global template library.
+// It supports the "q" (free text) and "category" query parameters.
+func (h *RubricHandler) SearchTemplates(c echo.Context) error {
+	templates, err := h.service.SearchTemplates(c.QueryParam("q"), c.QueryParam("category"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplateCategories handles GET requests for the template categories.
+func (h *RubricHandler) GetTemplateCategories(c echo.Context) error {
+	categories, err := h.service.GetTemplateCategories()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, categories)
+}
+
+// CreateTemplate handles POST requests to add a global template to the library.
+func (h *RubricHandler) CreateTemplate(c echo.Context) error {
+	var template models.Rubric
+	if err := c.Bind(&template); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+	template.CreatedByID = uint(userID)
+
+	if err := h.service.WithContext(c.Request().Context()).CreateTemplate(&template, userRole); err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, template)
+}
+
+// SaveAsTemplate handles POST requests to copy a rubric into the template library.
+func (h *RubricHandler) SaveAsTemplate(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	}
+
+	var req struct {
+		Category string `json:"category"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	userRole, _ := c.Get("userRole").(string)
+
+	template, err := h.service.WithContext(c.Request().Context()).SaveAsTemplate(uint(id), req.Category, userRole)
+	if err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, template)
+}
+
+// InstantiateTemplate handles POST requests to copy a global template into a project.
+func (h *RubricHandler) InstantiateTemplate(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	}
+
+	var req struct {
+		ProjectID uint `json:"projectId"`
+	}
+	if err := c.Bind(&req); err != nil || req.ProjectID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "A valid projectId is required"})
+	}
+
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	rubric, err := h.service.WithContext(c.Request().Context()).InstantiateTemplate(uint(id), req.ProjectID, uint(userID), userRole)
+	if err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, rubric)
+}
+
+// ImportRubric handles POST requests to import a rubric into a project from CSV or JSON.
+// The file is read from the "file" multipart field or, failing that, from the raw body.
+// The format comes from the "format" query parameter, the file extension or the content type.
+// With "preview=true" the rubric is validated and returned without being saved.
+func (h *RubricHandler) ImportRubric(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	req := services.RubricImportRequest{
+		ProjectID:   uint(projectID),
+		Format:      c.QueryParam("format"),
+		Name:        c.QueryParam("name"),
+		Description: c.QueryParam("description"),
+		Preview:     c.QueryParam("preview") == "true",
+	}
+
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Could not read uploaded file"})
+		}
+		defer file.Close()
+		if req.Data, err = io.ReadAll(file); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Could not read uploaded file"})
+		}
+		if req.Format == "" {
+			req.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+		}
+	} else {
+		if req.Data, err = io.ReadAll(c.Request().Body); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Could not read request body"})
+		}
+		if req.Format == "" {
+			switch contentType := c.Request().Header.Get(echo.HeaderContentType); {
+			case strings.HasPrefix(contentType, "text/csv"):
+				req.Format = "csv"
+			case strings.HasPrefix(contentType, echo.MIMEApplicationJSON):
+				req.Format = "json"
+			}
+		}
+	}
+
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	result, err := h.service.WithContext(c.Request().Context()).ImportRubric(req, uint(userID), userRole)
+	if err != nil {
+		if result != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error(), "issues": result.Issues})
+		}
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	if result.Preview {
+		return c.JSON(http.StatusOK, result)
+	}
+	return c.JSON(http.StatusCreated, result)
+}
+
+// ExportRubric handles GET requests to download a rubric as JSON (default) or CSV (?format=csv).
+func (h *RubricHandler) ExportRubric(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID format"})
+	}
+
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" {
+		format = "json"
+	}
+
+	data, err := h.service.ExportRubric(uint(id), format)
+	if err != nil {
+		return c.JSON(rubricErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	contentType := echo.MIMEApplicationJSON
+	if format == "csv" {
+		contentType = "text/csv"
+	}
+	fileName := fmt.Sprintf("rubric_%d.%s", id, format)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+
+	return c.Blob(http.StatusOK, contentType, data)
+}
+
+// rubricErrorStatus maps rubric service errors to HTTP status codes.
+func rubricErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrProjectArchived):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "forbidden"):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), "invalid template"),
+		strings.Contains(err.Error(), "invalid rubric import"),
+		strings.Contains(err.Error(), "invalid rubric export"):
+		return http.StatusBadRequest
+	case strings.Contains(err.Error(), "invalid rubric"):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
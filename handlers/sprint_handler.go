@@ -47,7 +47,7 @@ func (h *SprintHandler) CreateSprint(c echo.Context) error {
 
 	creatorID, _ := c.Get("userID").(float64)
 
-	if err := h.Service.CreateSprint(sprint, uint(projectID), uint(creatorID)); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).CreateSprint(sprint, uint(projectID), uint(creatorID)); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Could not create sprint: %v", err)})
 	}
 
@@ -132,7 +132,7 @@ func (h *SprintHandler) UpdateSprint(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
 	}
 
-	if err := h.Service.UpdateSprint(sprintToUpdate); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).UpdateSprint(sprintToUpdate); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not update sprint"})
 	}
 
@@ -155,7 +155,7 @@ func (h *SprintHandler) DeleteSprint(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid sprint ID"})
 	}
 
-	if err := h.Service.DeleteSprint(uint(sprintID)); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).DeleteSprint(uint(sprintID)); err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Sprint not found or could not be deleted"})
 	}
 
@@ -163,12 +163,18 @@ func (h *SprintHandler) DeleteSprint(c echo.Context) error {
 }
 
 // GetSprintTasks godoc
-// @Summary      Get all Tasks for a Sprint
-// @Description  Retrieves all tasks for a specific sprint with their relationships.
+// @Summary      Get the Tasks of a Sprint
+// @Description  Retrieves one page of the tasks of a specific sprint with their relationships, newest first by default.
 // @Tags         Sprints
 // @Produce      json
-// @Param        sprintId   path      int  true  "Sprint ID"
-// @Success      200       {array}   models.Task
+// @Param        sprintId    path      int     true   "Sprint ID"
+// @Param        status      query     string  false  "Task status"
+// @Param        assigneeId  query     string  false  "Assignee ID, or none for unassigned tasks"
+// @Param        q           query     string  false  "Text in the title"
+// @Param        limit       query     int     false  "Page size (default 50, max 200)"
+// @Param        offset      query     int     false  "Items to skip"
+// @Param        sort        query     string  false  "Sort fields: id, title, status, estimatedHours, spentHours, createdAt, updatedAt; prefix with - for descending"
+// @Success      200       {object}  services.Page[models.Task]
 // @Failure      400       {object}  map[string]string
 // @Failure      401       {object}  map[string]string
 // @Failure      404       {object}  map[string]string
@@ -180,12 +186,20 @@ func (h *SprintHandler) GetSprintTasks(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid sprint ID"})
 	}
 
-	tasks, err := h.Service.GetSprintTasks(uint(sprintID))
+	query, filter, err := parseTaskListQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	page, err := h.Service.ListSprintTasks(uint(sprintID), filter, query)
 	if err != nil {
+		if status := listErrorStatus(err); status != http.StatusInternalServerError {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not retrieve sprint tasks"})
 	}
 
-	return c.JSON(http.StatusOK, tasks)
+	return c.JSON(http.StatusOK, page)
 }
 
 // UpdateSprintStatusRequest defines the structure for updating sprint status.
@@ -229,7 +243,7 @@ func (h *SprintHandler) UpdateSprintStatus(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid status. Must be: planned, active, completed, or cancelled"})
 	}
 
-	if err := h.Service.UpdateSprintStatus(uint(sprintID), req.Status); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).UpdateSprintStatus(uint(sprintID), req.Status); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not update sprint status"})
 	}
 
@@ -8,7 +8,6 @@ import (
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/services"
 	"github.com/buga/API_wrkf/utils"
-	"github.com/buga/API_wrkf/websocket"
 	"github.com/labstack/echo/v4"
 )
 
@@ -34,18 +33,12 @@ type CreateTaskRequest struct {
 
 // TaskHandler handles HTTP requests for tasks.
 type TaskHandler struct {
-	Service     *services.TaskService
-	wsManager   *websocket.WebSocketManager
-	userService *services.UserService
+	Service *services.TaskService
 }
 
 // NewTaskHandler creates a new instance of TaskHandler.
-func NewTaskHandler(service *services.TaskService, wsManager *websocket.WebSocketManager, userService *services.UserService) *TaskHandler {
-	return &TaskHandler{
-		Service:     service,
-		wsManager:   wsManager,
-		userService: userService,
-	}
+func NewTaskHandler(service *services.TaskService) *TaskHandler {
+	return &TaskHandler{Service: service}
 }
 
 // CreateTask godoc
@@ -88,26 +81,27 @@ func (h *TaskHandler) CreateTask(c echo.Context) error {
 		IsDeliverable:  req.IsDeliverable,
 	}
 
-	createdTask, err := h.Service.CreateTask(task, uint(userStoryID), creatorID)
+	createdTask, err := h.Service.WithContext(c.Request().Context()).CreateTask(task, uint(userStoryID), creatorID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Could not create task: %v", err)})
 	}
 
-	// Broadcast WebSocket event
-	if h.wsManager != nil && createdTask.UserStory.ProjectID != 0 {
-		h.wsManager.BroadcastTaskCreated(createdTask.UserStory.ProjectID, createdTask)
-	}
-
 	return c.JSON(http.StatusCreated, createdTask)
 }
 
 // GetTasksByUserStoryID godoc
-// @Summary      Get all Tasks for a User Story
-// @Description  Retrieves a list of all tasks for a specific user story.
+// @Summary      Get the Tasks of a User Story
+// @Description  Retrieves one page of the tasks of a specific user story.
 // @Tags         Tasks
 // @Produce      json
-// @Param        storyId   path      int  true  "User Story ID"
-// @Success      200       {array}   models.Task
+// @Param        storyId     path      int     true   "User Story ID"
+// @Param        status      query     string  false  "Task status"
+// @Param        assigneeId  query     string  false  "Assignee ID, or none for unassigned tasks"
+// @Param        q           query     string  false  "Text in the title"
+// @Param        limit       query     int     false  "Page size (default 50, max 200)"
+// @Param        offset      query     int     false  "Items to skip"
+// @Param        sort        query     string  false  "Sort fields: id, title, status, estimatedHours, spentHours, createdAt, updatedAt; prefix with - for descending"
+// @Success      200       {object}  services.Page[models.Task]
 // @Failure      400       {object}  map[string]string
 // @Failure      401       {object}  map[string]string
 // @Security     ApiKeyAuth
@@ -118,12 +112,20 @@ func (h *TaskHandler) GetTasksByUserStoryID(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user story ID"})
 	}
 
-	tasks, err := h.Service.GetTasksByUserStoryID(uint(userStoryID))
+	query, filter, err := parseTaskListQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	page, err := h.Service.ListTasksByUserStoryID(uint(userStoryID), filter, query)
 	if err != nil {
+		if status := listErrorStatus(err); status != http.StatusInternalServerError {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not retrieve tasks"})
 	}
 
-	return c.JSON(http.StatusOK, tasks)
+	return c.JSON(http.StatusOK, page)
 }
 
 // UpdateTask godoc
@@ -155,7 +157,7 @@ func (h *TaskHandler) UpdateTask(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid input"})
 	}
 
-	updatedTask, err := h.Service.UpdateTask(taskToUpdate)
+	updatedTask, err := h.Service.WithContext(c.Request().Context()).UpdateTask(taskToUpdate)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not update task"})
 	}
@@ -179,32 +181,18 @@ func (h *TaskHandler) DeleteTask(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid task ID"})
 	}
 
-	// Get info before deleting
-	task, err := h.Service.GetTaskByID(uint(taskId))
-	if err != nil {
+	if _, err := h.Service.GetTaskByID(uint(taskId)); err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Task not found"})
 	}
-	projectID := task.UserStory.ProjectID
-
-	deleterID, err := utils.GetUserIDFromContext(c)
-	if err != nil {
+	if _, err := utils.GetUserIDFromContext(c); err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID"})
 	}
-	deleter, err := h.userService.GetUserByID(deleterID)
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
-	}
 
 	// Delete the task
-	if err := h.Service.DeleteTask(uint(taskId)); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).DeleteTask(uint(taskId)); err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Task not found or could not be deleted"})
 	}
 
-	// Broadcast WebSocket event
-	if h.wsManager != nil && projectID != 0 {
-		h.wsManager.BroadcastTaskDeleted(projectID, uint(taskId), deleter)
-	}
-
 	return c.NoContent(http.StatusNoContent)
 }
 
@@ -233,24 +221,15 @@ func (h *TaskHandler) AssignTask(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
-	assignerID, err := utils.GetUserIDFromContext(c)
-	if err != nil {
+	if _, err := utils.GetUserIDFromContext(c); err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID"})
 	}
 
-	assignedTask, err := h.Service.AssignTask(uint(taskId), req.UserID)
+	assignedTask, err := h.Service.WithContext(c.Request().Context()).AssignTask(uint(taskId), req.UserID)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// Broadcast WebSocket event
-	if h.wsManager != nil && assignedTask.UserStory.ProjectID != 0 {
-		assigner, err := h.userService.GetUserByID(assignerID)
-		if err == nil && assignedTask.AssignedTo != nil {
-			h.wsManager.BroadcastTaskAssigned(assignedTask.UserStory.ProjectID, assignedTask.ID, assignedTask.AssignedTo, assigner)
-		}
-	}
-
 	return c.JSON(http.StatusOK, assignedTask)
 }
 
@@ -284,30 +263,17 @@ func (h *TaskHandler) UpdateTaskStatus(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID"})
 	}
-	updater, err := h.userService.GetUserByID(userID)
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
-	}
 
-	// Get the original task to find the old status and project ID
-	originalTask, err := h.Service.GetTaskByID(uint(taskID))
-	if err != nil {
+	if _, err := h.Service.GetTaskByID(uint(taskID)); err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Task not found"})
 	}
-	oldStatus := originalTask.Status
-	projectID := originalTask.UserStory.ProjectID
 
 	// Update the task status, passing the updater's ID to the service layer
-	updatedTask, err := h.Service.UpdateTaskStatus(uint(taskID), req.Status, userID)
+	updatedTask, err := h.Service.WithContext(c.Request().Context()).UpdateTaskStatus(uint(taskID), req.Status, userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	// Broadcast the event
-	if h.wsManager != nil {
-		h.wsManager.BroadcastTaskStatusUpdated(projectID, updatedTask.ID, string(oldStatus), string(updatedTask.Status), updater)
-	}
-
 	return c.JSON(http.StatusOK, updatedTask)
 }
 
@@ -335,7 +301,7 @@ func (h *TaskHandler) AddComment(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Comment content cannot be empty"})
 	}
 
-	comment, err := h.Service.AddCommentToTask(uint(taskID), authorID, body.Content)
+	comment, err := h.Service.WithContext(c.Request().Context()).AddCommentToTask(uint(taskID), authorID, body.Content)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
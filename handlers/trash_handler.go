@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+)
+
+// TrashHandler handles HTTP requests for the trash of deleted projects, user stories and tasks.
+type TrashHandler struct {
+	Service *services.TrashService
+}
+
+// NewTrashHandler creates a new instance of TrashHandler.
+func NewTrashHandler(service *services.TrashService) *TrashHandler {
+	return &TrashHandler{Service: service}
+}
+
+// trashErrorStatus maps trash service errors to HTTP status codes.
+func trashErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "forbidden"):
+		return http.StatusForbidden
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "invalid restore"):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func parseTrashID(c echo.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	return uint(id), err
+}
+
+// GetTrashedProjects lists the deleted projects the user may restore.
+func (h *TrashHandler) GetTrashedProjects(c echo.Context) error {
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	projects, err := h.Service.GetTrashedProjects(uint(userID), userRole)
+	if err != nil {
+		return c.JSON(trashErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, projects)
+}
+
+// GetProjectTrash lists the deleted user stories and tasks of a project.
+func (h *TrashHandler) GetProjectTrash(c echo.Context) error {
+	projectID, err := parseTrashID(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	trash, err := h.Service.GetProjectTrash(projectID, uint(userID), userRole)
+	if err != nil {
+		return c.JSON(trashErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, trash)
+}
+
+// RestoreProject takes a project and the items deleted with it out of the trash.
+func (h *TrashHandler) RestoreProject(c echo.Context) error {
+	projectID, err := parseTrashID(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	project, err := h.Service.WithContext(c.Request().Context()).RestoreProject(projectID, uint(userID), userRole)
+	if err != nil {
+		return c.JSON(trashErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, project)
+}
+
+// RestoreUserStory takes a user story and the tasks deleted with it out of the trash.
+func (h *TrashHandler) RestoreUserStory(c echo.Context) error {
+	storyID, err := parseTrashID(c, "storyId")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid user story ID"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	story, err := h.Service.WithContext(c.Request().Context()).RestoreUserStory(storyID, uint(userID), userRole)
+	if err != nil {
+		return c.JSON(trashErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, story)
+}
+
+// RestoreTask takes a task out of the trash.
+func (h *TrashHandler) RestoreTask(c echo.Context) error {
+	taskID, err := parseTrashID(c, "taskId")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid task ID"})
+	}
+	userID, _ := c.Get("userID").(float64)
+	userRole, _ := c.Get("userRole").(string)
+
+	task, err := h.Service.WithContext(c.Request().Context()).RestoreTask(taskID, uint(userID), userRole)
+	if err != nil {
+		return c.JSON(trashErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, task)
+}
+
+// PurgeProject permanently deletes a project in the trash (admin only).
+func (h *TrashHandler) PurgeProject(c echo.Context) error {
+	projectID, err := parseTrashID(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid project ID"})
+	}
+	userRole, _ := c.Get("userRole").(string)
+
+	if err := h.Service.WithContext(c.Request().Context()).PurgeProject(projectID, userRole); err != nil {
+		return c.JSON(trashErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PurgeUserStory permanently deletes a user story in the trash (admin only).
+func (h *TrashHandler) PurgeUserStory(c echo.Context) error {
+	storyID, err := parseTrashID(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid user story ID"})
+	}
+	userRole, _ := c.Get("userRole").(string)
+
+	if err := h.Service.WithContext(c.Request().Context()).PurgeUserStory(storyID, userRole); err != nil {
+		return c.JSON(trashErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PurgeTask permanently deletes a task in the trash (admin only).
+func (h *TrashHandler) PurgeTask(c echo.Context) error {
+	taskID, err := parseTrashID(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid task ID"})
+	}
+	userRole, _ := c.Get("userRole").(string)
+
+	if err := h.Service.WithContext(c.Request().Context()).PurgeTask(taskID, userRole); err != nil {
+		return c.JSON(trashErrorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PurgeExpired permanently deletes everything that has been in the trash longer than
+// olderThanDays (defaults to the configured retention period).
+func (h *TrashHandler) PurgeExpired(c echo.Context) error {
+	userRole, _ := c.Get("userRole").(string)
+	if userRole != string(models.RoleAdmin) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": "forbidden: only admins can purge"})
+	}
+
+	olderThan := h.Service.Retention
+	if raw := c.QueryParam("olderThanDays"); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days < 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid olderThanDays"})
+		}
+		olderThan = time.Duration(days) * 24 * time.Hour
+	}
+
+	result, err := h.Service.WithContext(c.Request().Context()).PurgeExpired(olderThan)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
 
 	"github.com/labstack/echo/v4"
 )
@@ -97,21 +98,36 @@ func (h *UserHandler) GetUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, user)
 }
 
-// GetAllUsers handles retrieving all users.
+// GetAllUsers handles retrieving the users.
 // @Summary Get All Users
-// @Description Retrieves a list of all users. This is an admin-only endpoint.
+// @Description Retrieves one page of users. This is an admin-only endpoint.
 // @Tags Users
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.User
+// @Param role query string false "Platform role"
+// @Param q query string false "Text in the name or email"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Items to skip"
+// @Param sort query string false "Sort fields: id, name, email, role, createdAt; prefix with - for descending"
+// @Success 200 {object} services.Page[models.User]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/admin/users [get]
 func (h *UserHandler) GetAllUsers(c echo.Context) error {
-	users, err := h.Service.GetAllUsers()
+	query, err := parseListQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	filter := storage.UserFilter{Role: c.QueryParam("role"), Search: c.QueryParam("q")}
+
+	page, err := h.Service.ListUsers(filter, query)
 	if err != nil {
+		if status := listErrorStatus(err); status != http.StatusInternalServerError {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not retrieve users"})
 	}
-	return c.JSON(http.StatusOK, users)
+	return c.JSON(http.StatusOK, page)
 }
 
 // UpdateUser handles updating a user's information.
@@ -8,6 +8,7 @@ import (
 
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
 
 	"github.com/labstack/echo/v4"
 )
@@ -57,7 +58,7 @@ func (h *UserStoryHandler) CreateUserStory(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or missing user ID from token"})
 	}
 
-	if err := h.Service.CreateUserStory(userStory, uint(projectID), uint(creatorID)); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).CreateUserStory(userStory, uint(projectID), uint(creatorID)); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Could not create user story: %v", err)})
 	}
 
@@ -65,12 +66,20 @@ func (h *UserStoryHandler) CreateUserStory(c echo.Context) error {
 }
 
 // GetUserStoriesByProjectID godoc
-// @Summary      Get all User Stories for a project
-// @Description  Retrieves a list of all user stories (the Product Backlog) for a specific project.
+// @Summary      Get the User Stories of a project
+// @Description  Retrieves one page of the user stories (the Product Backlog) of a specific project.
 // @Tags         User Stories
 // @Produce      json
-// @Param        id   path      int  true  "Project ID"
-// @Success      200  {array}   models.UserStory
+// @Param        id          path      int     true   "Project ID"
+// @Param        status      query     string  false  "Status"
+// @Param        priority    query     string  false  "Priority"
+// @Param        sprintId    query     string  false  "Sprint ID, or none for the backlog"
+// @Param        assigneeId  query     string  false  "Assignee ID, or none for unassigned stories"
+// @Param        q           query     string  false  "Text in the title"
+// @Param        limit       query     int     false  "Page size (default 50, max 200)"
+// @Param        offset      query     int     false  "Items to skip"
+// @Param        sort        query     string  false  "Sort fields: id, title, status, priority, points, createdAt, updatedAt; prefix with - for descending"
+// @Success      200  {object}  services.Page[models.UserStory]
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
@@ -82,12 +91,27 @@ func (h *UserStoryHandler) GetUserStoriesByProjectID(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
 	}
 
-	userStories, err := h.Service.GetUserStoriesByProjectID(uint(projectID))
+	query, err := parseListQuery(c)
 	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	filter := storage.UserStoryFilter{Status: c.QueryParam("status"), Priority: c.QueryParam("priority"), Search: c.QueryParam("q")}
+	if filter.SprintID, filter.Backlog, err = parseIDFilter(c, "sprintId"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if filter.AssigneeID, filter.Unassigned, err = parseIDFilter(c, "assigneeId"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	page, err := h.Service.ListUserStoriesByProjectID(uint(projectID), filter, query)
+	if err != nil {
+		if status := listErrorStatus(err); status != http.StatusInternalServerError {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not retrieve user stories"})
 	}
 
-	return c.JSON(http.StatusOK, userStories)
+	return c.JSON(http.StatusOK, page)
 }
 
 // GetUserStoryByID godoc
@@ -144,7 +168,7 @@ func (h *UserStoryHandler) UpdateUserStory(c echo.Context) error {
 	userID, _ := c.Get("userID").(float64)
 	platformRole, _ := c.Get("userRole").(string)
 
-	updatedStory, err := h.Service.UpdateUserStory(uint(storyID), uint(userID), platformRole, updates)
+	updatedStory, err := h.Service.WithContext(c.Request().Context()).UpdateUserStory(uint(storyID), uint(userID), platformRole, updates)
 	if err != nil {
 		if strings.Contains(err.Error(), "forbidden") {
 			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
@@ -177,7 +201,7 @@ func (h *UserStoryHandler) DeleteUserStory(c echo.Context) error {
 	userID, _ := c.Get("userID").(float64)
 	platformRole, _ := c.Get("userRole").(string)
 
-	if err := h.Service.DeleteUserStory(uint(storyID), uint(userID), platformRole); err != nil {
+	if err := h.Service.WithContext(c.Request().Context()).DeleteUserStory(uint(storyID), uint(userID), platformRole); err != nil {
 		if strings.Contains(err.Error(), "forbidden") {
 			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
 		}
@@ -218,7 +242,7 @@ func (h *UserStoryHandler) AssignUserStoryToSprint(c echo.Context) error {
 	userID, _ := c.Get("userID").(float64)
 	platformRole, _ := c.Get("userRole").(string)
 
-	updatedStory, err := h.Service.AssignUserStoryToSprint(uint(sprintID), req.UserStoryID, uint(userID), platformRole)
+	updatedStory, err := h.Service.WithContext(c.Request().Context()).AssignUserStoryToSprint(uint(sprintID), req.UserStoryID, uint(userID), platformRole)
 	if err != nil {
 		if strings.Contains(err.Error(), "forbidden") {
 			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+)
+
+// WorkHandler handles HTTP requests for the caller's work across projects.
+type WorkHandler struct {
+	Service *services.WorkService
+}
+
+// NewWorkHandler creates a new instance of WorkHandler.
+func NewWorkHandler(service *services.WorkService) *WorkHandler {
+	return &WorkHandler{Service: service}
+}
+
+// GetMyWork returns the caller's assigned tasks grouped by project and status, their
+// user stories, upcoming events (?days=, 14 by default), unread notification count
+// and overdue items.
+func (h *WorkHandler) GetMyWork(c echo.Context) error {
+	userID, _ := c.Get("userID").(float64)
+
+	days := 0
+	if raw := c.QueryParam("days"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid days"})
+		}
+		days = value
+	}
+
+	work, err := h.Service.WithContext(c.Request().Context()).GetMyWork(uint(userID), days)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid work query") {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Could not retrieve your work"})
+	}
+	return c.JSON(http.StatusOK, work)
+}
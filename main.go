@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/buga/API_wrkf/config"
 	_ "github.com/buga/API_wrkf/docs"
@@ -100,6 +102,16 @@ func main() {
 	reportingRepo := storage.NewReportingRepository(db)
 	evalRepo := storage.NewEvaluationRepository(db)
 	eventRepo := storage.NewEventRepository(db)
+	auditRepo := storage.NewAuditRepository(db)
+	trashRepo := storage.NewTrashRepository(db)
+	projectTemplateRepo := storage.NewProjectTemplateRepository(db)
+	projectArchiveRepo := storage.NewProjectArchiveRepository(db)
+	bulkRepo := storage.NewBulkRepository(db)
+	workRepo := storage.NewWorkRepository(db)
+	healthRepo := storage.NewHealthRepository(db)
+	contributionRepo := storage.NewContributionRepository(db)
+	riskRepo := storage.NewRiskRepository(db)
+	exportRepo := storage.NewExportRepository(db)
 
 	// Services
 	userService := services.NewUserService(userRepo, cfg.JWTSecret)
@@ -108,14 +120,51 @@ func main() {
 	sprintService := services.NewSprintService(sprintRepo)
 	taskService := services.NewTaskService(taskRepo, projectService, notificationService)
 	userStoryService := services.NewUserStoryService(userStoryRepo, projectService, sprintService)
-	rubricService := services.NewRubricService(rubricRepo)
+	rubricService := services.NewRubricService(rubricRepo, projectRepo)
 	reportingService := services.NewReportingService(reportingRepo, userStoryRepo, sprintRepo)
-	evaluationService := services.NewEvaluationService(evalRepo, taskRepo, rubricRepo, projectService)
+	evaluationService := services.NewEvaluationService(evalRepo, taskRepo, rubricRepo, sprintRepo, projectService, notificationService)
 	eventService := services.NewEventService(eventRepo, projectService)
-	exportService := services.NewExportService(projectRepo, userStoryRepo, taskRepo) // <-- NEW
+	exportService := services.NewExportService(projectRepo, exportRepo) // <-- NEW
+	gradebookService := services.NewGradebookService(evalRepo, taskRepo, projectService)
+	auditService := services.NewAuditService(auditRepo)
+	trashService := services.NewTrashService(trashRepo, projectService, time.Duration(cfg.TrashRetentionDays)*24*time.Hour)
+	projectTemplateService := services.NewProjectTemplateService(projectTemplateRepo, projectService)
+	projectArchiveService := services.NewProjectArchiveService(projectArchiveRepo, userRepo, projectService)
+	bulkService := services.NewBulkService(bulkRepo, projectService, sprintService, notificationService)
+	workService := services.NewWorkService(workRepo, eventRepo, notificationService)
+	healthService := services.NewHealthService(healthRepo, projectService)
+	contributionService := services.NewContributionService(contributionRepo, projectService, sprintService)
+	riskService := services.NewRiskService(riskRepo, projectService, notificationService, time.Duration(cfg.RiskStaleDays)*24*time.Hour)
+
+	// Domain events, published by the services once their changes are saved
+	eventBus := services.NewEventBus()
+	projectService.Events = eventBus
+	sprintService.Events = eventBus
+	taskService.Events = eventBus
+	userStoryService.Events = eventBus
+	evaluationService.Events = eventBus
+	eventService.Events = eventBus
+	bulkService.Events = eventBus
+	notificationService.Events = eventBus
+
+	// Background jobs run on one instance at a time
+	jobLock := storage.NewJobLock(db)
+	trashService.Jobs = jobLock
+	riskService.Jobs = jobLock
 
 	// WebSocket Manager (initialized before handlers that need it)
 	wsManager := websocket.NewWebSocketManager()
+	wsManager.SetAuthorizer(websocket.NewServiceAuthorizer(projectService, taskService))
+	eventBus.Subscribe(websocket.NewEventBroadcaster(wsManager, userService).Handle)
+	switch cfg.WSBackplane {
+	case "memory":
+	case "postgres":
+		if err := wsManager.SetBackplane(websocket.NewPostgresBackplane(db)); err != nil {
+			log.Fatalf("could not start the WebSocket backplane: %v", err)
+		}
+	default:
+		log.Fatalf("unknown WS_BACKPLANE '%s': use 'memory' or 'postgres'", cfg.WSBackplane)
+	}
 	go wsManager.Run()
 
 	// Handlers
@@ -129,12 +178,28 @@ func main() {
 	evaluationHandler := handlers.NewEvaluationHandler(evaluationService)
 	eventHandler := handlers.NewEventHandler(eventService)
 	exportHandler := handlers.NewExportHandler(exportService) // <-- NEW
-	taskHandler := handlers.NewTaskHandler(taskService, wsManager, userService)
+	gradebookHandler := handlers.NewGradebookHandler(gradebookService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	trashHandler := handlers.NewTrashHandler(trashService)
+	projectTemplateHandler := handlers.NewProjectTemplateHandler(projectTemplateService)
+	projectArchiveHandler := handlers.NewProjectArchiveHandler(projectArchiveService)
+	bulkHandler := handlers.NewBulkHandler(bulkService)
+	workHandler := handlers.NewWorkHandler(workService)
+	healthHandler := handlers.NewHealthHandler(healthService)
+	contributionHandler := handlers.NewContributionHandler(contributionService)
+	riskHandler := handlers.NewRiskHandler(riskService)
+	taskHandler := handlers.NewTaskHandler(taskService)
 	webSocketHandler := websocket.NewWebSocketHandler(wsManager, cfg.JWTSecret, userService, projectService)
 
 	// Final setup
 	createAdminUserIfNeeded(userService, cfg.Admin)
 
+	// Purge trash older than the retention period once a day
+	go trashService.RunRetentionPurge(context.Background(), 24*time.Hour)
+
+	// Flag stale and at-risk work every hour
+	go riskService.RunDetection(context.Background(), time.Hour)
+
 	// --- Inicializar Echo y configurar routes ---
 	e := echo.New()
 	// Configurar CORS
@@ -165,7 +230,7 @@ func main() {
 	// WebSocket route
 	e.GET("/ws", webSocketHandler.HandleConnection)
 
-	routes.SetupRoutes(e, userHandler, projectHandler, sprintHandler, userStoryHandler, taskHandler, notificationHandler, rubricHandler, reportingHandler, evaluationHandler, eventHandler, exportHandler, cfg.JWTSecret)
+	routes.SetupRoutes(e, userHandler, projectHandler, sprintHandler, userStoryHandler, taskHandler, notificationHandler, rubricHandler, reportingHandler, evaluationHandler, eventHandler, exportHandler, gradebookHandler, auditHandler, trashHandler, projectTemplateHandler, projectArchiveHandler, bulkHandler, workHandler, healthHandler, contributionHandler, riskHandler, cfg.JWTSecret)
 
 	// --- Iniciar Servidor ---
 	fmt.Println("Iniciando el servidor en el puerto 8080...")
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ProjectWriteChecker indica si un recurso pertenece a un proyecto archivado.
+// ProjectService lo implementa.
+type ProjectWriteChecker interface {
+	EnsureWritable(kind string, id uint) error
+}
+
+// resourceKinds relaciona el primer segmento de la ruta con el tipo de recurso
+// cuyo ID aparece como primer parámetro de la ruta.
+var resourceKinds = map[string]string{
+	"projects":          "project",
+	"userstories":       "user_story",
+	"tasks":             "task",
+	"sprints":           "sprint",
+	"events":            "event",
+	"rubrics":           "rubric",
+	"evaluations":       "evaluation",
+	"evaluation-rounds": "evaluation_round",
+}
+
+// readOnlyRoutes son escrituras que sólo leen el proyecto de origen (copias y plantillas),
+// así que se permiten aunque esté archivado.
+var readOnlyRoutes = map[string]bool{
+	"/api/projects/:id/clone":            true,
+	"/api/projects/:id/save-as-template": true,
+	"/api/rubrics/:id/save-as-template":  true,
+}
+
+// ArchivedProjectMiddleware responde 409 a cualquier escritura (POST, PUT, PATCH, DELETE)
+// sobre un proyecto archivado o sobre sus historias, tareas, sprints, eventos,
+// rúbricas y evaluaciones. Las lecturas pasan siempre.
+func ArchivedProjectMiddleware(checker ProjectWriteChecker) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+			if readOnlyRoutes[c.Path()] {
+				return next(c)
+			}
+
+			kind, id, ok := resourceFromPath(c)
+			if !ok {
+				return next(c)
+			}
+			if err := checker.EnsureWritable(kind, id); errors.Is(err, models.ErrProjectArchived) {
+				return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+			}
+			return next(c)
+		}
+	}
+}
+
+// resourceFromPath obtiene el tipo y el ID del recurso a partir de la ruta registrada,
+// p. ej. "/api/tasks/:taskId/status" -> ("task", valor de :taskId).
+func resourceFromPath(c echo.Context) (string, uint, bool) {
+	path := strings.TrimPrefix(c.Path(), "/api/")
+	path = strings.TrimPrefix(path, "admin/")
+	segments := strings.Split(path, "/")
+	if len(segments) < 2 || !strings.HasPrefix(segments[1], ":") {
+		return "", 0, false
+	}
+
+	kind, ok := resourceKinds[segments[0]]
+	if !ok {
+		return "", 0, false
+	}
+	id, err := strconv.ParseUint(c.Param(strings.TrimPrefix(segments[1], ":")), 10, 32)
+	if err != nil {
+		return "", 0, false
+	}
+	return kind, uint(id), true
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/buga/API_wrkf/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AuditContextMiddleware guarda en el contexto de la solicitud quién la realiza
+// (usuario, IP e ID de solicitud) para que el registro de auditoría lo anote.
+// Reutiliza la cabecera X-Request-ID si el cliente la envía y la devuelve en la respuesta.
+// Debe utilizarse DESPUÉS de JWTAuthMiddleware.
+func AuditContextMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		requestID := c.Request().Header.Get(echo.HeaderXRequestID)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+
+		userID, _ := GetUserIDFromContext(c)
+		ctx := storage.WithAuditActor(c.Request().Context(), storage.AuditActor{
+			UserID:    userID,
+			IP:        c.RealIP(),
+			RequestID: requestID,
+		})
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		return next(c)
+	}
+}
+
+// newRequestID genera un identificador aleatorio de 16 bytes en hexadecimal.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditAction is the kind of change recorded in the audit log.
+type AuditAction string
+
+const (
+	AuditActionCreate AuditAction = "create"
+	AuditActionUpdate AuditAction = "update"
+	AuditActionDelete AuditAction = "delete"
+)
+
+// ErrAuditLogAppendOnly is returned when something tries to modify or remove an audit entry.
+var ErrAuditLogAppendOnly = errors.New("audit log entries are append-only")
+
+// AuditLog is an append-only record of a create, update or delete on an audited entity.
+// Before and After hold the entity's columns as JSON; Before is null on create and After on delete.
+type AuditLog struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	EntityType string          `gorm:"type:varchar(40);not null;index:idx_audit_entity" json:"entityType"`
+	EntityID   uint            `gorm:"not null;index:idx_audit_entity" json:"entityId"`
+	Action     AuditAction     `gorm:"type:varchar(10);not null" json:"action"`
+	ActorID    *uint           `gorm:"index" json:"actorId"` // Nil for changes made outside of a request
+	Actor      *User           `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	IPAddress  string          `gorm:"type:varchar(45)" json:"ipAddress"`
+	RequestID  string          `gorm:"type:varchar(64);index" json:"requestId"`
+	Before     json.RawMessage `gorm:"type:jsonb" json:"before"`
+	After      json.RawMessage `gorm:"type:jsonb" json:"after"`
+	CreatedAt  time.Time       `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+// BeforeUpdate keeps audit entries immutable.
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+// BeforeDelete keeps audit entries from being removed.
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
@@ -2,19 +2,48 @@ package models
 
 import "time"
 
-// Evaluation represents a formal assessment of a submitted task (deliverable)
-// against a specific rubric.
+// EvaluationStatus defines the lifecycle states of an evaluation.
+type EvaluationStatus string
+
+const (
+	EvaluationStatusDraft     EvaluationStatus = "draft"
+	EvaluationStatusSubmitted EvaluationStatus = "submitted"
+	EvaluationStatusPublished EvaluationStatus = "published"
+)
+
+// EvaluationType distinguishes what an evaluation assesses.
+type EvaluationType string
+
+const (
+	// EvaluationTypeTask assesses a task (deliverable); it is the default.
+	EvaluationTypeTask EvaluationType = "task"
+	// EvaluationTypePeer assesses a team mate within an evaluation round.
+	EvaluationTypePeer EvaluationType = "peer"
+	// EvaluationTypeSelf is a member's self-assessment within an evaluation round.
+	EvaluationTypeSelf EvaluationType = "self"
+)
+
+// Evaluation represents a formal assessment against a specific rubric. Task
+// evaluations grade a deliverable; peer and self evaluations grade a project
+// member (the evaluatee) within an evaluation round.
 type Evaluation struct {
-	ID                   uint   `gorm:"primaryKey"`
-	TaskID               uint   `gorm:"not null;uniqueIndex:idx_task_evaluator"`
-	Task                 Task   `gorm:"foreignKey:TaskID"`
-	EvaluatorID          uint   `gorm:"not null;uniqueIndex:idx_task_evaluator"`
-	Evaluator            User   `gorm:"foreignKey:EvaluatorID"`
-	RubricID             uint   `gorm:"not null"`
-	Rubric               Rubric `gorm:"foreignKey:RubricID"`
-	OverallFeedback      string `gorm:"type:text"`
+	ID                   uint             `gorm:"primaryKey"`
+	Type                 EvaluationType   `gorm:"type:varchar(10);not null;default:'task'"`
+	TaskID               *uint            `gorm:"uniqueIndex:idx_task_evaluator"` // Set for task evaluations only
+	Task                 *Task            `gorm:"foreignKey:TaskID"`
+	RoundID              *uint            `gorm:"uniqueIndex:idx_round_evaluator_evaluatee"` // Set for peer and self evaluations only
+	Round                *EvaluationRound `gorm:"foreignKey:RoundID" json:",omitempty"`
+	EvaluateeID          *uint            `gorm:"uniqueIndex:idx_round_evaluator_evaluatee"`
+	Evaluatee            *User            `gorm:"foreignKey:EvaluateeID" json:",omitempty"`
+	EvaluatorID          uint             `gorm:"not null;uniqueIndex:idx_task_evaluator;uniqueIndex:idx_round_evaluator_evaluatee"`
+	Evaluator            User             `gorm:"foreignKey:EvaluatorID"`
+	RubricID             uint             `gorm:"not null"`
+	Rubric               Rubric           `gorm:"foreignKey:RubricID"`
+	OverallFeedback      string           `gorm:"type:text"`
 	TotalScore           float64
-	Status               string                `gorm:"type:varchar(20);not null;default:'draft'"` // e.g., draft, published
+	Status               EvaluationStatus `gorm:"type:varchar(20);not null;default:'draft'"` // draft -> submitted -> published
+	SubmittedAt          *time.Time
+	PublishedAt          *time.Time
 	CreatedAt            time.Time             `gorm:"autoCreateTime"`
 	UpdatedAt            time.Time             `gorm:"autoUpdateTime"`
 	CriterionEvaluations []CriterionEvaluation `gorm:"foreignKey:EvaluationID;constraint:OnDelete:CASCADE;"`
@@ -32,3 +61,42 @@ type CriterionEvaluation struct {
 	CreatedAt    time.Time       `gorm:"autoCreateTime"`
 	UpdatedAt    time.Time       `gorm:"autoUpdateTime"`
 }
+
+// EvaluationRoundStatus defines whether a round still accepts evaluations.
+type EvaluationRoundStatus string
+
+const (
+	EvaluationRoundStatusOpen   EvaluationRoundStatus = "open"
+	EvaluationRoundStatusClosed EvaluationRoundStatus = "closed"
+)
+
+// EvaluationRound groups the peer and self evaluations of a sprint. Every
+// participating member is assigned a set of peers to review using the round's rubric.
+type EvaluationRound struct {
+	ID             uint                  `gorm:"primaryKey"`
+	Name           string                `gorm:"not null"`
+	SprintID       uint                  `gorm:"not null;index"`
+	Sprint         Sprint                `gorm:"foreignKey:SprintID" json:"-"`
+	ProjectID      uint                  `gorm:"not null;index"`
+	RubricID       uint                  `gorm:"not null"`
+	Rubric         Rubric                `gorm:"foreignKey:RubricID" json:"-"`
+	Anonymous      bool                  `gorm:"not null;default:false"` // Hides reviewer identities from reviewees
+	PeersPerMember int                   `gorm:"not null"`
+	Status         EvaluationRoundStatus `gorm:"type:varchar(10);not null;default:'open'"`
+	DueDate        *time.Time
+	CreatedByID    uint                   `gorm:"not null"`
+	CreatedAt      time.Time              `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time              `gorm:"autoUpdateTime"`
+	Assignments    []PeerReviewAssignment `gorm:"foreignKey:RoundID;constraint:OnDelete:CASCADE;"`
+}
+
+// PeerReviewAssignment records that a reviewer must evaluate a reviewee within a round.
+type PeerReviewAssignment struct {
+	ID         uint      `gorm:"primaryKey"`
+	RoundID    uint      `gorm:"not null;uniqueIndex:idx_round_reviewer_reviewee"`
+	ReviewerID uint      `gorm:"not null;uniqueIndex:idx_round_reviewer_reviewee"`
+	Reviewer   User      `gorm:"foreignKey:ReviewerID"`
+	RevieweeID uint      `gorm:"not null;uniqueIndex:idx_round_reviewer_reviewee"`
+	Reviewee   User      `gorm:"foreignKey:RevieweeID"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
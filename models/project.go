@@ -1,19 +1,74 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectStatus defines the lifecycle stages of a project.
+type ProjectStatus string
+
+const (
+	ProjectStatusPlanning ProjectStatus = "planning"
+	ProjectStatusActive   ProjectStatus = "active"
+	ProjectStatusOnHold   ProjectStatus = "on_hold"
+	ProjectStatusArchived ProjectStatus = "archived" // Read-only until an admin unarchives it
+)
+
+// ErrProjectArchived is returned for any change to an archived project or its contents.
+var ErrProjectArchived = errors.New("project is archived and read-only")
+
+// projectStatusTransitions lists the statuses each status can move to. Leaving
+// "archived" is not a regular transition: only an admin can unarchive a project.
+var projectStatusTransitions = map[ProjectStatus][]ProjectStatus{
+	ProjectStatusPlanning: {ProjectStatusActive, ProjectStatusOnHold, ProjectStatusArchived},
+	ProjectStatusActive:   {ProjectStatusOnHold, ProjectStatusArchived},
+	ProjectStatusOnHold:   {ProjectStatusActive, ProjectStatusArchived},
+}
+
+// IsValid checks if the status is one of the known project statuses.
+func (s ProjectStatus) IsValid() bool {
+	switch s {
+	case ProjectStatusPlanning, ProjectStatusActive, ProjectStatusOnHold, ProjectStatusArchived:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanTransitionTo reports whether a project may move from s to next.
+func (s ProjectStatus) CanTransitionTo(next ProjectStatus) bool {
+	for _, allowed := range projectStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
 
 type Project struct {
 	ID          uint   `gorm:"primaryKey"`
 	Name        string `gorm:"not null"`
 	Description string
-	Status      string `gorm:"not null;default:'planning'"`
-	StartDate   *time.Time
-	EndDate     *time.Time
-	CreatedByID uint      `gorm:"not null"`
-	CreatedBy   User      `gorm:"foreignKey:CreatedByID"`
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
-	Members     []ProjectMember
+	Status      string `gorm:"not null;default:'planning'"` // See ProjectStatus
+	ArchivedAt  *time.Time
+	// EvaluationPolicy controls which project roles may evaluate deliverables.
+	EvaluationPolicy EvaluationPolicy `gorm:"type:varchar(40);not null;default:'instructors_only'"`
+	StartDate        *time.Time
+	EndDate          *time.Time
+	CreatedByID      uint           `gorm:"not null"`
+	CreatedBy        User           `gorm:"foreignKey:CreatedByID"`
+	CreatedAt        time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt        time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt        gorm.DeletedAt `gorm:"index"` // Set while the project is in the trash
+	Members          []ProjectMember
+}
+
+// IsArchived reports whether the project is archived and therefore read-only.
+func (p *Project) IsArchived() bool {
+	return ProjectStatus(p.Status) == ProjectStatusArchived
 }
 
 type ProjectMember struct {
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// ProjectTemplate is a reusable project structure, typically a course project that is
+// run again every term. Its content stores dates as offsets from the project start,
+// so a new project can be created from it on any start date.
+type ProjectTemplate struct {
+	ID              uint             `json:"id" gorm:"primaryKey"`
+	Name            string           `json:"name" gorm:"not null"`
+	Description     string           `json:"description"`
+	SourceProjectID *uint            `json:"sourceProjectId" gorm:"index"` // Project the template was saved from
+	CreatedByID     uint             `json:"createdById" gorm:"not null"`
+	CreatedBy       User             `json:"createdBy" gorm:"foreignKey:CreatedByID"`
+	Content         ProjectBlueprint `json:"content" gorm:"type:jsonb;serializer:json"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	UpdatedAt       time.Time        `json:"updatedAt"`
+}
+
+// ProjectBlueprint describes the structure of a project independently of its IDs and
+// dates. All offsets are in minutes from the project start.
+type ProjectBlueprint struct {
+	DurationMinutes  *int64               `json:"durationMinutes,omitempty"` // From StartDate to EndDate, when both are set
+	EvaluationPolicy EvaluationPolicy     `json:"evaluationPolicy"`
+	Sprints          []SprintBlueprint    `json:"sprints"`
+	UserStories      []UserStoryBlueprint `json:"userStories"`
+	Rubrics          []RubricBlueprint    `json:"rubrics"`
+	Events           []EventBlueprint     `json:"events"`
+}
+
+// SprintBlueprint is a sprint of a project blueprint. Stories refer to it by its position in Sprints.
+type SprintBlueprint struct {
+	Name               string `json:"name"`
+	Goal               string `json:"goal"`
+	StartOffsetMinutes *int64 `json:"startOffsetMinutes,omitempty"`
+	EndOffsetMinutes   *int64 `json:"endOffsetMinutes,omitempty"`
+}
+
+// UserStoryBlueprint is a story skeleton with its tasks. SprintIndex points into the
+// blueprint's Sprints when the story was planned in a sprint.
+type UserStoryBlueprint struct {
+	Title              string          `json:"title"`
+	Description        string          `json:"description"`
+	AcceptanceCriteria string          `json:"acceptanceCriteria"`
+	Priority           string          `json:"priority"`
+	Points             *int            `json:"points,omitempty"`
+	SprintIndex        *int            `json:"sprintIndex,omitempty"`
+	Tasks              []TaskBlueprint `json:"tasks"`
+}
+
+// TaskBlueprint is a task skeleton of a user story.
+type TaskBlueprint struct {
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	EstimatedHours *float32 `json:"estimatedHours,omitempty"`
+	IsDeliverable  bool     `json:"isDeliverable"`
+}
+
+// RubricBlueprint is a project rubric with its criteria and levels.
+type RubricBlueprint struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Category    string                     `json:"category"`
+	Status      RubricStatus               `json:"status"`
+	Criteria    []RubricCriterionBlueprint `json:"criteria"`
+}
+
+// RubricCriterionBlueprint is a criterion of a rubric blueprint.
+type RubricCriterionBlueprint struct {
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	MaxPoints   float64                `json:"maxPoints"`
+	Levels      []RubricLevelBlueprint `json:"levels"`
+}
+
+// RubricLevelBlueprint is a performance level of a rubric criterion blueprint.
+type RubricLevelBlueprint struct {
+	Score       float64 `json:"score"`
+	Description string  `json:"description"`
+}
+
+// EventBlueprint is a calendar event of a project blueprint.
+type EventBlueprint struct {
+	Title              string `json:"title"`
+	Description        string `json:"description"`
+	Type               string `json:"type"`
+	StartOffsetMinutes int64  `json:"startOffsetMinutes"`
+	EndOffsetMinutes   int64  `json:"endOffsetMinutes"`
+}
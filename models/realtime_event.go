@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// RealtimeEvent is a WebSocket event on its way from the API instance that raised it to
+// every instance, when they share the Postgres backplane. Only the latest events of each
+// project are kept.
+type RealtimeEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	ProjectID uint      `gorm:"not null;uniqueIndex:idx_realtime_event_seq"`
+	Seq       uint64    `gorm:"not null;uniqueIndex:idx_realtime_event_seq"` // Position in the project's sequence
+	Channels  string    `gorm:"not null"`                                    // Comma-separated channels, e.g. "project:12,task:31"
+	Message   string    `gorm:"type:text;not null"`                          // The message as sent to clients
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// RiskKind is the kind of problem a risk finding reports.
+type RiskKind string
+
+const (
+	// RiskStaleTask is a task in progress or in review whose status has not changed for a while.
+	RiskStaleTask RiskKind = "stale_task"
+	// RiskStoryWithoutTasks is a user story in an active sprint that has no tasks.
+	RiskStoryWithoutTasks RiskKind = "story_without_tasks"
+	// RiskDepartedAssignee is an open task assigned to someone who is no longer a project member.
+	RiskDepartedAssignee RiskKind = "departed_assignee"
+	// RiskSprintAtRisk is an active sprint ending soon with much of its scope still open.
+	RiskSprintAtRisk RiskKind = "sprint_at_risk"
+)
+
+// RiskFinding is a problem found by the at-risk work detection job. A finding stays open
+// while the job keeps finding it and is resolved once it no longer does; a new finding is
+// opened if the problem comes back, so a problem has at most one open finding.
+type RiskFinding struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ProjectID      uint       `gorm:"not null;index" json:"projectId"`
+	Kind           RiskKind   `gorm:"type:varchar(30);not null;uniqueIndex:idx_risk_open_entity,where:resolved_at IS NULL" json:"kind"`
+	EntityType     string     `gorm:"type:varchar(20);not null;uniqueIndex:idx_risk_open_entity,where:resolved_at IS NULL" json:"entityType"` // "task", "user_story" or "sprint"
+	EntityID       uint       `gorm:"not null;uniqueIndex:idx_risk_open_entity,where:resolved_at IS NULL" json:"entityId"`
+	Message        string     `gorm:"not null" json:"message"`
+	NotifiedUserID *uint      `json:"notifiedUserId"` // Assignee or scrum master told about it; nil when nobody could be
+	DetectedAt     time.Time  `gorm:"not null" json:"detectedAt"`
+	LastSeenAt     time.Time  `gorm:"not null" json:"lastSeenAt"`
+	ResolvedAt     *time.Time `gorm:"index" json:"resolvedAt"`
+}
@@ -19,14 +19,52 @@ const (
 	RoleScrumMaster   ProjectRole = "scrum_master"
 	RoleProductOwner  ProjectRole = "product_owner"
 	RoleTeamDeveloper ProjectRole = "team_developer"
+	// RoleInstructor is held by teachers who supervise and grade a project.
+	RoleInstructor ProjectRole = "instructor"
 )
 
 // IsValid checks if the project role is a defined valid role.
 func (r ProjectRole) IsValid() bool {
 	switch r {
-	case RoleScrumMaster, RoleProductOwner, RoleTeamDeveloper:
+	case RoleScrumMaster, RoleProductOwner, RoleTeamDeveloper, RoleInstructor:
 		return true
 	default:
 		return false
 	}
 }
+
+// --- Evaluation Policies ---
+
+// EvaluationPolicy defines which project roles may evaluate deliverables.
+// Instructors are always allowed to evaluate.
+type EvaluationPolicy string
+
+const (
+	EvaluationPolicyInstructorsOnly            EvaluationPolicy = "instructors_only"
+	EvaluationPolicyInstructorsAndScrumMaster  EvaluationPolicy = "instructors_and_scrum_master"
+	EvaluationPolicyInstructorsAndProductOwner EvaluationPolicy = "instructors_and_product_owner"
+)
+
+// IsValid checks if the evaluation policy is a defined valid policy.
+func (p EvaluationPolicy) IsValid() bool {
+	switch p {
+	case EvaluationPolicyInstructorsOnly, EvaluationPolicyInstructorsAndScrumMaster, EvaluationPolicyInstructorsAndProductOwner:
+		return true
+	default:
+		return false
+	}
+}
+
+// AllowsRole reports whether a member with the given project role may evaluate under this policy.
+func (p EvaluationPolicy) AllowsRole(role ProjectRole) bool {
+	switch role {
+	case RoleInstructor:
+		return true
+	case RoleScrumMaster:
+		return p == EvaluationPolicyInstructorsAndScrumMaster
+	case RoleProductOwner:
+		return p == EvaluationPolicyInstructorsAndProductOwner
+	default:
+		return false
+	}
+}
@@ -11,32 +11,41 @@ const (
 	RubricStatusArchived RubricStatus = "ARCHIVED"
 )
 
-// Rubric represents the main structure for an evaluation rubric.
+// Rubric represents the main structure for an evaluation rubric. Rubrics without
+// a project are global templates that can be instantiated into any project.
+// Editing an ACTIVE or already used rubric creates a new version instead of
+// changing it in place; all versions share the RootRubricID of the first one.
 type Rubric struct {
-	ID          uint              `json:"id" gorm:"primaryKey"`
-	Name        string            `json:"name" gorm:"not null"`
-	Description string            `json:"description"`
-	ProjectID   uint              `json:"projectId" gorm:"not null"`
-	Project     Project           `json:"project" gorm:"foreignKey:ProjectID"`
-	CreatedByID uint              `json:"createdById" gorm:"not null"`
-	CreatedBy   User              `json:"createdBy" gorm:"foreignKey:CreatedByID"`
-	Status      RubricStatus      `json:"status" gorm:"type:varchar(20);not null;default:'DRAFT'"`
-	IsTemplate  bool              `json:"isTemplate" gorm:"default:false"`
-	Criteria    []RubricCriterion `json:"criteria" gorm:"foreignKey:RubricID;constraint:OnDelete:CASCADE;"`
-	CreatedAt   time.Time         `json:"createdAt"`
-	UpdatedAt   time.Time         `json:"updatedAt"`
+	ID               uint              `json:"id" gorm:"primaryKey"`
+	Name             string            `json:"name" gorm:"not null"`
+	Description      string            `json:"description"`
+	ProjectID        *uint             `json:"projectId" gorm:"index"` // Nil for global templates
+	Project          *Project          `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
+	CreatedByID      uint              `json:"createdById" gorm:"not null"`
+	CreatedBy        User              `json:"createdBy" gorm:"foreignKey:CreatedByID"`
+	Status           RubricStatus      `json:"status" gorm:"type:varchar(20);not null;default:'DRAFT'"`
+	IsTemplate       bool              `json:"isTemplate" gorm:"default:false"`
+	Category         string            `json:"category" gorm:"index"`         // Groups templates in the library
+	SourceTemplateID *uint             `json:"sourceTemplateId" gorm:"index"` // Template this rubric was instantiated from
+	Version          int               `json:"version" gorm:"not null;default:1"`
+	RootRubricID     *uint             `json:"rootRubricId" gorm:"index"` // Nil on the first version
+	SupersededAt     *time.Time        `json:"supersededAt"`              // Set once a newer version exists
+	Criteria         []RubricCriterion `json:"criteria" gorm:"foreignKey:RubricID;constraint:OnDelete:CASCADE;"`
+	CreatedAt        time.Time         `json:"createdAt"`
+	UpdatedAt        time.Time         `json:"updatedAt"`
 }
 
 // RubricCriterion represents a single criterion within a rubric.
 type RubricCriterion struct {
-	ID          uint                   `json:"id" gorm:"primaryKey"`
-	RubricID    uint                   `json:"rubricId" gorm:"not null"`
-	Title       string                 `json:"title" gorm:"not null"`
-	Description string                 `json:"description"`
-	MaxPoints   float64                `json:"maxPoints" gorm:"not null"`
-	Levels      []RubricCriterionLevel `json:"levels" gorm:"foreignKey:CriterionID;constraint:OnDelete:CASCADE;"`
-	CreatedAt   time.Time              `json:"createdAt"`
-	UpdatedAt   time.Time              `json:"updatedAt"`
+	ID                uint                   `json:"id" gorm:"primaryKey"`
+	RubricID          uint                   `json:"rubricId" gorm:"not null"`
+	Title             string                 `json:"title" gorm:"not null"`
+	Description       string                 `json:"description"`
+	MaxPoints         float64                `json:"maxPoints" gorm:"not null"`
+	OriginCriterionID *uint                  `json:"originCriterionId"` // Same criterion in the version that introduced it
+	Levels            []RubricCriterionLevel `json:"levels" gorm:"foreignKey:CriterionID;constraint:OnDelete:CASCADE;"`
+	CreatedAt         time.Time              `json:"createdAt"`
+	UpdatedAt         time.Time              `json:"updatedAt"`
 }
 
 // RubricCriterionLevel represents a performance level within a criterion.
@@ -48,3 +57,8 @@ type RubricCriterionLevel struct {
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
 }
+
+// BelongsToProject reports whether the rubric is scoped to the given project.
+func (r *Rubric) BelongsToProject(projectID uint) bool {
+	return r.ProjectID != nil && *r.ProjectID == projectID
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // TaskStatus defines the possible statuses for a Task.
 type TaskStatus string
@@ -34,11 +38,13 @@ type Task struct {
 	AssignedTo     *User `gorm:"foreignKey:AssignedToID"`
 	EstimatedHours *float32
 	SpentHours     *float32
-	IsDeliverable  bool      `gorm:"default:false"`
-	CreatedByID    uint      `gorm:"not null"`
-	CreatedBy      User      `gorm:"foreignKey:CreatedByID"`
-	CreatedAt      time.Time `gorm:"autoCreateTime"`
-	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+	IsDeliverable  bool           `gorm:"default:false"`
+	Labels         []string       `gorm:"type:jsonb;serializer:json"`
+	CreatedByID    uint           `gorm:"not null"`
+	CreatedBy      User           `gorm:"foreignKey:CreatedByID"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt      gorm.DeletedAt `gorm:"index"` // Set while the task is in the trash
 	History        []TaskHistory
 	Comments       []TaskComment
 }
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type UserStory struct {
 	ID                 uint   `gorm:"primaryKey"`
@@ -17,7 +21,9 @@ type UserStory struct {
 	CreatedByID        uint    `gorm:"not null"`
 	CreatedBy          User    `gorm:"foreignKey:CreatedByID"`
 	AssignedToID       *uint
-	AssignedTo         *User     `gorm:"foreignKey:AssignedToID"`
-	CreatedAt          time.Time `gorm:"autoCreateTime"`
-	UpdatedAt          time.Time `gorm:"autoUpdateTime"`
+	AssignedTo         *User          `gorm:"foreignKey:AssignedToID"`
+	Labels             []string       `gorm:"type:jsonb;serializer:json"`
+	CreatedAt          time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt          time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt          gorm.DeletedAt `gorm:"index"` // Set while the story is in the trash
 }
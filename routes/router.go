@@ -9,7 +9,7 @@ import (
 )
 
 // SetupRoutes configures the application routes.
-func SetupRoutes(e *echo.Echo, userHandler *handlers.UserHandler, projectHandler *handlers.ProjectHandler, sprintHandler *handlers.SprintHandler, userStoryHandler *handlers.UserStoryHandler, taskHandler *handlers.TaskHandler, notificationHandler *handlers.NotificationHandler, rubricHandler *handlers.RubricHandler, reportingHandler *handlers.ReportingHandler, evaluationHandler *handlers.EvaluationHandler, eventHandler *handlers.EventHandler, exportHandler *handlers.ExportHandler, jwtSecret string) {
+func SetupRoutes(e *echo.Echo, userHandler *handlers.UserHandler, projectHandler *handlers.ProjectHandler, sprintHandler *handlers.SprintHandler, userStoryHandler *handlers.UserStoryHandler, taskHandler *handlers.TaskHandler, notificationHandler *handlers.NotificationHandler, rubricHandler *handlers.RubricHandler, reportingHandler *handlers.ReportingHandler, evaluationHandler *handlers.EvaluationHandler, eventHandler *handlers.EventHandler, exportHandler *handlers.ExportHandler, gradebookHandler *handlers.GradebookHandler, auditHandler *handlers.AuditHandler, trashHandler *handlers.TrashHandler, projectTemplateHandler *handlers.ProjectTemplateHandler, projectArchiveHandler *handlers.ProjectArchiveHandler, bulkHandler *handlers.BulkHandler, workHandler *handlers.WorkHandler, healthHandler *handlers.HealthHandler, contributionHandler *handlers.ContributionHandler, riskHandler *handlers.RiskHandler, jwtSecret string) {
 	// --- Swagger Route ---
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
@@ -20,12 +20,15 @@ func SetupRoutes(e *echo.Echo, userHandler *handlers.UserHandler, projectHandler
 	// --- General Authenticated Routes ---
 	api := e.Group("/api")
 	api.Use(middleware.JWTAuthMiddleware(jwtSecret))
+	api.Use(middleware.AuditContextMiddleware)
+	api.Use(middleware.ArchivedProjectMiddleware(projectHandler.Service))
 
 	// Authentication routes
 	api.POST("/logout", userHandler.Logout)
 
 	// User routes
 	api.GET("/me", userHandler.GetCurrentUser)
+	api.GET("/me/work", workHandler.GetMyWork)
 	api.GET("/users/:id", userHandler.GetUser)
 
 	// Notification routes
@@ -36,18 +39,48 @@ func SetupRoutes(e *echo.Echo, userHandler *handlers.UserHandler, projectHandler
 	// Project routes
 	api.POST("/projects", projectHandler.CreateProject)
 	api.GET("/projects", projectHandler.GetAllProjects)
+	api.GET("/projects/trash", trashHandler.GetTrashedProjects)
 	api.GET("/projects/:id", projectHandler.GetProjectByID)
 	api.PUT("/projects/:id", projectHandler.UpdateProject)
 	api.DELETE("/projects/:id", projectHandler.DeleteProject)
+	api.PUT("/projects/:id/evaluation-policy", projectHandler.UpdateEvaluationPolicy)
 	api.GET("/projects/:id/unassigned-users", projectHandler.GetUnassignedUsers)
 	api.GET("/projects/:id/members", projectHandler.GetProjectMembers)
 	api.GET("/projects/:id/active-sprint", projectHandler.GetActiveSprint)
 	api.GET("/projects/:id/export", exportHandler.ExportProject) // <-- NEW
+	api.GET("/projects/:id/gradebook", gradebookHandler.GetGradebook)
+	api.GET("/projects/:id/gradebook/export", gradebookHandler.ExportGradebook)
+
+	// Project templates and cloning
+	api.POST("/projects/:id/save-as-template", projectTemplateHandler.SaveProjectAsTemplate)
+	api.POST("/projects/:id/clone", projectTemplateHandler.CloneProject)
+	api.GET("/project-templates", projectTemplateHandler.GetTemplates)
+	api.GET("/project-templates/:id", projectTemplateHandler.GetTemplate)
+	api.DELETE("/project-templates/:id", projectTemplateHandler.DeleteTemplate)
+	api.POST("/project-templates/:id/instantiate", projectTemplateHandler.CreateProjectFromTemplate)
+
+	// Project archives
+	api.GET("/projects/:id/archive", projectArchiveHandler.ExportProjectArchive)
+	api.POST("/projects/import", projectArchiveHandler.ImportProjectArchive)
+
+	// Bulk operations
+	api.POST("/projects/:id/tasks/bulk", bulkHandler.BulkUpdateTasks)
+	api.POST("/projects/:id/userstories/bulk", bulkHandler.BulkUpdateUserStories)
+
+	// Trash routes
+	api.GET("/projects/:id/trash", trashHandler.GetProjectTrash)
+	api.POST("/projects/:id/restore", trashHandler.RestoreProject)
+	api.POST("/userstories/:storyId/restore", trashHandler.RestoreUserStory)
+	api.POST("/tasks/:taskId/restore", trashHandler.RestoreTask)
 
 	// Reporting routes
 	api.GET("/projects/:id/reports/velocity", reportingHandler.GetProjectVelocity)
+	api.GET("/projects/:id/health", healthHandler.GetProjectHealth)
 	api.GET("/sprints/:id/reports/burndown", reportingHandler.GetSprintBurndown)
 	api.GET("/sprints/:id/reports/commitment", reportingHandler.GetSprintCommitmentReport)
+	api.GET("/projects/:id/contributions", contributionHandler.GetProjectContributions)
+	api.GET("/sprints/:id/contributions", contributionHandler.GetSprintContributions)
+	api.GET("/projects/:id/risks", riskHandler.GetProjectRisks)
 
 	// Rubric routes
 	api.POST("/rubrics", rubricHandler.CreateRubric)
@@ -56,6 +89,17 @@ func SetupRoutes(e *echo.Echo, userHandler *handlers.UserHandler, projectHandler
 	api.PUT("/rubrics/:id", rubricHandler.UpdateRubric)
 	api.DELETE("/rubrics/:id", rubricHandler.DeleteRubric)
 	api.POST("/rubrics/:id/duplicate", rubricHandler.DuplicateRubric)
+	api.GET("/rubrics/:id/versions", rubricHandler.GetRubricVersions)
+	api.GET("/rubrics/:id/diff", rubricHandler.DiffRubricVersions)
+	api.POST("/rubrics/:id/save-as-template", rubricHandler.SaveAsTemplate)
+	api.GET("/rubrics/:id/export", rubricHandler.ExportRubric)
+	api.POST("/projects/:id/rubrics/import", rubricHandler.ImportRubric)
+
+	// Global rubric template library
+	api.GET("/rubric-templates", rubricHandler.SearchTemplates)
+	api.POST("/rubric-templates", rubricHandler.CreateTemplate)
+	api.GET("/rubric-templates/categories", rubricHandler.GetTemplateCategories)
+	api.POST("/rubric-templates/:id/instantiate", rubricHandler.InstantiateTemplate)
 
 	// User Story routes
 	api.POST("/projects/:id/userstories", userStoryHandler.CreateUserStory)
@@ -77,6 +121,19 @@ func SetupRoutes(e *echo.Echo, userHandler *handlers.UserHandler, projectHandler
 	// Evaluation routes (for tasks)
 	api.POST("/tasks/:taskId/evaluations", evaluationHandler.CreateEvaluation)
 	api.GET("/tasks/:taskId/evaluations", evaluationHandler.GetEvaluationsByTaskID)
+	api.GET("/evaluations/:id", evaluationHandler.GetEvaluationByID)
+	api.PUT("/evaluations/:id", evaluationHandler.UpdateEvaluation)
+	api.POST("/evaluations/:id/submit", evaluationHandler.SubmitEvaluation)
+	api.POST("/evaluations/:id/publish", evaluationHandler.PublishEvaluation)
+
+	// Peer and self evaluation rounds (scoped to a sprint)
+	api.POST("/sprints/:id/evaluation-rounds", evaluationHandler.CreateEvaluationRound)
+	api.GET("/sprints/:id/evaluation-rounds", evaluationHandler.GetEvaluationRoundsBySprintID)
+	api.GET("/evaluation-rounds/:id", evaluationHandler.GetEvaluationRound)
+	api.POST("/evaluation-rounds/:id/evaluations", evaluationHandler.CreateRoundEvaluation)
+	api.POST("/evaluation-rounds/:id/close", evaluationHandler.CloseEvaluationRound)
+	api.GET("/evaluation-rounds/:id/received", evaluationHandler.GetReceivedEvaluations)
+	api.GET("/evaluation-rounds/:id/results", evaluationHandler.GetEvaluationRoundResults)
 
 	// Sprint routes
 	api.POST("/projects/:id/sprints", sprintHandler.CreateSprint)
@@ -98,6 +155,7 @@ func SetupRoutes(e *echo.Echo, userHandler *handlers.UserHandler, projectHandler
 	admin := e.Group("/api/admin")
 	admin.Use(middleware.JWTAuthMiddleware(jwtSecret))
 	admin.Use(middleware.AdminAuthMiddleware)
+	admin.Use(middleware.AuditContextMiddleware)
 
 	// Admin user management
 	admin.GET("/users", userHandler.GetAllUsers)
@@ -107,5 +165,19 @@ func SetupRoutes(e *echo.Echo, userHandler *handlers.UserHandler, projectHandler
 	admin.DELETE("/users/:id", userHandler.DeleteUser)
 
 	// Admin project management
-	admin.POST("/projects/:id/members", projectHandler.AddMemberToProject)
+	admin.POST("/projects/:id/members", projectHandler.AddMemberToProject, middleware.ArchivedProjectMiddleware(projectHandler.Service))
+	admin.DELETE("/projects/:id/members/:userId", projectHandler.RemoveMemberFromProject, middleware.ArchivedProjectMiddleware(projectHandler.Service))
+	admin.POST("/projects/:id/unarchive", projectHandler.UnarchiveProject)
+
+	// Admin audit log
+	admin.GET("/audit-logs", auditHandler.GetAuditLogs)
+
+	// Admin trash purge
+	admin.DELETE("/trash/projects/:id", trashHandler.PurgeProject)
+	admin.DELETE("/trash/userstories/:id", trashHandler.PurgeUserStory)
+	admin.DELETE("/trash/tasks/:id", trashHandler.PurgeTask)
+	admin.POST("/trash/purge", trashHandler.PurgeExpired)
+
+	// At-risk work detection
+	admin.POST("/risks/scan", riskHandler.ScanRisks)
 }
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+// auditLogSortFields are the fields the audit log can be sorted by.
+var auditLogSortFields = sortFields{"id": "id", "createdAt": "created_at"}
+
+// AuditService gives administrators read access to the audit log.
+// Entries are written by the storage layer in the same transaction as each change.
+type AuditService struct {
+	Repo *storage.AuditRepository
+}
+
+// NewAuditService creates a new AuditService.
+func NewAuditService(repo *storage.AuditRepository) *AuditService {
+	return &AuditService{Repo: repo}
+}
+
+// GetAuditLogs queries the audit log by entity, actor and time range, newest first by
+// default. Only platform admins may read it.
+func (s *AuditService) GetAuditLogs(filter storage.AuditLogFilter, query ListQuery, requestingUserRole string) (*Page[models.AuditLog], error) {
+	if requestingUserRole != string(models.RoleAdmin) {
+		return nil, fmt.Errorf("forbidden: only admins can read the audit log")
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return nil, fmt.Errorf("invalid audit log query: 'from' is after 'to'")
+	}
+	opts, err := query.options(auditLogSortFields, "-createdAt")
+	if err != nil {
+		return nil, err
+	}
+
+	logs, total, err := s.Repo.FindAuditLogs(filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve audit log: %w", err)
+	}
+	return newPage(logs, total, opts), nil
+}
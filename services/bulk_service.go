@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+// MaxBulkItems is the largest number of items a single bulk operation accepts.
+const MaxBulkItems = 500
+
+// Bulk actions. Tasks accept all but BulkActionSprint.
+const (
+	BulkActionSprint = "sprint" // Move user stories to a sprint, or back to the backlog
+	BulkActionStatus = "status"
+	BulkActionAssign = "assign" // Reassign, or unassign with a null assignee
+	BulkActionLabel  = "label"  // Add and remove labels
+	BulkActionDelete = "delete" // Move to the trash
+)
+
+// BulkRequest describes a bulk operation on tasks or user stories of one project.
+type BulkRequest struct {
+	IDs          []uint   `json:"ids"`
+	Action       string   `json:"action"`
+	Status       string   `json:"status"`       // For "status"
+	AssigneeID   *uint    `json:"assigneeId"`   // For "assign"; null unassigns
+	SprintID     *uint    `json:"sprintId"`     // For "sprint"; null moves to the backlog
+	AddLabels    []string `json:"addLabels"`    // For "label"
+	RemoveLabels []string `json:"removeLabels"` // For "label"
+}
+
+// BulkItemResult is the outcome for one item of a bulk operation.
+type BulkItemResult struct {
+	ID        uint   `json:"id"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	Unchanged bool   `json:"unchanged,omitempty"` // The item already had the requested value
+	OldValue  string `json:"oldValue,omitempty"`
+	NewValue  string `json:"newValue,omitempty"`
+}
+
+// BulkResult is the outcome of a bulk operation. Applied is false when any item failed,
+// in which case nothing was changed.
+type BulkResult struct {
+	ProjectID uint             `json:"projectId"`
+	Entity    string           `json:"entity"` // "task" or "user_story"
+	Action    string           `json:"action"`
+	Applied   bool             `json:"applied"`
+	Items     []BulkItemResult `json:"items"`
+}
+
+// Changed returns the results of the items that were modified.
+func (r *BulkResult) Changed() []BulkItemResult {
+	changed := make([]BulkItemResult, 0, len(r.Items))
+	for _, item := range r.Items {
+		if item.OK && !item.Unchanged {
+			changed = append(changed, item)
+		}
+	}
+	return changed
+}
+
+// BulkService applies status, assignment, label, sprint and delete changes to many
+// tasks or user stories at once, all or nothing.
+type BulkService struct {
+	Repo                *storage.BulkRepository
+	ProjectService      *ProjectService
+	SprintService       *SprintService
+	NotificationService *NotificationService
+	Events              *EventBus // Optional, set once at startup
+}
+
+// NewBulkService creates a new instance of BulkService.
+func NewBulkService(repo *storage.BulkRepository, projectService *ProjectService, sprintService *SprintService, notificationService *NotificationService) *BulkService {
+	return &BulkService{
+		Repo:                repo,
+		ProjectService:      projectService,
+		SprintService:       sprintService,
+		NotificationService: notificationService,
+	}
+}
+
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *BulkService) WithContext(ctx context.Context) *BulkService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	scoped.Events = s.Events.WithContext(ctx)
+	return &scoped
+}
+
+// BulkUpdateTasks applies a bulk operation to tasks of a project. Any project member or
+// an admin may run it. Every changed task gets a history entry.
+func (s *BulkService) BulkUpdateTasks(projectID uint, req BulkRequest, requestingUserID uint, requestingUserRole string) (*BulkResult, error) {
+	if err := s.validateRequest(projectID, &req, false); err != nil {
+		return nil, err
+	}
+	if requestingUserRole != string(models.RoleAdmin) {
+		if _, err := s.ProjectService.GetUserRoleInProject(requestingUserID, projectID); err != nil {
+			return nil, fmt.Errorf("forbidden: you are not a member of this project")
+		}
+	}
+
+	tasks, err := s.Repo.GetTasksInProject(projectID, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Task, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	result := &BulkResult{ProjectID: projectID, Entity: "task", Action: req.Action}
+	var changes []storage.BulkTaskChange
+	for _, id := range req.IDs {
+		task, ok := byID[id]
+		item := BulkItemResult{ID: id, OK: ok}
+		if !ok {
+			item.Error = "task not found in this project"
+			result.Items = append(result.Items, item)
+			continue
+		}
+
+		change := storage.BulkTaskChange{Task: task, History: models.TaskHistory{ChangedByID: requestingUserID}}
+		switch req.Action {
+		case BulkActionStatus:
+			item.OldValue, item.NewValue = string(task.Status), req.Status
+			task.Status = models.TaskStatus(req.Status)
+			change.Columns = []string{"Status"}
+			change.History.FieldName = "status"
+		case BulkActionAssign:
+			item.OldValue, item.NewValue = optionalID(task.AssignedToID), optionalID(req.AssigneeID)
+			task.AssignedToID, task.AssignedTo = req.AssigneeID, nil
+			change.Columns = []string{"AssignedToID"}
+			change.History.FieldName = "assignedTo"
+		case BulkActionLabel:
+			oldLabels := task.Labels
+			task.Labels = applyLabels(task.Labels, req.AddLabels, req.RemoveLabels)
+			item.OldValue, item.NewValue = strings.Join(oldLabels, ","), strings.Join(task.Labels, ",")
+			change.Columns = []string{"Labels"}
+			change.History.FieldName = "labels"
+		case BulkActionDelete:
+			item.OldValue, item.NewValue = "", "deleted"
+			change.Delete = true
+			change.History.FieldName = "deleted"
+		}
+
+		if item.OldValue == item.NewValue {
+			item.Unchanged = true
+		} else {
+			change.History.OldValue, change.History.NewValue = item.OldValue, item.NewValue
+			changes = append(changes, change)
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	if err := rejectFailedItems(result); err != nil {
+		return result, err
+	}
+	if err := s.Repo.ApplyTaskChanges(changes); err != nil {
+		return nil, fmt.Errorf("could not apply bulk operation: %w", err)
+	}
+	result.Applied = true
+	s.publishBulkUpdate(result)
+
+	if req.Action == BulkActionAssign && req.AssigneeID != nil && len(changes) > 0 && *req.AssigneeID != requestingUserID {
+		message := fmt.Sprintf("Se te han asignado %d tareas.", len(changes))
+		if _, err := s.NotificationService.CreateNotification(*req.AssigneeID, message, fmt.Sprintf("/projects/%d", projectID)); err != nil {
+			log.Printf("could not create notification for bulk task assignment: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// BulkUpdateUserStories applies a bulk operation to user stories of a project. Product
+// owners, scrum masters and admins may run it. Each story is saved on its own, so the
+// audit log holds one history entry per story.
+func (s *BulkService) BulkUpdateUserStories(projectID uint, req BulkRequest, requestingUserID uint, requestingUserRole string) (*BulkResult, error) {
+	if err := s.validateRequest(projectID, &req, true); err != nil {
+		return nil, err
+	}
+	if requestingUserRole != string(models.RoleAdmin) {
+		role, err := s.ProjectService.GetUserRoleInProject(requestingUserID, projectID)
+		if err != nil || (role != string(models.RoleProductOwner) && role != string(models.RoleScrumMaster)) {
+			return nil, fmt.Errorf("forbidden: you do not have permission to update these user stories")
+		}
+	}
+
+	stories, err := s.Repo.GetUserStoriesInProject(projectID, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.UserStory, len(stories))
+	for i := range stories {
+		byID[stories[i].ID] = &stories[i]
+	}
+
+	result := &BulkResult{ProjectID: projectID, Entity: "user_story", Action: req.Action}
+	var changes []storage.BulkUserStoryChange
+	for _, id := range req.IDs {
+		story, ok := byID[id]
+		item := BulkItemResult{ID: id, OK: ok}
+		if !ok {
+			item.Error = "user story not found in this project"
+			result.Items = append(result.Items, item)
+			continue
+		}
+
+		change := storage.BulkUserStoryChange{Story: story}
+		switch req.Action {
+		case BulkActionSprint:
+			item.OldValue, item.NewValue = optionalID(story.SprintID), optionalID(req.SprintID)
+			story.SprintID = req.SprintID
+			change.Columns = []string{"SprintID"}
+		case BulkActionStatus:
+			item.OldValue, item.NewValue = story.Status, req.Status
+			story.Status = req.Status
+			change.Columns = []string{"Status"}
+		case BulkActionAssign:
+			item.OldValue, item.NewValue = optionalID(story.AssignedToID), optionalID(req.AssigneeID)
+			story.AssignedToID = req.AssigneeID
+			change.Columns = []string{"AssignedToID"}
+		case BulkActionLabel:
+			oldLabels := story.Labels
+			story.Labels = applyLabels(story.Labels, req.AddLabels, req.RemoveLabels)
+			item.OldValue, item.NewValue = strings.Join(oldLabels, ","), strings.Join(story.Labels, ",")
+			change.Columns = []string{"Labels"}
+		case BulkActionDelete:
+			item.OldValue, item.NewValue = "", "deleted"
+			change.Delete = true
+		}
+
+		if item.OldValue == item.NewValue {
+			item.Unchanged = true
+		} else {
+			changes = append(changes, change)
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	if err := rejectFailedItems(result); err != nil {
+		return result, err
+	}
+	if err := s.Repo.ApplyUserStoryChanges(changes); err != nil {
+		return nil, fmt.Errorf("could not apply bulk operation: %w", err)
+	}
+	result.Applied = true
+	s.publishBulkUpdate(result)
+	return result, nil
+}
+
+// publishBulkUpdate announces the items a bulk operation changed, if any.
+func (s *BulkService) publishBulkUpdate(result *BulkResult) {
+	if changed := result.Changed(); len(changed) > 0 {
+		s.Events.Publish(result.ProjectID, BulkUpdated{Entity: result.Entity, Action: result.Action, Items: changed})
+	}
+}
+
+// validateRequest checks the parts of a request that do not depend on the items.
+func (s *BulkService) validateRequest(projectID uint, req *BulkRequest, stories bool) error {
+	if len(req.IDs) == 0 {
+		return fmt.Errorf("invalid bulk request: no ids given")
+	}
+	if len(req.IDs) > MaxBulkItems {
+		return fmt.Errorf("invalid bulk request: at most %d items per request", MaxBulkItems)
+	}
+
+	switch req.Action {
+	case BulkActionStatus:
+		if stories && strings.TrimSpace(req.Status) == "" {
+			return fmt.Errorf("invalid bulk request: status is required")
+		}
+		if !stories && !models.IsValidTaskStatus(req.Status) {
+			return fmt.Errorf("invalid bulk request: invalid task status '%s'", req.Status)
+		}
+	case BulkActionAssign:
+		if req.AssigneeID != nil {
+			if _, err := s.ProjectService.GetUserRoleInProject(*req.AssigneeID, projectID); err != nil {
+				return fmt.Errorf("invalid bulk request: assignee is not a member of this project")
+			}
+		}
+	case BulkActionLabel:
+		if len(req.AddLabels) == 0 && len(req.RemoveLabels) == 0 {
+			return fmt.Errorf("invalid bulk request: addLabels or removeLabels is required")
+		}
+	case BulkActionDelete:
+	case BulkActionSprint:
+		if !stories {
+			return fmt.Errorf("invalid bulk request: unknown action '%s'", req.Action)
+		}
+		if req.SprintID != nil {
+			sprint, err := s.SprintService.GetSprintByID(*req.SprintID)
+			if err != nil || sprint.ProjectID != projectID {
+				return fmt.Errorf("invalid bulk request: sprint not found in this project")
+			}
+		}
+	default:
+		return fmt.Errorf("invalid bulk request: unknown action '%s'", req.Action)
+	}
+	return nil
+}
+
+// rejectFailedItems marks duplicate IDs as failed and returns an error when any item failed.
+func rejectFailedItems(result *BulkResult) error {
+	seen := make(map[uint]bool, len(result.Items))
+	failed := 0
+	for i := range result.Items {
+		item := &result.Items[i]
+		if item.OK && seen[item.ID] {
+			item.OK, item.Error, item.Unchanged, item.OldValue, item.NewValue = false, "duplicate id", false, "", ""
+		}
+		seen[item.ID] = true
+		if !item.OK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("bulk operation rejected: %d of %d item(s) failed, nothing was changed", failed, len(result.Items))
+	}
+	return nil
+}
+
+// applyLabels adds and then removes labels, keeping the order and dropping blanks and repeats.
+func applyLabels(labels, add, remove []string) []string {
+	removed := make(map[string]bool, len(remove))
+	for _, label := range remove {
+		removed[strings.TrimSpace(label)] = true
+	}
+	seen := map[string]bool{}
+	result := []string{}
+	for _, label := range append(append([]string{}, labels...), add...) {
+		label = strings.TrimSpace(label)
+		if label == "" || seen[label] || removed[label] {
+			continue
+		}
+		seen[label] = true
+		result = append(result, label)
+	}
+	return result
+}
+
+// optionalID formats an optional ID, empty when nil.
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+// MemberContribution is what one member contributed to a project or a sprint.
+//
+// Completed tasks are credited to their current assignee. PointsContributed shares each
+// user story's points evenly between its tasks and adds the shares of the member's done
+// tasks. Cycle time runs from a task's first move to in progress (or its creation) to
+// its last move to done. A review is a move out of in review made by someone other than
+// the task's assignee; its turnaround is the time the task spent waiting in review.
+type MemberContribution struct {
+	UserID                       uint     `json:"userId"`
+	Name                         string   `json:"name"`
+	Email                        string   `json:"email"`
+	Role                         string   `json:"role"`
+	TasksAssigned                int      `json:"tasksAssigned"`
+	TasksCompleted               int      `json:"tasksCompleted"`
+	PointsContributed            float64  `json:"pointsContributed"`
+	HoursLogged                  float64  `json:"hoursLogged"`
+	Efficiency                   *float64 `json:"efficiency"` // Estimated over spent hours of done tasks, as a percentage
+	AverageCycleTimeHours        *float64 `json:"averageCycleTimeHours"`
+	ReviewsCompleted             int      `json:"reviewsCompleted"`
+	AverageReviewTurnaroundHours *float64 `json:"averageReviewTurnaroundHours"`
+	Comments                     int      `json:"comments"`
+}
+
+// ContributionReport lists the contribution of every member of a project, over the
+// whole project or a single sprint. Instructors are left out.
+type ContributionReport struct {
+	ProjectID   uint                 `json:"projectId"`
+	ProjectName string               `json:"projectName"`
+	SprintID    *uint                `json:"sprintId"`
+	SprintName  *string              `json:"sprintName"`
+	GeneratedAt time.Time            `json:"generatedAt"`
+	Members     []MemberContribution `json:"members"`
+}
+
+// ContributionService computes per-member contribution analytics and keeps them as
+// UserMetric rows.
+type ContributionService struct {
+	Repo           *storage.ContributionRepository
+	ProjectService *ProjectService
+	SprintService  *SprintService
+}
+
+// NewContributionService creates a new instance of ContributionService.
+func NewContributionService(repo *storage.ContributionRepository, projectService *ProjectService, sprintService *SprintService) *ContributionService {
+	return &ContributionService{
+		Repo:           repo,
+		ProjectService: projectService,
+		SprintService:  sprintService,
+	}
+}
+
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *ContributionService) WithContext(ctx context.Context) *ContributionService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	scoped.ProjectService = s.ProjectService.WithContext(ctx)
+	scoped.SprintService = s.SprintService.WithContext(ctx)
+	return &scoped
+}
+
+// GetProjectContributions reports the contribution of each member over the whole project.
+// Only instructors, scrum masters and platform admins may see it.
+func (s *ContributionService) GetProjectContributions(projectID, userID uint, userRole string) (*ContributionReport, error) {
+	return s.buildReport(projectID, nil, userID, userRole)
+}
+
+// GetSprintContributions reports the contribution of each member to the tasks of a
+// sprint. Only instructors, scrum masters and platform admins may see it.
+func (s *ContributionService) GetSprintContributions(sprintID, userID uint, userRole string) (*ContributionReport, error) {
+	sprint, err := s.SprintService.GetSprintByID(sprintID)
+	if err != nil {
+		return nil, fmt.Errorf("sprint not found")
+	}
+	return s.buildReport(sprint.ProjectID, sprint, userID, userRole)
+}
+
+func (s *ContributionService) buildReport(projectID uint, sprint *models.Sprint, userID uint, userRole string) (*ContributionReport, error) {
+	project, err := s.ProjectService.GetProjectByID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("project not found")
+	}
+	if userRole != string(models.RoleAdmin) {
+		role, _ := s.ProjectService.GetUserRoleInProject(userID, projectID)
+		if models.ProjectRole(role) != models.RoleInstructor && models.ProjectRole(role) != models.RoleScrumMaster {
+			return nil, fmt.Errorf("forbidden: only instructors and scrum masters can view contributions")
+		}
+	}
+
+	var sprintID *uint
+	if sprint != nil {
+		sprintID = &sprint.ID
+	}
+	members, err := s.ProjectService.GetProjectMembers(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load project members: %w", err)
+	}
+	tasks, err := s.Repo.GetTasks(projectID, sprintID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load tasks: %w", err)
+	}
+	history, err := s.Repo.GetStatusHistory(projectID, sprintID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load task history: %w", err)
+	}
+	comments, err := s.Repo.CountComments(projectID, sprintID)
+	if err != nil {
+		return nil, fmt.Errorf("could not count comments: %w", err)
+	}
+
+	report := &ContributionReport{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		SprintID:    sprintID,
+		GeneratedAt: time.Now(),
+		Members:     make([]MemberContribution, 0, len(members)),
+	}
+	if sprint != nil {
+		report.SprintName = &sprint.Name
+	}
+
+	// Rows: every member except instructors, in membership order.
+	for _, member := range members {
+		if models.ProjectRole(member.Role) == models.RoleInstructor {
+			continue
+		}
+		report.Members = append(report.Members, MemberContribution{
+			UserID: member.UserID,
+			Name:   fullName(member.User),
+			Email:  member.User.Correo,
+			Role:   member.Role,
+		})
+	}
+	rows := make(map[uint]*contributionTally, len(report.Members))
+	for i := range report.Members {
+		rows[report.Members[i].UserID] = &contributionTally{member: &report.Members[i]}
+	}
+
+	historyByTask := make(map[uint][]models.TaskHistory)
+	for _, change := range history {
+		historyByTask[change.TaskID] = append(historyByTask[change.TaskID], change)
+	}
+
+	for _, task := range tasks {
+		changes := historyByTask[task.TaskID]
+		var assignee uint
+		if task.AssignedToID != nil {
+			assignee = *task.AssignedToID
+		}
+		countReviews(changes, assignee, rows)
+
+		row, ok := rows[assignee]
+		if !ok {
+			continue
+		}
+		row.member.TasksAssigned++
+		if task.SpentHours != nil {
+			row.member.HoursLogged += float64(*task.SpentHours)
+		}
+		if task.Status != string(models.StatusDone) {
+			continue
+		}
+
+		row.member.TasksCompleted++
+		if task.StoryPoints != nil && task.StoryTaskCount > 0 {
+			row.member.PointsContributed += float64(*task.StoryPoints) / float64(task.StoryTaskCount)
+		}
+		if task.EstimatedHours != nil && task.SpentHours != nil && *task.SpentHours > 0 {
+			row.estimated += float64(*task.EstimatedHours)
+			row.spent += float64(*task.SpentHours)
+		}
+		if cycle, ok := cycleTime(task.CreatedAt, changes); ok {
+			row.cycleHours = append(row.cycleHours, cycle)
+		}
+	}
+
+	for _, row := range comments {
+		if tally, ok := rows[row.AuthorID]; ok {
+			tally.member.Comments = row.Comments
+		}
+	}
+	for _, tally := range rows {
+		tally.finish()
+	}
+
+	if project.Status != string(models.ProjectStatusArchived) {
+		if err := s.saveUserMetrics(report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// saveUserMetrics stores the report as the day's UserMetric of each member.
+func (s *ContributionService) saveUserMetrics(report *ContributionReport) error {
+	now := report.GeneratedAt
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	metrics := make([]models.UserMetric, 0, len(report.Members))
+	for _, member := range report.Members {
+		tasksCompleted := member.TasksCompleted
+		points := int(math.Round(member.PointsContributed))
+		hours := int(math.Round(member.HoursLogged))
+		metric := models.UserMetric{
+			UserID:            member.UserID,
+			SprintID:          report.SprintID,
+			ProjectID:         &report.ProjectID,
+			Date:              day,
+			TasksCompleted:    &tasksCompleted,
+			PointsContributed: &points,
+			HoursLogged:       &hours,
+		}
+		if member.Efficiency != nil {
+			efficiency := int(math.Round(*member.Efficiency))
+			metric.Efficiency = &efficiency
+		}
+		metrics = append(metrics, metric)
+	}
+	if err := s.Repo.SaveUserMetrics(metrics); err != nil {
+		return fmt.Errorf("could not save user metrics: %w", err)
+	}
+	return nil
+}
+
+// contributionTally accumulates the figures of a member that are averaged at the end.
+type contributionTally struct {
+	member          *MemberContribution
+	estimated       float64
+	spent           float64
+	cycleHours      []float64
+	turnaroundHours []float64
+}
+
+func (t *contributionTally) finish() {
+	t.member.PointsContributed = roundTo(t.member.PointsContributed, 2)
+	t.member.HoursLogged = roundTo(t.member.HoursLogged, 2)
+	if t.spent > 0 {
+		efficiency := roundTo(t.estimated/t.spent*100, 1)
+		t.member.Efficiency = &efficiency
+	}
+	t.member.AverageCycleTimeHours = averageHours(t.cycleHours)
+	t.member.ReviewsCompleted = len(t.turnaroundHours)
+	t.member.AverageReviewTurnaroundHours = averageHours(t.turnaroundHours)
+}
+
+// cycleTime returns the hours between a task's first move to in progress, or its
+// creation when it never had one, and its last move to done.
+func cycleTime(createdAt time.Time, changes []models.TaskHistory) (float64, bool) {
+	start, end := createdAt, time.Time{}
+	started := false
+	for _, change := range changes {
+		if !started && change.NewValue == string(models.StatusInProgress) {
+			start, started = change.ChangedAt, true
+		}
+		if change.NewValue == string(models.StatusDone) {
+			end = change.ChangedAt
+		}
+	}
+	if end.IsZero() || end.Before(start) {
+		return 0, false
+	}
+	return end.Sub(start).Hours(), true
+}
+
+// countReviews credits every move out of in review made by someone other than the
+// task's assignee to that reviewer, with the time the task waited in review.
+func countReviews(changes []models.TaskHistory, assignee uint, rows map[uint]*contributionTally) {
+	var enteredReview time.Time
+	for _, change := range changes {
+		if change.OldValue == string(models.StatusInReview) && !enteredReview.IsZero() {
+			if reviewer, ok := rows[change.ChangedByID]; ok && change.ChangedByID != assignee {
+				reviewer.turnaroundHours = append(reviewer.turnaroundHours, change.ChangedAt.Sub(enteredReview).Hours())
+			}
+			enteredReview = time.Time{}
+		}
+		if change.NewValue == string(models.StatusInReview) {
+			enteredReview = change.ChangedAt
+		}
+	}
+}
+
+func averageHours(hours []float64) *float64 {
+	if len(hours) == 0 {
+		return nil
+	}
+	var total float64
+	for _, value := range hours {
+		total += value
+	}
+	average := roundTo(total/float64(len(hours)), 2)
+	return &average
+}
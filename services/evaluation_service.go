@@ -1,7 +1,13 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/storage"
@@ -9,26 +15,49 @@ import (
 
 // EvaluationService handles the business logic for evaluations.
 type EvaluationService struct {
-	EvalRepo       *storage.EvaluationRepository
-	TaskRepo       *storage.TaskRepository
-	RubricRepo     storage.RubricRepository // Use interface type
-	ProjectService *ProjectService          // To check user roles
+	EvalRepo            *storage.EvaluationRepository
+	TaskRepo            *storage.TaskRepository
+	RubricRepo          storage.RubricRepository  // Use interface type
+	SprintRepo          *storage.SprintRepository // To scope evaluation rounds to a sprint
+	ProjectService      *ProjectService           // To check user roles
+	NotificationService *NotificationService      // To notify the assignee on publish
+	Events              *EventBus                 // Optional, set once at startup
 }
 
 // NewEvaluationService creates a new instance of EvaluationService.
-func NewEvaluationService(evalRepo *storage.EvaluationRepository, taskRepo *storage.TaskRepository, rubricRepo storage.RubricRepository, projectService *ProjectService) *EvaluationService {
+func NewEvaluationService(evalRepo *storage.EvaluationRepository, taskRepo *storage.TaskRepository, rubricRepo storage.RubricRepository, sprintRepo *storage.SprintRepository, projectService *ProjectService, notificationService *NotificationService) *EvaluationService {
 	return &EvaluationService{
-		EvalRepo:       evalRepo,
-		TaskRepo:       taskRepo,
-		RubricRepo:     rubricRepo,
-		ProjectService: projectService,
+		EvalRepo:            evalRepo,
+		TaskRepo:            taskRepo,
+		RubricRepo:          rubricRepo,
+		SprintRepo:          sprintRepo,
+		ProjectService:      projectService,
+		NotificationService: notificationService,
 	}
 }
 
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *EvaluationService) WithContext(ctx context.Context) *EvaluationService {
+	scoped := *s
+	scoped.EvalRepo = s.EvalRepo.WithContext(ctx)
+	scoped.TaskRepo = s.TaskRepo.WithContext(ctx)
+	scoped.RubricRepo = s.RubricRepo.WithContext(ctx)
+	scoped.SprintRepo = s.SprintRepo.WithContext(ctx)
+	scoped.Events = s.Events.WithContext(ctx)
+	return &scoped
+}
+
 // CreateEvaluationRequest defines the structure for the evaluation payload.
 type CreateEvaluationRequest struct {
-	RubricID             uint                          `json:"rubricId"`
-	OverallFeedback      string                        `json:"overallFeedback"`
+	RubricID             uint                         `json:"rubricId"`
+	OverallFeedback      string                       `json:"overallFeedback"`
+	CriterionEvaluations []CriterionEvaluationRequest `json:"criterionEvaluations"`
+}
+
+// UpdateEvaluationRequest defines the payload for editing a draft evaluation.
+// When CriterionEvaluations is provided it replaces the existing scores entirely.
+type UpdateEvaluationRequest struct {
+	OverallFeedback      *string                      `json:"overallFeedback"`
 	CriterionEvaluations []CriterionEvaluationRequest `json:"criterionEvaluations"`
 }
 
@@ -39,7 +68,7 @@ type CriterionEvaluationRequest struct {
 	Feedback    string  `json:"feedback"`
 }
 
-// CreateEvaluation handles the complex business logic for creating a new evaluation.
+// CreateEvaluation validates the scores against the rubric and saves a new draft evaluation.
 func (s *EvaluationService) CreateEvaluation(taskID uint, evaluatorID uint, req CreateEvaluationRequest) (*models.Evaluation, error) {
 	// 1. Verify that the task exists.
 	_, err := s.TaskRepo.GetTaskByID(taskID)
@@ -47,48 +76,50 @@ func (s *EvaluationService) CreateEvaluation(taskID uint, evaluatorID uint, req
 		return nil, fmt.Errorf("task with ID %d not found", taskID)
 	}
 
-	// 2. Get the project and verify the evaluator is a teacher ('docente').
+	// 2. Get the project and verify the evaluator is allowed by the project's evaluation policy.
 	projectID, err := s.TaskRepo.GetProjectIDForTask(taskID)
 	if err != nil {
 		return nil, fmt.Errorf("could not find project for task %d", taskID)
 	}
-	role, err := s.ProjectService.GetUserRoleInProject(evaluatorID, projectID)
+	canEvaluate, err := s.ProjectService.CanEvaluate(evaluatorID, projectID)
 	if err != nil {
-		return nil, fmt.Errorf("could not verify user role in project")
+		return nil, fmt.Errorf("could not verify user role in project: %w", err)
 	}
-	if role != "docente" { // Assuming 'docente' is the role for teachers.
-		return nil, fmt.Errorf("user does not have permission to evaluate this task")
+	if !canEvaluate {
+		return nil, fmt.Errorf("forbidden: user does not have permission to evaluate this task")
 	}
 
-	// 3. Verify that the rubric exists and belongs to the same project.
+	// 3. Verify that the rubric exists, belongs to the same project and is usable.
 	rubric, err := s.RubricRepo.FindByID(req.RubricID)
 	if err != nil {
 		return nil, fmt.Errorf("rubric with ID %d not found", req.RubricID)
 	}
-	if rubric.ProjectID != projectID {
-		return nil, fmt.Errorf("rubric does not belong to the same project as the task")
+	if !rubric.BelongsToProject(projectID) {
+		return nil, fmt.Errorf("invalid evaluation: rubric does not belong to the same project as the task")
+	}
+	if rubric.Status != models.RubricStatusActive {
+		return nil, fmt.Errorf("invalid evaluation: rubric %d is not active", rubric.ID)
+	}
+	if rubric.SupersededAt != nil {
+		return nil, fmt.Errorf("invalid evaluation: rubric %d has been superseded by a newer version", rubric.ID)
+	}
+
+	// 4. Validate the scores sent so far. Drafts may leave criteria unscored.
+	if err := validateCriterionScores(rubric, req.CriterionEvaluations, false); err != nil {
+		return nil, err
 	}
 
-	// 4. Build the Evaluation model from the request.
+	// 5. Build the Evaluation model from the request.
 	evaluation := &models.Evaluation{
-		TaskID:          taskID,
-		EvaluatorID:     evaluatorID,
-		RubricID:        req.RubricID,
-		OverallFeedback: req.OverallFeedback,
-		Status:          "published", // Or could be 'draft' initially
-	}
-
-	// 5. Process criterion evaluations and calculate total score.
-	var totalScore float64
-	for _, critEvalReq := range req.CriterionEvaluations {
-		evaluation.CriterionEvaluations = append(evaluation.CriterionEvaluations, models.CriterionEvaluation{
-			CriterionID: critEvalReq.CriterionID,
-			Score:       critEvalReq.Score,
-			Feedback:    critEvalReq.Feedback,
-		})
-		totalScore += critEvalReq.Score
+		Type:                 models.EvaluationTypeTask,
+		TaskID:               &taskID,
+		EvaluatorID:          evaluatorID,
+		RubricID:             req.RubricID,
+		OverallFeedback:      req.OverallFeedback,
+		Status:               models.EvaluationStatusDraft,
+		CriterionEvaluations: buildCriterionEvaluations(req.CriterionEvaluations),
 	}
-	evaluation.TotalScore = totalScore
+	evaluation.TotalScore = totalScore(evaluation.CriterionEvaluations)
 
 	// 6. Save the evaluation to the database.
 	if err := s.EvalRepo.CreateEvaluation(evaluation); err != nil {
@@ -98,7 +129,796 @@ func (s *EvaluationService) CreateEvaluation(taskID uint, evaluatorID uint, req
 	return evaluation, nil
 }
 
-// GetEvaluationsByTaskID retrieves all evaluations for a specific task.
-func (s *EvaluationService) GetEvaluationsByTaskID(taskID uint) ([]models.Evaluation, error) {
-	return s.EvalRepo.GetEvaluationsByTaskID(taskID)
+// GetEvaluationByID retrieves a single evaluation. Peer and self evaluations are
+// only visible to their evaluator and to users allowed to evaluate the project;
+// reviewees read them through GetReceivedEvaluations, which honours anonymity.
+// Task evaluations are visible to everyone once published, and to the same users before.
+func (s *EvaluationService) GetEvaluationByID(id, userID uint) (*models.Evaluation, error) {
+	evaluation, err := s.EvalRepo.GetEvaluationByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation with ID %d not found", id)
+	}
+	if evaluation.TaskID != nil && evaluation.Status != models.EvaluationStatusPublished && evaluation.EvaluatorID != userID {
+		projectID, err := s.TaskRepo.GetProjectIDForTask(*evaluation.TaskID)
+		if err != nil {
+			return nil, fmt.Errorf("task with ID %d not found", *evaluation.TaskID)
+		}
+		if err := s.requireEvaluator(userID, projectID); err != nil {
+			return nil, err
+		}
+	}
+	if evaluation.RoundID != nil && evaluation.EvaluatorID != userID {
+		round, err := s.EvalRepo.GetRoundByID(*evaluation.RoundID)
+		if err != nil {
+			return nil, fmt.Errorf("evaluation round with ID %d not found", *evaluation.RoundID)
+		}
+		if err := s.requireEvaluator(userID, round.ProjectID); err != nil {
+			return nil, err
+		}
+	}
+	return evaluation, nil
+}
+
+// UpdateEvaluation edits a draft evaluation. Only the original evaluator may edit it.
+func (s *EvaluationService) UpdateEvaluation(evaluationID, userID uint, req UpdateEvaluationRequest) (*models.Evaluation, error) {
+	evaluation, err := s.getOwnEvaluation(evaluationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if evaluation.Status != models.EvaluationStatusDraft {
+		return nil, fmt.Errorf("invalid transition: only draft evaluations can be edited (current status: %s)", evaluation.Status)
+	}
+	if err := s.ensureRoundOpen(evaluation); err != nil {
+		return nil, err
+	}
+
+	rubric, err := s.usableRubric(evaluation)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.OverallFeedback != nil {
+		evaluation.OverallFeedback = *req.OverallFeedback
+	}
+	if req.CriterionEvaluations != nil {
+		if err := validateCriterionScores(rubric, req.CriterionEvaluations, false); err != nil {
+			return nil, err
+		}
+		evaluation.CriterionEvaluations = buildCriterionEvaluations(req.CriterionEvaluations)
+	}
+	evaluation.TotalScore = totalScore(evaluation.CriterionEvaluations)
+
+	if err := s.EvalRepo.UpdateEvaluation(evaluation); err != nil {
+		return nil, fmt.Errorf("could not update evaluation: %w", err)
+	}
+	return s.EvalRepo.GetEvaluationByID(evaluation.ID)
+}
+
+// SubmitEvaluation moves a draft to the submitted state once every criterion is scored.
+func (s *EvaluationService) SubmitEvaluation(evaluationID, userID uint) (*models.Evaluation, error) {
+	evaluation, err := s.getOwnEvaluation(evaluationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if evaluation.Status != models.EvaluationStatusDraft {
+		return nil, fmt.Errorf("invalid transition: only draft evaluations can be submitted (current status: %s)", evaluation.Status)
+	}
+	if err := s.ensureRoundOpen(evaluation); err != nil {
+		return nil, err
+	}
+
+	rubric, err := s.usableRubric(evaluation)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCriterionScores(rubric, criterionRequestsFrom(evaluation.CriterionEvaluations), true); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	evaluation.Status = models.EvaluationStatusSubmitted
+	evaluation.SubmittedAt = &now
+	if err := s.EvalRepo.UpdateEvaluationStatus(evaluation); err != nil {
+		return nil, fmt.Errorf("could not submit evaluation: %w", err)
+	}
+	s.publishTaskEvaluation(evaluation)
+	return evaluation, nil
+}
+
+// PublishEvaluation makes a submitted evaluation visible and notifies the task's assignee.
+func (s *EvaluationService) PublishEvaluation(evaluationID, userID uint) (*models.Evaluation, error) {
+	evaluation, err := s.getOwnEvaluation(evaluationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if evaluation.RoundID != nil {
+		return nil, fmt.Errorf("invalid transition: peer and self evaluations are published when their round is closed")
+	}
+	if evaluation.Status != models.EvaluationStatusSubmitted {
+		return nil, fmt.Errorf("invalid transition: only submitted evaluations can be published (current status: %s)", evaluation.Status)
+	}
+
+	now := time.Now()
+	evaluation.Status = models.EvaluationStatusPublished
+	evaluation.PublishedAt = &now
+	if err := s.EvalRepo.UpdateEvaluationStatus(evaluation); err != nil {
+		return nil, fmt.Errorf("could not publish evaluation: %w", err)
+	}
+	s.publishTaskEvaluation(evaluation)
+
+	// --- Create Notification ---
+	if evaluation.Task != nil && evaluation.Task.AssignedToID != nil {
+		message := fmt.Sprintf("Se ha publicado la evaluación de la tarea '%s'.", evaluation.Task.Title)
+		link := fmt.Sprintf("/tasks/%d", evaluation.Task.ID)
+		if _, err := s.NotificationService.CreateNotification(*evaluation.Task.AssignedToID, message, link); err != nil {
+			log.Printf("could not create notification for published evaluation: %v", err)
+		}
+	}
+	// --- End Notification ---
+
+	return evaluation, nil
+}
+
+// publishTaskEvaluation announces the new status of a task evaluation to the project.
+func (s *EvaluationService) publishTaskEvaluation(evaluation *models.Evaluation) {
+	if evaluation.TaskID == nil {
+		return
+	}
+	projectID, err := s.TaskRepo.GetProjectIDForTask(*evaluation.TaskID)
+	if err != nil {
+		log.Printf("could not get project of evaluation %d: %v", evaluation.ID, err)
+		return
+	}
+	s.Events.Publish(projectID, TaskEvaluationChanged{EvaluationID: evaluation.ID, TaskID: *evaluation.TaskID, Status: evaluation.Status})
+}
+
+// GetEvaluationsByTaskID retrieves the evaluations of a task the user may see: all of
+// them for users allowed to evaluate the project, otherwise the published ones and
+// the user's own.
+func (s *EvaluationService) GetEvaluationsByTaskID(taskID, userID uint) ([]models.Evaluation, error) {
+	projectID, err := s.TaskRepo.GetProjectIDForTask(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task with ID %d not found", taskID)
+	}
+	evaluations, err := s.EvalRepo.GetEvaluationsByTaskID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	canEvaluate, err := s.ProjectService.CanEvaluate(userID, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify user role in project: %w", err)
+	}
+	if canEvaluate {
+		return evaluations, nil
+	}
+
+	visible := make([]models.Evaluation, 0, len(evaluations))
+	for _, evaluation := range evaluations {
+		if evaluation.Status == models.EvaluationStatusPublished || evaluation.EvaluatorID == userID {
+			visible = append(visible, evaluation)
+		}
+	}
+	return visible, nil
+}
+
+// --- Peer and self evaluation rounds ---
+
+// outlierFraction is the share of a rubric's maximum score by which a peer score
+// may deviate from the median of the scores a member received before it is flagged.
+const outlierFraction = 0.25
+
+// CreateEvaluationRoundRequest defines the payload for opening an evaluation round.
+// PeersPerMember defaults to every other participant when omitted.
+type CreateEvaluationRoundRequest struct {
+	Name           string     `json:"name"`
+	RubricID       uint       `json:"rubricId"`
+	Anonymous      bool       `json:"anonymous"`
+	PeersPerMember int        `json:"peersPerMember"`
+	DueDate        *time.Time `json:"dueDate"`
+}
+
+// CreateRoundEvaluationRequest defines the payload for a peer or self evaluation.
+// An EvaluateeID equal to the evaluator's own ID creates a self-assessment.
+type CreateRoundEvaluationRequest struct {
+	EvaluateeID          uint                         `json:"evaluateeId"`
+	OverallFeedback      string                       `json:"overallFeedback"`
+	CriterionEvaluations []CriterionEvaluationRequest `json:"criterionEvaluations"`
+}
+
+// ReceivedEvaluation is a published peer or self evaluation as seen by its evaluatee.
+// EvaluatorID and EvaluatorName are omitted when the round is anonymous.
+type ReceivedEvaluation struct {
+	EvaluationID         uint                         `json:"evaluationId"`
+	Type                 models.EvaluationType        `json:"type"`
+	EvaluatorID          *uint                        `json:"evaluatorId,omitempty"`
+	EvaluatorName        string                       `json:"evaluatorName,omitempty"`
+	TotalScore           float64                      `json:"totalScore"`
+	OverallFeedback      string                       `json:"overallFeedback"`
+	CriterionEvaluations []CriterionEvaluationRequest `json:"criterionEvaluations"`
+}
+
+// OutlierScore is a peer score that deviates notably from the rest received by a member.
+type OutlierScore struct {
+	EvaluationID uint    `json:"evaluationId"`
+	EvaluatorID  uint    `json:"evaluatorId"`
+	Score        float64 `json:"score"`
+	Deviation    float64 `json:"deviation"` // Score minus the median of the member's peer scores
+}
+
+// MemberRoundResult aggregates the peer and self evaluations received by a member.
+type MemberRoundResult struct {
+	UserID              uint           `json:"userId"`
+	Name                string         `json:"name"`
+	ExpectedPeerReviews int            `json:"expectedPeerReviews"`
+	PeerReviewCount     int            `json:"peerReviewCount"`
+	PeerAverage         *float64       `json:"peerAverage"`
+	AdjustedPeerAverage *float64       `json:"adjustedPeerAverage"` // Peer average excluding outliers
+	SelfScore           *float64       `json:"selfScore"`
+	SelfPeerGap         *float64       `json:"selfPeerGap"` // SelfScore minus PeerAverage
+	Outliers            []OutlierScore `json:"outliers"`
+}
+
+// EvaluationRoundResults is the per-member aggregation of a round.
+type EvaluationRoundResults struct {
+	RoundID          uint                `json:"roundId"`
+	Status           string              `json:"status"`
+	MaxScore         float64             `json:"maxScore"`
+	OutlierThreshold float64             `json:"outlierThreshold"`
+	Members          []MemberRoundResult `json:"members"`
+}
+
+// CreateEvaluationRound opens a peer evaluation round for a sprint. Every project member
+// except instructors participates and is assigned PeersPerMember peers to review.
+func (s *EvaluationService) CreateEvaluationRound(sprintID, creatorID uint, req CreateEvaluationRoundRequest) (*models.EvaluationRound, error) {
+	sprint, err := s.SprintRepo.GetSprintByID(sprintID)
+	if err != nil {
+		return nil, fmt.Errorf("sprint with ID %d not found", sprintID)
+	}
+	if err := s.requireEvaluator(creatorID, sprint.ProjectID); err != nil {
+		return nil, err
+	}
+
+	rubric, err := s.RubricRepo.FindByID(req.RubricID)
+	if err != nil {
+		return nil, fmt.Errorf("rubric with ID %d not found", req.RubricID)
+	}
+	if !rubric.BelongsToProject(sprint.ProjectID) {
+		return nil, fmt.Errorf("invalid evaluation round: rubric does not belong to the sprint's project")
+	}
+	if rubric.Status != models.RubricStatusActive {
+		return nil, fmt.Errorf("invalid evaluation round: rubric %d is not active", rubric.ID)
+	}
+	if rubric.SupersededAt != nil {
+		return nil, fmt.Errorf("invalid evaluation round: rubric %d has been superseded by a newer version", rubric.ID)
+	}
+
+	participants, err := s.roundParticipants(sprint.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(participants) < 2 {
+		return nil, fmt.Errorf("invalid evaluation round: at least two participating members are required")
+	}
+
+	peersPerMember := req.PeersPerMember
+	if peersPerMember <= 0 {
+		peersPerMember = len(participants) - 1
+	}
+	if peersPerMember > len(participants)-1 {
+		return nil, fmt.Errorf("invalid evaluation round: peersPerMember cannot exceed %d", len(participants)-1)
+	}
+
+	name := req.Name
+	if name == "" {
+		name = fmt.Sprintf("Evaluación de pares - %s", sprint.Name)
+	}
+
+	round := &models.EvaluationRound{
+		Name:           name,
+		SprintID:       sprint.ID,
+		ProjectID:      sprint.ProjectID,
+		RubricID:       rubric.ID,
+		Anonymous:      req.Anonymous,
+		PeersPerMember: peersPerMember,
+		Status:         models.EvaluationRoundStatusOpen,
+		DueDate:        req.DueDate,
+		CreatedByID:    creatorID,
+		Assignments:    assignPeers(participants, peersPerMember),
+	}
+	if err := s.EvalRepo.CreateRound(round); err != nil {
+		return nil, fmt.Errorf("could not create evaluation round: %w", err)
+	}
+	s.Events.Publish(round.ProjectID, EvaluationRoundOpened{RoundID: round.ID, SprintID: round.SprintID, Name: round.Name})
+
+	// --- Create Notifications ---
+	message := fmt.Sprintf("Se ha abierto la ronda de evaluación '%s'.", round.Name)
+	link := fmt.Sprintf("/evaluation-rounds/%d", round.ID)
+	for _, userID := range participants {
+		if _, err := s.NotificationService.CreateNotification(userID, message, link); err != nil {
+			log.Printf("could not create notification for evaluation round %d: %v", round.ID, err)
+		}
+	}
+	// --- End Notifications ---
+
+	return s.EvalRepo.GetRoundByID(round.ID)
+}
+
+// GetEvaluationRoundsBySprintID lists the evaluation rounds of a sprint to a member of its project.
+func (s *EvaluationService) GetEvaluationRoundsBySprintID(sprintID, userID uint) ([]models.EvaluationRound, error) {
+	sprint, err := s.SprintRepo.GetSprintByID(sprintID)
+	if err != nil {
+		return nil, fmt.Errorf("sprint with ID %d not found", sprintID)
+	}
+	if _, err := s.ProjectService.GetUserRoleInProject(userID, sprint.ProjectID); err != nil {
+		return nil, fmt.Errorf("forbidden: you are not a member of this project")
+	}
+	return s.EvalRepo.GetRoundsBySprintID(sprintID)
+}
+
+// GetEvaluationRound retrieves a round. Users allowed to evaluate the project see every
+// assignment; participants only see the peers they have to review.
+func (s *EvaluationService) GetEvaluationRound(roundID, userID uint) (*models.EvaluationRound, error) {
+	round, err := s.EvalRepo.GetRoundByID(roundID)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation round with ID %d not found", roundID)
+	}
+	if s.requireEvaluator(userID, round.ProjectID) == nil {
+		return round, nil
+	}
+	if !isRoundParticipant(round, userID) {
+		return nil, fmt.Errorf("forbidden: you do not participate in this evaluation round")
+	}
+
+	own := make([]models.PeerReviewAssignment, 0, round.PeersPerMember)
+	for _, assignment := range round.Assignments {
+		if assignment.ReviewerID == userID {
+			own = append(own, assignment)
+		}
+	}
+	round.Assignments = own
+	return round, nil
+}
+
+// CreateRoundEvaluation starts a draft peer evaluation of an assigned reviewee, or a
+// self-assessment when the evaluatee is the evaluator. Drafts are then edited and
+// submitted through UpdateEvaluation and SubmitEvaluation.
+func (s *EvaluationService) CreateRoundEvaluation(roundID, evaluatorID uint, req CreateRoundEvaluationRequest) (*models.Evaluation, error) {
+	round, err := s.EvalRepo.GetRoundByID(roundID)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation round with ID %d not found", roundID)
+	}
+	if round.Status != models.EvaluationRoundStatusOpen {
+		return nil, fmt.Errorf("invalid transition: evaluation round %d is closed", round.ID)
+	}
+
+	evaluationType := models.EvaluationTypePeer
+	if req.EvaluateeID == evaluatorID {
+		evaluationType = models.EvaluationTypeSelf
+		if !isRoundParticipant(round, evaluatorID) {
+			return nil, fmt.Errorf("forbidden: you do not participate in this evaluation round")
+		}
+	} else {
+		assigned, err := s.EvalRepo.IsReviewAssigned(round.ID, evaluatorID, req.EvaluateeID)
+		if err != nil {
+			return nil, fmt.Errorf("could not verify review assignment: %w", err)
+		}
+		if !assigned {
+			return nil, fmt.Errorf("forbidden: you are not assigned to review this member")
+		}
+	}
+
+	rubric, err := s.RubricRepo.FindByID(round.RubricID)
+	if err != nil {
+		return nil, fmt.Errorf("rubric with ID %d not found", round.RubricID)
+	}
+	if err := validateCriterionScores(rubric, req.CriterionEvaluations, false); err != nil {
+		return nil, err
+	}
+
+	evaluateeID := req.EvaluateeID
+	evaluation := &models.Evaluation{
+		Type:                 evaluationType,
+		RoundID:              &round.ID,
+		EvaluateeID:          &evaluateeID,
+		EvaluatorID:          evaluatorID,
+		RubricID:             round.RubricID,
+		OverallFeedback:      req.OverallFeedback,
+		Status:               models.EvaluationStatusDraft,
+		CriterionEvaluations: buildCriterionEvaluations(req.CriterionEvaluations),
+	}
+	evaluation.TotalScore = totalScore(evaluation.CriterionEvaluations)
+
+	if err := s.EvalRepo.CreateEvaluation(evaluation); err != nil {
+		return nil, fmt.Errorf("could not save evaluation: %w", err)
+	}
+	return evaluation, nil
+}
+
+// CloseEvaluationRound stops a round from accepting evaluations and publishes the
+// submitted ones. Drafts that were never submitted are left out of the results.
+func (s *EvaluationService) CloseEvaluationRound(roundID, userID uint) (*models.EvaluationRound, error) {
+	round, err := s.EvalRepo.GetRoundByID(roundID)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation round with ID %d not found", roundID)
+	}
+	if err := s.requireEvaluator(userID, round.ProjectID); err != nil {
+		return nil, err
+	}
+	if round.Status != models.EvaluationRoundStatusOpen {
+		return nil, fmt.Errorf("invalid transition: evaluation round %d is already closed", round.ID)
+	}
+
+	if err := s.EvalRepo.PublishRoundEvaluations(round.ID, time.Now()); err != nil {
+		return nil, fmt.Errorf("could not close evaluation round: %w", err)
+	}
+	round.Status = models.EvaluationRoundStatusClosed
+	s.Events.Publish(round.ProjectID, EvaluationRoundClosed{RoundID: round.ID, SprintID: round.SprintID})
+
+	// --- Create Notifications ---
+	message := fmt.Sprintf("Los resultados de la ronda de evaluación '%s' están disponibles.", round.Name)
+	link := fmt.Sprintf("/evaluation-rounds/%d", round.ID)
+	for _, userID := range participantIDs(round) {
+		if _, err := s.NotificationService.CreateNotification(userID, message, link); err != nil {
+			log.Printf("could not create notification for evaluation round %d: %v", round.ID, err)
+		}
+	}
+	// --- End Notifications ---
+
+	return round, nil
+}
+
+// GetReceivedEvaluations returns the published evaluations a member received in a round,
+// hiding reviewer identities when the round is anonymous.
+func (s *EvaluationService) GetReceivedEvaluations(roundID, userID uint) ([]ReceivedEvaluation, error) {
+	round, err := s.EvalRepo.GetRoundByID(roundID)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation round with ID %d not found", roundID)
+	}
+	if !isRoundParticipant(round, userID) {
+		return nil, fmt.Errorf("forbidden: you do not participate in this evaluation round")
+	}
+
+	evaluations, err := s.EvalRepo.GetRoundEvaluations(round.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	received := make([]ReceivedEvaluation, 0)
+	for _, evaluation := range evaluations {
+		if evaluation.EvaluateeID == nil || *evaluation.EvaluateeID != userID || evaluation.Status != models.EvaluationStatusPublished {
+			continue
+		}
+		item := ReceivedEvaluation{
+			EvaluationID:         evaluation.ID,
+			Type:                 evaluation.Type,
+			TotalScore:           evaluation.TotalScore,
+			OverallFeedback:      evaluation.OverallFeedback,
+			CriterionEvaluations: criterionRequestsFrom(evaluation.CriterionEvaluations),
+		}
+		if !round.Anonymous || evaluation.Type == models.EvaluationTypeSelf {
+			evaluatorID := evaluation.EvaluatorID
+			item.EvaluatorID = &evaluatorID
+			item.EvaluatorName = fullName(evaluation.Evaluator)
+		}
+		received = append(received, item)
+	}
+	return received, nil
+}
+
+// GetEvaluationRoundResults aggregates submitted and published evaluations per member.
+// A peer score is flagged as an outlier when the member received at least three peer
+// scores and it deviates from their median by more than outlierFraction of the rubric's
+// maximum score; outliers are excluded from AdjustedPeerAverage.
+func (s *EvaluationService) GetEvaluationRoundResults(roundID, userID uint) (*EvaluationRoundResults, error) {
+	round, err := s.EvalRepo.GetRoundByID(roundID)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation round with ID %d not found", roundID)
+	}
+	if err := s.requireEvaluator(userID, round.ProjectID); err != nil {
+		return nil, err
+	}
+
+	rubric, err := s.RubricRepo.FindByID(round.RubricID)
+	if err != nil {
+		return nil, fmt.Errorf("rubric with ID %d not found", round.RubricID)
+	}
+	var maxScore float64
+	for _, criterion := range rubric.Criteria {
+		maxScore += criterion.MaxPoints
+	}
+
+	evaluations, err := s.EvalRepo.GetRoundEvaluations(round.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &EvaluationRoundResults{
+		RoundID:          round.ID,
+		Status:           string(round.Status),
+		MaxScore:         maxScore,
+		OutlierThreshold: maxScore * outlierFraction,
+		Members:          make([]MemberRoundResult, 0),
+	}
+
+	members := make(map[uint]*MemberRoundResult)
+	for _, assignment := range round.Assignments {
+		member, ok := members[assignment.RevieweeID]
+		if !ok {
+			member = &MemberRoundResult{UserID: assignment.RevieweeID, Name: fullName(assignment.Reviewee), Outliers: []OutlierScore{}}
+			members[assignment.RevieweeID] = member
+		}
+		member.ExpectedPeerReviews++
+	}
+
+	peerScores := make(map[uint][]models.Evaluation)
+	for _, evaluation := range evaluations {
+		if evaluation.EvaluateeID == nil || evaluation.Status == models.EvaluationStatusDraft {
+			continue
+		}
+		member, ok := members[*evaluation.EvaluateeID]
+		if !ok {
+			continue
+		}
+		if evaluation.Type == models.EvaluationTypeSelf {
+			score := evaluation.TotalScore
+			member.SelfScore = &score
+			continue
+		}
+		peerScores[member.UserID] = append(peerScores[member.UserID], evaluation)
+	}
+
+	for _, userID := range participantIDs(round) {
+		member := members[userID]
+		received := peerScores[userID]
+		member.PeerReviewCount = len(received)
+		if len(received) > 0 {
+			scores := make([]float64, 0, len(received))
+			for _, evaluation := range received {
+				scores = append(scores, evaluation.TotalScore)
+			}
+			average := mean(scores)
+			member.PeerAverage = &average
+
+			kept := scores
+			if len(scores) >= 3 {
+				center := median(scores)
+				kept = make([]float64, 0, len(scores))
+				for _, evaluation := range received {
+					deviation := evaluation.TotalScore - center
+					if math.Abs(deviation) > results.OutlierThreshold {
+						member.Outliers = append(member.Outliers, OutlierScore{
+							EvaluationID: evaluation.ID,
+							EvaluatorID:  evaluation.EvaluatorID,
+							Score:        evaluation.TotalScore,
+							Deviation:    deviation,
+						})
+						continue
+					}
+					kept = append(kept, evaluation.TotalScore)
+				}
+			}
+			adjusted := mean(kept)
+			member.AdjustedPeerAverage = &adjusted
+
+			if member.SelfScore != nil {
+				gap := *member.SelfScore - average
+				member.SelfPeerGap = &gap
+			}
+		}
+		results.Members = append(results.Members, *member)
+	}
+
+	return results, nil
+}
+
+// requireEvaluator checks that a user may evaluate deliverables of a project.
+func (s *EvaluationService) requireEvaluator(userID, projectID uint) error {
+	canEvaluate, err := s.ProjectService.CanEvaluate(userID, projectID)
+	if err != nil {
+		return fmt.Errorf("could not verify user role in project: %w", err)
+	}
+	if !canEvaluate {
+		return fmt.Errorf("forbidden: you do not have permission to manage evaluations in this project")
+	}
+	return nil
+}
+
+// usableRubric reloads the rubric of a draft and rejects it if it was deactivated, or if
+// a newer version superseded it, since the draft was created. Peer and self evaluations
+// keep the rubric their round was opened with.
+func (s *EvaluationService) usableRubric(evaluation *models.Evaluation) (*models.Rubric, error) {
+	rubric, err := s.RubricRepo.FindByID(evaluation.RubricID)
+	if err != nil {
+		return nil, fmt.Errorf("rubric with ID %d not found", evaluation.RubricID)
+	}
+	if rubric.Status != models.RubricStatusActive {
+		return nil, fmt.Errorf("invalid evaluation: rubric %d is not active", rubric.ID)
+	}
+	if evaluation.RoundID == nil && rubric.SupersededAt != nil {
+		return nil, fmt.Errorf("invalid evaluation: rubric %d has been superseded by a newer version", rubric.ID)
+	}
+	return rubric, nil
+}
+
+// ensureRoundOpen rejects changes to peer and self evaluations of a closed round.
+func (s *EvaluationService) ensureRoundOpen(evaluation *models.Evaluation) error {
+	if evaluation.RoundID == nil {
+		return nil
+	}
+	round, err := s.EvalRepo.GetRoundByID(*evaluation.RoundID)
+	if err != nil {
+		return fmt.Errorf("evaluation round with ID %d not found", *evaluation.RoundID)
+	}
+	if round.Status != models.EvaluationRoundStatusOpen {
+		return fmt.Errorf("invalid transition: evaluation round %d is closed", round.ID)
+	}
+	return nil
+}
+
+// roundParticipants returns the IDs of the project members taking part in peer
+// evaluation (everyone but instructors), sorted for a stable assignment.
+func (s *EvaluationService) roundParticipants(projectID uint) ([]uint, error) {
+	members, err := s.ProjectService.GetProjectMembers(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load project members: %w", err)
+	}
+	participants := make([]uint, 0, len(members))
+	for _, member := range members {
+		if models.ProjectRole(member.Role) != models.RoleInstructor {
+			participants = append(participants, member.UserID)
+		}
+	}
+	sort.Slice(participants, func(i, j int) bool { return participants[i] < participants[j] })
+	return participants, nil
+}
+
+// assignPeers assigns each participant the next peersPerMember participants in a
+// circular order, so every member reviews and is reviewed by exactly peersPerMember peers.
+func assignPeers(participants []uint, peersPerMember int) []models.PeerReviewAssignment {
+	assignments := make([]models.PeerReviewAssignment, 0, len(participants)*peersPerMember)
+	for i, reviewerID := range participants {
+		for offset := 1; offset <= peersPerMember; offset++ {
+			assignments = append(assignments, models.PeerReviewAssignment{
+				ReviewerID: reviewerID,
+				RevieweeID: participants[(i+offset)%len(participants)],
+			})
+		}
+	}
+	return assignments
+}
+
+// isRoundParticipant reports whether a user reviews or is reviewed in a round.
+func isRoundParticipant(round *models.EvaluationRound, userID uint) bool {
+	for _, assignment := range round.Assignments {
+		if assignment.ReviewerID == userID || assignment.RevieweeID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// participantIDs returns the distinct reviewees of a round in ascending order.
+func participantIDs(round *models.EvaluationRound) []uint {
+	seen := make(map[uint]bool)
+	ids := make([]uint, 0)
+	for _, assignment := range round.Assignments {
+		if !seen[assignment.RevieweeID] {
+			seen[assignment.RevieweeID] = true
+			ids = append(ids, assignment.RevieweeID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func fullName(user models.User) string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s", user.Nombre, user.ApellidoPaterno))
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// getOwnEvaluation loads an evaluation and checks that it belongs to the given evaluator.
+func (s *EvaluationService) getOwnEvaluation(evaluationID, userID uint) (*models.Evaluation, error) {
+	evaluation, err := s.EvalRepo.GetEvaluationByID(evaluationID)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation with ID %d not found", evaluationID)
+	}
+	if evaluation.EvaluatorID != userID {
+		return nil, fmt.Errorf("forbidden: only the evaluator can modify this evaluation")
+	}
+	return evaluation, nil
+}
+
+// validateCriterionScores checks each score against its criterion's MaxPoints and levels.
+// When requireAll is true, every criterion of the rubric must be scored exactly once.
+func validateCriterionScores(rubric *models.Rubric, reqs []CriterionEvaluationRequest, requireAll bool) error {
+	criteria := make(map[uint]models.RubricCriterion, len(rubric.Criteria))
+	for _, criterion := range rubric.Criteria {
+		criteria[criterion.ID] = criterion
+	}
+
+	scored := make(map[uint]bool, len(reqs))
+	for _, req := range reqs {
+		criterion, ok := criteria[req.CriterionID]
+		if !ok {
+			return fmt.Errorf("invalid evaluation: criterion %d does not belong to rubric %d", req.CriterionID, rubric.ID)
+		}
+		if scored[req.CriterionID] {
+			return fmt.Errorf("invalid evaluation: criterion '%s' is scored more than once", criterion.Title)
+		}
+		scored[req.CriterionID] = true
+
+		if req.Score < 0 || req.Score > criterion.MaxPoints {
+			return fmt.Errorf("invalid evaluation: score %.2f for criterion '%s' must be between 0 and %.2f", req.Score, criterion.Title, criterion.MaxPoints)
+		}
+		if len(criterion.Levels) > 0 && !matchesLevel(criterion.Levels, req.Score) {
+			return fmt.Errorf("invalid evaluation: score %.2f for criterion '%s' does not match any of its levels", req.Score, criterion.Title)
+		}
+	}
+
+	if requireAll {
+		for _, criterion := range rubric.Criteria {
+			if !scored[criterion.ID] {
+				return fmt.Errorf("invalid evaluation: criterion '%s' has not been scored", criterion.Title)
+			}
+		}
+	}
+	return nil
+}
+
+// matchesLevel reports whether a score corresponds to one of the criterion's levels.
+func matchesLevel(levels []models.RubricCriterionLevel, score float64) bool {
+	for _, level := range levels {
+		if level.Score == score {
+			return true
+		}
+	}
+	return false
+}
+
+func buildCriterionEvaluations(reqs []CriterionEvaluationRequest) []models.CriterionEvaluation {
+	criterionEvaluations := make([]models.CriterionEvaluation, 0, len(reqs))
+	for _, req := range reqs {
+		criterionEvaluations = append(criterionEvaluations, models.CriterionEvaluation{
+			CriterionID: req.CriterionID,
+			Score:       req.Score,
+			Feedback:    req.Feedback,
+		})
+	}
+	return criterionEvaluations
+}
+
+func criterionRequestsFrom(criterionEvaluations []models.CriterionEvaluation) []CriterionEvaluationRequest {
+	reqs := make([]CriterionEvaluationRequest, 0, len(criterionEvaluations))
+	for _, ce := range criterionEvaluations {
+		reqs = append(reqs, CriterionEvaluationRequest{CriterionID: ce.CriterionID, Score: ce.Score, Feedback: ce.Feedback})
+	}
+	return reqs
+}
+
+func totalScore(criterionEvaluations []models.CriterionEvaluation) float64 {
+	var total float64
+	for _, ce := range criterionEvaluations {
+		total += ce.Score
+	}
+	return total
 }
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 type EventService struct {
 	EventRepo      *storage.EventRepository
 	ProjectService *ProjectService // To check user roles
+	Events         *EventBus       // Optional, set once at startup
 }
 
 // NewEventService creates a new instance of EventService.
@@ -22,6 +24,14 @@ func NewEventService(eventRepo *storage.EventRepository, projectService *Project
 	}
 }
 
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *EventService) WithContext(ctx context.Context) *EventService {
+	scoped := *s
+	scoped.EventRepo = s.EventRepo.WithContext(ctx)
+	scoped.Events = s.Events.WithContext(ctx)
+	return &scoped
+}
+
 // checkUserPermission is a helper to verify if a user is a member of the project.
 func (s *EventService) checkUserPermission(userID, projectID uint) error {
 	_, err := s.ProjectService.GetUserRoleInProject(userID, projectID)
@@ -46,7 +56,12 @@ func (s *EventService) CreateEvent(event *models.Event, projectID, creatorID uin
 	if err := s.EventRepo.Create(event); err != nil {
 		return nil, fmt.Errorf("could not create event: %w", err)
 	}
-	return s.EventRepo.FindByID(event.ID) // Return hydrated event
+	created, err := s.EventRepo.FindByID(event.ID) // Return hydrated event
+	if err != nil {
+		return nil, err
+	}
+	s.Events.Publish(projectID, CalendarEventCreated{Event: created})
+	return created, nil
 }
 
 // GetEventByID retrieves a single event, checking for permissions.
@@ -91,6 +106,7 @@ func (s *EventService) UpdateEvent(eventID, userID uint, updates map[string]inte
 	if err := s.EventRepo.Update(event); err != nil {
 		return nil, fmt.Errorf("could not update event: %w", err)
 	}
+	s.Events.Publish(event.ProjectID, CalendarEventUpdated{Event: event})
 	return event, nil
 }
 
@@ -103,5 +119,9 @@ func (s *EventService) DeleteEvent(eventID, userID uint) error {
 	if err := s.checkUserPermission(userID, event.ProjectID); err != nil {
 		return err
 	}
-	return s.EventRepo.Delete(eventID)
+	if err := s.EventRepo.Delete(eventID); err != nil {
+		return err
+	}
+	s.Events.Publish(event.ProjectID, CalendarEventDeleted{EventID: eventID})
+	return nil
 }
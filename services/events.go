@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+// DomainEvent is a change made through a service. Every event has a name, which is also
+// the type of the WebSocket message that announces it.
+type DomainEvent interface {
+	EventName() string
+}
+
+// PublishedEvent is a DomainEvent as handed to the subscribers of the EventBus. It is
+// about a project, or meant for a single user when UserID is set instead.
+type PublishedEvent struct {
+	ProjectID  uint
+	UserID     uint
+	ActorID    uint // User who made the change; 0 when it is not known
+	OccurredAt time.Time
+	Event      DomainEvent
+}
+
+// EventBus hands the domain events published by the services to its subscribers, e.g.
+// the WebSocket layer. Subscribers run synchronously, after the change is saved, and
+// must not block. A nil bus drops every event, so services work without one.
+type EventBus struct {
+	subscribers *eventSubscribers // Shared by the bus and its scoped copies
+	actorID     uint
+}
+
+type eventSubscribers struct {
+	mutex    sync.RWMutex
+	handlers []func(PublishedEvent)
+}
+
+// NewEventBus creates a new EventBus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: &eventSubscribers{}}
+}
+
+// WithContext returns a copy of the bus that attributes the events it publishes to the
+// actor of the request (see storage.AuditActor).
+func (b *EventBus) WithContext(ctx context.Context) *EventBus {
+	if b == nil {
+		return nil
+	}
+	scoped := *b
+	if actor, ok := storage.AuditActorFromContext(ctx); ok {
+		scoped.actorID = actor.UserID
+	}
+	return &scoped
+}
+
+// Subscribe registers a handler for every event published from now on.
+func (b *EventBus) Subscribe(handler func(PublishedEvent)) {
+	b.subscribers.mutex.Lock()
+	b.subscribers.handlers = append(b.subscribers.handlers, handler)
+	b.subscribers.mutex.Unlock()
+}
+
+// Publish hands an event of a project to every subscriber.
+func (b *EventBus) Publish(projectID uint, event DomainEvent) {
+	if b == nil || projectID == 0 {
+		return
+	}
+	b.publish(PublishedEvent{ProjectID: projectID, Event: event})
+}
+
+// PublishToUser hands an event meant for a single user to every subscriber.
+func (b *EventBus) PublishToUser(userID uint, event DomainEvent) {
+	if b == nil || userID == 0 {
+		return
+	}
+	b.publish(PublishedEvent{UserID: userID, Event: event})
+}
+
+func (b *EventBus) publish(published PublishedEvent) {
+	published.ActorID = b.actorID
+	published.OccurredAt = time.Now().UTC()
+
+	b.subscribers.mutex.RLock()
+	handlers := b.subscribers.handlers
+	b.subscribers.mutex.RUnlock()
+	for _, handle := range handlers {
+		handle(published)
+	}
+}
+
+// --- Tasks ---
+
+// TaskCreated is published when a task is created, with its assignee if it was given one.
+type TaskCreated struct {
+	Task *models.Task `json:"task"`
+}
+
+// TaskUpdated is published when the details of a task are edited.
+type TaskUpdated struct {
+	Task *models.Task `json:"task"`
+}
+
+// TaskAssigned is published when a task is assigned to a member.
+type TaskAssigned struct {
+	Task *models.Task `json:"task"`
+}
+
+// TaskStatusChanged is published when a task moves to another status.
+type TaskStatusChanged struct {
+	TaskID    uint              `json:"taskId"`
+	OldStatus models.TaskStatus `json:"oldStatus"`
+	NewStatus models.TaskStatus `json:"newStatus"`
+}
+
+// TaskDeleted is published when a task is moved to the trash.
+type TaskDeleted struct {
+	TaskID uint `json:"taskId"`
+}
+
+// TaskCommentAdded is published when a task is commented on.
+type TaskCommentAdded struct {
+	TaskID  uint                `json:"taskId"`
+	Comment *models.TaskComment `json:"comment"`
+}
+
+func (TaskCreated) EventName() string       { return "task_created" }
+func (TaskUpdated) EventName() string       { return "task_updated" }
+func (TaskAssigned) EventName() string      { return "task_assigned" }
+func (TaskStatusChanged) EventName() string { return "task_status_updated" }
+func (TaskDeleted) EventName() string       { return "task_deleted" }
+func (TaskCommentAdded) EventName() string  { return "task_comment_added" }
+
+// --- User stories ---
+
+// UserStoryCreated is published when a user story is created.
+type UserStoryCreated struct {
+	UserStory *models.UserStory `json:"userStory"`
+}
+
+// UserStoryUpdated is published when a user story is edited or moved to a sprint.
+type UserStoryUpdated struct {
+	UserStory *models.UserStory `json:"userStory"`
+}
+
+// UserStoryDeleted is published when a user story is moved to the trash. SprintID is the
+// sprint it was in, if any.
+type UserStoryDeleted struct {
+	UserStoryID uint  `json:"userStoryId"`
+	SprintID    *uint `json:"sprintId"`
+}
+
+func (UserStoryCreated) EventName() string { return "user_story_created" }
+func (UserStoryUpdated) EventName() string { return "user_story_updated" }
+func (UserStoryDeleted) EventName() string { return "user_story_deleted" }
+
+// --- Sprints ---
+
+// SprintCreated is published when a sprint is created.
+type SprintCreated struct {
+	Sprint *models.Sprint `json:"sprint"`
+}
+
+// SprintUpdated is published when a sprint is edited or changes status.
+type SprintUpdated struct {
+	Sprint *models.Sprint `json:"sprint"`
+}
+
+// SprintDeleted is published when a sprint is moved to the trash.
+type SprintDeleted struct {
+	SprintID uint `json:"sprintId"`
+}
+
+func (SprintCreated) EventName() string { return "sprint_created" }
+func (SprintUpdated) EventName() string { return "sprint_updated" }
+func (SprintDeleted) EventName() string { return "sprint_deleted" }
+
+// --- Calendar events ---
+
+// CalendarEventCreated is published when a calendar event is created.
+type CalendarEventCreated struct {
+	Event *models.Event `json:"event"`
+}
+
+// CalendarEventUpdated is published when a calendar event is edited.
+type CalendarEventUpdated struct {
+	Event *models.Event `json:"event"`
+}
+
+// CalendarEventDeleted is published when a calendar event is deleted.
+type CalendarEventDeleted struct {
+	EventID uint `json:"eventId"`
+}
+
+func (CalendarEventCreated) EventName() string { return "calendar_event_created" }
+func (CalendarEventUpdated) EventName() string { return "calendar_event_updated" }
+func (CalendarEventDeleted) EventName() string { return "calendar_event_deleted" }
+
+// --- Projects and members ---
+
+// ProjectUpdated is published when a project's details or status change.
+type ProjectUpdated struct {
+	Project *models.Project `json:"project"`
+}
+
+// MemberAdded is published when a user joins a project, including its creator.
+type MemberAdded struct {
+	UserID uint   `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// MemberRemoved is published when a user leaves a project.
+type MemberRemoved struct {
+	UserID uint `json:"userId"`
+}
+
+func (ProjectUpdated) EventName() string { return "project_updated" }
+func (MemberAdded) EventName() string    { return "member_added" }
+func (MemberRemoved) EventName() string  { return "member_removed" }
+
+// --- Evaluations ---
+
+// TaskEvaluationChanged is published when a task evaluation is submitted or published.
+// Scores and feedback are left out: they are read over the REST API by those allowed to.
+// Drafts and peer evaluations are private to their evaluator and are not announced.
+type TaskEvaluationChanged struct {
+	EvaluationID uint                    `json:"evaluationId"`
+	TaskID       uint                    `json:"taskId"`
+	Status       models.EvaluationStatus `json:"status"`
+}
+
+// EvaluationRoundOpened is published when a peer evaluation round is opened for a sprint.
+type EvaluationRoundOpened struct {
+	RoundID  uint   `json:"roundId"`
+	SprintID uint   `json:"sprintId"`
+	Name     string `json:"name"`
+}
+
+// EvaluationRoundClosed is published when a round is closed and its results are published.
+type EvaluationRoundClosed struct {
+	RoundID  uint `json:"roundId"`
+	SprintID uint `json:"sprintId"`
+}
+
+func (TaskEvaluationChanged) EventName() string { return "evaluation_updated" }
+func (EvaluationRoundOpened) EventName() string { return "evaluation_round_opened" }
+func (EvaluationRoundClosed) EventName() string { return "evaluation_round_closed" }
+
+// --- Bulk operations ---
+
+// BulkUpdated is published once for a bulk operation that changed some items.
+type BulkUpdated struct {
+	Entity string           `json:"entity"`
+	Action string           `json:"action"`
+	Items  []BulkItemResult `json:"items"`
+}
+
+func (BulkUpdated) EventName() string { return "bulk_update" }
+
+// --- Notifications ---
+
+// NotificationCreated is published to the recipient of a new notification, with the
+// number of notifications they have not read yet.
+type NotificationCreated struct {
+	Notification *models.Notification `json:"notification"`
+	UnreadCount  int64                `json:"unreadCount"`
+}
+
+// NotificationsRead is published to a user when they mark some of their notifications
+// as read, or all of them, so their other devices can follow.
+type NotificationsRead struct {
+	NotificationIDs []uint `json:"notificationIds"` // Empty when All is set
+	All             bool   `json:"all"`
+	UnreadCount     int64  `json:"unreadCount"`
+}
+
+// NotificationDeleted is published to a user when one of their notifications is deleted.
+type NotificationDeleted struct {
+	NotificationID uint  `json:"notificationId"`
+	UnreadCount    int64 `json:"unreadCount"`
+}
+
+func (NotificationCreated) EventName() string { return "notification_created" }
+func (NotificationsRead) EventName() string   { return "notifications_read" }
+func (NotificationDeleted) EventName() string { return "notification_deleted" }
@@ -2,9 +2,14 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"fmt"
+	"io"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/storage"
@@ -12,115 +17,319 @@ import (
 
 // ExportService handles the business logic for exporting data.
 type ExportService struct {
-	ProjectRepo   *storage.ProjectRepository
-	UserStoryRepo *storage.UserStoryRepository
-	TaskRepo      *storage.TaskRepository
+	ProjectRepo *storage.ProjectRepository
+	ExportRepo  *storage.ExportRepository
 }
 
 // NewExportService creates a new instance of ExportService.
 func NewExportService(
 	projectRepo *storage.ProjectRepository,
-	userStoryRepo *storage.UserStoryRepository,
-	taskRepo *storage.TaskRepository,
+	exportRepo *storage.ExportRepository,
 ) *ExportService {
 	return &ExportService{
-		ProjectRepo:   projectRepo,
-		UserStoryRepo: userStoryRepo,
-		TaskRepo:      taskRepo,
+		ProjectRepo: projectRepo,
+		ExportRepo:  exportRepo,
 	}
 }
 
+// WithContext returns a copy of the service whose queries carry ctx, so a streamed
+// export stops when the client goes away.
+func (s *ExportService) WithContext(ctx context.Context) *ExportService {
+	return &ExportService{ProjectRepo: s.ProjectRepo.WithContext(ctx), ExportRepo: s.ExportRepo.WithContext(ctx)}
+}
+
+// ExportFile is a rendered export.
+type ExportFile struct {
+	Format      string
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// csvColumn is a column of the CSV export, selected by its key.
+type csvColumn struct {
+	Key    string
+	Header string
+	Value  func(row *storage.ExportRow) string
+}
+
+// csvColumns lists every CSV column in its default position.
+var csvColumns = []csvColumn{
+	{"project", "Project Name", func(r *storage.ExportRow) string { return r.ProjectName }},
+	{"story_id", "User Story ID", func(r *storage.ExportRow) string { return strconv.Itoa(int(r.StoryID)) }},
+	{"story_title", "User Story Title", func(r *storage.ExportRow) string { return r.StoryTitle }},
+	{"story_status", "User Story Status", func(r *storage.ExportRow) string { return r.StoryStatus }},
+	{"story_priority", "User Story Priority", func(r *storage.ExportRow) string { return r.StoryPriority }},
+	{"story_points", "User Story Points", func(r *storage.ExportRow) string { return formatPoints(r.StoryPoints) }},
+	{"sprint", "Sprint", func(r *storage.ExportRow) string { return stringValue(r.SprintName) }},
+	{"task_id", "Task ID", func(r *storage.ExportRow) string {
+		if r.TaskID == nil {
+			return ""
+		}
+		return strconv.Itoa(int(*r.TaskID))
+	}},
+	{"task_title", "Task Title", func(r *storage.ExportRow) string { return stringValue(r.TaskTitle) }},
+	{"task_status", "Task Status", func(r *storage.ExportRow) string { return stringValue(r.TaskStatus) }},
+	{"assigned_to", "Assigned To", func(r *storage.ExportRow) string {
+		if r.TaskID == nil {
+			return ""
+		}
+		if r.AssignedTo == nil {
+			return "Unassigned"
+		}
+		return *r.AssignedTo
+	}},
+	{"estimated_hours", "Estimated Hours", func(r *storage.ExportRow) string { return formatHours(r.EstimatedHours) }},
+	{"spent_hours", "Spent Hours", func(r *storage.ExportRow) string { return formatHours(r.SpentHours) }},
+	{"deliverable", "Deliverable", func(r *storage.ExportRow) string {
+		if r.IsDeliverable == nil {
+			return ""
+		}
+		return strconv.FormatBool(*r.IsDeliverable)
+	}},
+}
+
+// defaultCSVColumns are the columns exported when none are selected.
+var defaultCSVColumns = []string{"project", "story_id", "story_title", "story_status", "task_id", "task_title", "task_status", "assigned_to"}
+
+// CSVColumnKeys lists the keys of every CSV column.
+func CSVColumnKeys() []string {
+	keys := make([]string, len(csvColumns))
+	for i, column := range csvColumns {
+		keys[i] = column.Key
+	}
+	return keys
+}
+
+// selectCSVColumns resolves column keys, in the given order, to their definitions.
+func selectCSVColumns(keys []string) ([]csvColumn, error) {
+	if len(keys) == 0 {
+		keys = defaultCSVColumns
+	}
+	selected := make([]csvColumn, 0, len(keys))
+	for _, key := range keys {
+		found := false
+		for _, column := range csvColumns {
+			if column.Key == strings.TrimSpace(strings.ToLower(key)) {
+				selected = append(selected, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown export column '%s' (available: %s)", key, strings.Join(CSVColumnKeys(), ", "))
+		}
+	}
+	return selected, nil
+}
+
+// csvFlushRows is how many rows are written between flushes of a streamed CSV export.
+const csvFlushRows = 500
+
+// CSVExport is a validated CSV export, ready to be streamed.
+type CSVExport struct {
+	FileName  string
+	repo      *storage.ExportRepository
+	projectID uint
+	filter    storage.ExportFilter
+	columns   []csvColumn
+}
+
+// NewCSVExport checks the project, columns and filter of a CSV export. Nothing is read
+// until Write is called, so the caller can still answer with an error status.
+func (s *ExportService) NewCSVExport(projectID uint, columns []string, filter storage.ExportFilter) (*CSVExport, error) {
+	if _, err := s.ProjectRepo.GetProjectByID(projectID); err != nil {
+		return nil, fmt.Errorf("project not found: %w", err)
+	}
+	if err := validateExportFilter(filter); err != nil {
+		return nil, err
+	}
+	selected, err := selectCSVColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+	return &CSVExport{
+		FileName:  fmt.Sprintf("project_%d_export_%s.csv", projectID, time.Now().Format("20060102")),
+		repo:      s.ExportRepo,
+		projectID: projectID,
+		filter:    filter,
+		columns:   selected,
+	}, nil
+}
+
+// Write streams the CSV to out as rows are read from the database. When w is an
+// http.Flusher it is flushed every csvFlushRows rows.
+func (e *CSVExport) Write(out io.Writer) error {
+	w := csv.NewWriter(out)
+	flusher, _ := out.(http.Flusher)
+
+	header := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		header[i] = column.Header
+	}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	count := 0
+	record := make([]string, len(e.columns))
+	err := e.repo.StreamProjectRows(e.projectID, e.filter, func(row *storage.ExportRow) error {
+		for i, column := range e.columns {
+			record[i] = column.Value(row)
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+		if count++; count%csvFlushRows == 0 {
+			w.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return w.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV writer: %w", err)
+	}
+	return nil
+}
+
 // ExportProjectToCSV generates a CSV file in memory for a given project.
 func (s *ExportService) ExportProjectToCSV(projectID uint) ([]byte, error) {
-	// 1. Fetch all necessary data.
+	export, err := s.NewCSVExport(projectID, nil, storage.ExportFilter{})
+	if err != nil {
+		return nil, err
+	}
+	b := new(bytes.Buffer)
+	if err := export.Write(b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// ExportProject renders a project with the writer registered for format (see ExportFormats).
+func (s *ExportService) ExportProject(projectID uint, format string, filter storage.ExportFilter) (*ExportFile, error) {
+	writer, err := GetExportWriter(format)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateExportFilter(filter); err != nil {
+		return nil, err
+	}
+	data, err := s.loadExportData(projectID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return renderExport(writer, format, data)
+}
+
+// ExportReport renders the project of a report configuration in each of its
+// ExportFormats (CSV when none are set), limited to the report's sprint if it has one.
+func (s *ExportService) ExportReport(report *models.Report) ([]ExportFile, error) {
+	return s.exportReportFormats(report, report.ExportFormats)
+}
+
+// ExportScheduledReport renders a scheduled report in its own ExportFormats, falling
+// back to those of its report configuration. ReportConfig must be loaded.
+func (s *ExportService) ExportScheduledReport(scheduled *models.ScheduledReport) ([]ExportFile, error) {
+	formats := scheduled.ExportFormats
+	if len(formats) == 0 {
+		formats = scheduled.ReportConfig.ExportFormats
+	}
+	return s.exportReportFormats(&scheduled.ReportConfig, formats)
+}
+
+// exportReportFormats loads the report's project once and renders it in every format.
+func (s *ExportService) exportReportFormats(report *models.Report, formats []string) ([]ExportFile, error) {
+	if report.ProjectID == nil {
+		return nil, fmt.Errorf("report %d has no project to export", report.ID)
+	}
+	if len(formats) == 0 {
+		formats = []string{"csv"}
+	}
+	if err := ValidateExportFormats(formats); err != nil {
+		return nil, err
+	}
+	data, err := s.loadExportData(*report.ProjectID, storage.ExportFilter{SprintID: report.SprintID})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]ExportFile, 0, len(formats))
+	for _, format := range formats {
+		writer, _ := GetExportWriter(format)
+		file, err := renderExport(writer, format, data)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *file)
+	}
+	return files, nil
+}
+
+// loadExportData fetches the project with its sprints, user stories and tasks.
+func (s *ExportService) loadExportData(projectID uint, filter storage.ExportFilter) (*ExportData, error) {
 	project, err := s.ProjectRepo.GetProjectByID(projectID)
 	if err != nil {
 		return nil, fmt.Errorf("project not found: %w", err)
 	}
 
-	userStories, err := s.UserStoryRepo.GetUserStoriesByProjectID(projectID)
+	sprints, err := s.ExportRepo.GetProjectSprints(projectID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch sprints: %w", err)
+	}
+
+	userStories, tasks, err := s.ExportRepo.GetProjectStoriesAndTasks(projectID, filter)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch user stories: %w", err)
 	}
 
-	var allTasks []models.Task
-	for _, us := range userStories {
-		tasks, err := s.TaskRepo.GetTasksByUserStoryID(us.ID)
-		if err != nil {
-			return nil, fmt.Errorf("could not fetch tasks for user story %d: %w", us.ID, err)
-		}
-		allTasks = append(allTasks, tasks...)
+	return &ExportData{
+		Project:     project,
+		Sprints:     sprints,
+		UserStories: userStories,
+		Tasks:       tasks,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// validateExportFilter rejects unknown task statuses.
+func validateExportFilter(filter storage.ExportFilter) error {
+	if filter.TaskStatus != "" && !models.IsValidTaskStatus(filter.TaskStatus) {
+		return fmt.Errorf("invalid task status '%s'", filter.TaskStatus)
 	}
+	return nil
+}
 
-	// 2. Generate CSV content in memory.
+// renderExport writes data with writer into an in-memory file.
+func renderExport(writer ExportWriter, format string, data *ExportData) (*ExportFile, error) {
 	b := new(bytes.Buffer)
-	w := csv.NewWriter(b)
-
-	// Write header
-	header := []string{
-		"Project Name",
-		"User Story ID",
-		"User Story Title",
-		"User Story Status",
-		"Task ID",
-		"Task Title",
-		"Task Status",
-		"Assigned To",
-	}
-	if err := w.Write(header); err != nil {
-		return nil, fmt.Errorf("failed to write CSV header: %w", err)
-	}
-
-	// 3. Write data rows.
-	taskMap := make(map[uint][]models.Task)
-	for _, task := range allTasks {
-		taskMap[task.UserStoryID] = append(taskMap[task.UserStoryID], task)
-	}
-
-	for _, us := range userStories {
-		tasksInStory := taskMap[us.ID]
-		if len(tasksInStory) == 0 {
-			// Write a row even for user stories with no tasks
-			row := []string{
-				project.Name,
-				strconv.Itoa(int(us.ID)),
-				us.Title,
-				us.Status,
-				"", // No Task ID
-				"", // No Task Title
-				"", // No Task Status
-				"", // No Assigned To
-			}
-			if err := w.Write(row); err != nil {
-				return nil, fmt.Errorf("failed to write CSV row: %w", err)
-			}
-		} else {
-			for _, task := range tasksInStory {
-				assignedTo := "Unassigned"
-				if task.AssignedTo != nil {
-					assignedTo = task.AssignedTo.Nombre
-				}
-				row := []string{
-					project.Name,
-					strconv.Itoa(int(us.ID)),
-					us.Title,
-					us.Status,
-					strconv.Itoa(int(task.ID)),
-					task.Title,
-					string(task.Status),
-					assignedTo,
-				}
-				if err := w.Write(row); err != nil {
-					return nil, fmt.Errorf("failed to write CSV row: %w", err)
-				}
-			}
-		}
+	if err := writer.Write(b, data); err != nil {
+		return nil, fmt.Errorf("failed to write %s export: %w", format, err)
 	}
+	return &ExportFile{
+		Format:      format,
+		FileName:    fmt.Sprintf("project_%d_export_%s.%s", data.Project.ID, data.GeneratedAt.Format("20060102"), writer.Extension()),
+		ContentType: writer.ContentType(),
+		Data:        b.Bytes(),
+	}, nil
+}
 
-	w.Flush()
-	if err := w.Error(); err != nil {
-		return nil, fmt.Errorf("error flushing CSV writer: %w", err)
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
 	}
+	return *s
+}
 
-	return b.Bytes(), nil
+func formatHours(hours *float32) string {
+	if hours == nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*hours), 'f', -1, 32)
 }
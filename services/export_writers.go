@@ -0,0 +1,433 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+// ExportData is the project content handed to an ExportWriter.
+type ExportData struct {
+	Project     *models.Project
+	Sprints     []models.Sprint
+	UserStories []models.UserStory
+	Tasks       []models.Task // With AssignedTo
+	GeneratedAt time.Time
+}
+
+// ExportWriter renders ExportData in one file format. Writers are registered by format
+// name with RegisterExportWriter and looked up by the project export endpoint and by
+// Report.ExportFormats / ScheduledReport.ExportFormats.
+type ExportWriter interface {
+	ContentType() string
+	Extension() string
+	Write(w io.Writer, data *ExportData) error
+}
+
+var exportWriters = map[string]ExportWriter{}
+
+// RegisterExportWriter makes a writer available under a format name, replacing any
+// writer previously registered under that name.
+func RegisterExportWriter(format string, writer ExportWriter) {
+	exportWriters[strings.ToLower(format)] = writer
+}
+
+// GetExportWriter returns the writer registered for a format.
+func GetExportWriter(format string) (ExportWriter, error) {
+	writer, ok := exportWriters[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported export format '%s' (supported: %s)", format, strings.Join(ExportFormats(), ", "))
+	}
+	return writer, nil
+}
+
+// ExportFormats lists the registered format names in alphabetical order.
+func ExportFormats() []string {
+	formats := make([]string, 0, len(exportWriters))
+	for format := range exportWriters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ValidateExportFormats checks that every format of a report configuration is registered.
+func ValidateExportFormats(formats []string) error {
+	for _, format := range formats {
+		if _, err := GetExportWriter(format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterExportWriter("csv", csvExportWriter{})
+	RegisterExportWriter("json", jsonExportWriter{})
+	RegisterExportWriter("xlsx", xlsxExportWriter{})
+	RegisterExportWriter("md", markdownSprintReportWriter{})
+	RegisterExportWriter("html", htmlSprintReportWriter{})
+}
+
+// tasksByStory groups the tasks by user story, keeping their order.
+func (d *ExportData) tasksByStory() map[uint][]models.Task {
+	grouped := make(map[uint][]models.Task)
+	for _, task := range d.Tasks {
+		grouped[task.UserStoryID] = append(grouped[task.UserStoryID], task)
+	}
+	return grouped
+}
+
+// assigneeName returns the name of the user a task is assigned to, or "Unassigned".
+func assigneeName(task models.Task) string {
+	if task.AssignedTo != nil {
+		return task.AssignedTo.Nombre
+	}
+	return "Unassigned"
+}
+
+// formatDate formats an optional date as YYYY-MM-DD.
+func formatDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
+// formatPoints formats optional story points, empty when unestimated.
+func formatPoints(points *int) string {
+	if points == nil {
+		return ""
+	}
+	return strconv.Itoa(*points)
+}
+
+// --- CSV ---
+
+// csvExportWriter writes one row per task, plus one row for each user story without tasks.
+type csvExportWriter struct{}
+
+func (csvExportWriter) ContentType() string { return "text/csv" }
+func (csvExportWriter) Extension() string   { return "csv" }
+
+func (csvExportWriter) Write(out io.Writer, data *ExportData) error {
+	columns, _ := selectCSVColumns(nil)
+	w := csv.NewWriter(out)
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Header
+	}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, row := range data.rows() {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = column.Value(&row)
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error flushing CSV writer: %w", err)
+	}
+	return nil
+}
+
+// rows flattens the data like storage.ExportRepository.StreamProjectRows does.
+func (d *ExportData) rows() []storage.ExportRow {
+	sprintNames := make(map[uint]*string, len(d.Sprints))
+	for i := range d.Sprints {
+		sprintNames[d.Sprints[i].ID] = &d.Sprints[i].Name
+	}
+	tasks := d.tasksByStory()
+
+	var rows []storage.ExportRow
+	for _, us := range d.UserStories {
+		story := storage.ExportRow{
+			ProjectName: d.Project.Name, StoryID: us.ID, StoryTitle: us.Title, StoryStatus: us.Status,
+			StoryPriority: us.Priority, StoryPoints: us.Points,
+		}
+		if us.SprintID != nil {
+			story.SprintName = sprintNames[*us.SprintID]
+		}
+		if len(tasks[us.ID]) == 0 {
+			// Write a row even for user stories with no tasks
+			rows = append(rows, story)
+			continue
+		}
+		for _, task := range tasks[us.ID] {
+			row := story
+			id, title, status, deliverable := task.ID, task.Title, string(task.Status), task.IsDeliverable
+			row.TaskID, row.TaskTitle, row.TaskStatus, row.IsDeliverable = &id, &title, &status, &deliverable
+			row.EstimatedHours, row.SpentHours = task.EstimatedHours, task.SpentHours
+			if task.AssignedTo != nil {
+				row.AssignedTo = &task.AssignedTo.Nombre
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// --- Sprint summaries, shared by the XLSX, JSON and report writers ---
+
+// SprintSummary holds the figures of a sprint report.
+type SprintSummary struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name"`
+	Goal            string     `json:"goal"`
+	Status          string     `json:"status"`
+	StartDate       *time.Time `json:"startDate"`
+	EndDate         *time.Time `json:"endDate"`
+	Stories         int        `json:"stories"`
+	StoriesDone     int        `json:"storiesDone"`
+	CommittedPoints int        `json:"committedPoints"`
+	CompletedPoints int        `json:"completedPoints"`
+	Tasks           int        `json:"tasks"`
+	TasksDone       int        `json:"tasksDone"`
+
+	userStories []models.UserStory
+}
+
+// sprintSummaries computes the summary of every sprint, in the order of data.Sprints.
+func (d *ExportData) sprintSummaries() []SprintSummary {
+	tasks := d.tasksByStory()
+	summaries := make([]SprintSummary, len(d.Sprints))
+	index := make(map[uint]int, len(d.Sprints))
+	for i, sprint := range d.Sprints {
+		summaries[i] = SprintSummary{ID: sprint.ID, Name: sprint.Name, Goal: sprint.Goal, Status: sprint.Status, StartDate: sprint.StartDate, EndDate: sprint.EndDate}
+		index[sprint.ID] = i
+	}
+
+	for _, us := range d.UserStories {
+		if us.SprintID == nil {
+			continue
+		}
+		i, ok := index[*us.SprintID]
+		if !ok {
+			continue
+		}
+		s := &summaries[i]
+		s.userStories = append(s.userStories, us)
+		s.Stories++
+		points := 0
+		if us.Points != nil {
+			points = *us.Points
+		}
+		s.CommittedPoints += points
+		if us.Status == "done" {
+			s.StoriesDone++
+			s.CompletedPoints += points
+		}
+		for _, task := range tasks[us.ID] {
+			s.Tasks++
+			if task.Status == models.StatusDone {
+				s.TasksDone++
+			}
+		}
+	}
+	return summaries
+}
+
+// backlogStories counts the user stories not planned in any sprint.
+func (d *ExportData) backlogStories() int {
+	count := 0
+	for _, us := range d.UserStories {
+		if us.SprintID == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// --- JSON ---
+
+// jsonExportWriter writes the project with its sprint summaries and its user stories with their tasks.
+type jsonExportWriter struct{}
+
+func (jsonExportWriter) ContentType() string { return "application/json" }
+func (jsonExportWriter) Extension() string   { return "json" }
+
+type jsonExportTask struct {
+	ID             uint     `json:"id"`
+	Title          string   `json:"title"`
+	Status         string   `json:"status"`
+	AssignedToID   *uint    `json:"assignedToId"`
+	AssignedTo     string   `json:"assignedTo"`
+	EstimatedHours *float32 `json:"estimatedHours"`
+	SpentHours     *float32 `json:"spentHours"`
+	IsDeliverable  bool     `json:"isDeliverable"`
+}
+
+type jsonExportUserStory struct {
+	ID       uint             `json:"id"`
+	Title    string           `json:"title"`
+	Status   string           `json:"status"`
+	Priority string           `json:"priority"`
+	Points   *int             `json:"points"`
+	SprintID *uint            `json:"sprintId"`
+	Tasks    []jsonExportTask `json:"tasks"`
+}
+
+func (jsonExportWriter) Write(w io.Writer, data *ExportData) error {
+	tasks := data.tasksByStory()
+	stories := make([]jsonExportUserStory, 0, len(data.UserStories))
+	for _, us := range data.UserStories {
+		story := jsonExportUserStory{ID: us.ID, Title: us.Title, Status: us.Status, Priority: us.Priority, Points: us.Points, SprintID: us.SprintID, Tasks: []jsonExportTask{}}
+		for _, task := range tasks[us.ID] {
+			story.Tasks = append(story.Tasks, jsonExportTask{
+				ID: task.ID, Title: task.Title, Status: string(task.Status), AssignedToID: task.AssignedToID, AssignedTo: assigneeName(task),
+				EstimatedHours: task.EstimatedHours, SpentHours: task.SpentHours, IsDeliverable: task.IsDeliverable,
+			})
+		}
+		stories = append(stories, story)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]interface{}{
+		"project": map[string]interface{}{
+			"id":          data.Project.ID,
+			"name":        data.Project.Name,
+			"description": data.Project.Description,
+			"status":      data.Project.Status,
+			"startDate":   data.Project.StartDate,
+			"endDate":     data.Project.EndDate,
+		},
+		"generatedAt": data.GeneratedAt,
+		"sprints":     data.sprintSummaries(),
+		"userStories": stories,
+	})
+}
+
+// --- Markdown sprint report ---
+
+// markdownSprintReportWriter writes a sprint report: one section per sprint with its
+// figures and user stories.
+type markdownSprintReportWriter struct{}
+
+func (markdownSprintReportWriter) ContentType() string { return "text/markdown; charset=utf-8" }
+func (markdownSprintReportWriter) Extension() string   { return "md" }
+
+func (markdownSprintReportWriter) Write(w io.Writer, data *ExportData) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Sprint report: %s\n\n", markdownEscape(data.Project.Name))
+	fmt.Fprintf(&b, "Generated on %s. %d sprint(s), %d user stories in the backlog.\n", data.GeneratedAt.Format("2006-01-02 15:04 MST"), len(data.Sprints), data.backlogStories())
+
+	for _, s := range data.sprintSummaries() {
+		fmt.Fprintf(&b, "\n## %s (%s)\n\n", markdownEscape(s.Name), s.Status)
+		if s.StartDate != nil || s.EndDate != nil {
+			fmt.Fprintf(&b, "%s to %s\n\n", formatDate(s.StartDate), formatDate(s.EndDate))
+		}
+		if s.Goal != "" {
+			fmt.Fprintf(&b, "**Goal:** %s\n\n", markdownEscape(s.Goal))
+		}
+		b.WriteString("| Metric | Done | Total |\n|---|---:|---:|\n")
+		fmt.Fprintf(&b, "| User stories | %d | %d |\n", s.StoriesDone, s.Stories)
+		fmt.Fprintf(&b, "| Points | %d | %d |\n", s.CompletedPoints, s.CommittedPoints)
+		fmt.Fprintf(&b, "| Tasks | %d | %d |\n", s.TasksDone, s.Tasks)
+
+		if len(s.userStories) > 0 {
+			b.WriteString("\n| ID | User story | Status | Points |\n|---:|---|---|---:|\n")
+			for _, us := range s.userStories {
+				fmt.Fprintf(&b, "| %d | %s | %s | %s |\n", us.ID, markdownEscape(us.Title), us.Status, formatPoints(us.Points))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownEscape keeps user text from breaking tables and formatting.
+func markdownEscape(s string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "|", "\\|", "*", "\\*", "_", "\\_", "`", "\\`", "\n", " ", "\r", "")
+	return replacer.Replace(s)
+}
+
+// --- HTML sprint report ---
+
+// htmlSprintReportWriter writes the sprint report as a standalone HTML page with print
+// styles, ready to be saved as PDF from a browser.
+type htmlSprintReportWriter struct{}
+
+func (htmlSprintReportWriter) ContentType() string { return "text/html; charset=utf-8" }
+func (htmlSprintReportWriter) Extension() string   { return "html" }
+
+var sprintReportTemplate = template.Must(template.New("sprint-report").Funcs(template.FuncMap{
+	"date":   formatDate,
+	"points": formatPoints,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Sprint report: {{.Project.Name}}</title>
+<style>
+  body { font-family: Helvetica, Arial, sans-serif; font-size: 11pt; color: #222; margin: 2em; }
+  h1 { font-size: 18pt; margin-bottom: 0.2em; }
+  h2 { font-size: 14pt; margin-top: 1.5em; border-bottom: 1px solid #999; }
+  table { border-collapse: collapse; margin: 0.5em 0; }
+  th, td { border: 1px solid #bbb; padding: 0.25em 0.6em; text-align: left; }
+  td.num, th.num { text-align: right; }
+  .meta { color: #666; }
+  section { page-break-inside: avoid; }
+  @page { size: A4; margin: 1.5cm; }
+  @media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Sprint report: {{.Project.Name}}</h1>
+<p class="meta">Generated on {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}. {{len .Sprints}} sprint(s), {{.Backlog}} user stories in the backlog.</p>
+{{range .Sprints}}<section>
+<h2>{{.Name}} ({{.Status}})</h2>
+{{if or .StartDate .EndDate}}<p class="meta">{{date .StartDate}} to {{date .EndDate}}</p>
+{{end}}{{if .Goal}}<p><strong>Goal:</strong> {{.Goal}}</p>
+{{end}}<table>
+<tr><th>Metric</th><th class="num">Done</th><th class="num">Total</th></tr>
+<tr><td>User stories</td><td class="num">{{.StoriesDone}}</td><td class="num">{{.Stories}}</td></tr>
+<tr><td>Points</td><td class="num">{{.CompletedPoints}}</td><td class="num">{{.CommittedPoints}}</td></tr>
+<tr><td>Tasks</td><td class="num">{{.TasksDone}}</td><td class="num">{{.Tasks}}</td></tr>
+</table>
+{{if .UserStories}}<table>
+<tr><th class="num">ID</th><th>User story</th><th>Status</th><th class="num">Points</th></tr>
+{{range .UserStories}}<tr><td class="num">{{.ID}}</td><td>{{.Title}}</td><td>{{.Status}}</td><td class="num">{{points .Points}}</td></tr>
+{{end}}</table>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
+
+// htmlSprint exposes the user stories of a summary to the template.
+type htmlSprint struct {
+	SprintSummary
+	UserStories []models.UserStory
+}
+
+func (htmlSprintReportWriter) Write(w io.Writer, data *ExportData) error {
+	summaries := data.sprintSummaries()
+	sprints := make([]htmlSprint, len(summaries))
+	for i, s := range summaries {
+		sprints[i] = htmlSprint{SprintSummary: s, UserStories: s.userStories}
+	}
+	return sprintReportTemplate.Execute(w, map[string]interface{}{
+		"Project":     data.Project,
+		"GeneratedAt": data.GeneratedAt,
+		"Backlog":     data.backlogStories(),
+		"Sprints":     sprints,
+	})
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/models"
+)
+
+// xlsxExportWriter writes an Office Open XML workbook with one sheet each for user
+// stories, tasks and sprints. The package is built directly with archive/zip, using
+// inline strings so no shared string table is needed.
+type xlsxExportWriter struct{}
+
+func (xlsxExportWriter) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+func (xlsxExportWriter) Extension() string { return "xlsx" }
+
+// xlsxSheet is a worksheet; cells are strings, numbers (int, float32) or nil for empty.
+type xlsxSheet struct {
+	Name string
+	Rows [][]interface{}
+}
+
+func (xlsxExportWriter) Write(w io.Writer, data *ExportData) error {
+	sprintNames := make(map[uint]string, len(data.Sprints))
+	for _, sprint := range data.Sprints {
+		sprintNames[sprint.ID] = sprint.Name
+	}
+	tasks := data.tasksByStory()
+
+	stories := xlsxSheet{Name: "User Stories", Rows: [][]interface{}{
+		{"ID", "Title", "Status", "Priority", "Points", "Sprint", "Tasks", "Tasks Done"},
+	}}
+	for _, us := range data.UserStories {
+		sprint := ""
+		if us.SprintID != nil {
+			sprint = sprintNames[*us.SprintID]
+		}
+		done := 0
+		for _, task := range tasks[us.ID] {
+			if task.Status == models.StatusDone {
+				done++
+			}
+		}
+		stories.Rows = append(stories.Rows, []interface{}{int(us.ID), us.Title, us.Status, us.Priority, optionalInt(us.Points), sprint, len(tasks[us.ID]), done})
+	}
+
+	storyTitles := make(map[uint]string, len(data.UserStories))
+	for _, us := range data.UserStories {
+		storyTitles[us.ID] = us.Title
+	}
+	taskSheet := xlsxSheet{Name: "Tasks", Rows: [][]interface{}{
+		{"ID", "User Story ID", "User Story", "Title", "Status", "Assigned To", "Estimated Hours", "Spent Hours", "Deliverable"},
+	}}
+	for _, task := range data.Tasks {
+		deliverable := "no"
+		if task.IsDeliverable {
+			deliverable = "yes"
+		}
+		taskSheet.Rows = append(taskSheet.Rows, []interface{}{
+			int(task.ID), int(task.UserStoryID), storyTitles[task.UserStoryID], task.Title, string(task.Status), assigneeName(task),
+			optionalFloat(task.EstimatedHours), optionalFloat(task.SpentHours), deliverable,
+		})
+	}
+
+	sprints := xlsxSheet{Name: "Sprints", Rows: [][]interface{}{
+		{"ID", "Name", "Status", "Start Date", "End Date", "Stories", "Stories Done", "Committed Points", "Completed Points", "Tasks", "Tasks Done"},
+	}}
+	for _, s := range data.sprintSummaries() {
+		sprints.Rows = append(sprints.Rows, []interface{}{
+			int(s.ID), s.Name, s.Status, formatDate(s.StartDate), formatDate(s.EndDate),
+			s.Stories, s.StoriesDone, s.CommittedPoints, s.CompletedPoints, s.Tasks, s.TasksDone,
+		})
+	}
+
+	return writeXLSX(w, []xlsxSheet{stories, taskSheet, sprints})
+}
+
+func optionalInt(value *int) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func optionalFloat(value *float32) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// writeXLSX writes the sheets as a minimal XLSX package.
+func writeXLSX(w io.Writer, sheets []xlsxSheet) error {
+	zw := zip.NewWriter(w)
+	add := func(name, content string) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, content)
+		return err
+	}
+
+	var overrides, workbookSheets, relationships strings.Builder
+	for i, sheet := range sheets {
+		n := i + 1
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbookSheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.Name), n, n)
+		fmt.Fprintf(&relationships, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + workbookSheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			relationships.String() + `</Relationships>`},
+	}
+	for _, file := range files {
+		if err := add(file.name, file.content); err != nil {
+			return fmt.Errorf("failed to write XLSX part %s: %w", file.name, err)
+		}
+	}
+	for i, sheet := range sheets {
+		name := fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)
+		if err := add(name, worksheetXML(sheet)); err != nil {
+			return fmt.Errorf("failed to write XLSX part %s: %w", name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// worksheetXML renders the rows of a sheet.
+func worksheetXML(sheet xlsxSheet) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range sheet.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, value := range row {
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			switch v := value.(type) {
+			case nil:
+			case int:
+				fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, v)
+			case float32:
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(float64(v), 'f', -1, 32))
+			default:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(fmt.Sprint(v)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// xlsxColumn converts a zero-based column index to its letters (0 -> A, 26 -> AA).
+func xlsxColumn(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// xmlEscape escapes text for XML content and attributes.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
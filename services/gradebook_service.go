@@ -0,0 +1,344 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+	"github.com/buga/API_wrkf/utils"
+)
+
+// GradebookService builds the evaluation gradebook of a project.
+type GradebookService struct {
+	EvalRepo       *storage.EvaluationRepository
+	TaskRepo       *storage.TaskRepository
+	ProjectService *ProjectService
+}
+
+// NewGradebookService creates a new instance of GradebookService.
+func NewGradebookService(evalRepo *storage.EvaluationRepository, taskRepo *storage.TaskRepository, projectService *ProjectService) *GradebookService {
+	return &GradebookService{
+		EvalRepo:       evalRepo,
+		TaskRepo:       taskRepo,
+		ProjectService: projectService,
+	}
+}
+
+// GradebookTask is a gradebook column: a deliverable or evaluated task.
+type GradebookTask struct {
+	TaskID      uint   `json:"taskId"`
+	Title       string `json:"title"`
+	UserStoryID uint   `json:"userStoryId"`
+}
+
+// GradebookCriterionScore is the score obtained on a single rubric criterion.
+type GradebookCriterionScore struct {
+	CriterionID uint    `json:"criterionId"`
+	Title       string  `json:"title"`
+	Score       float64 `json:"score"`
+	MaxPoints   float64 `json:"maxPoints"`
+}
+
+// GradebookEntry is one evaluation of a member's task.
+type GradebookEntry struct {
+	TaskID        uint                      `json:"taskId"`
+	EvaluationID  uint                      `json:"evaluationId"`
+	EvaluatorID   uint                      `json:"evaluatorId"`
+	EvaluatorName string                    `json:"evaluatorName"`
+	Status        models.EvaluationStatus   `json:"status"`
+	RubricID      uint                      `json:"rubricId"`
+	RubricName    string                    `json:"rubricName"`
+	RubricVersion int                       `json:"rubricVersion"`
+	TotalScore    float64                   `json:"totalScore"`
+	MaxScore      float64                   `json:"maxScore"`
+	Percentage    float64                   `json:"percentage"`
+	Criteria      []GradebookCriterionScore `json:"criteria"`
+}
+
+// GradebookRow holds every evaluation received by a member. A task graded by several
+// evaluators counts once, with the mean of their scores; WeightedAverage then weighs
+// each task by its rubric's maximum score (TotalScore / MaxScore, as a percentage).
+type GradebookRow struct {
+	UserID          uint             `json:"userId"`
+	Name            string           `json:"name"`
+	Email           string           `json:"email"`
+	Entries         []GradebookEntry `json:"entries"`
+	TotalScore      float64          `json:"totalScore"`
+	MaxScore        float64          `json:"maxScore"`
+	WeightedAverage *float64         `json:"weightedAverage"`
+}
+
+// Gradebook is the members × tasks matrix of evaluation results of a project.
+type Gradebook struct {
+	ProjectID   uint            `json:"projectId"`
+	ProjectName string          `json:"projectName"`
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Tasks       []GradebookTask `json:"tasks"`
+	Rows        []GradebookRow  `json:"rows"`
+}
+
+// GetGradebook builds the gradebook of a project. Only instructors, scrum masters and
+// platform admins may see it. Drafts are left out, and so are submitted evaluations for
+// users not allowed to evaluate the project: they only see the published ones.
+func (s *GradebookService) GetGradebook(projectID, userID uint, userRole string) (*Gradebook, error) {
+	project, err := s.ProjectService.GetProjectByID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("project not found")
+	}
+	if userRole != string(models.RoleAdmin) {
+		role, _ := s.ProjectService.GetUserRoleInProject(userID, projectID)
+		if models.ProjectRole(role) != models.RoleInstructor && models.ProjectRole(role) != models.RoleScrumMaster {
+			return nil, fmt.Errorf("forbidden: only instructors and scrum masters can view the gradebook")
+		}
+	}
+
+	members, err := s.ProjectService.GetProjectMembers(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load project members: %w", err)
+	}
+	tasks, err := s.TaskRepo.GetDeliverableTasksByProjectID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load deliverable tasks: %w", err)
+	}
+	evaluations, err := s.EvalRepo.GetTaskEvaluationsByProjectID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load evaluations: %w", err)
+	}
+	canEvaluate, err := s.ProjectService.CanEvaluate(userID, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify user role in project: %w", err)
+	}
+	if !canEvaluate {
+		published := make([]models.Evaluation, 0, len(evaluations))
+		for _, evaluation := range evaluations {
+			if evaluation.Status == models.EvaluationStatusPublished {
+				published = append(published, evaluation)
+			}
+		}
+		evaluations = published
+	}
+
+	gradebook := &Gradebook{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		GeneratedAt: time.Now(),
+		Tasks:       make([]GradebookTask, 0, len(tasks)),
+		Rows:        make([]GradebookRow, 0, len(members)),
+	}
+
+	// Columns: deliverable tasks plus any other task that has been evaluated.
+	columns := make(map[uint]bool)
+	for _, task := range tasks {
+		columns[task.ID] = true
+		gradebook.Tasks = append(gradebook.Tasks, GradebookTask{TaskID: task.ID, Title: task.Title, UserStoryID: task.UserStoryID})
+	}
+	for _, evaluation := range evaluations {
+		if evaluation.Task != nil && !columns[evaluation.Task.ID] {
+			columns[evaluation.Task.ID] = true
+			gradebook.Tasks = append(gradebook.Tasks, GradebookTask{TaskID: evaluation.Task.ID, Title: evaluation.Task.Title, UserStoryID: evaluation.Task.UserStoryID})
+		}
+	}
+	sort.Slice(gradebook.Tasks, func(i, j int) bool { return gradebook.Tasks[i].TaskID < gradebook.Tasks[j].TaskID })
+
+	// Rows: every member except instructors, in membership order.
+	rows := make(map[uint]*GradebookRow)
+	for _, member := range members {
+		if models.ProjectRole(member.Role) == models.RoleInstructor {
+			continue
+		}
+		gradebook.Rows = append(gradebook.Rows, GradebookRow{
+			UserID:  member.UserID,
+			Name:    fullName(member.User),
+			Email:   member.User.Correo,
+			Entries: []GradebookEntry{},
+		})
+	}
+	for i := range gradebook.Rows {
+		rows[gradebook.Rows[i].UserID] = &gradebook.Rows[i]
+	}
+
+	for _, evaluation := range evaluations {
+		if evaluation.Task == nil || evaluation.Task.AssignedToID == nil {
+			continue
+		}
+		row, ok := rows[*evaluation.Task.AssignedToID]
+		if !ok {
+			continue
+		}
+		row.Entries = append(row.Entries, gradebookEntry(evaluation))
+	}
+
+	for i := range gradebook.Rows {
+		summarizeGradebookRow(&gradebook.Rows[i])
+	}
+	return gradebook, nil
+}
+
+// ExportGradebookToCSV renders the gradebook matrix as CSV: one row per member and one
+// column per task holding the (mean) score obtained.
+func (s *GradebookService) ExportGradebookToCSV(projectID, userID uint, userRole string) ([]byte, error) {
+	gradebook, err := s.GetGradebook(projectID, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(bytes.Buffer)
+	w := csv.NewWriter(b)
+	for _, row := range gradebookMatrix(gradebook) {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = formatGradebookValue(value)
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("error flushing CSV writer: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// ExportGradebookToXLSX renders the gradebook as a workbook with the matrix on a
+// "Gradebook" sheet and the per-criterion breakdown on a "Details" sheet.
+func (s *GradebookService) ExportGradebookToXLSX(projectID, userID uint, userRole string) ([]byte, error) {
+	gradebook, err := s.GetGradebook(projectID, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+
+	taskTitles := make(map[uint]string, len(gradebook.Tasks))
+	for _, task := range gradebook.Tasks {
+		taskTitles[task.TaskID] = task.Title
+	}
+
+	details := [][]interface{}{{
+		"Member", "Email", "Task ID", "Task Title", "Evaluator", "Status",
+		"Rubric", "Rubric Version", "Criterion", "Score", "Max Points",
+	}}
+	for _, row := range gradebook.Rows {
+		for _, entry := range row.Entries {
+			for _, criterion := range entry.Criteria {
+				details = append(details, []interface{}{
+					row.Name, row.Email, entry.TaskID, taskTitles[entry.TaskID], entry.EvaluatorName, string(entry.Status),
+					entry.RubricName, entry.RubricVersion, criterion.Title, criterion.Score, criterion.MaxPoints,
+				})
+			}
+		}
+	}
+
+	return utils.WriteXLSX([]utils.XLSXSheet{
+		{Name: "Gradebook", Rows: gradebookMatrix(gradebook)},
+		{Name: "Details", Rows: details},
+	})
+}
+
+func gradebookEntry(evaluation models.Evaluation) GradebookEntry {
+	entry := GradebookEntry{
+		TaskID:        evaluation.Task.ID,
+		EvaluationID:  evaluation.ID,
+		EvaluatorID:   evaluation.EvaluatorID,
+		EvaluatorName: fullName(evaluation.Evaluator),
+		Status:        evaluation.Status,
+		RubricID:      evaluation.RubricID,
+		RubricName:    evaluation.Rubric.Name,
+		RubricVersion: evaluation.Rubric.Version,
+		TotalScore:    evaluation.TotalScore,
+		Criteria:      make([]GradebookCriterionScore, 0, len(evaluation.CriterionEvaluations)),
+	}
+	for _, criterion := range evaluation.Rubric.Criteria {
+		entry.MaxScore += criterion.MaxPoints
+	}
+	if entry.MaxScore > 0 {
+		entry.Percentage = entry.TotalScore / entry.MaxScore * 100
+	}
+	for _, ce := range evaluation.CriterionEvaluations {
+		entry.Criteria = append(entry.Criteria, GradebookCriterionScore{
+			CriterionID: ce.CriterionID,
+			Title:       ce.Criterion.Title,
+			Score:       ce.Score,
+			MaxPoints:   ce.Criterion.MaxPoints,
+		})
+	}
+	return entry
+}
+
+// summarizeGradebookRow computes a row's totals, averaging evaluators per task.
+func summarizeGradebookRow(row *GradebookRow) {
+	for _, score := range taskScores(row.Entries) {
+		row.TotalScore += score.total
+		row.MaxScore += score.max
+	}
+	if row.MaxScore > 0 {
+		average := row.TotalScore / row.MaxScore * 100
+		row.WeightedAverage = &average
+	}
+}
+
+type taskScore struct {
+	total, max float64
+}
+
+// taskScores returns the mean score and maximum of each task among the given entries.
+func taskScores(entries []GradebookEntry) map[uint]taskScore {
+	sums := make(map[uint]taskScore)
+	counts := make(map[uint]int)
+	for _, entry := range entries {
+		sum := sums[entry.TaskID]
+		sum.total += entry.TotalScore
+		sum.max += entry.MaxScore
+		sums[entry.TaskID] = sum
+		counts[entry.TaskID]++
+	}
+	for taskID, sum := range sums {
+		n := float64(counts[taskID])
+		sums[taskID] = taskScore{total: sum.total / n, max: sum.max / n}
+	}
+	return sums
+}
+
+// gradebookMatrix lays out the gradebook as a header plus one row per member.
+func gradebookMatrix(gradebook *Gradebook) [][]interface{} {
+	header := []interface{}{"Member", "Email"}
+	for _, task := range gradebook.Tasks {
+		header = append(header, fmt.Sprintf("%s (#%d)", task.Title, task.TaskID))
+	}
+	header = append(header, "Total Score", "Max Score", "Weighted Average (%)")
+
+	matrix := [][]interface{}{header}
+	for _, row := range gradebook.Rows {
+		scores := taskScores(row.Entries)
+		record := []interface{}{row.Name, row.Email}
+		for _, task := range gradebook.Tasks {
+			if score, ok := scores[task.TaskID]; ok {
+				record = append(record, score.total)
+			} else {
+				record = append(record, "")
+			}
+		}
+		record = append(record, row.TotalScore, row.MaxScore, row.WeightedAverage)
+		matrix = append(matrix, record)
+	}
+	return matrix
+}
+
+func formatGradebookValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', 2, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+	"gorm.io/gorm"
+)
+
+// Health factor keys.
+const (
+	HealthFactorCommitment = "commitment_completion"
+	HealthFactorVelocity   = "velocity_stability"
+	HealthFactorStaleTasks = "stale_tasks"
+	HealthFactorUnassigned = "unassigned_sprint_work"
+	HealthFactorEvents     = "missed_events"
+	HealthFactorScopeChurn = "scope_churn"
+)
+
+// Health statuses, from the overall score.
+const (
+	HealthStatusHealthy  = "healthy"  // 75 and above
+	HealthStatusAtRisk   = "at_risk"  // 50 to 74
+	HealthStatusCritical = "critical" // Below 50
+)
+
+const (
+	healthSprintWindow  = 5                   // Closed sprints considered for commitment and velocity
+	healthStaleAfter    = 5 * 24 * time.Hour  // In-flight tasks untouched this long are stale
+	healthEventWindow   = 14 * 24 * time.Hour // How far back missed deadlines count
+	healthEventPenalty  = 25                  // Points lost per missed deadline or milestone
+	healthHistoryDays   = 30
+	healthHealthyScore  = 75
+	healthCriticalScore = 50
+)
+
+// healthWeights is how much each factor counts towards the overall score. Factors
+// without data are left out and the remaining weights share the score.
+var healthWeights = map[string]int{
+	HealthFactorCommitment: 25,
+	HealthFactorVelocity:   15,
+	HealthFactorStaleTasks: 20,
+	HealthFactorUnassigned: 15,
+	HealthFactorEvents:     10,
+	HealthFactorScopeChurn: 15,
+}
+
+// HealthFactor is one signal of the project health score. Score goes from 0 (bad) to
+// 100 (good); Value is the raw measurement the score comes from.
+type HealthFactor struct {
+	Key       string  `json:"key"`
+	Score     int     `json:"score"`
+	Weight    int     `json:"weight"`
+	Available bool    `json:"available"` // False when there is not enough data; the factor is then left out
+	Value     float64 `json:"value"`
+	Detail    string  `json:"detail"`
+}
+
+// HealthSnapshot is the persisted score of a project on one day.
+type HealthSnapshot struct {
+	Date  time.Time `json:"date"`
+	Score int       `json:"score"`
+}
+
+// ProjectHealth is the health score of a project, the factors behind it and its history.
+type ProjectHealth struct {
+	ProjectID  uint             `json:"projectId"`
+	Score      int              `json:"score"`
+	Status     string           `json:"status"`
+	Factors    []HealthFactor   `json:"factors"`
+	History    []HealthSnapshot `json:"history"`
+	ComputedAt time.Time        `json:"computedAt"`
+}
+
+// HealthService scores how a project is doing from its sprints, tasks, events and scope.
+type HealthService struct {
+	Repo           *storage.HealthRepository
+	ProjectService *ProjectService
+}
+
+// NewHealthService creates a new instance of HealthService.
+func NewHealthService(repo *storage.HealthRepository, projectService *ProjectService) *HealthService {
+	return &HealthService{
+		Repo:           repo,
+		ProjectService: projectService,
+	}
+}
+
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *HealthService) WithContext(ctx context.Context) *HealthService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	scoped.ProjectService = s.ProjectService.WithContext(ctx)
+	return &scoped
+}
+
+// GetProjectHealth computes the project's health for a project member or an admin.
+func (s *HealthService) GetProjectHealth(projectID, requestingUserID uint, requestingUserRole string) (*ProjectHealth, error) {
+	if _, err := s.ProjectService.GetProjectByID(projectID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("project not found")
+		}
+		return nil, err
+	}
+	if requestingUserRole != string(models.RoleAdmin) {
+		if _, err := s.ProjectService.GetUserRoleInProject(requestingUserID, projectID); err != nil {
+			return nil, fmt.Errorf("forbidden: you are not a member of this project")
+		}
+	}
+	return s.ComputeProjectHealth(projectID, time.Now())
+}
+
+// ComputeProjectHealth scores the project as of now and stores the result as the
+// project's ProjectMetric for the day, replacing an earlier score of the same day.
+// Archived projects are scored but nothing is stored for them.
+func (s *HealthService) ComputeProjectHealth(projectID uint, now time.Time) (*ProjectHealth, error) {
+	project, err := s.ProjectService.GetProjectByID(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	sprints, err := s.Repo.GetClosedSprints(projectID, healthSprintWindow)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve closed sprints: %w", err)
+	}
+	active, err := s.Repo.GetActiveSprint(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve the active sprint: %w", err)
+	}
+	var activeID uint
+	if active != nil {
+		activeID = active.ID
+	}
+	tasks, err := s.Repo.CountTasks(projectID, activeID, now.Add(-healthStaleAfter))
+	if err != nil {
+		return nil, fmt.Errorf("could not count tasks: %w", err)
+	}
+	missed, err := s.Repo.GetMissedEvents(projectID, now.Add(-healthEventWindow), now)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve missed events: %w", err)
+	}
+	churn, err := s.scopeChurnFactor(projectID, active, now)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &ProjectHealth{
+		ProjectID: projectID,
+		Factors: []HealthFactor{
+			commitmentFactor(sprints),
+			velocityFactor(sprints),
+			staleTasksFactor(tasks),
+			unassignedFactor(active, tasks),
+			missedEventsFactor(missed),
+			churn,
+		},
+		ComputedAt: now,
+	}
+	health.Score = overallHealthScore(health.Factors)
+	health.Status = healthStatus(health.Score)
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if project.Status != string(models.ProjectStatusArchived) {
+		if err := s.saveMetric(projectID, day, health.Score, sprints); err != nil {
+			return nil, err
+		}
+	}
+
+	metrics, err := s.Repo.ListMetrics(projectID, day.AddDate(0, 0, -(healthHistoryDays-1)))
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve the health history: %w", err)
+	}
+	health.History = []HealthSnapshot{}
+	for _, metric := range metrics {
+		if metric.HealthScore != nil {
+			health.History = append(health.History, HealthSnapshot{Date: metric.Date, Score: *metric.HealthScore})
+		}
+	}
+	return health, nil
+}
+
+// saveMetric stores the day's score together with the project's story totals and velocity.
+func (s *HealthService) saveMetric(projectID uint, day time.Time, score int, sprints []storage.HealthSprintRow) error {
+	totals, err := s.Repo.GetStoryTotals(projectID)
+	if err != nil {
+		return fmt.Errorf("could not count user stories: %w", err)
+	}
+	metric := &models.ProjectMetric{
+		ProjectID:            projectID,
+		Date:                 day,
+		TotalUserStories:     &totals.TotalUserStories,
+		CompletedUserStories: &totals.CompletedUserStories,
+		TotalPoints:          &totals.TotalPoints,
+		CompletedPoints:      &totals.CompletedPoints,
+		HealthScore:          &score,
+	}
+	if len(sprints) > 0 {
+		velocity := int(math.Round(meanCompletedPoints(sprints)))
+		metric.AverageVelocity = &velocity
+	}
+	if err := s.Repo.SaveMetric(metric); err != nil {
+		return fmt.Errorf("could not save the health score: %w", err)
+	}
+	return nil
+}
+
+// scopeChurnFactor measures how many user stories moved into or out of the active
+// sprint since it started, relative to the stories it started with. Moves are read
+// from the audit log.
+func (s *HealthService) scopeChurnFactor(projectID uint, active *models.Sprint, now time.Time) (HealthFactor, error) {
+	factor := HealthFactor{Key: HealthFactorScopeChurn, Weight: healthWeights[HealthFactorScopeChurn]}
+	if active == nil || active.StartDate == nil || active.StartDate.After(now) {
+		factor.Detail = "No started sprint is active"
+		return factor, nil
+	}
+
+	entries, err := s.Repo.GetStoryAuditSince(projectID, *active.StartDate)
+	if err != nil {
+		return factor, fmt.Errorf("could not retrieve user story changes: %w", err)
+	}
+	current, err := s.Repo.CountSprintStories(active.ID)
+	if err != nil {
+		return factor, fmt.Errorf("could not count sprint user stories: %w", err)
+	}
+
+	added, removed := 0, 0
+	for _, entry := range entries {
+		before, after := auditSprintID(entry.Before), auditSprintID(entry.After)
+		switch {
+		case before != active.ID && after == active.ID:
+			added++
+		case before == active.ID && after != active.ID:
+			removed++
+		}
+	}
+
+	baseline := int(current) - added + removed
+	if baseline < 1 {
+		baseline = 1
+	}
+	factor.Available = true
+	factor.Value = roundTo(float64(added+removed)/float64(baseline)*100, 1)
+	factor.Score = clampScore(100 - factor.Value)
+	factor.Detail = fmt.Sprintf("%d user stories added and %d removed since the sprint started", added, removed)
+	return factor, nil
+}
+
+// auditSprintID returns the sprint_id of an audited user story snapshot, 0 when it has none.
+func auditSprintID(snapshot json.RawMessage) uint {
+	if len(snapshot) == 0 {
+		return 0
+	}
+	var row struct {
+		SprintID *uint `json:"sprint_id"`
+	}
+	if err := json.Unmarshal(snapshot, &row); err != nil || row.SprintID == nil {
+		return 0
+	}
+	return *row.SprintID
+}
+
+// commitmentFactor is the share of committed points completed in the recent closed
+// sprints, the most recent sprints weighing the most.
+func commitmentFactor(sprints []storage.HealthSprintRow) HealthFactor {
+	factor := HealthFactor{Key: HealthFactorCommitment, Weight: healthWeights[HealthFactorCommitment]}
+	var rates []float64 // Most recent first
+	for _, sprint := range sprints {
+		if sprint.CommittedPoints > 0 {
+			rates = append(rates, math.Min(float64(sprint.CompletedPoints)/float64(sprint.CommittedPoints), 1)*100)
+		}
+	}
+	if len(rates) == 0 {
+		factor.Detail = "No closed sprint with committed points"
+		return factor
+	}
+
+	var sum, weights float64
+	for i, rate := range rates {
+		weight := float64(len(rates) - i)
+		sum += rate * weight
+		weights += weight
+	}
+	factor.Available = true
+	factor.Value = roundTo(sum/weights, 1)
+	factor.Score = clampScore(factor.Value)
+	factor.Detail = fmt.Sprintf("%.0f%% of committed points completed over the last %d sprint(s)", factor.Value, len(rates))
+	if len(rates) > 1 {
+		factor.Detail += fmt.Sprintf(", trend %+.0f points", rates[0]-rates[len(rates)-1])
+	}
+	return factor
+}
+
+// velocityFactor scores how steady the completed points of the recent closed sprints
+// are, from their coefficient of variation.
+func velocityFactor(sprints []storage.HealthSprintRow) HealthFactor {
+	factor := HealthFactor{Key: HealthFactorVelocity, Weight: healthWeights[HealthFactorVelocity]}
+	if len(sprints) < 2 {
+		factor.Detail = "At least two closed sprints are needed"
+		return factor
+	}
+
+	mean := meanCompletedPoints(sprints)
+	factor.Available = true
+	if mean == 0 {
+		factor.Detail = "No points were completed in the last sprints"
+		return factor
+	}
+	var variance float64
+	for _, sprint := range sprints {
+		variance += math.Pow(float64(sprint.CompletedPoints)-mean, 2)
+	}
+	variation := math.Sqrt(variance/float64(len(sprints))) / mean * 100
+	factor.Value = roundTo(variation, 1)
+	factor.Score = clampScore(100 - variation)
+	factor.Detail = fmt.Sprintf("Velocity varies by %.0f%% around %.1f points over the last %d sprints", variation, mean, len(sprints))
+	return factor
+}
+
+func meanCompletedPoints(sprints []storage.HealthSprintRow) float64 {
+	total := 0
+	for _, sprint := range sprints {
+		total += sprint.CompletedPoints
+	}
+	return float64(total) / float64(len(sprints))
+}
+
+// staleTasksFactor is the share of in-progress and in-review tasks that have not been
+// updated for a while.
+func staleTasksFactor(tasks storage.HealthTaskCounts) HealthFactor {
+	factor := HealthFactor{Key: HealthFactorStaleTasks, Weight: healthWeights[HealthFactorStaleTasks], Available: true}
+	if tasks.InFlight == 0 {
+		factor.Score = 100
+		factor.Detail = "No tasks in progress or in review"
+		return factor
+	}
+	factor.Value = roundTo(float64(tasks.Stale)/float64(tasks.InFlight)*100, 1)
+	factor.Score = clampScore(100 - factor.Value)
+	factor.Detail = fmt.Sprintf("%d of %d tasks in progress or in review not updated for %d days",
+		tasks.Stale, tasks.InFlight, int(healthStaleAfter.Hours()/24))
+	return factor
+}
+
+// unassignedFactor is the share of open tasks in the active sprint that nobody owns.
+func unassignedFactor(active *models.Sprint, tasks storage.HealthTaskCounts) HealthFactor {
+	factor := HealthFactor{Key: HealthFactorUnassigned, Weight: healthWeights[HealthFactorUnassigned]}
+	if active == nil {
+		factor.Detail = "No sprint is active"
+		return factor
+	}
+	factor.Available = true
+	if tasks.SprintOpen == 0 {
+		factor.Score = 100
+		factor.Detail = "No open tasks in the active sprint"
+		return factor
+	}
+	factor.Value = roundTo(float64(tasks.SprintUnassigned)/float64(tasks.SprintOpen)*100, 1)
+	factor.Score = clampScore(100 - factor.Value)
+	factor.Detail = fmt.Sprintf("%d of %d open tasks in %s are unassigned", tasks.SprintUnassigned, tasks.SprintOpen, active.Name)
+	return factor
+}
+
+// missedEventsFactor counts the deadlines and milestones recently passed with work
+// still open; each one costs healthEventPenalty points.
+func missedEventsFactor(events []models.Event) HealthFactor {
+	factor := HealthFactor{Key: HealthFactorEvents, Weight: healthWeights[HealthFactorEvents], Available: true}
+	factor.Value = float64(len(events))
+	factor.Score = clampScore(float64(100 - healthEventPenalty*len(events)))
+	factor.Detail = fmt.Sprintf("%d deadline(s) or milestone(s) passed in the last %d days with work still open",
+		len(events), int(healthEventWindow.Hours()/24))
+	return factor
+}
+
+// overallHealthScore is the weighted mean of the available factors, 100 when none is.
+func overallHealthScore(factors []HealthFactor) int {
+	var sum, weights int
+	for _, factor := range factors {
+		if factor.Available {
+			sum += factor.Score * factor.Weight
+			weights += factor.Weight
+		}
+	}
+	if weights == 0 {
+		return 100
+	}
+	return int(math.Round(float64(sum) / float64(weights)))
+}
+
+func healthStatus(score int) string {
+	switch {
+	case score >= healthHealthyScore:
+		return HealthStatusHealthy
+	case score >= healthCriticalScore:
+		return HealthStatusAtRisk
+	default:
+		return HealthStatusCritical
+	}
+}
+
+func clampScore(value float64) int {
+	return int(math.Round(math.Max(0, math.Min(100, value))))
+}
+
+func roundTo(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
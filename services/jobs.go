@@ -0,0 +1,20 @@
+package services
+
+import "github.com/buga/API_wrkf/storage"
+
+// runJob runs fn once no other instance is running the job; without a lock it just runs fn.
+func runJob(lock *storage.JobLock, job storage.Job, fn func() error) error {
+	if lock == nil {
+		return fn()
+	}
+	return lock.Run(job, fn)
+}
+
+// tryRunJob runs fn unless another instance is running the job, and reports whether it
+// ran; without a lock it always runs fn.
+func tryRunJob(lock *storage.JobLock, job storage.Job, fn func() error) (bool, error) {
+	if lock == nil {
+		return true, fn()
+	}
+	return lock.TryRun(job, fn)
+}
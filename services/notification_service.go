@@ -1,13 +1,16 @@
 package services
 
 import (
+	"log"
+
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/storage"
 )
 
 // NotificationService provides notification-related services.
 type NotificationService struct {
-	repo *storage.NotificationRepository
+	repo   *storage.NotificationRepository
+	Events *EventBus // Optional, set once at startup
 }
 
 // NewNotificationService creates a new NotificationService.
@@ -28,6 +31,9 @@ func (s *NotificationService) CreateNotification(userID uint, message string, li
 	if err != nil {
 		return nil, err
 	}
+	s.publish(userID, func(unread int64) DomainEvent {
+		return NotificationCreated{Notification: notification, UnreadCount: unread}
+	})
 
 	return notification, nil
 }
@@ -37,14 +43,50 @@ func (s *NotificationService) GetUserNotifications(userID uint) ([]models.Notifi
 	return s.repo.GetByUserID(userID)
 }
 
-// MarkNotificationAsRead marks a single notification as read.
+// notificationSortFields are the fields the notification list can be sorted by.
+var notificationSortFields = sortFields{"id": "id", "createdAt": "created_at", "read": "is_read"}
+
+// ListUserNotifications retrieves one page of a user's notifications matching the filter.
+func (s *NotificationService) ListUserNotifications(userID uint, filter storage.NotificationFilter, query ListQuery) (*Page[models.Notification], error) {
+	opts, err := query.options(notificationSortFields, "id")
+	if err != nil {
+		return nil, err
+	}
+	notifications, total, err := s.repo.ListByUserID(userID, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(notifications, total, opts), nil
+}
+
+// CountUnread counts the user's unread notifications.
+func (s *NotificationService) CountUnread(userID uint) (int64, error) {
+	return s.repo.CountUnread(userID)
+}
+
+// MarkNotificationAsRead marks a single notification as read. The user's other devices
+// are told only when it was unread.
 func (s *NotificationService) MarkNotificationAsRead(notificationID uint, userID uint) error {
-	return s.repo.MarkAsRead(notificationID, userID)
+	updated, err := s.repo.MarkAsRead(notificationID, userID)
+	if err != nil || updated == 0 {
+		return err
+	}
+	s.publish(userID, func(unread int64) DomainEvent {
+		return NotificationsRead{NotificationIDs: []uint{notificationID}, UnreadCount: unread}
+	})
+	return nil
 }
 
 // MarkAllUserNotificationsAsRead marks all of a user's notifications as read.
 func (s *NotificationService) MarkAllUserNotificationsAsRead(userID uint) error {
-	return s.repo.MarkAllAsRead(userID)
+	updated, err := s.repo.MarkAllAsRead(userID)
+	if err != nil || updated == 0 {
+		return err
+	}
+	s.publish(userID, func(unread int64) DomainEvent {
+		return NotificationsRead{NotificationIDs: []uint{}, All: true, UnreadCount: unread}
+	})
+	return nil
 }
 
 // GetNotificationByID retrieves a single notification by its ID.
@@ -54,5 +96,25 @@ func (s *NotificationService) GetNotificationByID(id uint) (*models.Notification
 
 // DeleteNotification deletes a notification.
 func (s *NotificationService) DeleteNotification(notificationID uint, userID uint) error {
-	return s.repo.Delete(notificationID, userID)
+	if err := s.repo.Delete(notificationID, userID); err != nil {
+		return err
+	}
+	s.publish(userID, func(unread int64) DomainEvent {
+		return NotificationDeleted{NotificationID: notificationID, UnreadCount: unread}
+	})
+	return nil
+}
+
+// publish sends a user an event built with their current unread count. The count is
+// only queried when there is a bus to publish on.
+func (s *NotificationService) publish(userID uint, event func(unread int64) DomainEvent) {
+	if s.Events == nil {
+		return
+	}
+	unread, err := s.repo.CountUnread(userID)
+	if err != nil {
+		log.Printf("could not count unread notifications of user %d: %v", userID, err)
+		return
+	}
+	s.Events.PublishToUser(userID, event(unread))
 }
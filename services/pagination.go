@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/buga/API_wrkf/storage"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// ListQuery holds the paging and sorting parameters shared by every list endpoint.
+// Sort is a comma-separated list of fields, each prefixed with "-" to sort it in
+// descending order, e.g. "-createdAt,title".
+type ListQuery struct {
+	Limit  int
+	Offset int
+	Sort   string
+}
+
+// Page is one page of a list, with the total number of items matching its filters.
+type Page[T any] struct {
+	Items  []T   `json:"items"`
+	Total  int64 `json:"total"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+}
+
+// newPage wraps the items read with opts, so an empty page still encodes as [].
+func newPage[T any](items []T, total int64, opts storage.ListOptions) *Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return &Page[T]{Items: items, Total: total, Limit: opts.Limit, Offset: opts.Offset}
+}
+
+// sortFields maps the sort fields a list accepts to their columns.
+type sortFields map[string]string
+
+// names lists the accepted sort fields in alphabetical order.
+func (f sortFields) names() string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// options validates the query against the list's sort fields and applies the default
+// sort and the limit bounds.
+func (q ListQuery) options(fields sortFields, defaultSort string) (storage.ListOptions, error) {
+	opts := storage.ListOptions{Limit: q.Limit, Offset: q.Offset}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultListLimit
+	}
+	if opts.Limit > maxListLimit {
+		opts.Limit = maxListLimit
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+
+	raw := q.Sort
+	if strings.TrimSpace(raw) == "" {
+		raw = defaultSort
+	}
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		direction := "asc"
+		if strings.HasPrefix(field, "-") {
+			field, direction = field[1:], "desc"
+		}
+		column, ok := fields[field]
+		if !ok {
+			return opts, fmt.Errorf("invalid list query: unknown sort field '%s' (available: %s)", field, fields.names())
+		}
+		opts.Sort = append(opts.Sort, column+" "+direction)
+	}
+	return opts, nil
+}
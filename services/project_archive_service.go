@@ -0,0 +1,836 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+// ProjectArchiveSchemaVersion is the version of the archive format produced by ExportProjectArchive.
+const ProjectArchiveSchemaVersion = 1
+
+// ProjectArchive is the portable JSON representation of a whole project (see
+// docs/project_archive.md). The "id" fields and every "...Id" reference are local to
+// the archive; users are referenced by archive ID and matched by email on import.
+type ProjectArchive struct {
+	SchemaVersion    int                      `json:"schemaVersion"`
+	ExportedAt       time.Time                `json:"exportedAt"`
+	Users            []ArchiveUser            `json:"users"`
+	Project          ArchiveProject           `json:"project"`
+	Members          []ArchiveMember          `json:"members"`
+	Sprints          []ArchiveSprint          `json:"sprints"`
+	UserStories      []ArchiveUserStory       `json:"userStories"`
+	Tasks            []ArchiveTask            `json:"tasks"`
+	Rubrics          []ArchiveRubric          `json:"rubrics"`
+	EvaluationRounds []ArchiveEvaluationRound `json:"evaluationRounds"`
+	Evaluations      []ArchiveEvaluation      `json:"evaluations"`
+	Events           []ArchiveEvent           `json:"events"`
+}
+
+// ArchiveUser identifies a user referenced by the archive. Passwords are never exported.
+type ArchiveUser struct {
+	ID              uint   `json:"id"`
+	Email           string `json:"email"`
+	Nombre          string `json:"nombre"`
+	ApellidoPaterno string `json:"apellidoPaterno"`
+	ApellidoMaterno string `json:"apellidoMaterno"`
+}
+
+// ArchiveProject holds the project's own fields.
+type ArchiveProject struct {
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	Status           string     `json:"status"`
+	EvaluationPolicy string     `json:"evaluationPolicy"`
+	StartDate        *time.Time `json:"startDate"`
+	EndDate          *time.Time `json:"endDate"`
+	ArchivedAt       *time.Time `json:"archivedAt"`
+	CreatedByID      uint       `json:"createdById"`
+	CreatedAt        time.Time  `json:"createdAt"`
+}
+
+// ArchiveMember is a project membership.
+type ArchiveMember struct {
+	UserID uint   `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// ArchiveSprint is a sprint of the project.
+type ArchiveSprint struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Goal        string     `json:"goal"`
+	Status      string     `json:"status"`
+	StartDate   *time.Time `json:"startDate"`
+	EndDate     *time.Time `json:"endDate"`
+	CreatedByID uint       `json:"createdById"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// ArchiveUserStory is a user story of the project.
+type ArchiveUserStory struct {
+	ID                 uint      `json:"id"`
+	Title              string    `json:"title"`
+	Description        string    `json:"description"`
+	AcceptanceCriteria string    `json:"acceptanceCriteria"`
+	Priority           string    `json:"priority"`
+	Status             string    `json:"status"`
+	Points             *int      `json:"points"`
+	SprintID           *uint     `json:"sprintId"`
+	CreatedByID        uint      `json:"createdById"`
+	AssignedToID       *uint     `json:"assignedToId"`
+	Labels             []string  `json:"labels,omitempty"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+// ArchiveTask is a task with its change history and comments.
+type ArchiveTask struct {
+	ID             uint                 `json:"id"`
+	UserStoryID    uint                 `json:"userStoryId"`
+	Title          string               `json:"title"`
+	Description    string               `json:"description"`
+	Status         string               `json:"status"`
+	AssignedToID   *uint                `json:"assignedToId"`
+	EstimatedHours *float32             `json:"estimatedHours"`
+	SpentHours     *float32             `json:"spentHours"`
+	IsDeliverable  bool                 `json:"isDeliverable"`
+	Labels         []string             `json:"labels,omitempty"`
+	CreatedByID    uint                 `json:"createdById"`
+	CreatedAt      time.Time            `json:"createdAt"`
+	History        []ArchiveTaskHistory `json:"history"`
+	Comments       []ArchiveTaskComment `json:"comments"`
+}
+
+// ArchiveTaskHistory is an entry of a task's change history.
+type ArchiveTaskHistory struct {
+	ChangedByID uint      `json:"changedById"`
+	FieldName   string    `json:"fieldName"`
+	OldValue    string    `json:"oldValue"`
+	NewValue    string    `json:"newValue"`
+	ChangedAt   time.Time `json:"changedAt"`
+}
+
+// ArchiveTaskComment is a comment on a task.
+type ArchiveTaskComment struct {
+	AuthorID  uint      `json:"authorId"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ArchiveRubric is a rubric version of the project. Versions of the same rubric share
+// a rootRubricId pointing at the first one, which always comes earlier in the list.
+type ArchiveRubric struct {
+	ID           uint               `json:"id"`
+	Name         string             `json:"name"`
+	Description  string             `json:"description"`
+	Category     string             `json:"category"`
+	Status       string             `json:"status"`
+	Version      int                `json:"version"`
+	RootRubricID *uint              `json:"rootRubricId"`
+	SupersededAt *time.Time         `json:"supersededAt"`
+	CreatedByID  uint               `json:"createdById"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	Criteria     []ArchiveCriterion `json:"criteria"`
+}
+
+// ArchiveCriterion is a criterion of an archived rubric.
+type ArchiveCriterion struct {
+	ID                uint                  `json:"id"`
+	Title             string                `json:"title"`
+	Description       string                `json:"description"`
+	MaxPoints         float64               `json:"maxPoints"`
+	OriginCriterionID *uint                 `json:"originCriterionId"`
+	Levels            []RubricLevelDocument `json:"levels"`
+}
+
+// ArchiveEvaluationRound is a peer and self evaluation round with its review assignments.
+type ArchiveEvaluationRound struct {
+	ID             uint                `json:"id"`
+	Name           string              `json:"name"`
+	SprintID       uint                `json:"sprintId"`
+	RubricID       uint                `json:"rubricId"`
+	Anonymous      bool                `json:"anonymous"`
+	PeersPerMember int                 `json:"peersPerMember"`
+	Status         string              `json:"status"`
+	DueDate        *time.Time          `json:"dueDate"`
+	CreatedByID    uint                `json:"createdById"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	Assignments    []ArchiveAssignment `json:"assignments"`
+}
+
+// ArchiveAssignment records that a reviewer must evaluate a reviewee within a round.
+type ArchiveAssignment struct {
+	ReviewerID uint `json:"reviewerId"`
+	RevieweeID uint `json:"revieweeId"`
+}
+
+// ArchiveEvaluation is a task, peer or self evaluation with its criterion scores.
+type ArchiveEvaluation struct {
+	ID              uint                    `json:"id"`
+	Type            string                  `json:"type"`
+	TaskID          *uint                   `json:"taskId"`
+	RoundID         *uint                   `json:"roundId"`
+	EvaluateeID     *uint                   `json:"evaluateeId"`
+	EvaluatorID     uint                    `json:"evaluatorId"`
+	RubricID        uint                    `json:"rubricId"`
+	OverallFeedback string                  `json:"overallFeedback"`
+	TotalScore      float64                 `json:"totalScore"`
+	Status          string                  `json:"status"`
+	SubmittedAt     *time.Time              `json:"submittedAt"`
+	PublishedAt     *time.Time              `json:"publishedAt"`
+	CreatedAt       time.Time               `json:"createdAt"`
+	Scores          []ArchiveCriterionScore `json:"scores"`
+}
+
+// ArchiveCriterionScore is the score given to one criterion in an evaluation.
+type ArchiveCriterionScore struct {
+	CriterionID uint    `json:"criterionId"`
+	Score       float64 `json:"score"`
+	Feedback    string  `json:"feedback"`
+}
+
+// ArchiveEvent is a calendar event of the project.
+type ArchiveEvent struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Type        string    `json:"type"`
+	StartDate   time.Time `json:"startDate"`
+	EndDate     time.Time `json:"endDate"`
+	CreatedByID uint      `json:"createdById"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ProjectArchiveImportRequest holds an uploaded archive. Unless DryRun is false,
+// the archive is only validated and nothing is written.
+type ProjectArchiveImportRequest struct {
+	Data   []byte
+	Name   string // Overrides the project name found in the archive
+	DryRun bool
+}
+
+// ProjectArchiveIssue is a validation problem found in an archive. Location is a JSON
+// path such as "tasks[3].userStoryId".
+type ProjectArchiveIssue struct {
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ProjectArchiveImportResult is the outcome of an import or a dry run.
+type ProjectArchiveImportResult struct {
+	DryRun         bool                  `json:"dryRun"`
+	Project        *models.Project       `json:"project,omitempty"` // Set once imported
+	Counts         map[string]int        `json:"counts"`            // Rows that are (or would be) created, per kind
+	MatchedUsers   int                   `json:"matchedUsers"`
+	UnmatchedUsers []string              `json:"unmatchedUsers"` // Emails with no account on this instance
+	Warnings       []string              `json:"warnings"`
+	Issues         []ProjectArchiveIssue `json:"issues"`
+}
+
+// ProjectArchiveService exports projects to portable archives and imports them back,
+// on the same instance or on another one.
+type ProjectArchiveService struct {
+	Repo           *storage.ProjectArchiveRepository
+	UserRepo       *storage.UserRepository
+	ProjectService *ProjectService // To check user roles
+}
+
+// NewProjectArchiveService creates a new instance of ProjectArchiveService.
+func NewProjectArchiveService(repo *storage.ProjectArchiveRepository, userRepo *storage.UserRepository, projectService *ProjectService) *ProjectArchiveService {
+	return &ProjectArchiveService{Repo: repo, UserRepo: userRepo, ProjectService: projectService}
+}
+
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *ProjectArchiveService) WithContext(ctx context.Context) *ProjectArchiveService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	return &scoped
+}
+
+// ExportProjectArchive builds the archive of a project. Any project member or an admin may export it.
+func (s *ProjectArchiveService) ExportProjectArchive(projectID, requestingUserID uint, requestingUserRole string) (*ProjectArchive, error) {
+	if _, err := s.ProjectService.GetProjectByID(projectID); err != nil {
+		return nil, fmt.Errorf("project not found")
+	}
+	if requestingUserRole != string(models.RoleAdmin) {
+		if _, err := s.ProjectService.GetUserRoleInProject(requestingUserID, projectID); err != nil {
+			return nil, fmt.Errorf("forbidden: you are not a member of this project")
+		}
+	}
+
+	graph, err := s.Repo.LoadProjectGraph(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not read project: %w", err)
+	}
+	archive := archiveFromGraph(graph)
+
+	userIDs := make([]uint, 0, len(archive.Users))
+	for _, user := range archive.Users {
+		userIDs = append(userIDs, user.ID)
+	}
+	users, err := s.UserRepo.GetUsersByIDs(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("could not read users: %w", err)
+	}
+	archive.Users = archive.Users[:0]
+	for _, user := range users {
+		archive.Users = append(archive.Users, ArchiveUser{
+			ID:              user.ID,
+			Email:           user.Correo,
+			Nombre:          user.Nombre,
+			ApellidoPaterno: user.ApellidoPaterno,
+			ApellidoMaterno: user.ApellidoMaterno,
+		})
+	}
+	return archive, nil
+}
+
+// ImportProjectArchive validates an archive and, unless it is a dry run, creates a new
+// project from it in a single transaction. Users are matched by email. References to
+// users missing on this instance fall back to the importing user, assignees are cleared,
+// and memberships, peer review assignments and evaluations of missing users are skipped;
+// all of this is reported as warnings. When the archive has problems the result lists
+// them and the error starts with "invalid project archive".
+func (s *ProjectArchiveService) ImportProjectArchive(req ProjectArchiveImportRequest, requestingUserID uint) (*ProjectArchiveImportResult, error) {
+	result := &ProjectArchiveImportResult{DryRun: req.DryRun, UnmatchedUsers: []string{}, Warnings: []string{}}
+
+	var archive ProjectArchive
+	if err := json.Unmarshal(req.Data, &archive); err != nil {
+		result.Issues = []ProjectArchiveIssue{{Message: fmt.Sprintf("malformed JSON: %v", err)}}
+		return result, fmt.Errorf("invalid project archive: %d problem(s) found", len(result.Issues))
+	}
+	if req.Name != "" {
+		archive.Project.Name = req.Name
+	}
+	if result.Issues = validateProjectArchive(&archive); len(result.Issues) > 0 {
+		return result, fmt.Errorf("invalid project archive: %d problem(s) found", len(result.Issues))
+	}
+
+	users, err := s.matchArchiveUsers(archive.Users)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range archive.Users {
+		if _, ok := users[user.ID]; ok {
+			result.MatchedUsers++
+		} else {
+			result.UnmatchedUsers = append(result.UnmatchedUsers, user.Email)
+		}
+	}
+
+	graph, warnings := graphFromArchive(&archive, users, requestingUserID)
+	result.Warnings = append(result.Warnings, warnings...)
+	result.Counts = graphCounts(graph)
+	if req.DryRun {
+		return result, nil
+	}
+
+	if err := s.Repo.ImportProjectGraph(graph); err != nil {
+		return nil, fmt.Errorf("could not import project: %w", err)
+	}
+	if result.Project, err = s.ProjectService.GetProjectByID(graph.Project.ID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// matchArchiveUsers maps the archive's user IDs to the IDs of local users with the same email.
+func (s *ProjectArchiveService) matchArchiveUsers(archiveUsers []ArchiveUser) (map[uint]uint, error) {
+	emails := make([]string, 0, len(archiveUsers))
+	for _, user := range archiveUsers {
+		emails = append(emails, user.Email)
+	}
+	localUsers, err := s.UserRepo.GetUsersByEmails(emails)
+	if err != nil {
+		return nil, fmt.Errorf("could not match users: %w", err)
+	}
+	byEmail := make(map[string]uint, len(localUsers))
+	for _, user := range localUsers {
+		byEmail[strings.ToLower(user.Correo)] = user.ID
+	}
+
+	matched := make(map[uint]uint, len(archiveUsers))
+	for _, user := range archiveUsers {
+		if id, ok := byEmail[strings.ToLower(user.Email)]; ok {
+			matched[user.ID] = id
+		}
+	}
+	return matched, nil
+}
+
+// archiveFromGraph converts a project graph to an archive. Users only carry their IDs;
+// the caller fills in their details.
+func archiveFromGraph(graph *storage.ProjectGraph) *ProjectArchive {
+	userIDs := map[uint]bool{}
+	ref := func(id uint) uint {
+		if id != 0 { // Zero means no user was recorded
+			userIDs[id] = true
+		}
+		return id
+	}
+	optRef := func(id *uint) *uint {
+		if id != nil {
+			ref(*id)
+		}
+		return id
+	}
+
+	p := graph.Project
+	archive := &ProjectArchive{
+		SchemaVersion: ProjectArchiveSchemaVersion,
+		ExportedAt:    time.Now().UTC(),
+		Project: ArchiveProject{
+			Name:             p.Name,
+			Description:      p.Description,
+			Status:           p.Status,
+			EvaluationPolicy: string(p.EvaluationPolicy),
+			StartDate:        p.StartDate,
+			EndDate:          p.EndDate,
+			ArchivedAt:       p.ArchivedAt,
+			CreatedByID:      ref(p.CreatedByID),
+			CreatedAt:        p.CreatedAt,
+		},
+		Members:          []ArchiveMember{},
+		Sprints:          []ArchiveSprint{},
+		UserStories:      []ArchiveUserStory{},
+		Tasks:            []ArchiveTask{},
+		Rubrics:          []ArchiveRubric{},
+		EvaluationRounds: []ArchiveEvaluationRound{},
+		Evaluations:      []ArchiveEvaluation{},
+		Events:           []ArchiveEvent{},
+	}
+
+	for _, m := range graph.Members {
+		archive.Members = append(archive.Members, ArchiveMember{UserID: ref(m.UserID), Role: m.Role})
+	}
+	for _, sp := range graph.Sprints {
+		archive.Sprints = append(archive.Sprints, ArchiveSprint{
+			ID: sp.ID, Name: sp.Name, Goal: sp.Goal, Status: sp.Status,
+			StartDate: sp.StartDate, EndDate: sp.EndDate,
+			CreatedByID: ref(sp.CreatedByID), CreatedAt: sp.CreatedAt,
+		})
+	}
+	for _, us := range graph.UserStories {
+		archive.UserStories = append(archive.UserStories, ArchiveUserStory{
+			ID: us.ID, Title: us.Title, Description: us.Description, AcceptanceCriteria: us.AcceptanceCriteria,
+			Priority: us.Priority, Status: us.Status, Points: us.Points, SprintID: us.SprintID,
+			CreatedByID: ref(us.CreatedByID), AssignedToID: optRef(us.AssignedToID), Labels: us.Labels, CreatedAt: us.CreatedAt,
+		})
+	}
+	for _, t := range graph.Tasks {
+		task := ArchiveTask{
+			ID: t.ID, UserStoryID: t.UserStoryID, Title: t.Title, Description: t.Description,
+			Status: string(t.Status), AssignedToID: optRef(t.AssignedToID),
+			EstimatedHours: t.EstimatedHours, SpentHours: t.SpentHours, IsDeliverable: t.IsDeliverable,
+			Labels: t.Labels, CreatedByID: ref(t.CreatedByID), CreatedAt: t.CreatedAt,
+			History: []ArchiveTaskHistory{}, Comments: []ArchiveTaskComment{},
+		}
+		for _, h := range t.History {
+			task.History = append(task.History, ArchiveTaskHistory{
+				ChangedByID: ref(h.ChangedByID), FieldName: h.FieldName, OldValue: h.OldValue, NewValue: h.NewValue, ChangedAt: h.ChangedAt,
+			})
+		}
+		for _, c := range t.Comments {
+			task.Comments = append(task.Comments, ArchiveTaskComment{AuthorID: ref(c.AuthorID), Content: c.Content, CreatedAt: c.CreatedAt})
+		}
+		archive.Tasks = append(archive.Tasks, task)
+	}
+	for _, r := range graph.Rubrics {
+		rubric := ArchiveRubric{
+			ID: r.ID, Name: r.Name, Description: r.Description, Category: r.Category, Status: string(r.Status),
+			Version: r.Version, RootRubricID: r.RootRubricID, SupersededAt: r.SupersededAt,
+			CreatedByID: ref(r.CreatedByID), CreatedAt: r.CreatedAt, Criteria: []ArchiveCriterion{},
+		}
+		for _, c := range r.Criteria {
+			criterion := ArchiveCriterion{
+				ID: c.ID, Title: c.Title, Description: c.Description, MaxPoints: c.MaxPoints,
+				OriginCriterionID: c.OriginCriterionID, Levels: []RubricLevelDocument{},
+			}
+			for _, l := range c.Levels {
+				criterion.Levels = append(criterion.Levels, RubricLevelDocument{Score: l.Score, Description: l.Description})
+			}
+			rubric.Criteria = append(rubric.Criteria, criterion)
+		}
+		archive.Rubrics = append(archive.Rubrics, rubric)
+	}
+	for _, r := range graph.EvaluationRounds {
+		round := ArchiveEvaluationRound{
+			ID: r.ID, Name: r.Name, SprintID: r.SprintID, RubricID: r.RubricID, Anonymous: r.Anonymous,
+			PeersPerMember: r.PeersPerMember, Status: string(r.Status), DueDate: r.DueDate,
+			CreatedByID: ref(r.CreatedByID), CreatedAt: r.CreatedAt, Assignments: []ArchiveAssignment{},
+		}
+		for _, a := range r.Assignments {
+			round.Assignments = append(round.Assignments, ArchiveAssignment{ReviewerID: ref(a.ReviewerID), RevieweeID: ref(a.RevieweeID)})
+		}
+		archive.EvaluationRounds = append(archive.EvaluationRounds, round)
+	}
+	for _, e := range graph.Evaluations {
+		evaluation := ArchiveEvaluation{
+			ID: e.ID, Type: string(e.Type), TaskID: e.TaskID, RoundID: e.RoundID, EvaluateeID: optRef(e.EvaluateeID),
+			EvaluatorID: ref(e.EvaluatorID), RubricID: e.RubricID, OverallFeedback: e.OverallFeedback,
+			TotalScore: e.TotalScore, Status: string(e.Status), SubmittedAt: e.SubmittedAt, PublishedAt: e.PublishedAt,
+			CreatedAt: e.CreatedAt, Scores: []ArchiveCriterionScore{},
+		}
+		for _, ce := range e.CriterionEvaluations {
+			evaluation.Scores = append(evaluation.Scores, ArchiveCriterionScore{CriterionID: ce.CriterionID, Score: ce.Score, Feedback: ce.Feedback})
+		}
+		archive.Evaluations = append(archive.Evaluations, evaluation)
+	}
+	for _, e := range graph.Events {
+		archive.Events = append(archive.Events, ArchiveEvent{
+			Title: e.Title, Description: e.Description, Type: e.Type, StartDate: e.StartDate, EndDate: e.EndDate,
+			CreatedByID: ref(e.CreatedByID), CreatedAt: e.CreatedAt,
+		})
+	}
+
+	for id := range userIDs {
+		archive.Users = append(archive.Users, ArchiveUser{ID: id})
+	}
+	return archive
+}
+
+// validateProjectArchive checks the schema version, required fields and that every
+// reference points at an item of the archive.
+func validateProjectArchive(a *ProjectArchive) []ProjectArchiveIssue {
+	var issues []ProjectArchiveIssue
+	add := func(location, format string, args ...interface{}) {
+		issues = append(issues, ProjectArchiveIssue{Location: location, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if a.SchemaVersion != ProjectArchiveSchemaVersion {
+		add("schemaVersion", "unsupported schema version %d, expected %d", a.SchemaVersion, ProjectArchiveSchemaVersion)
+		return issues
+	}
+	if strings.TrimSpace(a.Project.Name) == "" {
+		add("project.name", "the project name is required")
+	}
+	if a.Project.Status != "" && !models.ProjectStatus(a.Project.Status).IsValid() {
+		add("project.status", "unknown project status '%s'", a.Project.Status)
+	}
+
+	// collect indexes the archive IDs of a list and reports duplicates.
+	collect := func(kind string, ids []uint) map[uint]bool {
+		set := make(map[uint]bool, len(ids))
+		for i, id := range ids {
+			if set[id] {
+				add(fmt.Sprintf("%s[%d].id", kind, i), "duplicate id %d", id)
+			}
+			set[id] = true
+		}
+		return set
+	}
+	check := func(set map[uint]bool, location string, id uint) {
+		if !set[id] {
+			add(location, "unknown reference %d", id)
+		}
+	}
+	checkOpt := func(set map[uint]bool, location string, id *uint) {
+		if id != nil {
+			check(set, location, *id)
+		}
+	}
+
+	var ids []uint
+	for _, u := range a.Users {
+		ids = append(ids, u.ID)
+	}
+	users := collect("users", ids)
+	users[0] = true // Rows created without a recorded user keep a zero reference
+	for i, u := range a.Users {
+		if strings.TrimSpace(u.Email) == "" {
+			add(fmt.Sprintf("users[%d].email", i), "the user email is required")
+		}
+	}
+
+	ids = ids[:0]
+	for _, sp := range a.Sprints {
+		ids = append(ids, sp.ID)
+	}
+	sprints := collect("sprints", ids)
+	ids = ids[:0]
+	for _, us := range a.UserStories {
+		ids = append(ids, us.ID)
+	}
+	stories := collect("userStories", ids)
+	ids = ids[:0]
+	for _, t := range a.Tasks {
+		ids = append(ids, t.ID)
+	}
+	tasks := collect("tasks", ids)
+	ids = ids[:0]
+	for _, r := range a.EvaluationRounds {
+		ids = append(ids, r.ID)
+	}
+	rounds := collect("evaluationRounds", ids)
+
+	check(users, "project.createdById", a.Project.CreatedByID)
+	for i, m := range a.Members {
+		check(users, fmt.Sprintf("members[%d].userId", i), m.UserID)
+		if !models.ProjectRole(m.Role).IsValid() {
+			add(fmt.Sprintf("members[%d].role", i), "unknown project role '%s'", m.Role)
+		}
+	}
+	for i, sp := range a.Sprints {
+		check(users, fmt.Sprintf("sprints[%d].createdById", i), sp.CreatedByID)
+	}
+	for i, us := range a.UserStories {
+		checkOpt(sprints, fmt.Sprintf("userStories[%d].sprintId", i), us.SprintID)
+		check(users, fmt.Sprintf("userStories[%d].createdById", i), us.CreatedByID)
+		checkOpt(users, fmt.Sprintf("userStories[%d].assignedToId", i), us.AssignedToID)
+	}
+	for i, t := range a.Tasks {
+		check(stories, fmt.Sprintf("tasks[%d].userStoryId", i), t.UserStoryID)
+		check(users, fmt.Sprintf("tasks[%d].createdById", i), t.CreatedByID)
+		checkOpt(users, fmt.Sprintf("tasks[%d].assignedToId", i), t.AssignedToID)
+		if t.Status != "" && !models.IsValidTaskStatus(t.Status) {
+			add(fmt.Sprintf("tasks[%d].status", i), "unknown task status '%s'", t.Status)
+		}
+		for j, h := range t.History {
+			check(users, fmt.Sprintf("tasks[%d].history[%d].changedById", i, j), h.ChangedByID)
+		}
+		for j, c := range t.Comments {
+			check(users, fmt.Sprintf("tasks[%d].comments[%d].authorId", i, j), c.AuthorID)
+		}
+	}
+
+	// Rubric versions and criterion origins may only point backwards in the list.
+	rubrics := map[uint]bool{}
+	criteria := map[uint]bool{}
+	for i, r := range a.Rubrics {
+		if rubrics[r.ID] {
+			add(fmt.Sprintf("rubrics[%d].id", i), "duplicate id %d", r.ID)
+		}
+		checkOpt(rubrics, fmt.Sprintf("rubrics[%d].rootRubricId", i), r.RootRubricID)
+		check(users, fmt.Sprintf("rubrics[%d].createdById", i), r.CreatedByID)
+		for j, c := range r.Criteria {
+			checkOpt(criteria, fmt.Sprintf("rubrics[%d].criteria[%d].originCriterionId", i, j), c.OriginCriterionID)
+		}
+		for _, c := range r.Criteria {
+			if criteria[c.ID] {
+				add(fmt.Sprintf("rubrics[%d].criteria", i), "duplicate criterion id %d", c.ID)
+			}
+			criteria[c.ID] = true
+		}
+		rubrics[r.ID] = true
+	}
+
+	for i, r := range a.EvaluationRounds {
+		check(sprints, fmt.Sprintf("evaluationRounds[%d].sprintId", i), r.SprintID)
+		check(rubrics, fmt.Sprintf("evaluationRounds[%d].rubricId", i), r.RubricID)
+		check(users, fmt.Sprintf("evaluationRounds[%d].createdById", i), r.CreatedByID)
+		for j, as := range r.Assignments {
+			check(users, fmt.Sprintf("evaluationRounds[%d].assignments[%d].reviewerId", i, j), as.ReviewerID)
+			check(users, fmt.Sprintf("evaluationRounds[%d].assignments[%d].revieweeId", i, j), as.RevieweeID)
+		}
+	}
+	for i, e := range a.Evaluations {
+		if (e.TaskID == nil) == (e.RoundID == nil) {
+			add(fmt.Sprintf("evaluations[%d]", i), "an evaluation needs either a taskId or a roundId")
+		}
+		checkOpt(tasks, fmt.Sprintf("evaluations[%d].taskId", i), e.TaskID)
+		checkOpt(rounds, fmt.Sprintf("evaluations[%d].roundId", i), e.RoundID)
+		check(rubrics, fmt.Sprintf("evaluations[%d].rubricId", i), e.RubricID)
+		check(users, fmt.Sprintf("evaluations[%d].evaluatorId", i), e.EvaluatorID)
+		checkOpt(users, fmt.Sprintf("evaluations[%d].evaluateeId", i), e.EvaluateeID)
+		for j, sc := range e.Scores {
+			check(criteria, fmt.Sprintf("evaluations[%d].scores[%d].criterionId", i, j), sc.CriterionID)
+		}
+	}
+	for i, e := range a.Events {
+		check(users, fmt.Sprintf("events[%d].createdById", i), e.CreatedByID)
+	}
+	return issues
+}
+
+// graphFromArchive converts a validated archive to a project graph with local user IDs.
+// users maps archive user IDs to local ones; importerID stands in for missing users.
+func graphFromArchive(a *ProjectArchive, users map[uint]uint, importerID uint) (*storage.ProjectGraph, []string) {
+	var warnings []string
+	fallbacks := 0
+	required := func(id uint) uint {
+		if id == 0 {
+			return 0
+		}
+		if local, ok := users[id]; ok {
+			return local
+		}
+		fallbacks++
+		return importerID
+	}
+	optional := func(id *uint) *uint {
+		if id == nil {
+			return nil
+		}
+		if local, ok := users[*id]; ok {
+			return &local
+		}
+		fallbacks++
+		return nil
+	}
+	matched := func(id uint) bool {
+		_, ok := users[id]
+		return ok
+	}
+
+	p := a.Project
+	project := &models.Project{
+		Name:             p.Name,
+		Description:      p.Description,
+		Status:           p.Status,
+		EvaluationPolicy: models.EvaluationPolicy(p.EvaluationPolicy),
+		StartDate:        p.StartDate,
+		EndDate:          p.EndDate,
+		ArchivedAt:       p.ArchivedAt,
+		CreatedByID:      required(p.CreatedByID),
+		CreatedAt:        p.CreatedAt,
+	}
+	if project.Status == "" {
+		project.Status = string(models.ProjectStatusPlanning)
+	}
+	if !project.EvaluationPolicy.IsValid() {
+		project.EvaluationPolicy = models.EvaluationPolicyInstructorsOnly
+	}
+	graph := &storage.ProjectGraph{Project: project}
+
+	importerIsMember := false
+	for _, m := range a.Members {
+		if !matched(m.UserID) {
+			warnings = append(warnings, fmt.Sprintf("membership of user %d skipped: no matching account", m.UserID))
+			continue
+		}
+		local := users[m.UserID]
+		importerIsMember = importerIsMember || local == importerID
+		graph.Members = append(graph.Members, models.ProjectMember{UserID: local, Role: m.Role})
+	}
+	if !importerIsMember {
+		graph.Members = append(graph.Members, models.ProjectMember{UserID: importerID, Role: string(models.RoleProductOwner)})
+		warnings = append(warnings, "the importing user was added to the project as product_owner")
+	}
+
+	for _, sp := range a.Sprints {
+		graph.Sprints = append(graph.Sprints, models.Sprint{
+			ID: sp.ID, Name: sp.Name, Goal: sp.Goal, Status: sp.Status, StartDate: sp.StartDate, EndDate: sp.EndDate,
+			CreatedByID: required(sp.CreatedByID), CreatedAt: sp.CreatedAt,
+		})
+	}
+	for _, us := range a.UserStories {
+		graph.UserStories = append(graph.UserStories, models.UserStory{
+			ID: us.ID, Title: us.Title, Description: us.Description, AcceptanceCriteria: us.AcceptanceCriteria,
+			Priority: us.Priority, Status: us.Status, Points: us.Points, SprintID: us.SprintID,
+			CreatedByID: required(us.CreatedByID), AssignedToID: optional(us.AssignedToID), Labels: us.Labels, CreatedAt: us.CreatedAt,
+		})
+	}
+	for _, t := range a.Tasks {
+		task := models.Task{
+			ID: t.ID, UserStoryID: t.UserStoryID, Title: t.Title, Description: t.Description,
+			Status: models.TaskStatus(t.Status), AssignedToID: optional(t.AssignedToID),
+			EstimatedHours: t.EstimatedHours, SpentHours: t.SpentHours, IsDeliverable: t.IsDeliverable,
+			Labels: t.Labels, CreatedByID: required(t.CreatedByID), CreatedAt: t.CreatedAt,
+		}
+		for _, h := range t.History {
+			task.History = append(task.History, models.TaskHistory{
+				ChangedByID: required(h.ChangedByID), FieldName: h.FieldName, OldValue: h.OldValue, NewValue: h.NewValue, ChangedAt: h.ChangedAt,
+			})
+		}
+		for _, c := range t.Comments {
+			task.Comments = append(task.Comments, models.TaskComment{AuthorID: required(c.AuthorID), Content: c.Content, CreatedAt: c.CreatedAt})
+		}
+		graph.Tasks = append(graph.Tasks, task)
+	}
+	for _, r := range a.Rubrics {
+		rubric := models.Rubric{
+			ID: r.ID, Name: r.Name, Description: r.Description, Category: r.Category, Status: models.RubricStatus(r.Status),
+			Version: r.Version, RootRubricID: r.RootRubricID, SupersededAt: r.SupersededAt,
+			CreatedByID: required(r.CreatedByID), CreatedAt: r.CreatedAt,
+		}
+		if rubric.Version == 0 {
+			rubric.Version = 1
+		}
+		for _, c := range r.Criteria {
+			criterion := models.RubricCriterion{ID: c.ID, Title: c.Title, Description: c.Description, MaxPoints: c.MaxPoints, OriginCriterionID: c.OriginCriterionID}
+			for _, l := range c.Levels {
+				criterion.Levels = append(criterion.Levels, models.RubricCriterionLevel{Score: l.Score, Description: l.Description})
+			}
+			rubric.Criteria = append(rubric.Criteria, criterion)
+		}
+		graph.Rubrics = append(graph.Rubrics, rubric)
+	}
+
+	skippedAssignments, skippedEvaluations := 0, 0
+	for _, r := range a.EvaluationRounds {
+		round := models.EvaluationRound{
+			ID: r.ID, Name: r.Name, SprintID: r.SprintID, RubricID: r.RubricID, Anonymous: r.Anonymous,
+			PeersPerMember: r.PeersPerMember, Status: models.EvaluationRoundStatus(r.Status), DueDate: r.DueDate,
+			CreatedByID: required(r.CreatedByID), CreatedAt: r.CreatedAt,
+		}
+		for _, as := range r.Assignments {
+			if !matched(as.ReviewerID) || !matched(as.RevieweeID) {
+				skippedAssignments++
+				continue
+			}
+			round.Assignments = append(round.Assignments, models.PeerReviewAssignment{ReviewerID: users[as.ReviewerID], RevieweeID: users[as.RevieweeID]})
+		}
+		graph.EvaluationRounds = append(graph.EvaluationRounds, round)
+	}
+	for _, e := range a.Evaluations {
+		if !matched(e.EvaluatorID) || (e.EvaluateeID != nil && !matched(*e.EvaluateeID)) {
+			skippedEvaluations++
+			continue
+		}
+		evaluation := models.Evaluation{
+			ID: e.ID, Type: models.EvaluationType(e.Type), TaskID: e.TaskID, RoundID: e.RoundID,
+			EvaluateeID: optional(e.EvaluateeID), EvaluatorID: users[e.EvaluatorID], RubricID: e.RubricID,
+			OverallFeedback: e.OverallFeedback, TotalScore: e.TotalScore, Status: models.EvaluationStatus(e.Status),
+			SubmittedAt: e.SubmittedAt, PublishedAt: e.PublishedAt, CreatedAt: e.CreatedAt,
+		}
+		if evaluation.Type == "" {
+			evaluation.Type = models.EvaluationTypeTask
+		}
+		for _, sc := range e.Scores {
+			evaluation.CriterionEvaluations = append(evaluation.CriterionEvaluations, models.CriterionEvaluation{CriterionID: sc.CriterionID, Score: sc.Score, Feedback: sc.Feedback})
+		}
+		graph.Evaluations = append(graph.Evaluations, evaluation)
+	}
+	for _, e := range a.Events {
+		graph.Events = append(graph.Events, models.Event{
+			Title: e.Title, Description: e.Description, Type: e.Type, StartDate: e.StartDate, EndDate: e.EndDate,
+			CreatedByID: required(e.CreatedByID), CreatedAt: e.CreatedAt,
+		})
+	}
+
+	if fallbacks > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d reference(s) to users without an account were reassigned to the importing user or cleared", fallbacks))
+	}
+	if skippedAssignments > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d peer review assignment(s) skipped: no matching account", skippedAssignments))
+	}
+	if skippedEvaluations > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d evaluation(s) skipped: no matching account for the evaluator or evaluatee", skippedEvaluations))
+	}
+	return graph, warnings
+}
+
+// graphCounts counts the rows an import creates, per kind.
+func graphCounts(graph *storage.ProjectGraph) map[string]int {
+	counts := map[string]int{
+		"members":          len(graph.Members),
+		"sprints":          len(graph.Sprints),
+		"userStories":      len(graph.UserStories),
+		"tasks":            len(graph.Tasks),
+		"taskHistory":      0,
+		"taskComments":     0,
+		"rubrics":          len(graph.Rubrics),
+		"evaluationRounds": len(graph.EvaluationRounds),
+		"evaluations":      len(graph.Evaluations),
+		"events":           len(graph.Events),
+	}
+	for _, task := range graph.Tasks {
+		counts["taskHistory"] += len(task.History)
+		counts["taskComments"] += len(task.Comments)
+	}
+	return counts
+}
@@ -1,8 +1,10 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/storage"
@@ -18,6 +20,7 @@ type ProjectService struct {
 	SprintRepo          *storage.SprintRepository
 	TaskRepo            *storage.TaskRepository
 	NotificationService *NotificationService // Injected
+	Events              *EventBus            // Optional, set once at startup
 }
 
 // NewProjectService creates a new instance of ProjectService.
@@ -32,6 +35,18 @@ func NewProjectService(repo *storage.ProjectRepository, userRepo *storage.UserRe
 	}
 }
 
+// WithContext returns a copy of the service whose repositories carry ctx, so the
+// changes it makes are attributed to the request's actor in the audit log.
+func (s *ProjectService) WithContext(ctx context.Context) *ProjectService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	scoped.UserStoryRepo = s.UserStoryRepo.WithContext(ctx)
+	scoped.SprintRepo = s.SprintRepo.WithContext(ctx)
+	scoped.TaskRepo = s.TaskRepo.WithContext(ctx)
+	scoped.Events = s.Events.WithContext(ctx)
+	return &scoped
+}
+
 // GetUnassignedUsers retrieves users who are not admins and not already in the project.
 func (s *ProjectService) GetUnassignedUsers(projectID uint) ([]models.User, error) {
 	assignedUserIDs, err := s.Repo.GetMemberUserIDs(projectID)
@@ -61,6 +76,8 @@ func (s *ProjectService) CreateProject(project *models.Project, creatorID uint)
 	if err := s.Repo.AddMemberToProject(member); err != nil {
 		// Log the error but don't fail project creation
 		log.Printf("Error adding creator as project member: %v", err)
+	} else {
+		s.Events.Publish(project.ID, MemberAdded{UserID: creatorID, Role: member.Role})
 	}
 
 	return nil
@@ -91,6 +108,7 @@ func (s *ProjectService) AddMemberToProject(projectID, userID uint, role string)
 	if err := s.Repo.AddMemberToProject(member); err != nil {
 		return nil, err
 	}
+	s.Events.Publish(projectID, MemberAdded{UserID: userID, Role: role})
 
 	// --- Create Notification ---
 	project, err := s.Repo.GetProjectByID(projectID)
@@ -109,9 +127,43 @@ func (s *ProjectService) AddMemberToProject(projectID, userID uint, role string)
 	return s.Repo.GetProjectMemberByID(member.ID)
 }
 
-// GetProjects retrieves all projects.
-func (s *ProjectService) GetProjects() ([]models.Project, error) {
-	return s.Repo.GetProjects()
+// RemoveMemberFromProject takes a user out of a project. Their tasks stay assigned to
+// them; the at-risk work detection flags the ones not done.
+func (s *ProjectService) RemoveMemberFromProject(projectID, userID uint) error {
+	if _, err := s.Repo.GetProjectByID(projectID); err != nil {
+		return fmt.Errorf("project not found")
+	}
+	removed, err := s.Repo.RemoveMember(projectID, userID)
+	if err != nil {
+		return fmt.Errorf("could not remove member: %w", err)
+	}
+	if !removed {
+		return fmt.Errorf("user is not a member of this project")
+	}
+	s.Events.Publish(projectID, MemberRemoved{UserID: userID})
+	return nil
+}
+
+// projectSortFields are the fields the project list can be sorted by.
+var projectSortFields = sortFields{
+	"id": "id", "name": "name", "status": "status", "createdAt": "created_at", "startDate": "start_date", "endDate": "end_date",
+}
+
+// ListProjects retrieves one page of the projects matching the filter. Archived
+// projects are left out unless the filter asks for them.
+func (s *ProjectService) ListProjects(filter storage.ProjectFilter, query ListQuery) (*Page[models.Project], error) {
+	if filter.Status != "" && !models.ProjectStatus(filter.Status).IsValid() {
+		return nil, fmt.Errorf("invalid project status: '%s'", filter.Status)
+	}
+	opts, err := query.options(projectSortFields, "id")
+	if err != nil {
+		return nil, err
+	}
+	projects, total, err := s.Repo.ListProjects(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(projects, total, opts), nil
 }
 
 // GetProjectByID retrieves a single project by its ID.
@@ -143,15 +195,92 @@ func (s *ProjectService) UpdateProject(projectID uint, updates map[string]interf
 	if description, ok := updates["Description"].(string); ok {
 		existingProject.Description = description
 	}
+	if status, ok := updates["Status"].(string); ok && status != existingProject.Status {
+		if err := setProjectStatus(existingProject, models.ProjectStatus(status)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.Repo.UpdateProject(existingProject); err != nil {
+		return nil, err
+	}
+	s.Events.Publish(projectID, ProjectUpdated{Project: existingProject})
+
+	return existingProject, nil
+}
+
+// setProjectStatus moves a project along its lifecycle, recording when it was archived.
+func setProjectStatus(project *models.Project, status models.ProjectStatus) error {
+	if !status.IsValid() {
+		return fmt.Errorf("invalid project status: '%s'", status)
+	}
+	if !models.ProjectStatus(project.Status).CanTransitionTo(status) {
+		return fmt.Errorf("invalid status transition: a project cannot go from '%s' to '%s'", project.Status, status)
+	}
+
+	project.Status = string(status)
+	if status == models.ProjectStatusArchived {
+		now := time.Now()
+		project.ArchivedAt = &now
+	}
+	return nil
+}
+
+// UnarchiveProject makes an archived project writable again, moving it to the given
+// status ("active" when empty). Only platform admins may unarchive projects.
+func (s *ProjectService) UnarchiveProject(projectID uint, status string, requestingUserRole string) (*models.Project, error) {
+	if requestingUserRole != string(models.RoleAdmin) {
+		return nil, fmt.Errorf("forbidden: only admins can unarchive projects")
+	}
+
+	existingProject, err := s.Repo.GetProjectByID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("project not found")
+	}
+	if !existingProject.IsArchived() {
+		return nil, fmt.Errorf("invalid status transition: the project is not archived")
+	}
+
+	target := models.ProjectStatus(status)
+	if target == "" {
+		target = models.ProjectStatusActive
+	}
+	if !target.IsValid() || target == models.ProjectStatusArchived {
+		return nil, fmt.Errorf("invalid project status: '%s'", status)
+	}
 
+	existingProject.Status = string(target)
+	existingProject.ArchivedAt = nil
 	if err := s.Repo.UpdateProject(existingProject); err != nil {
 		return nil, err
 	}
+	s.Events.Publish(projectID, ProjectUpdated{Project: existingProject})
 
 	return existingProject, nil
 }
 
-// DeleteProject handles the transactional deletion of a project and all its dependencies.
+// EnsureWritable returns models.ErrProjectArchived when the resource of the given kind
+// ("project", "user_story", "task", "sprint", ...) belongs to an archived project.
+// Resources that do not exist or have no project are left for the caller to handle.
+func (s *ProjectService) EnsureWritable(kind string, id uint) error {
+	projectID, err := s.Repo.GetProjectIDFor(kind, id)
+	if err != nil || projectID == 0 {
+		return err
+	}
+
+	project, err := s.Repo.GetProjectByID(projectID)
+	if err != nil {
+		return nil // The project is in the trash; the request fails on its own.
+	}
+	if project.IsArchived() {
+		return models.ErrProjectArchived
+	}
+	return nil
+}
+
+// DeleteProject moves a project, its user stories and their tasks to the trash in a single
+// transaction. They all share the same deletion time so the project can be restored as a whole;
+// sprints and members are left untouched until the project is purged.
 func (s *ProjectService) DeleteProject(projectID uint, requestingUserID uint, requestingUserRole string) error {
 	existingProject, err := s.Repo.GetProjectByID(projectID)
 	if err != nil {
@@ -165,7 +294,8 @@ func (s *ProjectService) DeleteProject(projectID uint, requestingUserID uint, re
 	}
 
 	// Perform all deletions within a single transaction.
-	return s.Repo.DB.Transaction(func(tx *gorm.DB) error {
+	now := time.Now()
+	return s.Repo.DB.Session(&gorm.Session{NowFunc: func() time.Time { return now }}).Transaction(func(tx *gorm.DB) error {
 		// 1. Get all User Story IDs for the project.
 		storyIDs, err := s.UserStoryRepo.GetUserStoryIDsByProjectID(tx, projectID)
 		if err != nil {
@@ -184,17 +314,7 @@ func (s *ProjectService) DeleteProject(projectID uint, requestingUserID uint, re
 			return err // Rollback
 		}
 
-		// 4. Delete all Sprints for the project.
-		if err := s.SprintRepo.DeleteSprintsByProjectID(tx, projectID); err != nil {
-			return err // Rollback
-		}
-
-		// 5. Delete all Project Members for the project.
-		if err := s.Repo.DeleteProjectMembersByProjectID(tx, projectID); err != nil {
-			return err // Rollback
-		}
-
-		// 6. Finally, delete the project itself.
+		// 4. Finally, delete the project itself.
 		if err := s.Repo.DeleteProject(tx, projectID); err != nil {
 			return err // Rollback
 		}
@@ -203,6 +323,52 @@ func (s *ProjectService) DeleteProject(projectID uint, requestingUserID uint, re
 	})
 }
 
+// CanEvaluate reports whether a user may evaluate deliverables of a project,
+// according to the project's evaluation policy.
+func (s *ProjectService) CanEvaluate(userID, projectID uint) (bool, error) {
+	project, err := s.Repo.GetProjectByID(projectID)
+	if err != nil {
+		return false, fmt.Errorf("project not found")
+	}
+
+	role, err := s.Repo.GetUserRoleInProject(userID, projectID)
+	if err != nil {
+		return false, nil // Not a member of the project.
+	}
+
+	return project.EvaluationPolicy.AllowsRole(models.ProjectRole(role)), nil
+}
+
+// UpdateEvaluationPolicy changes who may evaluate deliverables in a project.
+// Only the project creator, a platform admin or a project instructor may change it.
+func (s *ProjectService) UpdateEvaluationPolicy(projectID uint, policy string, requestingUserID uint, requestingUserRole string) (*models.Project, error) {
+	existingProject, err := s.Repo.GetProjectByID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("project not found")
+	}
+
+	isOwner := existingProject.CreatedByID == requestingUserID
+	isAdmin := requestingUserRole == string(models.RoleAdmin)
+	role, _ := s.Repo.GetUserRoleInProject(requestingUserID, projectID)
+	isInstructor := models.ProjectRole(role) == models.RoleInstructor
+	if !isOwner && !isAdmin && !isInstructor {
+		return nil, fmt.Errorf("forbidden: you do not have permission to change the evaluation policy of this project")
+	}
+
+	evaluationPolicy := models.EvaluationPolicy(policy)
+	if !evaluationPolicy.IsValid() {
+		return nil, fmt.Errorf("invalid evaluation policy: '%s'", policy)
+	}
+
+	existingProject.EvaluationPolicy = evaluationPolicy
+	if err := s.Repo.UpdateProject(existingProject); err != nil {
+		return nil, err
+	}
+	s.Events.Publish(projectID, ProjectUpdated{Project: existingProject})
+
+	return existingProject, nil
+}
+
 // GetUserRoleInProject retrieves a user's role within a specific project.
 func (s *ProjectService) GetUserRoleInProject(userID, projectID uint) (string, error) {
 	return s.Repo.GetUserRoleInProject(userID, projectID)
@@ -213,6 +379,12 @@ func (s *ProjectService) GetActiveSprint(projectID uint) (*models.Sprint, error)
 	return s.SprintRepo.GetActiveSprint(projectID)
 }
 
+// GetProjectIDFor finds the project a resource of the given kind belongs to, or 0 when
+// there is none (see ProjectRepository.GetProjectIDFor).
+func (s *ProjectService) GetProjectIDFor(kind string, id uint) (uint, error) {
+	return s.Repo.GetProjectIDFor(kind, id)
+}
+
 // GetProjectsByUserID retrieves all projects a user is a member of.
 func (s *ProjectService) GetProjectsByUserID(userID uint) ([]models.Project, error) {
 	return s.Repo.GetProjectsByUserID(userID)
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+// ProjectParts selects which parts of a project are copied when it is cloned.
+// Tasks are only copied together with their user stories.
+type ProjectParts struct {
+	Sprints     bool `json:"sprints"`
+	UserStories bool `json:"userStories"`
+	Tasks       bool `json:"tasks"`
+	Rubrics     bool `json:"rubrics"`
+	Events      bool `json:"events"`
+	Members     bool `json:"members"`
+}
+
+// allTemplateParts is what a template captures: the whole structure, but no members.
+var allTemplateParts = ProjectParts{Sprints: true, UserStories: true, Tasks: true, Rubrics: true, Events: true}
+
+// NewProjectRequest holds the details of a project created from a template or a clone.
+// A nil StartDate keeps the source dates when cloning and means today for templates.
+type NewProjectRequest struct {
+	Name        string
+	Description string
+	StartDate   *time.Time
+}
+
+// ProjectTemplateService handles saving projects as templates, creating projects
+// from templates and cloning projects.
+type ProjectTemplateService struct {
+	Repo           *storage.ProjectTemplateRepository
+	ProjectService *ProjectService // To check user roles
+}
+
+// NewProjectTemplateService creates a new instance of ProjectTemplateService.
+func NewProjectTemplateService(repo *storage.ProjectTemplateRepository, projectService *ProjectService) *ProjectTemplateService {
+	return &ProjectTemplateService{Repo: repo, ProjectService: projectService}
+}
+
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *ProjectTemplateService) WithContext(ctx context.Context) *ProjectTemplateService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	return &scoped
+}
+
+// SaveProjectAsTemplate captures the sprints, user stories, tasks, rubrics and events of a
+// project as a template. Only the project creator, a platform admin or a project instructor may do it.
+func (s *ProjectTemplateService) SaveProjectAsTemplate(projectID uint, name, description string, requestingUserID uint, requestingUserRole string) (*models.ProjectTemplate, error) {
+	project, err := s.ProjectService.GetProjectByID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("project not found")
+	}
+	role, _ := s.ProjectService.GetUserRoleInProject(requestingUserID, projectID)
+	isInstructor := models.ProjectRole(role) == models.RoleInstructor
+	if project.CreatedByID != requestingUserID && requestingUserRole != string(models.RoleAdmin) && !isInstructor {
+		return nil, fmt.Errorf("forbidden: you do not have permission to save this project as a template")
+	}
+
+	tree, err := s.Repo.LoadProjectTree(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not read project: %w", err)
+	}
+
+	if name == "" {
+		name = project.Name
+	}
+	if description == "" {
+		description = project.Description
+	}
+	template := &models.ProjectTemplate{
+		Name:            name,
+		Description:     description,
+		SourceProjectID: &projectID,
+		CreatedByID:     requestingUserID,
+		Content:         blueprintFromTree(tree, allTemplateParts),
+	}
+	if err := s.Repo.Create(template); err != nil {
+		return nil, fmt.Errorf("could not save template: %w", err)
+	}
+	return template, nil
+}
+
+// GetTemplates lists the project templates, optionally filtered by name.
+func (s *ProjectTemplateService) GetTemplates(search string) ([]models.ProjectTemplate, error) {
+	return s.Repo.FindAll(search)
+}
+
+// GetTemplate retrieves a project template with its content.
+func (s *ProjectTemplateService) GetTemplate(id uint) (*models.ProjectTemplate, error) {
+	template, err := s.Repo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("project template not found")
+	}
+	return template, nil
+}
+
+// DeleteTemplate removes a project template. Only its creator or an admin may delete it.
+func (s *ProjectTemplateService) DeleteTemplate(id, requestingUserID uint, requestingUserRole string) error {
+	template, err := s.Repo.FindByID(id)
+	if err != nil {
+		return fmt.Errorf("project template not found")
+	}
+	if template.CreatedByID != requestingUserID && requestingUserRole != string(models.RoleAdmin) {
+		return fmt.Errorf("forbidden: you do not have permission to delete this template")
+	}
+	return s.Repo.Delete(id)
+}
+
+// CreateProjectFromTemplate creates a new project from a template, shifting every date
+// to the requested start date. The requesting user becomes its creator and product owner.
+func (s *ProjectTemplateService) CreateProjectFromTemplate(templateID uint, req NewProjectRequest, requestingUserID uint) (*models.Project, error) {
+	template, err := s.Repo.FindByID(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("project template not found")
+	}
+
+	if req.Name == "" {
+		req.Name = template.Name
+	}
+	if req.Description == "" {
+		req.Description = template.Description
+	}
+	start := startOfDay(time.Now())
+	if req.StartDate != nil {
+		start = *req.StartDate
+	}
+
+	tree := treeFromBlueprint(template.Content, req, start, requestingUserID)
+	tree.Members = []models.ProjectMember{{UserID: requestingUserID, Role: string(models.RoleProductOwner)}}
+	if err := s.Repo.CreateProjectTree(tree); err != nil {
+		return nil, fmt.Errorf("could not create project from template: %w", err)
+	}
+	return s.ProjectService.GetProjectByID(tree.Project.ID)
+}
+
+// CloneProject copies the selected parts of a project into a new one. Dates are shifted
+// to req.StartDate when it is set. Any project member or an admin may clone a project.
+func (s *ProjectTemplateService) CloneProject(projectID uint, req NewProjectRequest, parts ProjectParts, requestingUserID uint, requestingUserRole string) (*models.Project, error) {
+	if _, err := s.ProjectService.GetProjectByID(projectID); err != nil {
+		return nil, fmt.Errorf("project not found")
+	}
+	if requestingUserRole != string(models.RoleAdmin) {
+		if _, err := s.ProjectService.GetUserRoleInProject(requestingUserID, projectID); err != nil {
+			return nil, fmt.Errorf("forbidden: you are not a member of this project")
+		}
+	}
+
+	source, err := s.Repo.LoadProjectTree(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not read project: %w", err)
+	}
+
+	if req.Name == "" {
+		req.Name = source.Project.Name + " (copy)"
+	}
+	if req.Description == "" {
+		req.Description = source.Project.Description
+	}
+	start := projectBaseDate(source)
+	if req.StartDate != nil {
+		start = *req.StartDate
+	}
+
+	tree := treeFromBlueprint(blueprintFromTree(source, parts), req, start, requestingUserID)
+	tree.Members = []models.ProjectMember{{UserID: requestingUserID, Role: string(models.RoleProductOwner)}}
+	if parts.Members {
+		for _, member := range source.Members {
+			if member.UserID == requestingUserID {
+				tree.Members[0].Role = member.Role // Keep the cloner's own role
+				continue
+			}
+			tree.Members = append(tree.Members, models.ProjectMember{UserID: member.UserID, Role: member.Role})
+		}
+	}
+
+	if err := s.Repo.CreateProjectTree(tree); err != nil {
+		return nil, fmt.Errorf("could not clone project: %w", err)
+	}
+	return s.ProjectService.GetProjectByID(tree.Project.ID)
+}
+
+// blueprintFromTree turns the selected parts of a project into a blueprint whose dates
+// are relative to the project's base date.
+func blueprintFromTree(tree *storage.ProjectTree, parts ProjectParts) models.ProjectBlueprint {
+	base := projectBaseDate(tree)
+	blueprint := models.ProjectBlueprint{
+		EvaluationPolicy: tree.Project.EvaluationPolicy,
+		Sprints:          []models.SprintBlueprint{},
+		UserStories:      []models.UserStoryBlueprint{},
+		Rubrics:          []models.RubricBlueprint{},
+		Events:           []models.EventBlueprint{},
+	}
+	if tree.Project.StartDate != nil && tree.Project.EndDate != nil {
+		duration := int64(tree.Project.EndDate.Sub(*tree.Project.StartDate) / time.Minute)
+		blueprint.DurationMinutes = &duration
+	}
+
+	if parts.Sprints {
+		for _, sprint := range tree.Sprints {
+			blueprint.Sprints = append(blueprint.Sprints, models.SprintBlueprint{
+				Name:               sprint.Name,
+				Goal:               sprint.Goal,
+				StartOffsetMinutes: offsetMinutes(sprint.StartDate, base),
+				EndOffsetMinutes:   offsetMinutes(sprint.EndDate, base),
+			})
+		}
+	}
+
+	if parts.UserStories {
+		for _, node := range tree.UserStories {
+			story := models.UserStoryBlueprint{
+				Title:              node.Story.Title,
+				Description:        node.Story.Description,
+				AcceptanceCriteria: node.Story.AcceptanceCriteria,
+				Priority:           node.Story.Priority,
+				Points:             node.Story.Points,
+				Tasks:              []models.TaskBlueprint{},
+			}
+			if parts.Sprints {
+				story.SprintIndex = node.SprintIndex
+			}
+			if parts.Tasks {
+				for _, task := range node.Tasks {
+					story.Tasks = append(story.Tasks, models.TaskBlueprint{
+						Title:          task.Title,
+						Description:    task.Description,
+						EstimatedHours: task.EstimatedHours,
+						IsDeliverable:  task.IsDeliverable,
+					})
+				}
+			}
+			blueprint.UserStories = append(blueprint.UserStories, story)
+		}
+	}
+
+	if parts.Rubrics {
+		for _, rubric := range tree.Rubrics {
+			rb := models.RubricBlueprint{
+				Name:        rubric.Name,
+				Description: rubric.Description,
+				Category:    rubric.Category,
+				Status:      rubric.Status,
+				Criteria:    make([]models.RubricCriterionBlueprint, 0, len(rubric.Criteria)),
+			}
+			for _, criterion := range rubric.Criteria {
+				cb := models.RubricCriterionBlueprint{
+					Title:       criterion.Title,
+					Description: criterion.Description,
+					MaxPoints:   criterion.MaxPoints,
+					Levels:      make([]models.RubricLevelBlueprint, 0, len(criterion.Levels)),
+				}
+				for _, level := range criterion.Levels {
+					cb.Levels = append(cb.Levels, models.RubricLevelBlueprint{Score: level.Score, Description: level.Description})
+				}
+				rb.Criteria = append(rb.Criteria, cb)
+			}
+			blueprint.Rubrics = append(blueprint.Rubrics, rb)
+		}
+	}
+
+	if parts.Events {
+		for _, event := range tree.Events {
+			blueprint.Events = append(blueprint.Events, models.EventBlueprint{
+				Title:              event.Title,
+				Description:        event.Description,
+				Type:               event.Type,
+				StartOffsetMinutes: *offsetMinutes(&event.StartDate, base),
+				EndOffsetMinutes:   *offsetMinutes(&event.EndDate, base),
+			})
+		}
+	}
+	return blueprint
+}
+
+// treeFromBlueprint builds the rows of a new project from a blueprint, placing its
+// dates relative to start. Work items start fresh: stories in the backlog, tasks to do,
+// sprints planned and nothing assigned.
+func treeFromBlueprint(blueprint models.ProjectBlueprint, req NewProjectRequest, start time.Time, creatorID uint) *storage.ProjectTree {
+	project := &models.Project{
+		Name:             req.Name,
+		Description:      req.Description,
+		Status:           string(models.ProjectStatusPlanning),
+		EvaluationPolicy: blueprint.EvaluationPolicy,
+		StartDate:        &start,
+		CreatedByID:      creatorID,
+	}
+	if project.EvaluationPolicy == "" {
+		project.EvaluationPolicy = models.EvaluationPolicyInstructorsOnly
+	}
+	if blueprint.DurationMinutes != nil {
+		project.EndDate = shiftDate(start, blueprint.DurationMinutes)
+	}
+
+	tree := &storage.ProjectTree{Project: project}
+	for _, sprint := range blueprint.Sprints {
+		tree.Sprints = append(tree.Sprints, models.Sprint{
+			Name:        sprint.Name,
+			Goal:        sprint.Goal,
+			Status:      "planned",
+			StartDate:   shiftDate(start, sprint.StartOffsetMinutes),
+			EndDate:     shiftDate(start, sprint.EndOffsetMinutes),
+			CreatedByID: creatorID,
+		})
+	}
+
+	for _, story := range blueprint.UserStories {
+		node := storage.UserStoryNode{
+			Story: models.UserStory{
+				Title:              story.Title,
+				Description:        story.Description,
+				AcceptanceCriteria: story.AcceptanceCriteria,
+				Priority:           story.Priority,
+				Status:             "backlog",
+				Points:             story.Points,
+				CreatedByID:        creatorID,
+			},
+		}
+		if story.SprintIndex != nil && *story.SprintIndex >= 0 && *story.SprintIndex < len(tree.Sprints) {
+			node.SprintIndex = story.SprintIndex
+		}
+		if node.Story.Priority == "" {
+			node.Story.Priority = "medium"
+		}
+		for _, task := range story.Tasks {
+			node.Tasks = append(node.Tasks, models.Task{
+				Title:          task.Title,
+				Description:    task.Description,
+				Status:         models.StatusTodo,
+				EstimatedHours: task.EstimatedHours,
+				IsDeliverable:  task.IsDeliverable,
+				CreatedByID:    creatorID,
+			})
+		}
+		tree.UserStories = append(tree.UserStories, node)
+	}
+
+	for _, rubric := range blueprint.Rubrics {
+		newRubric := models.Rubric{
+			Name:        rubric.Name,
+			Description: rubric.Description,
+			Category:    rubric.Category,
+			Status:      rubric.Status,
+			CreatedByID: creatorID,
+			Version:     1,
+		}
+		if newRubric.Status == "" {
+			newRubric.Status = models.RubricStatusDraft
+		}
+		for _, criterion := range rubric.Criteria {
+			newCriterion := models.RubricCriterion{
+				Title:       criterion.Title,
+				Description: criterion.Description,
+				MaxPoints:   criterion.MaxPoints,
+			}
+			for _, level := range criterion.Levels {
+				newCriterion.Levels = append(newCriterion.Levels, models.RubricCriterionLevel{Score: level.Score, Description: level.Description})
+			}
+			newRubric.Criteria = append(newRubric.Criteria, newCriterion)
+		}
+		tree.Rubrics = append(tree.Rubrics, newRubric)
+	}
+
+	for _, event := range blueprint.Events {
+		tree.Events = append(tree.Events, models.Event{
+			Title:       event.Title,
+			Description: event.Description,
+			Type:        event.Type,
+			StartDate:   *shiftDate(start, &event.StartOffsetMinutes),
+			EndDate:     *shiftDate(start, &event.EndOffsetMinutes),
+			CreatedByID: creatorID,
+		})
+	}
+	return tree
+}
+
+// projectBaseDate is the date the offsets of a blueprint are measured from: the project's
+// start date or, when it has none, the earliest sprint or event, or else its creation day.
+func projectBaseDate(tree *storage.ProjectTree) time.Time {
+	if tree.Project.StartDate != nil {
+		return *tree.Project.StartDate
+	}
+
+	var earliest *time.Time
+	consider := func(t *time.Time) {
+		if t != nil && (earliest == nil || t.Before(*earliest)) {
+			earliest = t
+		}
+	}
+	for i := range tree.Sprints {
+		consider(tree.Sprints[i].StartDate)
+	}
+	for i := range tree.Events {
+		consider(&tree.Events[i].StartDate)
+	}
+	if earliest != nil {
+		return startOfDay(*earliest)
+	}
+	return startOfDay(tree.Project.CreatedAt)
+}
+
+func offsetMinutes(t *time.Time, base time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	offset := int64(t.Sub(base) / time.Minute)
+	return &offset
+}
+
+func shiftDate(start time.Time, offset *int64) *time.Time {
+	if offset == nil {
+		return nil
+	}
+	shifted := start.Add(time.Duration(*offset) * time.Minute)
+	return &shifted
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+const (
+	riskSprintEndingWithin = 3 * 24 * time.Hour // Active sprints ending this soon are checked
+	riskOpenScopeShare     = 0.5                // Share of the sprint still open that puts it at risk
+)
+
+// RiskScanResult summarises a run of the at-risk work detection.
+type RiskScanResult struct {
+	Opened   int                  `json:"opened"`
+	Open     int                  `json:"open"` // Findings still open after the run, new ones included
+	Resolved int                  `json:"resolved"`
+	Findings []models.RiskFinding `json:"findings"` // The findings opened by this run
+}
+
+// RiskService flags stale and at-risk work across projects. Each new finding notifies
+// the task's assignee or the project's scrum master once; findings are resolved when the
+// problem goes away.
+type RiskService struct {
+	Repo                *storage.RiskRepository
+	ProjectService      *ProjectService
+	NotificationService *NotificationService
+	StaleAfter          time.Duration    // How long a task may sit in progress or in review without a status change
+	Jobs                *storage.JobLock // Optional, set once at startup
+}
+
+// NewRiskService creates a new instance of RiskService.
+func NewRiskService(repo *storage.RiskRepository, projectService *ProjectService, notificationService *NotificationService, staleAfter time.Duration) *RiskService {
+	return &RiskService{
+		Repo:                repo,
+		ProjectService:      projectService,
+		NotificationService: notificationService,
+		StaleAfter:          staleAfter,
+	}
+}
+
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *RiskService) WithContext(ctx context.Context) *RiskService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	scoped.ProjectService = s.ProjectService.WithContext(ctx)
+	if s.Jobs != nil {
+		scoped.Jobs = s.Jobs.WithContext(ctx)
+	}
+	return &scoped
+}
+
+// ListProjectFindings lists the open findings of a project, and the resolved ones too
+// when includeResolved is set. Any project member or an admin may see them.
+func (s *RiskService) ListProjectFindings(projectID uint, includeResolved bool, requestingUserID uint, requestingUserRole string) ([]models.RiskFinding, error) {
+	if _, err := s.ProjectService.GetProjectByID(projectID); err != nil {
+		return nil, fmt.Errorf("project not found")
+	}
+	if requestingUserRole != string(models.RoleAdmin) {
+		if _, err := s.ProjectService.GetUserRoleInProject(requestingUserID, projectID); err != nil {
+			return nil, fmt.Errorf("forbidden: you are not a member of this project")
+		}
+	}
+	findings, err := s.Repo.ListFindings(projectID, includeResolved)
+	if err != nil {
+		return nil, err
+	}
+	if findings == nil {
+		findings = []models.RiskFinding{}
+	}
+	return findings, nil
+}
+
+// riskCandidate is a problem found by a detection run, before it is matched against
+// the open findings.
+type riskCandidate struct {
+	finding    models.RiskFinding
+	assigneeID *uint // Notified instead of the project lead when set
+}
+
+func riskKey(kind models.RiskKind, entityType string, entityID uint) string {
+	return fmt.Sprintf("%s/%s/%d", kind, entityType, entityID)
+}
+
+// Detect runs the detection once as of now: it opens a finding for every new problem
+// and notifies its assignee or the project's lead, refreshes the findings still found
+// and resolves the ones that are gone. It waits for a detection running on another
+// instance to finish first.
+func (s *RiskService) Detect(now time.Time) (*RiskScanResult, error) {
+	var result *RiskScanResult
+	err := runJob(s.Jobs, storage.JobRiskDetection, func() (err error) {
+		result, err = s.detect(now)
+		return err
+	})
+	return result, err
+}
+
+func (s *RiskService) detect(now time.Time) (*RiskScanResult, error) {
+	candidates, err := s.findCandidates(now)
+	if err != nil {
+		return nil, err
+	}
+	open, err := s.Repo.GetOpenFindings()
+	if err != nil {
+		return nil, fmt.Errorf("could not load open findings: %w", err)
+	}
+	openByKey := make(map[string]models.RiskFinding, len(open))
+	for _, finding := range open {
+		openByKey[riskKey(finding.Kind, finding.EntityType, finding.EntityID)] = finding
+	}
+
+	var opened, seen []models.RiskFinding
+	var newCandidates []riskCandidate
+	projectIDs := map[uint]bool{}
+	for _, candidate := range candidates {
+		key := riskKey(candidate.finding.Kind, candidate.finding.EntityType, candidate.finding.EntityID)
+		if existing, ok := openByKey[key]; ok {
+			existing.Message = candidate.finding.Message
+			seen = append(seen, existing)
+			delete(openByKey, key)
+			continue
+		}
+		newCandidates = append(newCandidates, candidate)
+		if candidate.assigneeID == nil {
+			projectIDs[candidate.finding.ProjectID] = true
+		}
+	}
+
+	leads, err := s.projectLeads(projectIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range newCandidates {
+		finding := candidate.finding
+		finding.DetectedAt, finding.LastSeenAt = now, now
+		if candidate.assigneeID != nil {
+			finding.NotifiedUserID = candidate.assigneeID
+		} else if lead, ok := leads[finding.ProjectID]; ok {
+			finding.NotifiedUserID = &lead
+		}
+		opened = append(opened, finding)
+	}
+
+	resolved := make([]uint, 0, len(openByKey))
+	for _, finding := range openByKey {
+		resolved = append(resolved, finding.ID)
+	}
+	// Only the findings actually inserted are reported and notified: one opened by
+	// another scan in the meantime is left to that scan.
+	openCount := len(opened) + len(seen)
+	opened, err = s.Repo.ApplyScan(opened, seen, resolved, now)
+	if err != nil {
+		return nil, fmt.Errorf("could not save findings: %w", err)
+	}
+
+	for _, finding := range opened {
+		if finding.NotifiedUserID == nil {
+			continue
+		}
+		if _, err := s.NotificationService.CreateNotification(*finding.NotifiedUserID, finding.Message, riskLink(finding)); err != nil {
+			// Log the error but keep going; the finding itself is saved.
+			log.Printf("could not create notification for risk finding %d: %v", finding.ID, err)
+		}
+	}
+
+	if opened == nil {
+		opened = []models.RiskFinding{}
+	}
+	return &RiskScanResult{Opened: len(opened), Open: openCount, Resolved: len(resolved), Findings: opened}, nil
+}
+
+// findCandidates gathers every problem currently present.
+func (s *RiskService) findCandidates(now time.Time) ([]riskCandidate, error) {
+	var candidates []riskCandidate
+	staleDays := int(s.StaleAfter.Hours() / 24)
+
+	stale, err := s.Repo.FindStaleTasks(now.Add(-s.StaleAfter))
+	if err != nil {
+		return nil, fmt.Errorf("could not find stale tasks: %w", err)
+	}
+	for _, task := range stale {
+		candidates = append(candidates, riskCandidate{
+			finding: models.RiskFinding{
+				ProjectID: task.ProjectID, Kind: models.RiskStaleTask, EntityType: "task", EntityID: task.TaskID,
+				Message: fmt.Sprintf("La tarea '%s' lleva más de %d días en %s sin cambios de estado.", task.Title, staleDays, task.Status),
+			},
+			assigneeID: task.AssigneeID,
+		})
+	}
+
+	stories, err := s.Repo.FindStoriesWithoutTasks()
+	if err != nil {
+		return nil, fmt.Errorf("could not find user stories without tasks: %w", err)
+	}
+	for _, story := range stories {
+		candidates = append(candidates, riskCandidate{finding: models.RiskFinding{
+			ProjectID: story.ProjectID, Kind: models.RiskStoryWithoutTasks, EntityType: "user_story", EntityID: story.StoryID,
+			Message: fmt.Sprintf("La historia '%s' del sprint '%s' no tiene tareas.", story.Title, story.SprintName),
+		}})
+	}
+
+	departed, err := s.Repo.FindDepartedAssignees()
+	if err != nil {
+		return nil, fmt.Errorf("could not find tasks of former members: %w", err)
+	}
+	for _, task := range departed {
+		candidates = append(candidates, riskCandidate{finding: models.RiskFinding{
+			ProjectID: task.ProjectID, Kind: models.RiskDepartedAssignee, EntityType: "task", EntityID: task.TaskID,
+			Message: fmt.Sprintf("La tarea '%s' está asignada a alguien que ya no es miembro del proyecto.", task.Title),
+		}})
+	}
+
+	sprints, err := s.Repo.FindSprintsEndingBy(now.Add(riskSprintEndingWithin))
+	if err != nil {
+		return nil, fmt.Errorf("could not find sprints ending soon: %w", err)
+	}
+	for _, sprint := range sprints {
+		share, ok := openScopeShare(sprint)
+		if !ok || share < riskOpenScopeShare {
+			continue
+		}
+		candidates = append(candidates, riskCandidate{finding: models.RiskFinding{
+			ProjectID: sprint.ProjectID, Kind: models.RiskSprintAtRisk, EntityType: "sprint", EntityID: sprint.SprintID,
+			Message: fmt.Sprintf("El sprint '%s' termina el %s con el %d%% del alcance pendiente.",
+				sprint.Name, sprint.EndDate.Format("2006-01-02"), int(math.Round(share*100))),
+		}})
+	}
+	return candidates, nil
+}
+
+// openScopeShare is the share of a sprint's points still open, or of its user stories
+// when none of them is estimated. It reports false for an empty sprint.
+func openScopeShare(sprint storage.RiskSprintRow) (float64, bool) {
+	if sprint.TotalPoints > 0 {
+		return float64(sprint.OpenPoints) / float64(sprint.TotalPoints), true
+	}
+	if sprint.TotalStories > 0 {
+		return float64(sprint.OpenStories) / float64(sprint.TotalStories), true
+	}
+	return 0, false
+}
+
+func (s *RiskService) projectLeads(projectIDs map[uint]bool) (map[uint]uint, error) {
+	ids := make([]uint, 0, len(projectIDs))
+	for id := range projectIDs {
+		ids = append(ids, id)
+	}
+	rows, err := s.Repo.GetProjectLeads(ids)
+	if err != nil {
+		return nil, fmt.Errorf("could not load project leads: %w", err)
+	}
+	leads := make(map[uint]uint, len(rows))
+	for _, row := range rows {
+		leads[row.ProjectID] = row.UserID
+	}
+	return leads, nil
+}
+
+func riskLink(finding models.RiskFinding) string {
+	switch finding.EntityType {
+	case "task":
+		return fmt.Sprintf("/tasks/%d", finding.EntityID)
+	case "user_story":
+		return fmt.Sprintf("/userstories/%d", finding.EntityID)
+	default:
+		return fmt.Sprintf("/sprints/%d", finding.EntityID)
+	}
+}
+
+// RunDetection runs the detection every interval until ctx is cancelled, skipping the
+// runs another instance is already doing. It is meant to be started in its own goroutine.
+func (s *RiskService) RunDetection(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var result *RiskScanResult
+		ran, err := tryRunJob(s.Jobs, storage.JobRiskDetection, func() (err error) {
+			result, err = s.detect(time.Now())
+			return err
+		})
+		if err != nil {
+			log.Printf("at-risk work detection failed: %v", err)
+		} else if ran && result.Opened+result.Resolved > 0 {
+			log.Printf("at-risk work detection: %d new findings, %d resolved, %d open",
+				result.Opened, result.Resolved, result.Open)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/buga/API_wrkf/models"
+)
+
+// RubricSchemaVersion is the version of the JSON format produced by ExportRubric.
+const RubricSchemaVersion = 1
+
+// rubricCSVHeader lists the CSV columns, one row per criterion level. A criterion
+// without levels is written as a single row with empty level columns.
+var rubricCSVHeader = []string{
+	"rubric_name", "rubric_description", "criterion_title", "criterion_description",
+	"max_points", "level_score", "level_description",
+}
+
+// RubricDocument is the documented JSON representation of a rubric used for import
+// and export (see docs/rubric_import_export.md).
+type RubricDocument struct {
+	SchemaVersion int                       `json:"schemaVersion"`
+	Name          string                    `json:"name"`
+	Description   string                    `json:"description"`
+	Category      string                    `json:"category,omitempty"`
+	Criteria      []RubricCriterionDocument `json:"criteria"`
+}
+
+// RubricCriterionDocument is a criterion in a RubricDocument.
+type RubricCriterionDocument struct {
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	MaxPoints   float64               `json:"maxPoints"`
+	Levels      []RubricLevelDocument `json:"levels"`
+}
+
+// RubricLevelDocument is a performance level in a RubricCriterionDocument.
+type RubricLevelDocument struct {
+	Score       float64 `json:"score"`
+	Description string  `json:"description"`
+}
+
+// RubricImportRequest holds an uploaded rubric file and where to import it.
+// Name and Description, when set, override the ones found in the file.
+type RubricImportRequest struct {
+	Format      string // "csv" or "json"
+	Data        []byte
+	ProjectID   uint
+	Name        string
+	Description string
+	Preview     bool // Validate and build the rubric without saving it
+}
+
+// RubricImportIssue is a validation problem found in an imported file. Location is a
+// CSV line number ("line 4") or a JSON path ("criteria[1].levels[0]").
+type RubricImportIssue struct {
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
+
+// RubricImportResult is the outcome of an import or a preview.
+type RubricImportResult struct {
+	Preview bool                `json:"preview"`
+	Rubric  *models.Rubric      `json:"rubric,omitempty"`
+	Issues  []RubricImportIssue `json:"issues"`
+}
+
+// ImportRubric parses a CSV or JSON rubric, validates it and, unless previewing, creates
+// the whole Rubric→Criteria→Levels tree as a DRAFT of the project. GORM saves nested
+// associations inside the transaction of the root insert, so a failure leaves nothing behind.
+// When the file has problems the result lists them and the error starts with "invalid rubric import".
+func (s *rubricService) ImportRubric(req RubricImportRequest, requestingUserID uint, requestingUserRole string) (*RubricImportResult, error) {
+	if err := s.requireProjectAccess(req.ProjectID, requestingUserID, requestingUserRole); err != nil {
+		return nil, err
+	}
+
+	var (
+		doc    *RubricDocument
+		issues []RubricImportIssue
+	)
+	switch strings.ToLower(req.Format) {
+	case "csv":
+		doc, issues = parseRubricCSV(req.Data)
+	case "json":
+		doc, issues = parseRubricJSON(req.Data)
+	default:
+		return nil, fmt.Errorf("invalid rubric import: unsupported format '%s', use csv or json", req.Format)
+	}
+
+	result := &RubricImportResult{Preview: req.Preview, Issues: issues}
+	if doc != nil {
+		if req.Name != "" {
+			doc.Name = req.Name
+		}
+		if req.Description != "" {
+			doc.Description = req.Description
+		}
+		result.Issues = append(result.Issues, validateRubricDocument(doc)...)
+
+		rubric := doc.toRubric()
+		rubric.ProjectID = &req.ProjectID
+		rubric.CreatedByID = requestingUserID
+		result.Rubric = &rubric
+	}
+
+	if len(result.Issues) > 0 {
+		return result, fmt.Errorf("invalid rubric import: %d problem(s) found", len(result.Issues))
+	}
+	if req.Preview {
+		return result, nil
+	}
+
+	if err := s.repo.Create(result.Rubric); err != nil {
+		return nil, fmt.Errorf("failed to create imported rubric: %w", err)
+	}
+	return result, nil
+}
+
+// ExportRubric renders a rubric as JSON (the documented schema) or CSV.
+func (s *rubricService) ExportRubric(id uint, format string) ([]byte, error) {
+	rubric, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("rubric with ID %d not found", id)
+	}
+	doc := rubricDocumentFrom(rubric)
+
+	switch strings.ToLower(format) {
+	case "json":
+		return json.MarshalIndent(doc, "", "  ")
+	case "csv":
+		return writeRubricCSV(doc)
+	default:
+		return nil, fmt.Errorf("invalid rubric export: unsupported format '%s', use csv or json", format)
+	}
+}
+
+func rubricDocumentFrom(rubric *models.Rubric) RubricDocument {
+	doc := RubricDocument{
+		SchemaVersion: RubricSchemaVersion,
+		Name:          rubric.Name,
+		Description:   rubric.Description,
+		Category:      rubric.Category,
+		Criteria:      make([]RubricCriterionDocument, 0, len(rubric.Criteria)),
+	}
+	for _, criterion := range rubric.Criteria {
+		c := RubricCriterionDocument{
+			Title:       criterion.Title,
+			Description: criterion.Description,
+			MaxPoints:   criterion.MaxPoints,
+			Levels:      make([]RubricLevelDocument, 0, len(criterion.Levels)),
+		}
+		for _, level := range criterion.Levels {
+			c.Levels = append(c.Levels, RubricLevelDocument{Score: level.Score, Description: level.Description})
+		}
+		doc.Criteria = append(doc.Criteria, c)
+	}
+	return doc
+}
+
+func (doc *RubricDocument) toRubric() models.Rubric {
+	rubric := models.Rubric{
+		Name:        doc.Name,
+		Description: doc.Description,
+		Category:    doc.Category,
+		Status:      models.RubricStatusDraft,
+		Criteria:    make([]models.RubricCriterion, 0, len(doc.Criteria)),
+	}
+	for _, c := range doc.Criteria {
+		criterion := models.RubricCriterion{
+			Title:       c.Title,
+			Description: c.Description,
+			MaxPoints:   c.MaxPoints,
+			Levels:      make([]models.RubricCriterionLevel, 0, len(c.Levels)),
+		}
+		for _, level := range c.Levels {
+			criterion.Levels = append(criterion.Levels, models.RubricCriterionLevel{Score: level.Score, Description: level.Description})
+		}
+		rubric.Criteria = append(rubric.Criteria, criterion)
+	}
+	return rubric
+}
+
+// validateRubricDocument checks the rules shared by both formats.
+func validateRubricDocument(doc *RubricDocument) []RubricImportIssue {
+	var issues []RubricImportIssue
+	add := func(location, format string, args ...interface{}) {
+		issues = append(issues, RubricImportIssue{Location: location, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(doc.Name) == "" {
+		add("name", "rubric name is required")
+	}
+	if len(doc.Criteria) == 0 {
+		add("criteria", "at least one criterion is required")
+	}
+
+	titles := make(map[string]bool, len(doc.Criteria))
+	for i, c := range doc.Criteria {
+		location := fmt.Sprintf("criteria[%d]", i)
+		if strings.TrimSpace(c.Title) == "" {
+			add(location, "criterion title is required")
+		} else if titles[strings.ToLower(c.Title)] {
+			add(location, "criterion '%s' is defined more than once", c.Title)
+		}
+		titles[strings.ToLower(c.Title)] = true
+		if c.MaxPoints <= 0 {
+			add(location, "maxPoints of criterion '%s' must be greater than 0", c.Title)
+		}
+
+		scores := make(map[float64]bool, len(c.Levels))
+		for j, level := range c.Levels {
+			levelLocation := fmt.Sprintf("%s.levels[%d]", location, j)
+			if level.Score < 0 {
+				add(levelLocation, "level score %s of criterion '%s' cannot be negative", formatScore(level.Score), c.Title)
+			}
+			if level.Score > c.MaxPoints {
+				add(levelLocation, "level score %s exceeds maxPoints %s of criterion '%s'", formatScore(level.Score), formatScore(c.MaxPoints), c.Title)
+			}
+			if scores[level.Score] {
+				add(levelLocation, "criterion '%s' has more than one level scored %s", c.Title, formatScore(level.Score))
+			}
+			scores[level.Score] = true
+			if strings.TrimSpace(level.Description) == "" {
+				add(levelLocation, "level description is required")
+			}
+		}
+	}
+	return issues
+}
+
+func parseRubricJSON(data []byte) (*RubricDocument, []RubricImportIssue) {
+	var doc RubricDocument
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, []RubricImportIssue{{Message: fmt.Sprintf("malformed JSON: %v", err)}}
+	}
+	if doc.SchemaVersion != 0 && doc.SchemaVersion != RubricSchemaVersion {
+		return nil, []RubricImportIssue{{Location: "schemaVersion", Message: fmt.Sprintf("unsupported schema version %d", doc.SchemaVersion)}}
+	}
+	return &doc, nil
+}
+
+// parseRubricCSV groups the criterion-level rows into criteria by title, in order of
+// first appearance. Rows may leave the criterion description and max points empty
+// after the first row of a criterion.
+func parseRubricCSV(data []byte) (*RubricDocument, []RubricImportIssue) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, []RubricImportIssue{{Location: "line 1", Message: "the file is empty or not valid CSV"}}
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"criterion_title", "max_points"} {
+		if _, ok := columns[required]; !ok {
+			return nil, []RubricImportIssue{{Location: "line 1", Message: fmt.Sprintf("missing required column '%s'", required)}}
+		}
+	}
+
+	doc := &RubricDocument{SchemaVersion: RubricSchemaVersion}
+	var issues []RubricImportIssue
+	criteria := make(map[string]int)
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		location := fmt.Sprintf("line %d", line)
+		if err != nil {
+			issues = append(issues, RubricImportIssue{Location: location, Message: fmt.Sprintf("malformed row: %v", err)})
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		if doc.Name == "" {
+			doc.Name = field("rubric_name")
+		}
+		if doc.Description == "" {
+			doc.Description = field("rubric_description")
+		}
+
+		title := field("criterion_title")
+		if title == "" {
+			issues = append(issues, RubricImportIssue{Location: location, Message: "criterion_title is required"})
+			continue
+		}
+
+		index, seen := criteria[strings.ToLower(title)]
+		if !seen {
+			doc.Criteria = append(doc.Criteria, RubricCriterionDocument{Title: title, Levels: []RubricLevelDocument{}})
+			index = len(doc.Criteria) - 1
+			criteria[strings.ToLower(title)] = index
+		}
+		criterion := &doc.Criteria[index]
+
+		if description := field("criterion_description"); description != "" && criterion.Description == "" {
+			criterion.Description = description
+		}
+		if raw := field("max_points"); raw != "" {
+			maxPoints, err := strconv.ParseFloat(raw, 64)
+			switch {
+			case err != nil:
+				issues = append(issues, RubricImportIssue{Location: location, Message: fmt.Sprintf("max_points '%s' is not a number", raw)})
+			case seen && criterion.MaxPoints != 0 && criterion.MaxPoints != maxPoints:
+				issues = append(issues, RubricImportIssue{Location: location, Message: fmt.Sprintf("max_points of criterion '%s' differs from a previous row", title)})
+			default:
+				criterion.MaxPoints = maxPoints
+			}
+		}
+
+		rawScore, levelDescription := field("level_score"), field("level_description")
+		if rawScore == "" && levelDescription == "" {
+			continue // Criterion without levels
+		}
+		score, err := strconv.ParseFloat(rawScore, 64)
+		if err != nil {
+			issues = append(issues, RubricImportIssue{Location: location, Message: fmt.Sprintf("level_score '%s' is not a number", rawScore)})
+			continue
+		}
+		criterion.Levels = append(criterion.Levels, RubricLevelDocument{Score: score, Description: levelDescription})
+	}
+
+	return doc, issues
+}
+
+func writeRubricCSV(doc RubricDocument) ([]byte, error) {
+	b := new(bytes.Buffer)
+	w := csv.NewWriter(b)
+	if err := w.Write(rubricCSVHeader); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, c := range doc.Criteria {
+		rows := [][]string{{"", ""}}
+		if len(c.Levels) > 0 {
+			rows = rows[:0]
+			for _, level := range c.Levels {
+				rows = append(rows, []string{formatScore(level.Score), level.Description})
+			}
+		}
+		for _, level := range rows {
+			record := []string{doc.Name, doc.Description, c.Title, c.Description, formatScore(c.MaxPoints), level[0], level[1]}
+			if err := w.Write(record); err != nil {
+				return nil, fmt.Errorf("failed to write CSV row: %w", err)
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("error flushing CSV writer: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
@@ -1,32 +1,91 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/storage"
 )
 
 // RubricService defines the business logic for rubrics.
 type RubricService interface {
-	CreateRubric(rubric *models.Rubric) error
+	CreateRubric(rubric *models.Rubric, requestingUserID uint, requestingUserRole string) error
 	GetAllRubrics(filters map[string]interface{}) ([]models.Rubric, error)
 	GetRubricByID(id uint) (*models.Rubric, error)
 	GetRubricsByProjectID(projectID uint) ([]models.Rubric, error) // Added this method
-	UpdateRubric(rubric *models.Rubric) error
-	DeleteRubric(id uint) error
+	UpdateRubric(rubric *models.Rubric, requestingUserID uint, requestingUserRole string) error
+	DeleteRubric(id, requestingUserID uint, requestingUserRole string) error
 	DuplicateRubric(id uint) (*models.Rubric, error)
+	GetRubricVersions(id uint) ([]models.Rubric, error)
+	DiffRubricVersions(id uint, fromVersion, toVersion int) (*RubricDiff, error)
+	CreateTemplate(template *models.Rubric, requestingUserRole string) error
+	SaveAsTemplate(id uint, category string, requestingUserRole string) (*models.Rubric, error)
+	SearchTemplates(query, category string) ([]RubricTemplateSummary, error)
+	GetTemplateCategories() ([]storage.TemplateCategoryCount, error)
+	InstantiateTemplate(templateID, projectID, requestingUserID uint, requestingUserRole string) (*models.Rubric, error)
+	ImportRubric(req RubricImportRequest, requestingUserID uint, requestingUserRole string) (*RubricImportResult, error)
+	ExportRubric(id uint, format string) ([]byte, error)
+	// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+	WithContext(ctx context.Context) RubricService
+}
+
+// RubricFieldChange describes a field whose value differs between two rubric versions.
+type RubricFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// RubricCriterionChange describes a criterion added, removed or modified between versions.
+type RubricCriterionChange struct {
+	Change          string              `json:"change"` // "added", "removed" or "modified"
+	Title           string              `json:"title"`
+	FromCriterionID *uint               `json:"fromCriterionId,omitempty"`
+	ToCriterionID   *uint               `json:"toCriterionId,omitempty"`
+	Changes         []RubricFieldChange `json:"changes,omitempty"`
+}
+
+// RubricDiff lists the differences between two versions of a rubric.
+type RubricDiff struct {
+	RootRubricID uint                    `json:"rootRubricId"`
+	FromVersion  int                     `json:"fromVersion"`
+	ToVersion    int                     `json:"toVersion"`
+	FromRubricID uint                    `json:"fromRubricId"`
+	ToRubricID   uint                    `json:"toRubricId"`
+	Changes      []RubricFieldChange     `json:"changes"`
+	Criteria     []RubricCriterionChange `json:"criteria"`
+}
+
+// RubricTemplateSummary is a global template together with how often it was instantiated.
+type RubricTemplateSummary struct {
+	models.Rubric
+	UsageCount int64 `json:"usageCount"`
 }
 
 type rubricService struct {
-	repo storage.RubricRepository
+	repo        storage.RubricRepository
+	projectRepo *storage.ProjectRepository
 }
 
 // NewRubricService creates a new instance of RubricService.
-func NewRubricService(repo storage.RubricRepository) RubricService {
-	return &rubricService{repo: repo}
+func NewRubricService(repo storage.RubricRepository, projectRepo *storage.ProjectRepository) RubricService {
+	return &rubricService{repo: repo, projectRepo: projectRepo}
 }
 
-func (s *rubricService) CreateRubric(rubric *models.Rubric) error {
+func (s *rubricService) WithContext(ctx context.Context) RubricService {
+	return &rubricService{repo: s.repo.WithContext(ctx), projectRepo: s.projectRepo.WithContext(ctx)}
+}
+
+// CreateRubric creates a project rubric, or a global template when it has no project.
+// Project rubrics need a member of the project or an admin; global templates an admin.
+func (s *rubricService) CreateRubric(rubric *models.Rubric, requestingUserID uint, requestingUserRole string) error {
+	if err := s.requireRubricAccess(rubric, requestingUserID, requestingUserRole); err != nil {
+		return err
+	}
 	return s.repo.Create(rubric)
 }
 
@@ -42,32 +101,319 @@ func (s *rubricService) GetRubricsByProjectID(projectID uint) ([]models.Rubric,
 	return s.repo.GetByProjectID(projectID)
 }
 
-func (s *rubricService) UpdateRubric(rubric *models.Rubric) error {
-	return s.repo.Update(rubric)
+// UpdateRubric edits a DRAFT rubric that no evaluation uses in place. Otherwise the
+// edit is saved as a new version, so existing criterion evaluations keep pointing at
+// the criteria they were scored against; rubric is then replaced by the new version.
+// The same users as for CreateRubric may edit it.
+func (s *rubricService) UpdateRubric(rubric *models.Rubric, requestingUserID uint, requestingUserRole string) error {
+	existing, err := s.repo.FindByID(rubric.ID)
+	if err != nil {
+		return fmt.Errorf("rubric with ID %d not found", rubric.ID)
+	}
+	if err := s.requireRubricAccess(existing, requestingUserID, requestingUserRole); err != nil {
+		return err
+	}
+	if existing.SupersededAt != nil {
+		return fmt.Errorf("invalid rubric update: rubric %d has been superseded by a newer version", existing.ID)
+	}
+
+	inUse, err := s.repo.IsInUse(existing.ID)
+	if err != nil {
+		return fmt.Errorf("could not check rubric usage: %w", err)
+	}
+	if existing.Status != models.RubricStatusActive && !inUse {
+		rubric.ProjectID = existing.ProjectID // Rubrics never move between projects or out of the library
+		return s.repo.Update(rubric)
+	}
+
+	next := newRubricVersion(existing, rubric)
+	if err := s.repo.CreateVersion(existing, next); err != nil {
+		return fmt.Errorf("invalid rubric update: could not create a new version: %w", err)
+	}
+	*rubric = *next
+	return nil
 }
 
-func (s *rubricService) DeleteRubric(id uint) error {
+// DeleteRubric deletes a rubric no evaluation uses. Rubrics with several versions are
+// kept whole: removing one would leave the others pointing at a missing root, or a
+// superseded version without its successor. The same users as for CreateRubric may delete it.
+func (s *rubricService) DeleteRubric(id, requestingUserID uint, requestingUserRole string) error {
+	rubric, err := s.repo.FindByID(id)
+	if err != nil {
+		return fmt.Errorf("rubric with ID %d not found", id)
+	}
+	if err := s.requireRubricAccess(rubric, requestingUserID, requestingUserRole); err != nil {
+		return err
+	}
+	versions, err := s.repo.FindVersions(rootRubricID(rubric))
+	if err != nil {
+		return fmt.Errorf("could not load rubric versions: %w", err)
+	}
+	if len(versions) > 1 {
+		return fmt.Errorf("invalid rubric deletion: rubric %d has %d versions", id, len(versions))
+	}
+	inUse, err := s.repo.IsInUse(id)
+	if err != nil {
+		return fmt.Errorf("could not check rubric usage: %w", err)
+	}
+	if inUse {
+		return fmt.Errorf("invalid rubric deletion: rubric %d is referenced by evaluations", id)
+	}
 	return s.repo.Delete(id)
 }
 
+// GetRubricVersions lists every version of the rubric's lineage, oldest first.
+func (s *rubricService) GetRubricVersions(id uint) ([]models.Rubric, error) {
+	rubric, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("rubric with ID %d not found", id)
+	}
+	return s.repo.FindVersions(rootRubricID(rubric))
+}
+
+// DiffRubricVersions compares two versions of the rubric's lineage. Criteria are
+// matched across versions through their origin criterion.
+func (s *rubricService) DiffRubricVersions(id uint, fromVersion, toVersion int) (*RubricDiff, error) {
+	versions, err := s.GetRubricVersions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	byNumber := make(map[int]*models.Rubric, len(versions))
+	for i := range versions {
+		byNumber[versions[i].Version] = &versions[i]
+	}
+	from, ok := byNumber[fromVersion]
+	if !ok {
+		return nil, fmt.Errorf("rubric version %d not found", fromVersion)
+	}
+	to, ok := byNumber[toVersion]
+	if !ok {
+		return nil, fmt.Errorf("rubric version %d not found", toVersion)
+	}
+
+	diff := &RubricDiff{
+		RootRubricID: rootRubricID(from),
+		FromVersion:  from.Version,
+		ToVersion:    to.Version,
+		FromRubricID: from.ID,
+		ToRubricID:   to.ID,
+		Changes:      []RubricFieldChange{},
+		Criteria:     []RubricCriterionChange{},
+	}
+	diff.Changes = appendChange(diff.Changes, "name", from.Name, to.Name)
+	diff.Changes = appendChange(diff.Changes, "description", from.Description, to.Description)
+	diff.Changes = appendChange(diff.Changes, "status", from.Status, to.Status)
+	diff.Changes = appendChange(diff.Changes, "isTemplate", from.IsTemplate, to.IsTemplate)
+
+	toCriteria := make(map[uint]*models.RubricCriterion, len(to.Criteria))
+	for i := range to.Criteria {
+		toCriteria[originCriterionID(&to.Criteria[i])] = &to.Criteria[i]
+	}
+
+	matched := make(map[uint]bool)
+	for i := range from.Criteria {
+		old := &from.Criteria[i]
+		key := originCriterionID(old)
+		current, ok := toCriteria[key]
+		if !ok {
+			diff.Criteria = append(diff.Criteria, RubricCriterionChange{Change: "removed", Title: old.Title, FromCriterionID: &old.ID})
+			continue
+		}
+		matched[key] = true
+
+		var changes []RubricFieldChange
+		changes = appendChange(changes, "title", old.Title, current.Title)
+		changes = appendChange(changes, "description", old.Description, current.Description)
+		changes = appendChange(changes, "maxPoints", old.MaxPoints, current.MaxPoints)
+		changes = appendChange(changes, "levels", levelSummary(old.Levels), levelSummary(current.Levels))
+		if len(changes) > 0 {
+			diff.Criteria = append(diff.Criteria, RubricCriterionChange{
+				Change:          "modified",
+				Title:           current.Title,
+				FromCriterionID: &old.ID,
+				ToCriterionID:   &current.ID,
+				Changes:         changes,
+			})
+		}
+	}
+	for i := range to.Criteria {
+		criterion := &to.Criteria[i]
+		if !matched[originCriterionID(criterion)] {
+			diff.Criteria = append(diff.Criteria, RubricCriterionChange{Change: "added", Title: criterion.Title, ToCriterionID: &criterion.ID})
+		}
+	}
+
+	return diff, nil
+}
+
 func (s *rubricService) DuplicateRubric(id uint) (*models.Rubric, error) {
 	original, err := s.repo.FindByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find original rubric: %w", err)
 	}
 
-	// Create a deep copy for duplication
-	newRubric := *original
-	newRubric.ID = 0 // Set ID to 0 to create a new record
+	newRubric := deepCopyRubric(original)
 	newRubric.Name = "Copia de " + original.Name
 	newRubric.Status = models.RubricStatusDraft // New duplicates are always drafts
 
+	if err := s.repo.Create(&newRubric); err != nil {
+		return nil, fmt.Errorf("failed to create duplicated rubric: %w", err)
+	}
+
+	return &newRubric, nil
+}
+
+// CreateTemplate adds a global template to the library. Only admins may manage global templates.
+func (s *rubricService) CreateTemplate(template *models.Rubric, requestingUserRole string) error {
+	if requestingUserRole != string(models.RoleAdmin) {
+		return fmt.Errorf("forbidden: only admins can manage global rubric templates")
+	}
+	template.ProjectID = nil
+	template.IsTemplate = true
+	template.SourceTemplateID = nil
+	return s.repo.Create(template)
+}
+
+// SaveAsTemplate copies an existing rubric into the library as a global template.
+func (s *rubricService) SaveAsTemplate(id uint, category string, requestingUserRole string) (*models.Rubric, error) {
+	if requestingUserRole != string(models.RoleAdmin) {
+		return nil, fmt.Errorf("forbidden: only admins can manage global rubric templates")
+	}
+	original, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("rubric with ID %d not found", id)
+	}
+
+	template := deepCopyRubric(original)
+	template.ProjectID = nil
+	template.IsTemplate = true
+	template.SourceTemplateID = nil
+	if category != "" {
+		template.Category = category
+	}
+
+	if err := s.repo.Create(&template); err != nil {
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+	return &template, nil
+}
+
+// SearchTemplates lists the latest version of the global templates matching the
+// search text and category, most used first.
+func (s *rubricService) SearchTemplates(query, category string) ([]RubricTemplateSummary, error) {
+	templates, err := s.repo.FindTemplates(query, category)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := make([]uint, 0, len(templates))
+	for i := range templates {
+		roots = append(roots, rootRubricID(&templates[i]))
+	}
+	usage, err := s.repo.CountTemplateUsage(roots)
+	if err != nil {
+		return nil, fmt.Errorf("could not count template usage: %w", err)
+	}
+
+	summaries := make([]RubricTemplateSummary, 0, len(templates))
+	for i := range templates {
+		summaries = append(summaries, RubricTemplateSummary{Rubric: templates[i], UsageCount: usage[rootRubricID(&templates[i])]})
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].UsageCount > summaries[j].UsageCount })
+	return summaries, nil
+}
+
+// GetTemplateCategories lists the template categories and how many templates each holds.
+func (s *rubricService) GetTemplateCategories() ([]storage.TemplateCategoryCount, error) {
+	return s.repo.CountTemplatesByCategory()
+}
+
+// InstantiateTemplate deep copies a global template into a project as a new DRAFT rubric.
+// The requesting user must be a member of the project or a platform admin.
+func (s *rubricService) InstantiateTemplate(templateID, projectID, requestingUserID uint, requestingUserRole string) (*models.Rubric, error) {
+	template, err := s.repo.FindByID(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("template with ID %d not found", templateID)
+	}
+	if !template.IsTemplate || template.ProjectID != nil {
+		return nil, fmt.Errorf("invalid template: rubric %d is not a global template", templateID)
+	}
+	if template.SupersededAt != nil {
+		return nil, fmt.Errorf("invalid template: rubric %d has been superseded by a newer version", templateID)
+	}
+
+	if err := s.requireProjectAccess(projectID, requestingUserID, requestingUserRole); err != nil {
+		return nil, err
+	}
+
+	root := rootRubricID(template)
+	rubric := deepCopyRubric(template)
+	rubric.ProjectID = &projectID
+	rubric.IsTemplate = false
+	rubric.SourceTemplateID = &root
+	rubric.Status = models.RubricStatusDraft
+	rubric.CreatedByID = requestingUserID
+
+	if err := s.repo.Create(&rubric); err != nil {
+		return nil, fmt.Errorf("failed to instantiate template: %w", err)
+	}
+	return &rubric, nil
+}
+
+// requireRubricAccess checks that the user may manage the rubric: an admin for global
+// templates, otherwise a member of its project (see requireProjectAccess).
+func (s *rubricService) requireRubricAccess(rubric *models.Rubric, userID uint, userRole string) error {
+	if rubric.ProjectID == nil {
+		if userRole != string(models.RoleAdmin) {
+			return fmt.Errorf("forbidden: only admins can manage global rubric templates")
+		}
+		return nil
+	}
+	return s.requireProjectAccess(*rubric.ProjectID, userID, userRole)
+}
+
+// requireProjectAccess checks that the project exists and is not archived, and that
+// the user is a member of it or a platform admin.
+func (s *rubricService) requireProjectAccess(projectID, userID uint, userRole string) error {
+	project, err := s.projectRepo.GetProjectByID(projectID)
+	if err != nil {
+		return fmt.Errorf("project not found")
+	}
+	if project.IsArchived() {
+		return models.ErrProjectArchived
+	}
+	if userRole == string(models.RoleAdmin) {
+		return nil
+	}
+	isMember, err := s.projectRepo.IsMember(projectID, userID)
+	if err != nil {
+		return fmt.Errorf("could not verify project membership: %w", err)
+	}
+	if !isMember {
+		return fmt.Errorf("forbidden: you are not a member of this project")
+	}
+	return nil
+}
+
+// deepCopyRubric copies a rubric with its criteria and levels, ready to be created as
+// the first version of a new lineage.
+func deepCopyRubric(original *models.Rubric) models.Rubric {
+	newRubric := *original
+	newRubric.ID = 0 // Set ID to 0 to create a new record
+	newRubric.Project = nil
+	newRubric.CreatedBy = models.User{}
+	newRubric.Version = 1 // Copies start a lineage of their own
+	newRubric.RootRubricID = nil
+	newRubric.SupersededAt = nil
+
 	// Deep copy criteria and levels
 	newRubric.Criteria = make([]models.RubricCriterion, len(original.Criteria))
 	for i, crit := range original.Criteria {
 		newCrit := crit
 		newCrit.ID = 0
 		newCrit.RubricID = 0 // Will be set by GORM on creation
+		newCrit.OriginCriterionID = nil
 
 		newCrit.Levels = make([]models.RubricCriterionLevel, len(crit.Levels))
 		for j, level := range crit.Levels {
@@ -78,10 +424,83 @@ func (s *rubricService) DuplicateRubric(id uint) (*models.Rubric, error) {
 		}
 		newRubric.Criteria[i] = newCrit
 	}
+	return newRubric
+}
 
-	if err := s.repo.Create(&newRubric); err != nil {
-		return nil, fmt.Errorf("failed to create duplicated rubric: %w", err)
+// newRubricVersion builds the next version of existing from the edited payload.
+// Payload criteria that carry the ID of one of existing's criteria keep its origin.
+func newRubricVersion(existing, payload *models.Rubric) *models.Rubric {
+	root := rootRubricID(existing)
+	next := &models.Rubric{
+		Name:         payload.Name,
+		Description:  payload.Description,
+		ProjectID:    existing.ProjectID,
+		CreatedByID:  existing.CreatedByID,
+		Status:       payload.Status,
+		IsTemplate:   payload.IsTemplate,
+		Category:     payload.Category,
+		Version:      existing.Version + 1,
+		RootRubricID: &root,
+		Criteria:     make([]models.RubricCriterion, 0, len(payload.Criteria)),
+	}
+	next.SourceTemplateID = existing.SourceTemplateID
+	if next.Status == "" {
+		next.Status = existing.Status
 	}
 
-	return &newRubric, nil
+	origins := make(map[uint]uint, len(existing.Criteria))
+	for i := range existing.Criteria {
+		origins[existing.Criteria[i].ID] = originCriterionID(&existing.Criteria[i])
+	}
+
+	for _, crit := range payload.Criteria {
+		newCrit := models.RubricCriterion{
+			Title:       crit.Title,
+			Description: crit.Description,
+			MaxPoints:   crit.MaxPoints,
+			Levels:      make([]models.RubricCriterionLevel, 0, len(crit.Levels)),
+		}
+		if origin, ok := origins[crit.ID]; ok {
+			newCrit.OriginCriterionID = &origin
+		}
+		for _, level := range crit.Levels {
+			newCrit.Levels = append(newCrit.Levels, models.RubricCriterionLevel{Score: level.Score, Description: level.Description})
+		}
+		next.Criteria = append(next.Criteria, newCrit)
+	}
+	return next
+}
+
+// rootRubricID returns the ID of the first version of a rubric's lineage.
+func rootRubricID(rubric *models.Rubric) uint {
+	if rubric.RootRubricID != nil {
+		return *rubric.RootRubricID
+	}
+	return rubric.ID
+}
+
+// originCriterionID returns the ID identifying a criterion across versions.
+func originCriterionID(criterion *models.RubricCriterion) uint {
+	if criterion.OriginCriterionID != nil {
+		return *criterion.OriginCriterionID
+	}
+	return criterion.ID
+}
+
+func appendChange(changes []RubricFieldChange, field string, from, to interface{}) []RubricFieldChange {
+	if from == to {
+		return changes
+	}
+	return append(changes, RubricFieldChange{Field: field, From: from, To: to})
+}
+
+// levelSummary renders a criterion's levels as a comparable "score: description" list.
+func levelSummary(levels []models.RubricCriterionLevel) string {
+	sorted := append([]models.RubricCriterionLevel(nil), levels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Score < sorted[j].Score })
+	parts := make([]string, 0, len(sorted))
+	for _, level := range sorted {
+		parts = append(parts, fmt.Sprintf("%s: %s", strconv.FormatFloat(level.Score, 'f', -1, 64), level.Description))
+	}
+	return strings.Join(parts, "; ")
 }
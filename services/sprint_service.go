@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"github.com/buga/API_wrkf/models"
 	"github.com/buga/API_wrkf/storage"
@@ -8,7 +9,8 @@ import (
 
 // SprintService handles the business logic for sprints.
 type SprintService struct {
-	Repo *storage.SprintRepository
+	Repo   *storage.SprintRepository
+	Events *EventBus // Optional, set once at startup
 }
 
 // NewSprintService creates a new instance of SprintService.
@@ -16,11 +18,20 @@ func NewSprintService(repo *storage.SprintRepository) *SprintService {
 	return &SprintService{Repo: repo}
 }
 
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *SprintService) WithContext(ctx context.Context) *SprintService {
+	return &SprintService{Repo: s.Repo.WithContext(ctx), Events: s.Events.WithContext(ctx)}
+}
+
 // CreateSprint handles the business logic for creating a new sprint.
 func (s *SprintService) CreateSprint(sprint *models.Sprint, projectID uint, creatorID uint) error {
 	sprint.ProjectID = projectID
 	sprint.CreatedByID = creatorID
-	return s.Repo.CreateSprint(sprint)
+	if err := s.Repo.CreateSprint(sprint); err != nil {
+		return err
+	}
+	s.Events.Publish(projectID, SprintCreated{Sprint: sprint})
+	return nil
 }
 
 // GetSprintsByProjectID retrieves all sprints for a specific project.
@@ -36,13 +47,25 @@ func (s *SprintService) GetSprintByID(id uint) (*models.Sprint, error) {
 // UpdateSprint handles the business logic for updating a sprint.
 func (s *SprintService) UpdateSprint(sprint *models.Sprint) error {
 	// In the future, you could add permission checks here.
-	return s.Repo.UpdateSprint(sprint)
+	if err := s.Repo.UpdateSprint(sprint); err != nil {
+		return err
+	}
+	s.Events.Publish(sprint.ProjectID, SprintUpdated{Sprint: sprint})
+	return nil
 }
 
 // DeleteSprint handles the business logic for deleting a sprint.
 func (s *SprintService) DeleteSprint(id uint) error {
 	// In future, you could add logic here to move user stories back to the backlog.
-	return s.Repo.DeleteSprint(id)
+	sprint, err := s.Repo.GetSprintByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.Repo.DeleteSprint(id); err != nil {
+		return err
+	}
+	s.Events.Publish(sprint.ProjectID, SprintDeleted{SprintID: id})
+	return nil
 }
 
 // GetSprintTasks retrieves all tasks for a specific sprint with their relationships.
@@ -50,6 +73,23 @@ func (s *SprintService) GetSprintTasks(sprintID uint) ([]models.Task, error) {
 	return s.Repo.GetSprintTasks(sprintID)
 }
 
+// ListSprintTasks retrieves one page of the tasks in a sprint matching the filter,
+// newest first unless sorted otherwise.
+func (s *SprintService) ListSprintTasks(sprintID uint, filter storage.TaskFilter, query ListQuery) (*Page[models.Task], error) {
+	if err := validateTaskFilter(filter); err != nil {
+		return nil, err
+	}
+	opts, err := query.options(taskSortFields, "-createdAt")
+	if err != nil {
+		return nil, err
+	}
+	tasks, total, err := s.Repo.ListSprintTasks(sprintID, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(tasks, total, opts), nil
+}
+
 // UpdateSprintStatus updates the status of a sprint.
 func (s *SprintService) UpdateSprintStatus(sprintID uint, status string) error {
 	if status == "active" {
@@ -62,5 +102,11 @@ func (s *SprintService) UpdateSprintStatus(sprintID uint, status string) error {
 			return fmt.Errorf("another sprint is already active in this project")
 		}
 	}
-	return s.Repo.UpdateSprintStatus(sprintID, status)
+	if err := s.Repo.UpdateSprintStatus(sprintID, status); err != nil {
+		return err
+	}
+	if sprint, err := s.Repo.GetSprintByID(sprintID); err == nil {
+		s.Events.Publish(sprint.ProjectID, SprintUpdated{Sprint: sprint})
+	}
+	return nil
 }
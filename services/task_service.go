@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -13,6 +14,7 @@ type TaskService struct {
 	Repo                *storage.TaskRepository
 	ProjectService      *ProjectService // Dependency to check project membership
 	NotificationService *NotificationService
+	Events              *EventBus // Optional, set once at startup
 }
 
 // NewTaskService creates a new instance of TaskService.
@@ -24,6 +26,14 @@ func NewTaskService(repo *storage.TaskRepository, projectService *ProjectService
 	}
 }
 
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *TaskService) WithContext(ctx context.Context) *TaskService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	scoped.Events = s.Events.WithContext(ctx)
+	return &scoped
+}
+
 // CreateTask handles the business logic for creating a new task and returns the hydrated object.
 func (s *TaskService) CreateTask(task *models.Task, userStoryID uint, creatorID uint) (*models.Task, error) {
 	// Keep a reference to the assigned ID, but don't save it directly on creation.
@@ -39,11 +49,18 @@ func (s *TaskService) CreateTask(task *models.Task, userStoryID uint, creatorID
 	}
 
 	// If an assignee was specified, assign the task now.
+	var created *models.Task
+	var err error
 	if assignedToID != nil && *assignedToID != 0 {
-		return s.AssignTask(task.ID, *assignedToID)
+		created, err = s.assignTask(task.ID, *assignedToID)
+	} else {
+		created, err = s.Repo.GetTaskByID(task.ID)
 	}
-
-	return s.Repo.GetTaskByID(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.Events.Publish(created.UserStory.ProjectID, TaskCreated{Task: created})
+	return created, nil
 }
 
 // GetTaskByID retrieves a single task by its ID.
@@ -51,13 +68,57 @@ func (s *TaskService) GetTaskByID(id uint) (*models.Task, error) {
 	return s.Repo.GetTaskByID(id)
 }
 
+// GetSprintIDForTask retrieves the sprint of a task's user story, or nil when it is in the backlog.
+func (s *TaskService) GetSprintIDForTask(taskID uint) (*uint, error) {
+	return s.Repo.GetSprintIDForTask(taskID)
+}
+
 // GetTasksByUserStoryID retrieves all tasks for a specific user story.
 func (s *TaskService) GetTasksByUserStoryID(userStoryID uint) ([]models.Task, error) {
 	return s.Repo.GetTasksByUserStoryID(userStoryID)
 }
 
+// taskSortFields are the fields the task lists can be sorted by.
+var taskSortFields = sortFields{
+	"id": "tasks.id", "title": "tasks.title", "status": "tasks.status", "estimatedHours": "tasks.estimated_hours",
+	"spentHours": "tasks.spent_hours", "createdAt": "tasks.created_at", "updatedAt": "tasks.updated_at",
+}
+
+// validateTaskFilter rejects unknown task statuses.
+func validateTaskFilter(filter storage.TaskFilter) error {
+	if filter.Status != "" && !models.IsValidTaskStatus(filter.Status) {
+		return fmt.Errorf("invalid list query: invalid task status '%s'", filter.Status)
+	}
+	return nil
+}
+
+// ListTasksByUserStoryID retrieves one page of a user story's tasks matching the filter.
+func (s *TaskService) ListTasksByUserStoryID(userStoryID uint, filter storage.TaskFilter, query ListQuery) (*Page[models.Task], error) {
+	if err := validateTaskFilter(filter); err != nil {
+		return nil, err
+	}
+	opts, err := query.options(taskSortFields, "id")
+	if err != nil {
+		return nil, err
+	}
+	tasks, total, err := s.Repo.ListTasksByUserStoryID(userStoryID, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(tasks, total, opts), nil
+}
+
 // UpdateTask handles the business logic for updating a task.
 func (s *TaskService) UpdateTask(task *models.Task) (*models.Task, error) {
+	updatedTask, err := s.saveTask(task)
+	if err != nil {
+		return nil, err
+	}
+	s.Events.Publish(updatedTask.UserStory.ProjectID, TaskUpdated{Task: updatedTask})
+	return updatedTask, nil
+}
+
+func (s *TaskService) saveTask(task *models.Task) (*models.Task, error) {
 	if err := s.Repo.UpdateTask(task); err != nil {
 		return nil, err
 	}
@@ -66,11 +127,29 @@ func (s *TaskService) UpdateTask(task *models.Task) (*models.Task, error) {
 
 // DeleteTask handles the business logic for deleting a task.
 func (s *TaskService) DeleteTask(id uint) error {
-	return s.Repo.DeleteTask(id)
+	projectID, err := s.Repo.GetProjectIDForTask(id)
+	if err != nil {
+		return fmt.Errorf("task not found")
+	}
+	if err := s.Repo.DeleteTask(id); err != nil {
+		return err
+	}
+	s.Events.Publish(projectID, TaskDeleted{TaskID: id})
+	return nil
 }
 
 // AssignTask handles the business logic for assigning a task to a user.
 func (s *TaskService) AssignTask(taskID, assignToUserID uint) (*models.Task, error) {
+	assignedTask, err := s.assignTask(taskID, assignToUserID)
+	if err != nil {
+		return nil, err
+	}
+	s.Events.Publish(assignedTask.UserStory.ProjectID, TaskAssigned{Task: assignedTask})
+	return assignedTask, nil
+}
+
+// assignTask assigns a task and notifies the assignee, without publishing an event.
+func (s *TaskService) assignTask(taskID, assignToUserID uint) (*models.Task, error) {
 	task, err := s.Repo.GetTaskByID(taskID)
 	if err != nil {
 		return nil, fmt.Errorf("task not found")
@@ -89,7 +168,7 @@ func (s *TaskService) AssignTask(taskID, assignToUserID uint) (*models.Task, err
 	task.AssignedTo = nil
 	task.AssignedToID = &assignToUserID
 
-	updatedTask, err := s.UpdateTask(task)
+	updatedTask, err := s.saveTask(task)
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +213,12 @@ func (s *TaskService) UpdateTaskStatus(taskID uint, newStatus string, updaterID
 	}
 
 	// 5. Return the updated, hydrated task.
-	return s.Repo.GetTaskByID(taskID)
+	updatedTask, err := s.Repo.GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	s.Events.Publish(updatedTask.UserStory.ProjectID, TaskStatusChanged{TaskID: taskID, OldStatus: oldStatus, NewStatus: newStatusTyped})
+	return updatedTask, nil
 }
 
 // AddCommentToTask adds a comment to a task and notifies the assignee.
@@ -155,6 +239,7 @@ func (s *TaskService) AddCommentToTask(taskID, authorID uint, content string) (*
 		log.Printf("could not get task for notification after commenting: %v", err)
 		return comment, nil // Return the comment even if notification fails
 	}
+	s.Events.Publish(task.UserStory.ProjectID, TaskCommentAdded{TaskID: taskID, Comment: comment})
 
 	// Only notify if there is an assignee and the assignee is not the one who commented.
 	if task.AssignedToID != nil && *task.AssignedToID != authorID {
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+// ProjectTrash lists the user stories and tasks of a project that are in the trash.
+type ProjectTrash struct {
+	ProjectID   uint               `json:"projectId"`
+	UserStories []models.UserStory `json:"userStories"`
+	Tasks       []models.Task      `json:"tasks"`
+}
+
+// PurgeResult counts the items permanently deleted by a purge, and those that could not be.
+type PurgeResult struct {
+	Projects    int `json:"projects"`
+	UserStories int `json:"userStories"`
+	Tasks       int `json:"tasks"`
+	Failed      int `json:"failed"` // Left in the trash; the next purge tries them again
+}
+
+// TrashService handles the business logic for the trash: listing deleted projects,
+// user stories and tasks, restoring them and purging them after the retention period.
+type TrashService struct {
+	Repo           *storage.TrashRepository
+	ProjectService *ProjectService  // To check user roles
+	Retention      time.Duration    // How long deleted items are kept before being purged
+	Jobs           *storage.JobLock // Optional, set once at startup
+}
+
+// NewTrashService creates a new instance of TrashService.
+func NewTrashService(repo *storage.TrashRepository, projectService *ProjectService, retention time.Duration) *TrashService {
+	return &TrashService{
+		Repo:           repo,
+		ProjectService: projectService,
+		Retention:      retention,
+	}
+}
+
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *TrashService) WithContext(ctx context.Context) *TrashService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	if s.Jobs != nil {
+		scoped.Jobs = s.Jobs.WithContext(ctx)
+	}
+	return &scoped
+}
+
+// GetTrashedProjects lists the deleted projects. Admins see all of them, other users
+// only the projects they created.
+func (s *TrashService) GetTrashedProjects(requestingUserID uint, requestingUserRole string) ([]models.Project, error) {
+	if requestingUserRole == string(models.RoleAdmin) {
+		return s.Repo.GetTrashedProjects(0)
+	}
+	return s.Repo.GetTrashedProjects(requestingUserID)
+}
+
+// GetProjectTrash lists the deleted user stories and tasks of a project. Any project member may see it.
+func (s *TrashService) GetProjectTrash(projectID, requestingUserID uint, requestingUserRole string) (*ProjectTrash, error) {
+	if _, err := s.ProjectService.GetProjectByID(projectID); err != nil {
+		return nil, fmt.Errorf("project not found")
+	}
+	if err := s.requireMember(projectID, requestingUserID, requestingUserRole); err != nil {
+		return nil, err
+	}
+
+	stories, err := s.Repo.GetTrashedUserStories(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve deleted user stories: %w", err)
+	}
+	tasks, err := s.Repo.GetTrashedTasks(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve deleted tasks: %w", err)
+	}
+	return &ProjectTrash{ProjectID: projectID, UserStories: stories, Tasks: tasks}, nil
+}
+
+// RestoreProject brings a deleted project back with the stories and tasks deleted with it.
+// Only the project creator or an admin may restore it.
+func (s *TrashService) RestoreProject(projectID, requestingUserID uint, requestingUserRole string) (*models.Project, error) {
+	project, err := s.Repo.GetTrashedProject(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("project not found in trash")
+	}
+	if project.CreatedByID != requestingUserID && requestingUserRole != string(models.RoleAdmin) {
+		return nil, fmt.Errorf("forbidden: you do not have permission to restore this project")
+	}
+
+	if err := s.Repo.RestoreProject(projectID); err != nil {
+		return nil, fmt.Errorf("could not restore project: %w", err)
+	}
+	return s.ProjectService.GetProjectByID(projectID)
+}
+
+// RestoreUserStory brings a deleted user story back with the tasks deleted with it.
+// The same roles that may delete a story (admin, product owner, scrum master) may restore it.
+func (s *TrashService) RestoreUserStory(storyID, requestingUserID uint, requestingUserRole string) (*models.UserStory, error) {
+	story, err := s.Repo.GetUserStory(storyID)
+	if err != nil || !story.DeletedAt.Valid {
+		return nil, fmt.Errorf("user story not found in trash")
+	}
+	if _, err := s.ProjectService.GetProjectByID(story.ProjectID); err != nil {
+		return nil, fmt.Errorf("invalid restore: the project of this user story is in the trash, restore the project first")
+	}
+	if requestingUserRole != string(models.RoleAdmin) {
+		role, err := s.ProjectService.GetUserRoleInProject(requestingUserID, story.ProjectID)
+		projectRole := models.ProjectRole(role)
+		if err != nil || (projectRole != models.RoleProductOwner && projectRole != models.RoleScrumMaster) {
+			return nil, fmt.Errorf("forbidden: you do not have permission to restore this user story")
+		}
+	}
+
+	if err := s.Repo.RestoreUserStory(storyID); err != nil {
+		return nil, fmt.Errorf("could not restore user story: %w", err)
+	}
+	return s.Repo.GetUserStory(storyID)
+}
+
+// RestoreTask brings a deleted task back, along with its user story if that was deleted too.
+// Any project member may restore a task.
+func (s *TrashService) RestoreTask(taskID, requestingUserID uint, requestingUserRole string) (*models.Task, error) {
+	task, err := s.Repo.GetTask(taskID)
+	if err != nil || !task.DeletedAt.Valid {
+		return nil, fmt.Errorf("task not found in trash")
+	}
+	story, err := s.Repo.GetUserStory(task.UserStoryID)
+	if err != nil {
+		return nil, fmt.Errorf("user story not found")
+	}
+	if _, err := s.ProjectService.GetProjectByID(story.ProjectID); err != nil {
+		return nil, fmt.Errorf("invalid restore: the project of this task is in the trash, restore the project first")
+	}
+	if err := s.requireMember(story.ProjectID, requestingUserID, requestingUserRole); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.RestoreTask(taskID); err != nil {
+		return nil, fmt.Errorf("could not restore task: %w", err)
+	}
+	return s.Repo.GetTask(taskID)
+}
+
+// PurgeProject permanently deletes a project in the trash. Admins only.
+func (s *TrashService) PurgeProject(projectID uint, requestingUserRole string) error {
+	if err := requireAdmin(requestingUserRole, "purge"); err != nil {
+		return err
+	}
+	if _, err := s.Repo.GetTrashedProject(projectID); err != nil {
+		return fmt.Errorf("project not found in trash")
+	}
+	return s.Repo.PurgeProject(projectID)
+}
+
+// PurgeUserStory permanently deletes a user story in the trash. Admins only.
+func (s *TrashService) PurgeUserStory(storyID uint, requestingUserRole string) error {
+	if err := requireAdmin(requestingUserRole, "purge"); err != nil {
+		return err
+	}
+	if story, err := s.Repo.GetUserStory(storyID); err != nil || !story.DeletedAt.Valid {
+		return fmt.Errorf("user story not found in trash")
+	}
+	return s.Repo.PurgeUserStory(storyID)
+}
+
+// PurgeTask permanently deletes a task in the trash. Admins only.
+func (s *TrashService) PurgeTask(taskID uint, requestingUserRole string) error {
+	if err := requireAdmin(requestingUserRole, "purge"); err != nil {
+		return err
+	}
+	if task, err := s.Repo.GetTask(taskID); err != nil || !task.DeletedAt.Valid {
+		return fmt.Errorf("task not found in trash")
+	}
+	return s.Repo.PurgeTask(taskID)
+}
+
+// PurgeExpired permanently deletes everything that has been in the trash for longer than
+// olderThan. Projects go first, so their stories and tasks are not purged twice. An item
+// that cannot be purged is logged and counted, and does not stop the others. It waits
+// for a purge running on another instance to finish first.
+func (s *TrashService) PurgeExpired(olderThan time.Duration) (*PurgeResult, error) {
+	var result *PurgeResult
+	err := runJob(s.Jobs, storage.JobRetentionPurge, func() (err error) {
+		result, err = s.purgeExpired(olderThan)
+		return err
+	})
+	return result, err
+}
+
+func (s *TrashService) purgeExpired(olderThan time.Duration) (*PurgeResult, error) {
+	projectIDs, storyIDs, taskIDs, err := s.Repo.GetExpiredTrash(time.Now().Add(-olderThan))
+	if err != nil {
+		return nil, fmt.Errorf("could not list expired trash: %w", err)
+	}
+
+	result := &PurgeResult{}
+	for _, id := range projectIDs {
+		if err := s.Repo.PurgeProject(id); err != nil {
+			log.Printf("could not purge project %d: %v", id, err)
+			result.Failed++
+			continue
+		}
+		result.Projects++
+	}
+	for _, id := range storyIDs {
+		if story, err := s.Repo.GetUserStory(id); err != nil || !story.DeletedAt.Valid {
+			continue // Already purged with its project
+		}
+		if err := s.Repo.PurgeUserStory(id); err != nil {
+			log.Printf("could not purge user story %d: %v", id, err)
+			result.Failed++
+			continue
+		}
+		result.UserStories++
+	}
+	for _, id := range taskIDs {
+		if task, err := s.Repo.GetTask(id); err != nil || !task.DeletedAt.Valid {
+			continue // Already purged with its story or project
+		}
+		if err := s.Repo.PurgeTask(id); err != nil {
+			log.Printf("could not purge task %d: %v", id, err)
+			result.Failed++
+			continue
+		}
+		result.Tasks++
+	}
+	return result, nil
+}
+
+// RunRetentionPurge purges expired trash every interval until ctx is cancelled, skipping
+// the runs another instance is already doing. It is meant to be started in its own goroutine.
+func (s *TrashService) RunRetentionPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var result *PurgeResult
+		ran, err := tryRunJob(s.Jobs, storage.JobRetentionPurge, func() (err error) {
+			result, err = s.purgeExpired(s.Retention)
+			return err
+		})
+		if err != nil {
+			log.Printf("trash retention purge failed: %v", err)
+		} else if ran && result.Projects+result.UserStories+result.Tasks+result.Failed > 0 {
+			log.Printf("trash retention purge: removed %d projects, %d user stories and %d tasks; %d could not be removed",
+				result.Projects, result.UserStories, result.Tasks, result.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *TrashService) requireMember(projectID, userID uint, role string) error {
+	if role == string(models.RoleAdmin) {
+		return nil
+	}
+	if _, err := s.ProjectService.GetUserRoleInProject(userID, projectID); err != nil {
+		return fmt.Errorf("forbidden: you are not a member of this project")
+	}
+	return nil
+}
+
+func requireAdmin(role, action string) error {
+	if role != string(models.RoleAdmin) {
+		return fmt.Errorf("forbidden: only admins can %s", action)
+	}
+	return nil
+}
@@ -87,6 +87,27 @@ func (s *UserService) GetAllUsers() ([]models.User, error) {
 	return users, nil
 }
 
+// userSortFields are the fields the user list can be sorted by.
+var userSortFields = sortFields{
+	"id": "id", "name": "nombre", "email": "correo", "role": "role", "createdAt": "created_at",
+}
+
+// ListUsers retrieves one page of the users matching the filter, without their passwords.
+func (s *UserService) ListUsers(filter storage.UserFilter, query ListQuery) (*Page[models.User], error) {
+	opts, err := query.options(userSortFields, "id")
+	if err != nil {
+		return nil, err
+	}
+	users, total, err := s.Repo.ListUsers(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		users[i].Contraseña = ""
+	}
+	return newPage(users, total, opts), nil
+}
+
 // UpdateUser handles the logic for updating a user's details.
 func (s *UserService) UpdateUser(id uint, updatedData *models.User) (*models.User, error) {
 	// Retrieve the existing user
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/buga/API_wrkf/models"
@@ -12,6 +13,7 @@ type UserStoryService struct {
 	Repo           *storage.UserStoryRepository
 	ProjectService *ProjectService // Dependency to check project-level roles
 	SprintService  *SprintService  // Dependency to check sprint details
+	Events         *EventBus       // Optional, set once at startup
 }
 
 // NewUserStoryService creates a new instance of UserStoryService.
@@ -23,6 +25,14 @@ func NewUserStoryService(repo *storage.UserStoryRepository, projectService *Proj
 	}
 }
 
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *UserStoryService) WithContext(ctx context.Context) *UserStoryService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	scoped.Events = s.Events.WithContext(ctx)
+	return &scoped
+}
+
 // CreateUserStory handles the business logic for creating a new user story.
 func (s *UserStoryService) CreateUserStory(userStory *models.UserStory, projectID uint, creatorID uint) error {
 	if s == nil || s.Repo == nil {
@@ -30,7 +40,11 @@ func (s *UserStoryService) CreateUserStory(userStory *models.UserStory, projectI
 	}
 	userStory.ProjectID = projectID
 	userStory.CreatedByID = creatorID
-	return s.Repo.CreateUserStory(userStory)
+	if err := s.Repo.CreateUserStory(userStory); err != nil {
+		return err
+	}
+	s.Events.Publish(projectID, UserStoryCreated{UserStory: userStory})
+	return nil
 }
 
 // GetUserStoriesByProjectID retrieves all user stories for a specific project.
@@ -38,6 +52,25 @@ func (s *UserStoryService) GetUserStoriesByProjectID(projectID uint) ([]models.U
 	return s.Repo.GetUserStoriesByProjectID(projectID)
 }
 
+// userStorySortFields are the fields the user story list can be sorted by.
+var userStorySortFields = sortFields{
+	"id": "id", "title": "title", "status": "status", "priority": "priority", "points": "points",
+	"createdAt": "created_at", "updatedAt": "updated_at",
+}
+
+// ListUserStoriesByProjectID retrieves one page of a project's user stories matching the filter.
+func (s *UserStoryService) ListUserStoriesByProjectID(projectID uint, filter storage.UserStoryFilter, query ListQuery) (*Page[models.UserStory], error) {
+	opts, err := query.options(userStorySortFields, "id")
+	if err != nil {
+		return nil, err
+	}
+	userStories, total, err := s.Repo.ListUserStoriesByProjectID(projectID, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(userStories, total, opts), nil
+}
+
 // GetUserStoryByID retrieves a single user story and manually hydrates the Sprint relationship.
 func (s *UserStoryService) GetUserStoryByID(id uint) (*models.UserStory, error) {
 	// 1. Get the base user story object.
@@ -146,7 +179,12 @@ func (s *UserStoryService) UpdateUserStory(storyID, requestingUserID uint, platf
 	}
 
 	// Re-fetch the user story to ensure all fields, especially pointers, are correctly hydrated.
-	return s.Repo.GetUserStoryByID(storyID)
+	updatedStory, err := s.Repo.GetUserStoryByID(storyID)
+	if err != nil {
+		return nil, err
+	}
+	s.Events.Publish(updatedStory.ProjectID, UserStoryUpdated{UserStory: updatedStory})
+	return updatedStory, nil
 }
 
 // DeleteUserStory handles deleting a user story after checking permissions.
@@ -159,7 +197,15 @@ func (s *UserStoryService) DeleteUserStory(storyID, requestingUserID uint, platf
 		return fmt.Errorf("forbidden: you do not have permission to delete this user story")
 	}
 
-	return s.Repo.DeleteUserStory(storyID)
+	story, err := s.Repo.GetUserStoryByID(storyID)
+	if err != nil {
+		return fmt.Errorf("user story not found")
+	}
+	if err := s.Repo.DeleteUserStory(storyID); err != nil {
+		return err
+	}
+	s.Events.Publish(story.ProjectID, UserStoryDeleted{UserStoryID: storyID, SprintID: story.SprintID})
+	return nil
 }
 
 // AssignUserStoryToSprint handles assigning a user story to a sprint with permission checks.
@@ -189,6 +235,7 @@ func (s *UserStoryService) AssignUserStoryToSprint(sprintID, storyID, requesting
 	if err := s.Repo.UpdateUserStory(userStory); err != nil {
 		return nil, err
 	}
+	s.Events.Publish(userStory.ProjectID, UserStoryUpdated{UserStory: userStory})
 
 	return userStory, nil
 }
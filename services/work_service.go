@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+)
+
+const (
+	defaultWorkEventDays = 14
+	maxWorkEventDays     = 90
+)
+
+// WorkTask is a task on the caller's plate. DueDate is the end of its sprint.
+type WorkTask struct {
+	ID         uint       `json:"id"`
+	Title      string     `json:"title"`
+	Status     string     `json:"status"`
+	StoryID    uint       `json:"storyId"`
+	StoryTitle string     `json:"storyTitle"`
+	SprintID   *uint      `json:"sprintId"`
+	SprintName *string    `json:"sprintName"`
+	DueDate    *time.Time `json:"dueDate"`
+	Overdue    bool       `json:"overdue"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// WorkProject groups the caller's tasks in one project by status.
+type WorkProject struct {
+	ProjectID     uint                  `json:"projectId"`
+	ProjectName   string                `json:"projectName"`
+	TaskCount     int                   `json:"taskCount"`
+	TasksByStatus map[string][]WorkTask `json:"tasksByStatus"`
+}
+
+// WorkStory is a user story assigned to the caller.
+type WorkStory struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	Points      *int       `json:"points"`
+	ProjectID   uint       `json:"projectId"`
+	ProjectName string     `json:"projectName"`
+	SprintID    *uint      `json:"sprintId"`
+	SprintName  *string    `json:"sprintName"`
+	DueDate     *time.Time `json:"dueDate"`
+	Overdue     bool       `json:"overdue"`
+	TasksTotal  int        `json:"tasksTotal"`
+	TasksDone   int        `json:"tasksDone"`
+}
+
+// WorkOverdueItem is a task or user story whose sprint ended before it was done.
+type WorkOverdueItem struct {
+	Kind        string    `json:"kind"` // "task" or "user_story"
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Status      string    `json:"status"`
+	ProjectID   uint      `json:"projectId"`
+	ProjectName string    `json:"projectName"`
+	DueDate     time.Time `json:"dueDate"`
+	DaysOverdue int       `json:"daysOverdue"`
+}
+
+// MyWork is the caller's work across projects.
+type MyWork struct {
+	Projects            []WorkProject     `json:"projects"`
+	Stories             []WorkStory       `json:"stories"`
+	UpcomingEvents      []models.Event    `json:"upcomingEvents"`
+	UnreadNotifications int64             `json:"unreadNotifications"`
+	Overdue             []WorkOverdueItem `json:"overdue"`
+	GeneratedAt         time.Time         `json:"generatedAt"`
+}
+
+// WorkService gathers what is on a user's plate across projects.
+type WorkService struct {
+	Repo                *storage.WorkRepository
+	EventRepo           *storage.EventRepository
+	NotificationService *NotificationService
+}
+
+// NewWorkService creates a new instance of WorkService.
+func NewWorkService(repo *storage.WorkRepository, eventRepo *storage.EventRepository, notificationService *NotificationService) *WorkService {
+	return &WorkService{
+		Repo:                repo,
+		EventRepo:           eventRepo,
+		NotificationService: notificationService,
+	}
+}
+
+// WithContext returns a copy of the service bound to the request context (see ProjectService.WithContext).
+func (s *WorkService) WithContext(ctx context.Context) *WorkService {
+	scoped := *s
+	scoped.Repo = s.Repo.WithContext(ctx)
+	scoped.EventRepo = s.EventRepo.WithContext(ctx)
+	return &scoped
+}
+
+// GetMyWork returns the user's assigned tasks grouped by project and status, the user
+// stories assigned to them, the events of their projects in the next eventDays days
+// (14 when 0), their unread notification count and everything overdue. Work in
+// archived projects is left out. It runs four queries whatever the amount of work.
+func (s *WorkService) GetMyWork(userID uint, eventDays int) (*MyWork, error) {
+	if eventDays < 0 || eventDays > maxWorkEventDays {
+		return nil, fmt.Errorf("invalid work query: days must be between 0 and %d", maxWorkEventDays)
+	}
+	if eventDays == 0 {
+		eventDays = defaultWorkEventDays
+	}
+	now := time.Now()
+
+	taskRows, err := s.Repo.GetAssignedTasks(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve assigned tasks: %w", err)
+	}
+	storyRows, err := s.Repo.GetAssignedStories(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve assigned user stories: %w", err)
+	}
+	events, err := s.EventRepo.FindUpcomingForUser(userID, now, now.AddDate(0, 0, eventDays))
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve upcoming events: %w", err)
+	}
+	unread, err := s.NotificationService.CountUnread(userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not count unread notifications: %w", err)
+	}
+
+	work := &MyWork{
+		Projects:            []WorkProject{},
+		Stories:             []WorkStory{},
+		UpcomingEvents:      events,
+		UnreadNotifications: unread,
+		Overdue:             []WorkOverdueItem{},
+		GeneratedAt:         now,
+	}
+	if work.UpcomingEvents == nil {
+		work.UpcomingEvents = []models.Event{}
+	}
+
+	projectIndex := map[uint]int{}
+	for _, row := range taskRows {
+		task := WorkTask{
+			ID: row.TaskID, Title: row.Title, Status: row.Status, StoryID: row.StoryID, StoryTitle: row.StoryTitle,
+			SprintID: row.SprintID, SprintName: row.SprintName, DueDate: row.DueDate, UpdatedAt: row.UpdatedAt,
+			Overdue: isOverdue(row.DueDate, row.Status == string(models.StatusDone), now),
+		}
+		i, ok := projectIndex[row.ProjectID]
+		if !ok {
+			i = len(work.Projects)
+			projectIndex[row.ProjectID] = i
+			work.Projects = append(work.Projects, WorkProject{
+				ProjectID: row.ProjectID, ProjectName: row.ProjectName, TasksByStatus: map[string][]WorkTask{},
+			})
+		}
+		work.Projects[i].TaskCount++
+		work.Projects[i].TasksByStatus[task.Status] = append(work.Projects[i].TasksByStatus[task.Status], task)
+		if task.Overdue {
+			work.Overdue = append(work.Overdue, overdueItem("task", task.ID, task.Title, task.Status, row.ProjectID, row.ProjectName, *task.DueDate, now))
+		}
+	}
+
+	for _, row := range storyRows {
+		story := WorkStory{
+			ID: row.StoryID, Title: row.Title, Status: row.Status, Priority: row.Priority, Points: row.Points,
+			ProjectID: row.ProjectID, ProjectName: row.ProjectName, SprintID: row.SprintID, SprintName: row.SprintName,
+			DueDate: row.DueDate, TasksTotal: row.TasksTotal, TasksDone: row.TasksDone,
+			Overdue: isOverdue(row.DueDate, row.Status == "done", now),
+		}
+		work.Stories = append(work.Stories, story)
+		if story.Overdue {
+			work.Overdue = append(work.Overdue, overdueItem("user_story", story.ID, story.Title, story.Status, row.ProjectID, row.ProjectName, *story.DueDate, now))
+		}
+	}
+
+	sort.SliceStable(work.Overdue, func(i, j int) bool { return work.Overdue[i].DueDate.Before(work.Overdue[j].DueDate) })
+	return work, nil
+}
+
+// isOverdue reports whether an item that is not done has passed its due date.
+func isOverdue(dueDate *time.Time, done bool, now time.Time) bool {
+	return dueDate != nil && !done && dueDate.Before(now)
+}
+
+func overdueItem(kind string, id uint, title, status string, projectID uint, projectName string, dueDate, now time.Time) WorkOverdueItem {
+	return WorkOverdueItem{
+		Kind: kind, ID: id, Title: title, Status: status, ProjectID: projectID, ProjectName: projectName,
+		DueDate: dueDate, DaysOverdue: int(now.Sub(dueDate).Hours() / 24),
+	}
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AuditActor identifies who made a change. It travels in the context of the
+// request and is copied into every audit entry written while handling it.
+type AuditActor struct {
+	UserID    uint
+	IP        string
+	RequestID string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a copy of ctx carrying the actor of the current request.
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActorFromContext returns the actor stored in ctx, if any.
+func AuditActorFromContext(ctx context.Context) (AuditActor, bool) {
+	actor, ok := ctx.Value(auditActorKey{}).(AuditActor)
+	return actor, ok
+}
+
+// auditedTables maps every audited table to the entity type stored in the log.
+var auditedTables = map[string]string{
+	"projects":          "project",
+	"project_members":   "project_member",
+	"sprints":           "sprint",
+	"user_stories":      "user_story",
+	"tasks":             "task",
+	"rubrics":           "rubric",
+	"evaluations":       "evaluation",
+	"evaluation_rounds": "evaluation_round",
+	"events":            "event",
+}
+
+const auditBeforeKey = "audit:before"
+
+// auditRow is the JSON snapshot of one audited row.
+type auditRow struct {
+	ID   uint
+	Data json.RawMessage
+}
+
+// RegisterAuditCallbacks hooks the audit log into GORM's create, update and delete
+// callbacks. Entries are written through the statement's own connection before the
+// commit, so they are part of the same transaction as the change they describe.
+func RegisterAuditCallbacks(db *gorm.DB) error {
+	callback := db.Callback()
+	const commit = "gorm:commit_or_rollback_transaction"
+
+	if err := callback.Create().After("gorm:after_create").Before(commit).
+		Register("audit:after_create", auditAfter(models.AuditActionCreate)); err != nil {
+		return err
+	}
+	if err := callback.Update().Before("gorm:update").Register("audit:before_update", auditBefore); err != nil {
+		return err
+	}
+	if err := callback.Update().After("gorm:after_update").Before(commit).
+		Register("audit:after_update", auditAfter(models.AuditActionUpdate)); err != nil {
+		return err
+	}
+	if err := callback.Delete().Before("gorm:delete").Register("audit:before_delete", auditBefore); err != nil {
+		return err
+	}
+	return callback.Delete().After("gorm:after_delete").Before(commit).
+		Register("audit:after_delete", auditAfter(models.AuditActionDelete))
+}
+
+func auditEntityType(db *gorm.DB) string {
+	if db.Statement.Schema == nil {
+		return ""
+	}
+	return auditedTables[db.Statement.Schema.Table]
+}
+
+// auditBefore snapshots the rows an update or delete is about to change.
+func auditBefore(db *gorm.DB) {
+	if db.Error != nil || auditEntityType(db) == "" {
+		return
+	}
+	rows, err := auditCurrentRows(db, nil)
+	if err != nil {
+		db.AddError(fmt.Errorf("audit: could not load rows before change: %w", err))
+		return
+	}
+	db.InstanceSet(auditBeforeKey, rows)
+}
+
+// auditAfter records the change once the statement has run.
+func auditAfter(action models.AuditAction) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		entityType := auditEntityType(db)
+		if db.Error != nil || entityType == "" || db.RowsAffected == 0 {
+			return
+		}
+
+		var entries []models.AuditLog
+		newEntry := func(id uint, before, after json.RawMessage) models.AuditLog {
+			entry := models.AuditLog{EntityType: entityType, EntityID: id, Action: action, Before: before, After: after}
+			if actor, ok := AuditActorFromContext(db.Statement.Context); ok {
+				if actor.UserID != 0 {
+					entry.ActorID = &actor.UserID
+				}
+				entry.IPAddress = actor.IP
+				entry.RequestID = actor.RequestID
+			}
+			return entry
+		}
+
+		switch action {
+		case models.AuditActionCreate:
+			// Upserts of associations saved alongside another entity are not new entities.
+			if _, upsert := db.Statement.Clauses["ON CONFLICT"]; upsert {
+				return
+			}
+			for _, value := range auditValues(db.Statement.ReflectValue) {
+				row, err := auditSnapshot(db, value)
+				if err != nil {
+					db.AddError(err)
+					return
+				}
+				entries = append(entries, newEntry(row.ID, nil, row.Data))
+			}
+		case models.AuditActionUpdate:
+			before, _ := db.InstanceGet(auditBeforeKey)
+			beforeRows, _ := before.([]auditRow)
+			if len(beforeRows) == 0 {
+				return
+			}
+			ids := make([]uint, 0, len(beforeRows))
+			for _, row := range beforeRows {
+				ids = append(ids, row.ID)
+			}
+			afterRows, err := auditCurrentRows(db, ids)
+			if err != nil {
+				db.AddError(fmt.Errorf("audit: could not load rows after change: %w", err))
+				return
+			}
+			after := make(map[uint]json.RawMessage, len(afterRows))
+			for _, row := range afterRows {
+				after[row.ID] = row.Data
+			}
+			for _, row := range beforeRows {
+				entries = append(entries, newEntry(row.ID, row.Data, after[row.ID]))
+			}
+		case models.AuditActionDelete:
+			before, _ := db.InstanceGet(auditBeforeKey)
+			beforeRows, _ := before.([]auditRow)
+			for _, row := range beforeRows {
+				entries = append(entries, newEntry(row.ID, row.Data, nil))
+			}
+		}
+
+		if len(entries) == 0 {
+			return
+		}
+		if err := db.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+			db.AddError(fmt.Errorf("audit: could not write audit log: %w", err))
+		}
+	}
+}
+
+// auditCurrentRows loads the rows matched by the statement, or the rows with the given
+// primary keys, through the statement's connection.
+func auditCurrentRows(db *gorm.DB, ids []uint) ([]auditRow, error) {
+	stmt := db.Statement
+	query := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).
+		Model(reflect.New(stmt.Schema.ModelType).Interface())
+	if stmt.Unscoped {
+		query = query.Unscoped() // Restores and purges act on rows in the trash
+	}
+	primaryKey := stmt.Schema.PrioritizedPrimaryField
+	if primaryKey == nil {
+		return nil, nil
+	}
+
+	if ids == nil {
+		filtered := false
+		if where, ok := stmt.Clauses["WHERE"]; ok && where.Expression != nil {
+			query = query.Clauses(where.Expression)
+			filtered = true
+		}
+		for _, value := range auditValues(stmt.ReflectValue) {
+			if id, zero := primaryKey.ValueOf(stmt.Context, value); !zero {
+				ids = append(ids, auditID(id))
+			}
+		}
+		if !filtered && len(ids) == 0 {
+			return nil, nil // Nothing identifies the rows; GORM rejects such statements anyway.
+		}
+	}
+	if len(ids) > 0 {
+		query = query.Where(clause.IN{Column: clause.Column{Table: stmt.Schema.Table, Name: primaryKey.DBName}, Values: auditIDValues(ids)})
+	}
+
+	found := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+	if err := query.Find(found.Interface()).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]auditRow, 0, found.Elem().Len())
+	for i := 0; i < found.Elem().Len(); i++ {
+		row, err := auditSnapshot(db, found.Elem().Index(i))
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// auditSnapshot serializes the columns of a row, keyed by column name.
+// Associations are left out; they are audited on their own tables.
+func auditSnapshot(db *gorm.DB, value reflect.Value) (auditRow, error) {
+	sch := db.Statement.Schema
+	data := make(map[string]interface{}, len(sch.DBNames))
+	for _, name := range sch.DBNames {
+		field := sch.FieldsByDBName[name]
+		// The raw field value: ValueOf wraps serializer fields (e.g. JSON columns).
+		data[name] = field.ReflectValueOf(db.Statement.Context, value).Interface()
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return auditRow{}, fmt.Errorf("audit: could not serialize %s: %w", sch.Table, err)
+	}
+
+	var id uint
+	if primaryKey := sch.PrioritizedPrimaryField; primaryKey != nil {
+		value, _ := primaryKey.ValueOf(db.Statement.Context, value)
+		id = auditID(value)
+	}
+	return auditRow{ID: id, Data: raw}, nil
+}
+
+// auditValues returns the structs held by a statement's value, which may be a single
+// struct or a slice of structs or pointers to them.
+func auditValues(value reflect.Value) []reflect.Value {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{value}
+	case reflect.Slice, reflect.Array:
+		values := make([]reflect.Value, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			if elem := reflect.Indirect(value.Index(i)); elem.Kind() == reflect.Struct {
+				values = append(values, elem)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func auditID(value interface{}) uint {
+	switch id := value.(type) {
+	case uint:
+		return id
+	case uint64:
+		return uint(id)
+	case uint32:
+		return uint(id)
+	case int:
+		return uint(id)
+	case int64:
+		return uint(id)
+	default:
+		return 0
+	}
+}
+
+func auditIDValues(ids []uint) []interface{} {
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	return values
+}
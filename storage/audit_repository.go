@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+)
+
+// AuditLogFilter narrows an audit log query. Zero values are ignored.
+type AuditLogFilter struct {
+	EntityType string
+	EntityID   uint
+	ActorID    uint
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+}
+
+// AuditRepository reads the audit log. Entries are only ever written by the audit
+// callbacks (see RegisterAuditCallbacks), so it offers no way to change them.
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new AuditRepository.
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// FindAuditLogs retrieves one page of the entries matching the filter, together with the
+// total number of matches. Actors are loaded with their name and email only.
+func (r *AuditRepository) FindAuditLogs(filter AuditLogFilter, opts ListOptions) ([]models.AuditLog, int64, error) {
+	query := r.db.Model(&models.AuditLog{})
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	for _, order := range opts.Sort {
+		query = query.Order(order)
+	}
+	var logs []models.AuditLog
+	err := query.Preload("Actor", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "nombre", "apellido_paterno", "apellido_materno", "correo")
+	}).
+		Order("id desc").
+		Limit(opts.Limit).
+		Offset(opts.Offset).
+		Find(&logs).Error
+	return logs, total, err
+}
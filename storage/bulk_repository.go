@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+)
+
+// BulkTaskChange is the change of one task in a bulk operation. Columns lists the
+// columns of Task to save; when Delete is set the task is moved to the trash instead.
+// History is recorded for the task either way.
+type BulkTaskChange struct {
+	Task    *models.Task
+	Columns []string
+	Delete  bool
+	History models.TaskHistory
+}
+
+// BulkUserStoryChange is the change of one user story in a bulk operation. Deleting a
+// story also moves its tasks to the trash.
+type BulkUserStoryChange struct {
+	Story   *models.UserStory
+	Columns []string
+	Delete  bool
+}
+
+// BulkRepository reads and writes the items of bulk operations.
+type BulkRepository struct {
+	DB *gorm.DB
+}
+
+// NewBulkRepository creates a new instance of BulkRepository.
+func NewBulkRepository(db *gorm.DB) *BulkRepository {
+	return &BulkRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *BulkRepository) WithContext(ctx context.Context) *BulkRepository {
+	return &BulkRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// GetTasksInProject returns the tasks among ids that belong to the project, with their
+// user story and assignee.
+func (r *BulkRepository) GetTasksInProject(projectID uint, ids []uint) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.DB.Preload("UserStory").Preload("AssignedTo").
+		Where("id IN ? AND user_story_id IN (?)", ids, r.DB.Model(&models.UserStory{}).Select("id").Where("project_id = ?", projectID)).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// GetUserStoriesInProject returns the user stories among ids that belong to the project.
+func (r *BulkRepository) GetUserStoriesInProject(projectID uint, ids []uint) ([]models.UserStory, error) {
+	var stories []models.UserStory
+	err := r.DB.Where("id IN ? AND project_id = ?", ids, projectID).Find(&stories).Error
+	return stories, err
+}
+
+// ApplyTaskChanges saves every change and its history entry in a single transaction.
+func (r *BulkRepository) ApplyTaskChanges(changes []BulkTaskChange) error {
+	now := time.Now()
+	return r.DB.Session(&gorm.Session{NowFunc: func() time.Time { return now }}).Transaction(func(tx *gorm.DB) error {
+		for i := range changes {
+			change := &changes[i]
+			if change.Delete {
+				if err := tx.Delete(&models.Task{}, change.Task.ID).Error; err != nil {
+					return err
+				}
+			} else if err := tx.Model(change.Task).Select(change.Columns).Omit("UserStory", "AssignedTo").Updates(change.Task).Error; err != nil {
+				return err
+			}
+
+			change.History.TaskID = change.Task.ID
+			if err := tx.Create(&change.History).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ApplyUserStoryChanges saves every change in a single transaction. Each story is
+// written on its own, so the audit log gets one entry per story. Stories deleted
+// together share the deletion time with their tasks, as in DeleteUserStory.
+func (r *BulkRepository) ApplyUserStoryChanges(changes []BulkUserStoryChange) error {
+	now := time.Now()
+	return r.DB.Session(&gorm.Session{NowFunc: func() time.Time { return now }}).Transaction(func(tx *gorm.DB) error {
+		for i := range changes {
+			change := &changes[i]
+			if change.Delete {
+				if err := tx.Where("user_story_id = ?", change.Story.ID).Delete(&models.Task{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Delete(&models.UserStory{}, change.Story.ID).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Model(change.Story).Select(change.Columns).Updates(change.Story).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+)
+
+// ContributionTaskRow is a task of a project or sprint with the points of its user story.
+type ContributionTaskRow struct {
+	TaskID         uint
+	Status         string
+	AssignedToID   *uint
+	StoryID        uint
+	StoryPoints    *int
+	StoryTaskCount int // Tasks of the user story, used to share its points
+	EstimatedHours *float32
+	SpentHours     *float32
+	CreatedAt      time.Time
+}
+
+// ContributionCommentRow counts the comments a user wrote on tasks of a project or sprint.
+type ContributionCommentRow struct {
+	AuthorID uint
+	Comments int
+}
+
+// ContributionRepository reads what each member contributed to a project or a sprint
+// and stores it as UserMetric rows. Items in the trash are left out.
+type ContributionRepository struct {
+	DB *gorm.DB
+}
+
+// NewContributionRepository creates a new instance of ContributionRepository.
+func NewContributionRepository(db *gorm.DB) *ContributionRepository {
+	return &ContributionRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx.
+func (r *ContributionRepository) WithContext(ctx context.Context) *ContributionRepository {
+	return &ContributionRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// scopedTaskIDs selects the IDs of the tasks of a project, or of one of its sprints
+// when sprintID is set.
+func (r *ContributionRepository) scopedTaskIDs(projectID uint, sprintID *uint) *gorm.DB {
+	query := r.DB.Table("tasks AS t").Select("t.id").
+		Joins("JOIN user_stories us ON us.id = t.user_story_id AND us.deleted_at IS NULL").
+		Where("us.project_id = ? AND t.deleted_at IS NULL", projectID)
+	if sprintID != nil {
+		query = query.Where("us.sprint_id = ?", *sprintID)
+	}
+	return query
+}
+
+// GetTasks lists the tasks of the scope with their story's points and task count.
+func (r *ContributionRepository) GetTasks(projectID uint, sprintID *uint) ([]ContributionTaskRow, error) {
+	var rows []ContributionTaskRow
+	query := r.DB.Table("tasks AS t").
+		Select(`t.id AS task_id, t.status, t.assigned_to_id, us.id AS story_id, us.points AS story_points,
+			(SELECT COUNT(*) FROM tasks st WHERE st.user_story_id = us.id AND st.deleted_at IS NULL) AS story_task_count,
+			t.estimated_hours, t.spent_hours, t.created_at`).
+		Joins("JOIN user_stories us ON us.id = t.user_story_id AND us.deleted_at IS NULL").
+		Where("us.project_id = ? AND t.deleted_at IS NULL", projectID)
+	if sprintID != nil {
+		query = query.Where("us.sprint_id = ?", *sprintID)
+	}
+	err := query.Order("t.id").Scan(&rows).Error
+	return rows, err
+}
+
+// GetStatusHistory returns the status changes of the scope's tasks in chronological order.
+func (r *ContributionRepository) GetStatusHistory(projectID uint, sprintID *uint) ([]models.TaskHistory, error) {
+	var history []models.TaskHistory
+	err := r.DB.
+		Where("field_name = ? AND task_id IN (?)", "status", r.scopedTaskIDs(projectID, sprintID)).
+		Order("changed_at, id").
+		Find(&history).Error
+	return history, err
+}
+
+// CountComments counts the comments each user wrote on the scope's tasks.
+func (r *ContributionRepository) CountComments(projectID uint, sprintID *uint) ([]ContributionCommentRow, error) {
+	var rows []ContributionCommentRow
+	err := r.DB.Model(&models.TaskComment{}).
+		Select("author_id, COUNT(*) AS comments").
+		Where("task_id IN (?)", r.scopedTaskIDs(projectID, sprintID)).
+		Group("author_id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// SaveUserMetrics stores the metrics as the day's snapshot of each user for their
+// project and sprint, replacing earlier snapshots of the same day, in one transaction.
+func (r *ContributionRepository) SaveUserMetrics(metrics []models.UserMetric) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range metrics {
+			metric := &metrics[i]
+			query := tx.Where("user_id = ? AND project_id = ? AND date = ?", metric.UserID, metric.ProjectID, metric.Date)
+			if metric.SprintID != nil {
+				query = query.Where("sprint_id = ?", *metric.SprintID)
+			} else {
+				query = query.Where("sprint_id IS NULL")
+			}
+			var existing models.UserMetric
+			err := query.First(&existing).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil {
+				metric.ID = existing.ID
+				metric.CreatedAt = existing.CreatedAt
+			}
+			if err := tx.Omit("User", "Sprint", "Project").Save(metric).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
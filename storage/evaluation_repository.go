@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"github.com/buga/API_wrkf/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // EvaluationRepository handles database operations for evaluations.
@@ -15,6 +19,12 @@ func NewEvaluationRepository(db *gorm.DB) *EvaluationRepository {
 	return &EvaluationRepository{db: db}
 }
 
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *EvaluationRepository) WithContext(ctx context.Context) *EvaluationRepository {
+	return &EvaluationRepository{db: r.db.WithContext(ctx)}
+}
+
 // CreateEvaluation creates a new evaluation in the database.
 // It uses a transaction to ensure that the evaluation and all its criterion evaluations are created atomically.
 func (r *EvaluationRepository) CreateEvaluation(evaluation *models.Evaluation) error {
@@ -34,3 +44,135 @@ func (r *EvaluationRepository) GetEvaluationsByTaskID(taskID uint) ([]models.Eva
 		Find(&evaluations).Error
 	return evaluations, err
 }
+
+// GetEvaluationByID retrieves a single evaluation with its task, rubric and criterion scores.
+func (r *EvaluationRepository) GetEvaluationByID(id uint) (*models.Evaluation, error) {
+	var evaluation models.Evaluation
+	err := r.db.
+		Preload("Task").
+		Preload("Evaluator").
+		Preload("Rubric").
+		Preload("CriterionEvaluations").
+		Preload("CriterionEvaluations.Criterion").
+		First(&evaluation, id).Error
+	return &evaluation, err
+}
+
+// UpdateEvaluation saves an evaluation's editable fields and replaces all of its
+// criterion evaluations within a single transaction.
+func (r *EvaluationRepository) UpdateEvaluation(evaluation *models.Evaluation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("evaluation_id = ?", evaluation.ID).Delete(&models.CriterionEvaluation{}).Error; err != nil {
+			return err
+		}
+
+		for i := range evaluation.CriterionEvaluations {
+			evaluation.CriterionEvaluations[i].ID = 0
+			evaluation.CriterionEvaluations[i].EvaluationID = evaluation.ID
+		}
+		if len(evaluation.CriterionEvaluations) > 0 {
+			if err := tx.Omit(clause.Associations).Create(&evaluation.CriterionEvaluations).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(evaluation).
+			Select("OverallFeedback", "TotalScore", "Status", "SubmittedAt", "PublishedAt").
+			Updates(evaluation).Error
+	})
+}
+
+// UpdateEvaluationStatus persists a status transition and its timestamps.
+func (r *EvaluationRepository) UpdateEvaluationStatus(evaluation *models.Evaluation) error {
+	return r.db.Model(evaluation).
+		Select("Status", "SubmittedAt", "PublishedAt").
+		Updates(evaluation).Error
+}
+
+// CreateRound creates an evaluation round together with its peer review assignments.
+func (r *EvaluationRepository) CreateRound(round *models.EvaluationRound) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(round).Error; err != nil {
+			return err
+		}
+		for i := range round.Assignments {
+			round.Assignments[i].RoundID = round.ID
+		}
+		if len(round.Assignments) == 0 {
+			return nil
+		}
+		return tx.Omit(clause.Associations).Create(&round.Assignments).Error
+	})
+}
+
+// GetRoundByID retrieves an evaluation round with its assignments and the users involved.
+func (r *EvaluationRepository) GetRoundByID(id uint) (*models.EvaluationRound, error) {
+	var round models.EvaluationRound
+	err := r.db.
+		Preload("Assignments").
+		Preload("Assignments.Reviewer").
+		Preload("Assignments.Reviewee").
+		First(&round, id).Error
+	return &round, err
+}
+
+// GetRoundsBySprintID retrieves all evaluation rounds of a sprint.
+func (r *EvaluationRepository) GetRoundsBySprintID(sprintID uint) ([]models.EvaluationRound, error) {
+	var rounds []models.EvaluationRound
+	err := r.db.Where("sprint_id = ?", sprintID).Order("created_at asc").Find(&rounds).Error
+	return rounds, err
+}
+
+// IsReviewAssigned checks whether a reviewer has been assigned to evaluate a reviewee in a round.
+func (r *EvaluationRepository) IsReviewAssigned(roundID, reviewerID, revieweeID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.PeerReviewAssignment{}).
+		Where("round_id = ? AND reviewer_id = ? AND reviewee_id = ?", roundID, reviewerID, revieweeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetRoundEvaluations retrieves every peer and self evaluation of a round.
+func (r *EvaluationRepository) GetRoundEvaluations(roundID uint) ([]models.Evaluation, error) {
+	var evaluations []models.Evaluation
+	err := r.db.
+		Preload("Evaluator").
+		Preload("CriterionEvaluations").
+		Preload("CriterionEvaluations.Criterion").
+		Where("round_id = ?", roundID).
+		Order("id asc").
+		Find(&evaluations).Error
+	return evaluations, err
+}
+
+// PublishRoundEvaluations publishes every submitted evaluation of a round and closes it, atomically.
+func (r *EvaluationRepository) PublishRoundEvaluations(roundID uint, publishedAt time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Evaluation{}).
+			Where("round_id = ? AND status = ?", roundID, models.EvaluationStatusSubmitted).
+			Updates(map[string]interface{}{"status": models.EvaluationStatusPublished, "published_at": publishedAt}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.EvaluationRound{}).Where("id = ?", roundID).
+			Update("status", models.EvaluationRoundStatusClosed).Error
+	})
+}
+
+// GetTaskEvaluationsByProjectID retrieves the submitted and published task evaluations
+// of a project, with the task, evaluator, rubric and criterion scores preloaded. The
+// evaluations of tasks in the trash are left out.
+func (r *EvaluationRepository) GetTaskEvaluationsByProjectID(projectID uint) ([]models.Evaluation, error) {
+	var evaluations []models.Evaluation
+	err := r.db.
+		Preload("Task").
+		Preload("Evaluator").
+		Preload("Rubric.Criteria").
+		Preload("CriterionEvaluations.Criterion").
+		Joins("JOIN tasks ON tasks.id = evaluations.task_id AND tasks.deleted_at IS NULL").
+		Joins("JOIN user_stories ON user_stories.id = tasks.user_story_id AND user_stories.deleted_at IS NULL").
+		Where("user_stories.project_id = ? AND evaluations.status IN ?", projectID,
+			[]models.EvaluationStatus{models.EvaluationStatusSubmitted, models.EvaluationStatusPublished}).
+		Order("evaluations.id asc").
+		Find(&evaluations).Error
+	return evaluations, err
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"time"
 
 	"github.com/buga/API_wrkf/models"
@@ -17,6 +18,12 @@ func NewEventRepository(db *gorm.DB) *EventRepository {
 	return &EventRepository{db: db}
 }
 
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *EventRepository) WithContext(ctx context.Context) *EventRepository {
+	return &EventRepository{db: r.db.WithContext(ctx)}
+}
+
 // Create creates a new event in the database.
 func (r *EventRepository) Create(event *models.Event) error {
 	return r.db.Create(event).Error
@@ -40,6 +47,20 @@ func (r *EventRepository) FindByProjectAndDateRange(projectID uint, start time.T
 	return events, err
 }
 
+// FindUpcomingForUser retrieves the events between start and end of every project the
+// user is a member of, soonest first. Archived projects and projects in the trash are left out.
+func (r *EventRepository) FindUpcomingForUser(userID uint, start time.Time, end time.Time) ([]models.Event, error) {
+	var events []models.Event
+	err := r.db.
+		Joins("Project").
+		Where("events.project_id IN (?)", r.db.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", userID)).
+		Where("events.end_date >= ? AND events.start_date <= ?", start, end).
+		Where(`"Project".status <> ?`, models.ProjectStatusArchived).
+		Order("events.start_date ASC").
+		Find(&events).Error
+	return events, err
+}
+
 // Update updates an existing event in the database.
 func (r *EventRepository) Update(event *models.Event) error {
 	return r.db.Save(event).Error
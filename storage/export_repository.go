@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+)
+
+// ExportFilter narrows a project export. SprintID keeps the user stories of one sprint;
+// TaskStatus and AssigneeID keep the matching tasks, and only the stories that have any.
+type ExportFilter struct {
+	SprintID   *uint
+	TaskStatus string
+	AssigneeID *uint
+	Unassigned bool // Keep only tasks without an assignee
+}
+
+// hasTaskFilter reports whether the filter selects tasks.
+func (f ExportFilter) hasTaskFilter() bool {
+	return f.TaskStatus != "" || f.AssigneeID != nil || f.Unassigned
+}
+
+// ExportRow is one row of a flat project export: a task with its user story, or a user
+// story without tasks, in which case the task fields are nil.
+type ExportRow struct {
+	ProjectName    string
+	StoryID        uint
+	StoryTitle     string
+	StoryStatus    string
+	StoryPriority  string
+	StoryPoints    *int
+	SprintName     *string
+	TaskID         *uint
+	TaskTitle      *string
+	TaskStatus     *string
+	AssignedTo     *string
+	EstimatedHours *float32
+	SpentHours     *float32
+	IsDeliverable  *bool
+}
+
+// ExportRepository reads project data for exports.
+type ExportRepository struct {
+	DB *gorm.DB
+}
+
+// NewExportRepository creates a new instance of ExportRepository.
+func NewExportRepository(db *gorm.DB) *ExportRepository {
+	return &ExportRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx.
+func (r *ExportRepository) WithContext(ctx context.Context) *ExportRepository {
+	return &ExportRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// StreamProjectRows runs a single joined query and calls fn for each row as it is read,
+// ordered by user story and task. Items in the trash are left out.
+func (r *ExportRepository) StreamProjectRows(projectID uint, filter ExportFilter, fn func(row *ExportRow) error) error {
+	taskJoin := "LEFT JOIN tasks t ON t.user_story_id = us.id AND t.deleted_at IS NULL"
+	var taskArgs []interface{}
+	if filter.TaskStatus != "" {
+		taskJoin += " AND t.status = ?"
+		taskArgs = append(taskArgs, filter.TaskStatus)
+	}
+	if filter.AssigneeID != nil {
+		taskJoin += " AND t.assigned_to_id = ?"
+		taskArgs = append(taskArgs, *filter.AssigneeID)
+	}
+	if filter.Unassigned {
+		taskJoin += " AND t.assigned_to_id IS NULL"
+	}
+
+	query := r.DB.Table("user_stories AS us").
+		Select(`p.name AS project_name, us.id AS story_id, us.title AS story_title, us.status AS story_status,
+			us.priority AS story_priority, us.points AS story_points, s.name AS sprint_name,
+			t.id AS task_id, t.title AS task_title, t.status AS task_status, u.nombre AS assigned_to,
+			t.estimated_hours, t.spent_hours, t.is_deliverable`).
+		Joins("JOIN projects p ON p.id = us.project_id").
+		Joins("LEFT JOIN sprints s ON s.id = us.sprint_id").
+		Joins(taskJoin, taskArgs...).
+		Joins("LEFT JOIN users u ON u.id = t.assigned_to_id").
+		Where("us.project_id = ? AND us.deleted_at IS NULL", projectID)
+	if filter.SprintID != nil {
+		query = query.Where("us.sprint_id = ?", *filter.SprintID)
+	}
+	if filter.hasTaskFilter() {
+		query = query.Where("t.id IS NOT NULL")
+	}
+
+	rows, err := query.Order("us.id, t.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row ExportRow
+		if err := r.DB.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetProjectSprints lists the sprints of a project, optionally only one of them.
+func (r *ExportRepository) GetProjectSprints(projectID uint, filter ExportFilter) ([]models.Sprint, error) {
+	var sprints []models.Sprint
+	query := r.DB.Where("project_id = ?", projectID)
+	if filter.SprintID != nil {
+		query = query.Where("id = ?", *filter.SprintID)
+	}
+	err := query.Order("start_date, id").Find(&sprints).Error
+	return sprints, err
+}
+
+// GetProjectStoriesAndTasks reads the user stories of a project and their tasks (with
+// AssignedTo) in two queries.
+func (r *ExportRepository) GetProjectStoriesAndTasks(projectID uint, filter ExportFilter) ([]models.UserStory, []models.Task, error) {
+	taskQuery := func(db *gorm.DB) *gorm.DB {
+		if filter.TaskStatus != "" {
+			db = db.Where("status = ?", filter.TaskStatus)
+		}
+		if filter.AssigneeID != nil {
+			db = db.Where("assigned_to_id = ?", *filter.AssigneeID)
+		}
+		if filter.Unassigned {
+			db = db.Where("assigned_to_id IS NULL")
+		}
+		return db
+	}
+
+	storyQuery := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("project_id = ?", projectID)
+		if filter.SprintID != nil {
+			db = db.Where("sprint_id = ?", *filter.SprintID)
+		}
+		if filter.hasTaskFilter() {
+			db = db.Where("id IN (?)", taskQuery(r.DB.Model(&models.Task{}).Select("user_story_id")))
+		}
+		return db
+	}
+
+	var stories []models.UserStory
+	if err := storyQuery(r.DB).Order("id").Find(&stories).Error; err != nil {
+		return nil, nil, err
+	}
+	var tasks []models.Task
+	storyIDs := storyQuery(r.DB.Model(&models.UserStory{})).Select("id")
+	if err := taskQuery(r.DB.Preload("AssignedTo").Where("user_story_id IN (?)", storyIDs)).Order("user_story_id, id").Find(&tasks).Error; err != nil {
+		return nil, nil, err
+	}
+	return stories, tasks, nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+)
+
+// HealthSprintRow is a closed sprint with the points it committed to and completed.
+type HealthSprintRow struct {
+	SprintID        uint
+	Name            string
+	EndDate         *time.Time
+	CommittedPoints int
+	CompletedPoints int
+}
+
+// HealthTaskCounts counts the tasks of a project that feed its health score.
+type HealthTaskCounts struct {
+	InFlight         int // In progress or in review
+	Stale            int // In flight and not updated since the cutoff
+	SprintOpen       int // Not done, in the active sprint
+	SprintUnassigned int // Not done and unassigned, in the active sprint
+}
+
+// HealthStoryTotals sums up the user stories of a project.
+type HealthStoryTotals struct {
+	TotalUserStories     int
+	CompletedUserStories int
+	TotalPoints          int
+	CompletedPoints      int
+}
+
+// HealthRepository reads the signals the project health score is computed from and
+// keeps one ProjectMetric per project and day as the score history. Items in the
+// trash are left out.
+type HealthRepository struct {
+	DB *gorm.DB
+}
+
+// NewHealthRepository creates a new instance of HealthRepository.
+func NewHealthRepository(db *gorm.DB) *HealthRepository {
+	return &HealthRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx.
+func (r *HealthRepository) WithContext(ctx context.Context) *HealthRepository {
+	return &HealthRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// GetClosedSprints returns up to limit completed or closed sprints of a project, most
+// recent first, with their committed and completed story points.
+func (r *HealthRepository) GetClosedSprints(projectID uint, limit int) ([]HealthSprintRow, error) {
+	var rows []HealthSprintRow
+	err := r.DB.Table("sprints AS s").
+		Select(`s.id AS sprint_id, s.name, s.end_date,
+			COALESCE(SUM(us.points), 0) AS committed_points,
+			COALESCE(SUM(CASE WHEN us.status = ? THEN us.points ELSE 0 END), 0) AS completed_points`, "done").
+		Joins("LEFT JOIN user_stories us ON us.sprint_id = s.id AND us.deleted_at IS NULL").
+		Where("s.project_id = ? AND s.status IN ?", projectID, []string{"completed", "closed"}).
+		Group("s.id, s.name, s.end_date").
+		Order("s.end_date DESC, s.id DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetActiveSprint returns the active sprint of a project, or nil when there is none.
+func (r *HealthRepository) GetActiveSprint(projectID uint) (*models.Sprint, error) {
+	var sprint models.Sprint
+	err := r.DB.Where("project_id = ? AND status = ?", projectID, "active").Order("id").First(&sprint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sprint, nil
+}
+
+// CountTasks counts in-flight and stale tasks across the project, and open and
+// unassigned tasks in the given sprint (0 when there is no active sprint).
+func (r *HealthRepository) CountTasks(projectID, sprintID uint, staleBefore time.Time) (HealthTaskCounts, error) {
+	inFlight := []models.TaskStatus{models.StatusInProgress, models.StatusInReview}
+	var counts HealthTaskCounts
+	err := r.DB.Table("tasks AS t").
+		Select(`COALESCE(SUM(CASE WHEN t.status IN ? THEN 1 ELSE 0 END), 0) AS in_flight,
+			COALESCE(SUM(CASE WHEN t.status IN ? AND t.updated_at < ? THEN 1 ELSE 0 END), 0) AS stale,
+			COALESCE(SUM(CASE WHEN us.sprint_id = ? AND t.status <> ? THEN 1 ELSE 0 END), 0) AS sprint_open,
+			COALESCE(SUM(CASE WHEN us.sprint_id = ? AND t.status <> ? AND t.assigned_to_id IS NULL THEN 1 ELSE 0 END), 0) AS sprint_unassigned`,
+			inFlight, inFlight, staleBefore, sprintID, models.StatusDone, sprintID, models.StatusDone).
+		Joins("JOIN user_stories us ON us.id = t.user_story_id AND us.deleted_at IS NULL").
+		Where("us.project_id = ? AND t.deleted_at IS NULL", projectID).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// GetMissedEvents returns the deadlines and milestones of a project that passed between
+// since and now while user stories of sprints ending by then were still not done.
+func (r *HealthRepository) GetMissedEvents(projectID uint, since, now time.Time) ([]models.Event, error) {
+	var events []models.Event
+	err := r.DB.
+		Where("project_id = ? AND type IN ? AND end_date >= ? AND end_date < ?", projectID, []string{"deadline", "milestone"}, since, now).
+		Where(`EXISTS (SELECT 1 FROM user_stories us JOIN sprints s ON s.id = us.sprint_id
+			WHERE us.project_id = events.project_id AND us.deleted_at IS NULL AND us.status <> ? AND s.end_date <= events.end_date)`, "done").
+		Order("end_date").
+		Find(&events).Error
+	return events, err
+}
+
+// GetStoryAuditSince returns the audit entries of the project's user stories, including
+// those in the trash, recorded since the given time in chronological order.
+func (r *HealthRepository) GetStoryAuditSince(projectID uint, since time.Time) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	err := r.DB.
+		Where("entity_type = ? AND created_at >= ?", "user_story", since).
+		Where("entity_id IN (SELECT id FROM user_stories WHERE project_id = ?)", projectID).
+		Order("created_at, id").
+		Find(&entries).Error
+	return entries, err
+}
+
+// CountSprintStories counts the user stories currently in a sprint.
+func (r *HealthRepository) CountSprintStories(sprintID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.UserStory{}).Where("sprint_id = ?", sprintID).Count(&count).Error
+	return count, err
+}
+
+// GetStoryTotals counts the user stories and points of a project, done or not.
+func (r *HealthRepository) GetStoryTotals(projectID uint) (HealthStoryTotals, error) {
+	var totals HealthStoryTotals
+	err := r.DB.Model(&models.UserStory{}).
+		Select(`COUNT(*) AS total_user_stories,
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) AS completed_user_stories,
+			COALESCE(SUM(points), 0) AS total_points,
+			COALESCE(SUM(CASE WHEN status = ? THEN points ELSE 0 END), 0) AS completed_points`, "done", "done").
+		Where("project_id = ?", projectID).
+		Scan(&totals).Error
+	return totals, err
+}
+
+// SaveMetric stores the metric as the project's snapshot for its day, replacing an
+// earlier snapshot of the same day.
+func (r *HealthRepository) SaveMetric(metric *models.ProjectMetric) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var existing models.ProjectMetric
+		err := tx.Where("project_id = ? AND date = ?", metric.ProjectID, metric.Date).First(&existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			metric.ID = existing.ID
+			metric.CreatedAt = existing.CreatedAt
+		}
+		return tx.Omit("Project").Save(metric).Error
+	})
+}
+
+// ListMetrics returns the project's snapshots since the given day, oldest first.
+func (r *HealthRepository) ListMetrics(projectID uint, since time.Time) ([]models.ProjectMetric, error) {
+	var metrics []models.ProjectMetric
+	err := r.DB.Where("project_id = ? AND date >= ?", projectID, since).Order("date").Find(&metrics).Error
+	return metrics, err
+}
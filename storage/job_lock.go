@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Job identifies a background job that only one API instance may run at a time.
+type Job int32
+
+const (
+	// JobRetentionPurge is the purge of expired trash.
+	JobRetentionPurge Job = iota + 1
+	// JobRiskDetection is the at-risk work detection.
+	JobRiskDetection
+)
+
+// jobLockSpace keeps the advisory locks taken by JobLock apart from any others.
+const jobLockSpace = 48052
+
+// JobLock keeps the API instances sharing a Postgres database from running the same
+// background job at the same time. It holds a transaction-level advisory lock on its
+// own connection while the job runs, so the lock goes away with the instance if it dies.
+// Other databases only serve a single instance, so the jobs there just run.
+type JobLock struct {
+	DB *gorm.DB
+}
+
+// NewJobLock creates a new instance of JobLock.
+func NewJobLock(db *gorm.DB) *JobLock {
+	return &JobLock{DB: db}
+}
+
+// WithContext returns a copy of the lock whose queries carry ctx.
+func (l *JobLock) WithContext(ctx context.Context) *JobLock {
+	return &JobLock{DB: l.DB.WithContext(ctx)}
+}
+
+// TryRun runs fn unless another instance is running the job, and reports whether it ran.
+func (l *JobLock) TryRun(job Job, fn func() error) (bool, error) {
+	if l.DB.Dialector.Name() != "postgres" {
+		return true, fn()
+	}
+	ran := false
+	err := l.DB.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?, ?)", jobLockSpace, int32(job)).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		ran = true
+		return fn()
+	})
+	return ran, err
+}
+
+// Run waits until no other instance is running the job, then runs fn.
+func (l *JobLock) Run(job Job, fn func() error) error {
+	if l.DB.Dialector.Name() != "postgres" {
+		return fn()
+	}
+	return l.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", jobLockSpace, int32(job)).Error; err != nil {
+			return err
+		}
+		return fn()
+	})
+}
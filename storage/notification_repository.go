@@ -27,6 +27,31 @@ func (r *NotificationRepository) GetByUserID(userID uint) ([]models.Notification
 	return notifications, err
 }
 
+// NotificationFilter narrows down the notifications returned by ListByUserID.
+type NotificationFilter struct {
+	Read *bool // Only read or only unread notifications
+}
+
+// ListByUserID retrieves one page of a user's notifications matching the filter,
+// together with the total number of matches.
+func (r *NotificationRepository) ListByUserID(userID uint, filter NotificationFilter, opts ListOptions) ([]models.Notification, int64, error) {
+	query := r.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if filter.Read != nil {
+		query = query.Where("is_read = ?", *filter.Read)
+	}
+
+	var notifications []models.Notification
+	total, err := findPage(query, opts, "id", &notifications)
+	return notifications, total, err
+}
+
+// CountUnread counts a user's unread notifications.
+func (r *NotificationRepository) CountUnread(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count).Error
+	return count, err
+}
+
 // GetByID retrieves a single notification by its ID.
 func (r *NotificationRepository) GetByID(id uint) (*models.Notification, error) {
 	var notification models.Notification
@@ -34,18 +59,21 @@ func (r *NotificationRepository) GetByID(id uint) (*models.Notification, error)
 	return &notification, err
 }
 
-// MarkAsRead marks a single notification as read, ensuring it belongs to the user.
-func (r *NotificationRepository) MarkAsRead(notificationID uint, userID uint) error {
-	return r.db.Model(&models.Notification{}).
-		Where("id = ? AND user_id = ?", notificationID, userID).
-		Update("is_read", true).Error
+// MarkAsRead marks a single notification as read, ensuring it belongs to the user. It
+// returns the number of notifications changed: 0 when it was already read.
+func (r *NotificationRepository) MarkAsRead(notificationID uint, userID uint) (int64, error) {
+	result := r.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND is_read = ?", notificationID, userID, false).
+		Update("is_read", true)
+	return result.RowsAffected, result.Error
 }
 
-// MarkAllAsRead marks all of a user's notifications as read.
-func (r *NotificationRepository) MarkAllAsRead(userID uint) error {
-	return r.db.Model(&models.Notification{}).
+// MarkAllAsRead marks all of a user's notifications as read and returns how many were unread.
+func (r *NotificationRepository) MarkAllAsRead(userID uint) (int64, error) {
+	result := r.db.Model(&models.Notification{}).
 		Where("user_id = ? AND is_read = ?", userID, false).
-		Update("is_read", true).Error
+		Update("is_read", true)
+	return result.RowsAffected, result.Error
 }
 
 // Delete removes a notification, ensuring it belongs to the user.
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ListOptions pages and sorts a list query. Sort holds ORDER BY expressions such as
+// "created_at desc", built by the services from the sort fields each list allows.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Sort   []string
+}
+
+// findPage counts the rows matched by query, ignoring Limit and Offset, and reads one
+// page of them into dest. Rows are ordered by opts.Sort and then by tiebreak, so pages
+// stay stable when sorting by a column with repeated values. Preloads are applied to
+// the page only.
+func findPage(query *gorm.DB, opts ListOptions, tiebreak string, dest interface{}, preloads ...string) (int64, error) {
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	for _, order := range opts.Sort {
+		query = query.Order(order)
+	}
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+	err := query.Order(tiebreak).Limit(opts.Limit).Offset(opts.Offset).Find(dest).Error
+	return total, err
+}
+
+// containsPattern turns a search term into a case-insensitive LIKE pattern.
+func containsPattern(search string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return "%" + replacer.Replace(strings.ToLower(strings.TrimSpace(search))) + "%"
+}
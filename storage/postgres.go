@@ -18,6 +18,9 @@ func NewConnection(config *config.DBConfig) (*gorm.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	if err := RegisterAuditCallbacks(db); err != nil {
+		return nil, fmt.Errorf("failed to register audit callbacks: %w", err)
+	}
 
 	return db, nil
 }
@@ -28,12 +31,18 @@ func NewTestConnection() (*gorm.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to test database: %w", err)
 	}
+	if err := RegisterAuditCallbacks(db); err != nil {
+		return nil, fmt.Errorf("failed to register audit callbacks: %w", err)
+	}
 	return db, nil
 }
 
 // Migrate automates the database migration for all models.
 func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := prepareRiskFindingKey(db); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Project{},
 		&models.ProjectMember{},
@@ -47,6 +56,8 @@ func Migrate(db *gorm.DB) error {
 		&models.RubricCriterionLevel{},
 		&models.Evaluation{},
 		&models.CriterionEvaluation{},
+		&models.EvaluationRound{},
+		&models.PeerReviewAssignment{},
 		&models.Conversation{},
 		&models.ConversationMember{},
 		&models.Message{},
@@ -59,5 +70,34 @@ func Migrate(db *gorm.DB) error {
 		&models.UserMetric{},
 		&models.Notification{},
 		&models.Event{},
-	)
+		&models.AuditLog{},
+		&models.ProjectTemplate{},
+		&models.RiskFinding{},
+		&models.RealtimeEvent{},
+	); err != nil {
+		return err
+	}
+
+	// Members granted the legacy "docente" role become instructors.
+	return db.Model(&models.ProjectMember{}).
+		Where("role = ?", "docente").
+		Update("role", models.RoleInstructor).Error
+}
+
+// prepareRiskFindingKey gets the risk findings ready for the unique index on the key of
+// the open ones: the index it replaces is dropped and, of the findings left open twice
+// by instances scanning at the same time, all but the oldest are resolved.
+func prepareRiskFindingKey(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.RiskFinding{}) || migrator.HasIndex(&models.RiskFinding{}, "idx_risk_open_entity") {
+		return nil
+	}
+	if migrator.HasIndex(&models.RiskFinding{}, "idx_risk_entity") {
+		if err := migrator.DropIndex(&models.RiskFinding{}, "idx_risk_entity"); err != nil {
+			return err
+		}
+	}
+	return db.Exec(`UPDATE risk_findings SET resolved_at = last_seen_at
+		WHERE resolved_at IS NULL AND id NOT IN (
+			SELECT MIN(id) FROM risk_findings WHERE resolved_at IS NULL GROUP BY kind, entity_type, entity_id)`).Error
 }
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProjectGraph is the complete content of a project: members, sprints, user stories,
+// tasks with their history and comments, every rubric version, evaluation rounds,
+// evaluations and events.
+type ProjectGraph struct {
+	Project          *models.Project
+	Members          []models.ProjectMember
+	Sprints          []models.Sprint
+	UserStories      []models.UserStory
+	Tasks            []models.Task            // With History and Comments
+	Rubrics          []models.Rubric          // With Criteria.Levels, oldest first
+	EvaluationRounds []models.EvaluationRound // With Assignments
+	Evaluations      []models.Evaluation      // With CriterionEvaluations
+	Events           []models.Event
+}
+
+// ProjectArchiveRepository reads and writes whole project graphs for archives.
+type ProjectArchiveRepository struct {
+	DB *gorm.DB
+}
+
+// NewProjectArchiveRepository creates a new instance of ProjectArchiveRepository.
+func NewProjectArchiveRepository(db *gorm.DB) *ProjectArchiveRepository {
+	return &ProjectArchiveRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *ProjectArchiveRepository) WithContext(ctx context.Context) *ProjectArchiveRepository {
+	return &ProjectArchiveRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// LoadProjectGraph reads everything that belongs to a project, with one query per table.
+// Items in the trash are left out.
+func (r *ProjectArchiveRepository) LoadProjectGraph(projectID uint) (*ProjectGraph, error) {
+	graph := &ProjectGraph{Project: &models.Project{}}
+	if err := r.DB.First(graph.Project, projectID).Error; err != nil {
+		return nil, err
+	}
+
+	storyIDs := r.DB.Model(&models.UserStory{}).Select("id").Where("project_id = ?", projectID)
+	taskIDs := r.DB.Model(&models.Task{}).Select("id").Where("user_story_id IN (?)", storyIDs)
+	roundIDs := r.DB.Model(&models.EvaluationRound{}).Select("id").Where("project_id = ?", projectID)
+
+	queries := []struct {
+		dest  interface{}
+		query *gorm.DB
+	}{
+		{&graph.Members, r.DB.Where("project_id = ?", projectID)},
+		{&graph.Sprints, r.DB.Where("project_id = ?", projectID)},
+		{&graph.UserStories, r.DB.Where("project_id = ?", projectID)},
+		{&graph.Tasks, r.DB.Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("changed_at, id") }).
+			Preload("Comments", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).
+			Where("user_story_id IN (?)", storyIDs)},
+		{&graph.Rubrics, r.DB.Preload("Criteria", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+			Preload("Criteria.Levels", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+			Where("project_id = ?", projectID)},
+		{&graph.EvaluationRounds, r.DB.Preload("Assignments").Where("project_id = ?", projectID)},
+		{&graph.Evaluations, r.DB.Preload("CriterionEvaluations").Where("task_id IN (?) OR round_id IN (?)", taskIDs, roundIDs)},
+		{&graph.Events, r.DB.Where("project_id = ?", projectID)},
+	}
+	for _, q := range queries {
+		if err := q.query.Order("id").Find(q.dest).Error; err != nil {
+			return nil, err
+		}
+	}
+	return graph, nil
+}
+
+// ImportProjectGraph creates a new project from a graph in a single transaction. The IDs
+// and foreign keys in the graph are those of the source instance: every row gets a new ID
+// and references between rows are remapped. User references must already be local.
+func (r *ProjectArchiveRepository) ImportProjectGraph(graph *ProjectGraph) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		create := func(value interface{}) error {
+			return tx.Omit(clause.Associations).Create(value).Error
+		}
+		remap := func(ids map[uint]uint, id *uint) *uint {
+			if id == nil {
+				return nil
+			}
+			if newID, ok := ids[*id]; ok {
+				return &newID
+			}
+			return nil
+		}
+
+		project := graph.Project
+		project.ID = 0
+		if err := create(project); err != nil {
+			return err
+		}
+
+		for i := range graph.Members {
+			member := &graph.Members[i]
+			member.ID, member.ProjectID = 0, project.ID
+			if err := create(member); err != nil {
+				return err
+			}
+		}
+
+		sprintIDs := make(map[uint]uint, len(graph.Sprints))
+		for i := range graph.Sprints {
+			sprint := &graph.Sprints[i]
+			oldID := sprint.ID
+			sprint.ID, sprint.ProjectID = 0, project.ID
+			if err := create(sprint); err != nil {
+				return err
+			}
+			sprintIDs[oldID] = sprint.ID
+		}
+
+		storyIDs := make(map[uint]uint, len(graph.UserStories))
+		for i := range graph.UserStories {
+			story := &graph.UserStories[i]
+			oldID := story.ID
+			story.ID, story.ProjectID = 0, project.ID
+			story.SprintID = remap(sprintIDs, story.SprintID)
+			if err := create(story); err != nil {
+				return err
+			}
+			storyIDs[oldID] = story.ID
+		}
+
+		taskIDs := make(map[uint]uint, len(graph.Tasks))
+		for i := range graph.Tasks {
+			task := &graph.Tasks[i]
+			oldID := task.ID
+			task.ID, task.UserStoryID = 0, storyIDs[task.UserStoryID]
+			if err := create(task); err != nil {
+				return err
+			}
+			taskIDs[oldID] = task.ID
+
+			for j := range task.History {
+				task.History[j].ID, task.History[j].TaskID = 0, task.ID
+				if err := create(&task.History[j]); err != nil {
+					return err
+				}
+			}
+			for j := range task.Comments {
+				task.Comments[j].ID, task.Comments[j].TaskID = 0, task.ID
+				if err := create(&task.Comments[j]); err != nil {
+					return err
+				}
+			}
+		}
+
+		rubricIDs := make(map[uint]uint, len(graph.Rubrics))
+		criterionIDs := make(map[uint]uint)
+		for i := range graph.Rubrics {
+			rubric := &graph.Rubrics[i]
+			oldID := rubric.ID
+			rubric.ID, rubric.ProjectID = 0, &project.ID
+			rubric.RootRubricID = remap(rubricIDs, rubric.RootRubricID)
+			rubric.SourceTemplateID = nil // Templates belong to the source instance
+			if err := create(rubric); err != nil {
+				return err
+			}
+			rubricIDs[oldID] = rubric.ID
+
+			for j := range rubric.Criteria {
+				criterion := &rubric.Criteria[j]
+				oldCriterionID := criterion.ID
+				criterion.ID, criterion.RubricID = 0, rubric.ID
+				criterion.OriginCriterionID = remap(criterionIDs, criterion.OriginCriterionID)
+				if err := create(criterion); err != nil {
+					return err
+				}
+				criterionIDs[oldCriterionID] = criterion.ID
+
+				for k := range criterion.Levels {
+					criterion.Levels[k].ID, criterion.Levels[k].CriterionID = 0, criterion.ID
+					if err := create(&criterion.Levels[k]); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		roundIDs := make(map[uint]uint, len(graph.EvaluationRounds))
+		for i := range graph.EvaluationRounds {
+			round := &graph.EvaluationRounds[i]
+			oldID := round.ID
+			round.ID, round.ProjectID = 0, project.ID
+			round.SprintID, round.RubricID = sprintIDs[round.SprintID], rubricIDs[round.RubricID]
+			if err := create(round); err != nil {
+				return err
+			}
+			roundIDs[oldID] = round.ID
+
+			for j := range round.Assignments {
+				round.Assignments[j].ID, round.Assignments[j].RoundID = 0, round.ID
+				if err := create(&round.Assignments[j]); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i := range graph.Evaluations {
+			evaluation := &graph.Evaluations[i]
+			evaluation.ID = 0
+			evaluation.TaskID = remap(taskIDs, evaluation.TaskID)
+			evaluation.RoundID = remap(roundIDs, evaluation.RoundID)
+			evaluation.RubricID = rubricIDs[evaluation.RubricID]
+			if err := create(evaluation); err != nil {
+				return err
+			}
+
+			for j := range evaluation.CriterionEvaluations {
+				score := &evaluation.CriterionEvaluations[j]
+				score.ID, score.EvaluationID = 0, evaluation.ID
+				score.CriterionID = criterionIDs[score.CriterionID]
+				if err := create(score); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i := range graph.Events {
+			event := &graph.Events[i]
+			event.ID, event.ProjectID = 0, project.ID
+			if err := create(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
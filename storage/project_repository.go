@@ -1,10 +1,20 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/buga/API_wrkf/models"
 	"gorm.io/gorm"
 )
 
+// ProjectFilter narrows down the projects returned by ListProjects.
+type ProjectFilter struct {
+	Status          string // Only projects with this status
+	IncludeArchived bool   // Archived projects are hidden unless requested or filtered by status
+	Search          string // Only projects whose name contains this text, ignoring case
+}
+
 // ProjectRepository handles database operations for projects.
 type ProjectRepository struct {
 	DB *gorm.DB
@@ -15,6 +25,12 @@ func NewProjectRepository(db *gorm.DB) *ProjectRepository {
 	return &ProjectRepository{DB: db}
 }
 
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *ProjectRepository) WithContext(ctx context.Context) *ProjectRepository {
+	return &ProjectRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // CreateProject adds a new project to the database.
 func (r *ProjectRepository) CreateProject(project *models.Project) error {
 	return r.DB.Create(project).Error
@@ -25,11 +41,23 @@ func (r *ProjectRepository) AddMemberToProject(member *models.ProjectMember) err
 	return r.DB.Create(member).Error
 }
 
-// GetProjects retrieves a list of all projects from the database.
-func (r *ProjectRepository) GetProjects() ([]models.Project, error) {
+// ListProjects retrieves one page of the projects matching the filter, together with
+// the total number of matches.
+func (r *ProjectRepository) ListProjects(filter ProjectFilter, opts ListOptions) ([]models.Project, int64, error) {
+	query := r.DB.Model(&models.Project{})
+	switch {
+	case filter.Status != "":
+		query = query.Where("status = ?", filter.Status)
+	case !filter.IncludeArchived:
+		query = query.Where("status <> ?", models.ProjectStatusArchived)
+	}
+	if filter.Search != "" {
+		query = query.Where(`LOWER(name) LIKE ? ESCAPE '\'`, containsPattern(filter.Search))
+	}
+
 	var projects []models.Project
-	err := r.DB.Preload("CreatedBy").Find(&projects).Error
-	return projects, err
+	total, err := findPage(query, opts, "id", &projects, "CreatedBy")
+	return projects, total, err
 }
 
 // GetProjectByID retrieves a single project by its ID, including its members and their user details.
@@ -56,7 +84,7 @@ func (r *ProjectRepository) DeleteProjectMembersByProjectID(tx *gorm.DB, project
 	return tx.Where("project_id = ?", projectID).Delete(&models.ProjectMember{}).Error
 }
 
-// DeleteProject removes a project from the database by its ID.
+// DeleteProject moves a project to the trash.
 func (r *ProjectRepository) DeleteProject(tx *gorm.DB, projectID uint) error {
 	return tx.Delete(&models.Project{}, projectID).Error
 }
@@ -88,6 +116,12 @@ func (r *ProjectRepository) IsMember(projectID, userID uint) (bool, error) {
 	return count > 0, nil
 }
 
+// RemoveMember deletes a user's membership of a project. It reports whether there was one.
+func (r *ProjectRepository) RemoveMember(projectID, userID uint) (bool, error) {
+	result := r.DB.Where("project_id = ? AND user_id = ?", projectID, userID).Delete(&models.ProjectMember{})
+	return result.RowsAffected > 0, result.Error
+}
+
 // GetMemberUserIDs retrieves all user IDs for a given project.
 func (r *ProjectRepository) GetMemberUserIDs(projectID uint) ([]uint, error) {
 	var userIDs []uint
@@ -104,3 +138,37 @@ func (r *ProjectRepository) GetProjectsByUserID(userID uint) ([]models.Project,
 		Find(&projects).Error
 	return projects, err
 }
+
+// projectIDQueries holds, per kind of resource, the query that finds the project it belongs to.
+// Projects, user stories and tasks in the trash are not found.
+var projectIDQueries = map[string]string{
+	"project":          "SELECT id FROM projects WHERE id = ? AND deleted_at IS NULL",
+	"user_story":       "SELECT project_id FROM user_stories WHERE id = ? AND deleted_at IS NULL",
+	"task":             "SELECT us.project_id FROM tasks t JOIN user_stories us ON us.id = t.user_story_id AND us.deleted_at IS NULL WHERE t.id = ? AND t.deleted_at IS NULL",
+	"sprint":           "SELECT project_id FROM sprints WHERE id = ?",
+	"event":            "SELECT project_id FROM events WHERE id = ?",
+	"rubric":           "SELECT project_id FROM rubrics WHERE id = ? AND project_id IS NOT NULL",
+	"evaluation_round": "SELECT project_id FROM evaluation_rounds WHERE id = ?",
+	"evaluation": `SELECT COALESCE(us.project_id, er.project_id, 0) FROM evaluations e
+		LEFT JOIN tasks t ON t.id = e.task_id AND t.deleted_at IS NULL
+		LEFT JOIN user_stories us ON us.id = t.user_story_id AND us.deleted_at IS NULL
+		LEFT JOIN evaluation_rounds er ON er.id = e.round_id
+		WHERE e.id = ?`,
+}
+
+// GetProjectIDFor finds the project a resource belongs to. It returns 0 when the
+// resource does not exist or is not tied to a project (e.g. a global rubric template).
+func (r *ProjectRepository) GetProjectIDFor(kind string, id uint) (uint, error) {
+	query, ok := projectIDQueries[kind]
+	if !ok {
+		return 0, fmt.Errorf("unknown resource kind '%s'", kind)
+	}
+	var projectIDs []uint
+	if err := r.DB.Raw(query, id).Scan(&projectIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(projectIDs) == 0 {
+		return 0, nil
+	}
+	return projectIDs[0], nil
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+)
+
+// ProjectTree is a project together with everything that hangs from it. It is read
+// from an existing project to build templates and clones, and written back in a single
+// transaction to create a new project.
+type ProjectTree struct {
+	Project     *models.Project
+	Members     []models.ProjectMember
+	Sprints     []models.Sprint
+	UserStories []UserStoryNode
+	Rubrics     []models.Rubric
+	Events      []models.Event
+}
+
+// UserStoryNode is a user story of a ProjectTree with its tasks.
+type UserStoryNode struct {
+	Story       models.UserStory
+	SprintIndex *int // Position of the story's sprint in ProjectTree.Sprints
+	Tasks       []models.Task
+}
+
+// ProjectTemplateRepository handles database operations for project templates.
+type ProjectTemplateRepository struct {
+	DB *gorm.DB
+}
+
+// NewProjectTemplateRepository creates a new instance of ProjectTemplateRepository.
+func NewProjectTemplateRepository(db *gorm.DB) *ProjectTemplateRepository {
+	return &ProjectTemplateRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *ProjectTemplateRepository) WithContext(ctx context.Context) *ProjectTemplateRepository {
+	return &ProjectTemplateRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// Create adds a new project template.
+func (r *ProjectTemplateRepository) Create(template *models.ProjectTemplate) error {
+	return r.DB.Create(template).Error
+}
+
+// FindAll lists the project templates, newest first. A non-empty search matches names.
+func (r *ProjectTemplateRepository) FindAll(search string) ([]models.ProjectTemplate, error) {
+	var templates []models.ProjectTemplate
+	query := r.DB.Preload("CreatedBy").Order("created_at desc, id desc")
+	if search != "" {
+		query = query.Where("LOWER(name) LIKE LOWER(?)", "%"+search+"%")
+	}
+	err := query.Find(&templates).Error
+	return templates, err
+}
+
+// FindByID retrieves a project template by its ID.
+func (r *ProjectTemplateRepository) FindByID(id uint) (*models.ProjectTemplate, error) {
+	var template models.ProjectTemplate
+	err := r.DB.Preload("CreatedBy").First(&template, id).Error
+	return &template, err
+}
+
+// Delete removes a project template.
+func (r *ProjectTemplateRepository) Delete(id uint) error {
+	return r.DB.Delete(&models.ProjectTemplate{}, id).Error
+}
+
+// LoadProjectTree reads a project with its members, sprints, user stories, tasks,
+// current rubric versions and events.
+func (r *ProjectTemplateRepository) LoadProjectTree(projectID uint) (*ProjectTree, error) {
+	tree := &ProjectTree{Project: &models.Project{}}
+	if err := r.DB.First(tree.Project, projectID).Error; err != nil {
+		return nil, err
+	}
+	if err := r.DB.Where("project_id = ?", projectID).Order("id").Find(&tree.Members).Error; err != nil {
+		return nil, err
+	}
+	if err := r.DB.Where("project_id = ?", projectID).Order("start_date, id").Find(&tree.Sprints).Error; err != nil {
+		return nil, err
+	}
+
+	var stories []models.UserStory
+	if err := r.DB.Where("project_id = ?", projectID).Order("id").Find(&stories).Error; err != nil {
+		return nil, err
+	}
+	var tasks []models.Task
+	if err := r.DB.Where("user_story_id IN (?)", r.DB.Model(&models.UserStory{}).Select("id").Where("project_id = ?", projectID)).
+		Order("id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	sprintIndex := make(map[uint]int, len(tree.Sprints))
+	for i, sprint := range tree.Sprints {
+		sprintIndex[sprint.ID] = i
+	}
+	tasksByStory := make(map[uint][]models.Task)
+	for _, task := range tasks {
+		tasksByStory[task.UserStoryID] = append(tasksByStory[task.UserStoryID], task)
+	}
+	for _, story := range stories {
+		node := UserStoryNode{Story: story, Tasks: tasksByStory[story.ID]}
+		if story.SprintID != nil {
+			if i, ok := sprintIndex[*story.SprintID]; ok {
+				node.SprintIndex = &i
+			}
+		}
+		tree.UserStories = append(tree.UserStories, node)
+	}
+
+	if err := r.DB.Preload("Criteria.Levels").
+		Where("project_id = ? AND superseded_at IS NULL", projectID).
+		Order("id").Find(&tree.Rubrics).Error; err != nil {
+		return nil, err
+	}
+	if err := r.DB.Where("project_id = ?", projectID).Order("start_date, id").Find(&tree.Events).Error; err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// CreateProjectTree creates a new project and everything in the tree in a single
+// transaction. IDs and foreign keys are assigned as the rows are created.
+func (r *ProjectTemplateRepository) CreateProjectTree(tree *ProjectTree) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		project := tree.Project
+		if err := tx.Omit("Members").Create(project).Error; err != nil {
+			return err
+		}
+
+		for i := range tree.Members {
+			tree.Members[i].ProjectID = project.ID
+			if err := tx.Create(&tree.Members[i]).Error; err != nil {
+				return err
+			}
+		}
+		for i := range tree.Sprints {
+			tree.Sprints[i].ProjectID = project.ID
+			if err := tx.Create(&tree.Sprints[i]).Error; err != nil {
+				return err
+			}
+		}
+		for i := range tree.UserStories {
+			node := &tree.UserStories[i]
+			node.Story.ProjectID = project.ID
+			node.Story.SprintID = nil
+			if node.SprintIndex != nil {
+				node.Story.SprintID = &tree.Sprints[*node.SprintIndex].ID
+			}
+			if err := tx.Create(&node.Story).Error; err != nil {
+				return err
+			}
+			for j := range node.Tasks {
+				node.Tasks[j].UserStoryID = node.Story.ID
+				if err := tx.Create(&node.Tasks[j]).Error; err != nil {
+					return err
+				}
+			}
+		}
+		for i := range tree.Rubrics {
+			tree.Rubrics[i].ProjectID = &project.ID
+			if err := tx.Create(&tree.Rubrics[i]).Error; err != nil {
+				return err
+			}
+		}
+		for i := range tree.Events {
+			tree.Events[i].ProjectID = project.ID
+			if err := tx.Create(&tree.Events[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
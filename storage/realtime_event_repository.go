@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+)
+
+// RealtimeEventRepository stores the WebSocket events shared between API instances
+// through Postgres and tells the instances about them with NOTIFY. It needs Postgres.
+type RealtimeEventRepository struct {
+	DB *gorm.DB
+}
+
+// NewRealtimeEventRepository creates a new instance of RealtimeEventRepository.
+func NewRealtimeEventRepository(db *gorm.DB) *RealtimeEventRepository {
+	return &RealtimeEventRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx.
+func (r *RealtimeEventRepository) WithContext(ctx context.Context) *RealtimeEventRepository {
+	return &RealtimeEventRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// Append numbers the event after the latest one of its project, stores it, drops the
+// project's events older than the latest keep ones and sends the event's ID on the
+// notification channel, in one transaction. A per-project advisory lock held until
+// commit makes the instances receive each project's events in sequence order.
+func (r *RealtimeEventRepository) Append(event *models.RealtimeEvent, keep int, channel string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", realtimeLockSpace, event.ProjectID).Error; err != nil {
+			return err
+		}
+		var latest uint64
+		if err := tx.Model(&models.RealtimeEvent{}).Select("COALESCE(MAX(seq), 0)").
+			Where("project_id = ?", event.ProjectID).Scan(&latest).Error; err != nil {
+			return err
+		}
+		event.Seq = latest + 1
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ? AND seq <= ?", event.ProjectID, int64(event.Seq)-int64(keep)).
+			Delete(&models.RealtimeEvent{}).Error; err != nil {
+			return err
+		}
+		return tx.Exec("SELECT pg_notify(?, ?)", channel, strconv.FormatUint(uint64(event.ID), 10)).Error
+	})
+}
+
+// Notify sends payload on the notification channel without storing anything.
+func (r *RealtimeEventRepository) Notify(channel, payload string) error {
+	return r.DB.Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+}
+
+// DeleteOlderThan removes the events created before cutoff and returns how many it
+// removed. The latest event of each project stays, so its numbering goes on from there.
+func (r *RealtimeEventRepository) DeleteOlderThan(cutoff time.Time) (int64, error) {
+	result := r.DB.Where("created_at < ?", cutoff).
+		Where("seq < (SELECT MAX(latest.seq) FROM realtime_events latest WHERE latest.project_id = realtime_events.project_id)").
+		Delete(&models.RealtimeEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// realtimeLockSpace keeps the advisory locks taken by Append apart from any others.
+const realtimeLockSpace = 48051
+
+// GetEvent retrieves a stored event by its ID.
+func (r *RealtimeEventRepository) GetEvent(id uint) (*models.RealtimeEvent, error) {
+	var event models.RealtimeEvent
+	err := r.DB.First(&event, id).Error
+	return &event, err
+}
+
+// LatestSeq returns the sequence number of a project's latest event, 0 if it had none.
+func (r *RealtimeEventRepository) LatestSeq(projectID uint) (uint64, error) {
+	var latest uint64
+	err := r.DB.Model(&models.RealtimeEvent{}).Select("COALESCE(MAX(seq), 0)").
+		Where("project_id = ?", projectID).Scan(&latest).Error
+	return latest, err
+}
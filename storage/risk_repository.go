@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RiskTaskRow is a task flagged by the at-risk work detection.
+type RiskTaskRow struct {
+	TaskID     uint
+	Title      string
+	Status     string
+	ProjectID  uint
+	AssigneeID *uint
+}
+
+// RiskStoryRow is a user story of an active sprint flagged by the at-risk work detection.
+type RiskStoryRow struct {
+	StoryID    uint
+	Title      string
+	ProjectID  uint
+	SprintID   uint
+	SprintName string
+}
+
+// RiskSprintRow is an active sprint with its total and still open scope.
+type RiskSprintRow struct {
+	SprintID     uint
+	Name         string
+	ProjectID    uint
+	EndDate      *time.Time
+	TotalStories int
+	OpenStories  int
+	TotalPoints  int
+	OpenPoints   int
+}
+
+// ProjectLeadRow is the user told about a project's findings that have no assignee.
+type ProjectLeadRow struct {
+	ProjectID uint
+	UserID    uint
+}
+
+// RiskRepository finds at-risk work across projects and stores the findings. Items in
+// the trash and archived projects are left out.
+type RiskRepository struct {
+	DB *gorm.DB
+}
+
+// NewRiskRepository creates a new instance of RiskRepository.
+func NewRiskRepository(db *gorm.DB) *RiskRepository {
+	return &RiskRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx.
+func (r *RiskRepository) WithContext(ctx context.Context) *RiskRepository {
+	return &RiskRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// openTasks selects the tasks of live projects.
+func (r *RiskRepository) openTasks() *gorm.DB {
+	return r.DB.Table("tasks AS t").
+		Select("t.id AS task_id, t.title, t.status, us.project_id, t.assigned_to_id AS assignee_id").
+		Joins("JOIN user_stories us ON us.id = t.user_story_id AND us.deleted_at IS NULL").
+		Joins("JOIN projects p ON p.id = us.project_id AND p.deleted_at IS NULL").
+		Where("t.deleted_at IS NULL AND p.status <> ?", models.ProjectStatusArchived)
+}
+
+// FindStaleTasks lists the tasks in progress or in review whose status last changed,
+// or which were created when they never changed, before the cutoff.
+func (r *RiskRepository) FindStaleTasks(cutoff time.Time) ([]RiskTaskRow, error) {
+	var rows []RiskTaskRow
+	err := r.openTasks().
+		Where("t.status IN ?", []models.TaskStatus{models.StatusInProgress, models.StatusInReview}).
+		Where(`COALESCE((SELECT MAX(h.changed_at) FROM task_histories h WHERE h.task_id = t.id AND h.field_name = ?), t.created_at) < ?`,
+			"status", cutoff).
+		Order("t.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// FindDepartedAssignees lists the tasks not done whose assignee is no longer a member
+// of the task's project.
+func (r *RiskRepository) FindDepartedAssignees() ([]RiskTaskRow, error) {
+	var rows []RiskTaskRow
+	err := r.openTasks().
+		Where("t.status <> ? AND t.assigned_to_id IS NOT NULL", models.StatusDone).
+		Where("NOT EXISTS (SELECT 1 FROM project_members pm WHERE pm.project_id = us.project_id AND pm.user_id = t.assigned_to_id)").
+		Order("t.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// FindStoriesWithoutTasks lists the user stories of active sprints that are not done
+// and have no tasks.
+func (r *RiskRepository) FindStoriesWithoutTasks() ([]RiskStoryRow, error) {
+	var rows []RiskStoryRow
+	err := r.DB.Table("user_stories AS us").
+		Select("us.id AS story_id, us.title, us.project_id, s.id AS sprint_id, s.name AS sprint_name").
+		Joins("JOIN sprints s ON s.id = us.sprint_id AND s.status = ?", "active").
+		Joins("JOIN projects p ON p.id = us.project_id AND p.deleted_at IS NULL").
+		Where("us.deleted_at IS NULL AND us.status <> ? AND p.status <> ?", "done", models.ProjectStatusArchived).
+		Where("NOT EXISTS (SELECT 1 FROM tasks t WHERE t.user_story_id = us.id AND t.deleted_at IS NULL)").
+		Order("us.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// FindSprintsEndingBy lists the active sprints ending by the given time with their
+// total and open (not done) user stories and points.
+func (r *RiskRepository) FindSprintsEndingBy(until time.Time) ([]RiskSprintRow, error) {
+	var rows []RiskSprintRow
+	err := r.DB.Table("sprints AS s").
+		Select(`s.id AS sprint_id, s.name, s.project_id, s.end_date,
+			COUNT(us.id) AS total_stories,
+			COALESCE(SUM(CASE WHEN us.status <> ? THEN 1 ELSE 0 END), 0) AS open_stories,
+			COALESCE(SUM(us.points), 0) AS total_points,
+			COALESCE(SUM(CASE WHEN us.status <> ? THEN us.points ELSE 0 END), 0) AS open_points`, "done", "done").
+		Joins("JOIN projects p ON p.id = s.project_id AND p.deleted_at IS NULL").
+		Joins("LEFT JOIN user_stories us ON us.sprint_id = s.id AND us.deleted_at IS NULL").
+		Where("s.status = ? AND s.end_date IS NOT NULL AND s.end_date <= ? AND p.status <> ?", "active", until, models.ProjectStatusArchived).
+		Group("s.id, s.name, s.project_id, s.end_date").
+		Order("s.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetProjectLeads returns, for each project, its longest-standing scrum master or, when
+// it has none, its creator.
+func (r *RiskRepository) GetProjectLeads(projectIDs []uint) ([]ProjectLeadRow, error) {
+	var rows []ProjectLeadRow
+	if len(projectIDs) == 0 {
+		return rows, nil
+	}
+	err := r.DB.Table("projects AS p").
+		Select(`p.id AS project_id, COALESCE((SELECT pm.user_id FROM project_members pm
+			WHERE pm.project_id = p.id AND pm.role = ? ORDER BY pm.id LIMIT 1), p.created_by_id) AS user_id`,
+			models.RoleScrumMaster).
+		Where("p.id IN ?", projectIDs).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetOpenFindings lists every finding that has not been resolved.
+func (r *RiskRepository) GetOpenFindings() ([]models.RiskFinding, error) {
+	var findings []models.RiskFinding
+	err := r.DB.Where("resolved_at IS NULL").Order("id").Find(&findings).Error
+	return findings, err
+}
+
+// ApplyScan records the outcome of a detection run in one transaction: it creates the
+// new findings, refreshes the message and last sighting of those still found and
+// resolves the others. A new finding whose problem already has an open finding is
+// skipped; the findings actually created are returned.
+func (r *RiskRepository) ApplyScan(opened, seen []models.RiskFinding, resolvedIDs []uint, now time.Time) ([]models.RiskFinding, error) {
+	inserted := make([]models.RiskFinding, 0, len(opened))
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		for _, finding := range opened {
+			result := tx.Clauses(clause.OnConflict{
+				Columns:     []clause.Column{{Name: "kind"}, {Name: "entity_type"}, {Name: "entity_id"}},
+				TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "resolved_at IS NULL"}}},
+				DoNothing:   true,
+			}).Create(&finding)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				inserted = append(inserted, finding)
+			}
+		}
+		for _, finding := range seen {
+			if err := tx.Model(&models.RiskFinding{}).Where("id = ?", finding.ID).
+				Updates(map[string]interface{}{"message": finding.Message, "last_seen_at": now}).Error; err != nil {
+				return err
+			}
+		}
+		if len(resolvedIDs) > 0 {
+			return tx.Model(&models.RiskFinding{}).Where("id IN ?", resolvedIDs).Update("resolved_at", now).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+// ListFindings lists the findings of a project, newest first. Resolved findings are
+// only included when asked for.
+func (r *RiskRepository) ListFindings(projectID uint, includeResolved bool) ([]models.RiskFinding, error) {
+	var findings []models.RiskFinding
+	query := r.DB.Where("project_id = ?", projectID)
+	if !includeResolved {
+		query = query.Where("resolved_at IS NULL")
+	}
+	err := query.Order("detected_at DESC, id DESC").Find(&findings).Error
+	return findings, err
+}
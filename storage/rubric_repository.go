@@ -1,6 +1,11 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/buga/API_wrkf/models"
 	"gorm.io/gorm"
 )
@@ -13,6 +18,19 @@ type RubricRepository interface {
 	GetByProjectID(projectID uint) ([]models.Rubric, error) // Added this method
 	Update(rubric *models.Rubric) error
 	Delete(id uint) error
+	IsInUse(id uint) (bool, error)
+	CreateVersion(previous, next *models.Rubric) error
+	FindVersions(rootID uint) ([]models.Rubric, error)
+	FindTemplates(query, category string) ([]models.Rubric, error)
+	CountTemplateUsage(templateIDs []uint) (map[uint]int64, error)
+	CountTemplatesByCategory() ([]TemplateCategoryCount, error)
+	WithContext(ctx context.Context) RubricRepository
+}
+
+// TemplateCategoryCount is the number of global templates in a category.
+type TemplateCategoryCount struct {
+	Category string `json:"category"`
+	Count    int64  `json:"count"`
 }
 
 type rubricRepository struct {
@@ -24,6 +42,12 @@ func NewRubricRepository(db *gorm.DB) RubricRepository {
 	return &rubricRepository{db: db}
 }
 
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *rubricRepository) WithContext(ctx context.Context) RubricRepository {
+	return &rubricRepository{db: r.db.WithContext(ctx)}
+}
+
 // Create adds a new rubric to the database.
 func (r *rubricRepository) Create(rubric *models.Rubric) error {
 	return r.db.Create(rubric).Error
@@ -33,7 +57,7 @@ func (r *rubricRepository) Create(rubric *models.Rubric) error {
 // Filters can be applied, e.g., map[string]interface{}{"is_template": true}
 func (r *rubricRepository) FindAll(filters map[string]interface{}) ([]models.Rubric, error) {
 	var rubrics []models.Rubric
-	query := r.db.Preload("Criteria.Levels")
+	query := r.db.Preload("Criteria.Levels").Where("superseded_at IS NULL")
 
 	if len(filters) > 0 {
 		query = query.Where(filters)
@@ -54,9 +78,12 @@ func (r *rubricRepository) FindByID(id uint) (*models.Rubric, error) {
 }
 
 // Update modifies an existing rubric in the database.
-// It performs a full update of the rubric and its associations.
+// It performs a full update of the rubric and its associations. Version
+// bookkeeping fields are never taken from the payload.
 func (r *rubricRepository) Update(rubric *models.Rubric) error {
-	return r.db.Session(&gorm.Session{FullSaveAssociations: true}).Save(rubric).Error
+	return r.db.Session(&gorm.Session{FullSaveAssociations: true}).
+		Omit("Version", "RootRubricID", "SupersededAt", "SourceTemplateID").
+		Save(rubric).Error
 }
 
 // Delete removes a rubric from the database.
@@ -75,6 +102,109 @@ func (r *rubricRepository) Delete(id uint) error {
 // GetByProjectID retrieves all rubrics for a specific project ID, with preloaded criteria and levels.
 func (r *rubricRepository) GetByProjectID(projectID uint) ([]models.Rubric, error) {
 	var rubrics []models.Rubric
-	err := r.db.Where("project_id = ?", projectID).Preload("Criteria.Levels").Find(&rubrics).Error
+	err := r.db.Where("project_id = ? AND superseded_at IS NULL", projectID).Preload("Criteria.Levels").Find(&rubrics).Error
+	return rubrics, err
+}
+
+// IsInUse reports whether any evaluation or evaluation round references the rubric.
+func (r *rubricRepository) IsInUse(id uint) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Evaluation{}).Where("rubric_id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := r.db.Model(&models.EvaluationRound{}).Where("rubric_id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreateVersion marks the previous version as superseded and creates the next one
+// within a single transaction. It fails if the previous version was already superseded.
+func (r *rubricRepository) CreateVersion(previous, next *models.Rubric) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Rubric{}).
+			Where("id = ? AND superseded_at IS NULL", previous.ID).
+			Update("superseded_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("rubric %d has already been superseded", previous.ID)
+		}
+		previous.SupersededAt = &now
+
+		return tx.Omit("Project", "CreatedBy").Create(next).Error
+	})
+}
+
+// FindVersions retrieves every version of a rubric, oldest first.
+func (r *rubricRepository) FindVersions(rootID uint) ([]models.Rubric, error) {
+	var rubrics []models.Rubric
+	err := r.db.Preload("Criteria.Levels").
+		Where("id = ? OR root_rubric_id = ?", rootID, rootID).
+		Order("version asc").
+		Find(&rubrics).Error
 	return rubrics, err
 }
+
+// globalTemplates scopes a query to the latest version of every global template.
+func (r *rubricRepository) globalTemplates() *gorm.DB {
+	return r.db.Model(&models.Rubric{}).
+		Where("is_template = ? AND project_id IS NULL AND superseded_at IS NULL", true)
+}
+
+// FindTemplates searches the global templates by name, description or category
+// (case-insensitive), optionally restricted to one category.
+func (r *rubricRepository) FindTemplates(query, category string) ([]models.Rubric, error) {
+	var rubrics []models.Rubric
+	q := r.globalTemplates().Preload("Criteria.Levels")
+	if query != "" {
+		like := "%" + strings.ToLower(query) + "%"
+		q = q.Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR LOWER(category) LIKE ?", like, like, like)
+	}
+	if category != "" {
+		q = q.Where("category = ?", category)
+	}
+	err := q.Order("name asc").Find(&rubrics).Error
+	return rubrics, err
+}
+
+// CountTemplateUsage counts how many rubrics were instantiated from each template,
+// keyed by the template's root rubric ID. Later versions of an instance are not counted again.
+func (r *rubricRepository) CountTemplateUsage(templateIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		SourceTemplateID uint
+		Count            int64
+	}
+	counts := make(map[uint]int64, len(templateIDs))
+	if len(templateIDs) == 0 {
+		return counts, nil
+	}
+	err := r.db.Model(&models.Rubric{}).
+		Select("source_template_id, COUNT(*) AS count").
+		Where("source_template_id IN ? AND root_rubric_id IS NULL", templateIDs).
+		Group("source_template_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.SourceTemplateID] = row.Count
+	}
+	return counts, nil
+}
+
+// CountTemplatesByCategory lists the template categories with the number of templates in each.
+func (r *rubricRepository) CountTemplatesByCategory() ([]TemplateCategoryCount, error) {
+	var categories []TemplateCategoryCount
+	err := r.globalTemplates().
+		Select("category, COUNT(*) AS count").
+		Group("category").
+		Order("category asc").
+		Scan(&categories).Error
+	return categories, err
+}
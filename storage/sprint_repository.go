@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"github.com/buga/API_wrkf/models"
 	"gorm.io/gorm"
 )
@@ -15,6 +16,12 @@ func NewSprintRepository(db *gorm.DB) *SprintRepository {
 	return &SprintRepository{DB: db}
 }
 
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *SprintRepository) WithContext(ctx context.Context) *SprintRepository {
+	return &SprintRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // CreateSprint adds a new sprint to the database.
 func (r *SprintRepository) CreateSprint(sprint *models.Sprint) error {
 	return r.DB.Create(sprint).Error
@@ -108,3 +115,15 @@ func (r *SprintRepository) GetActiveSprint(projectID uint) (*models.Sprint, erro
 	}
 	return &sprint, nil
 }
+
+// ListSprintTasks retrieves one page of the tasks in a sprint matching the filter,
+// with their relationships, together with the total number of matches.
+func (r *SprintRepository) ListSprintTasks(sprintID uint, filter TaskFilter, opts ListOptions) ([]models.Task, int64, error) {
+	query := filter.apply(r.DB.Model(&models.Task{}).
+		Joins("JOIN user_stories ON tasks.user_story_id = user_stories.id").
+		Where("user_stories.sprint_id = ?", sprintID))
+
+	var tasks []models.Task
+	total, err := findPage(query, opts, "tasks.id", &tasks, "AssignedTo", "CreatedBy", "UserStory", "UserStory.Project")
+	return tasks, total, err
+}
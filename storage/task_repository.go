@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"github.com/buga/API_wrkf/models"
 	"gorm.io/gorm"
 )
@@ -15,6 +16,12 @@ func NewTaskRepository(db *gorm.DB) *TaskRepository {
 	return &TaskRepository{DB: db}
 }
 
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *TaskRepository) WithContext(ctx context.Context) *TaskRepository {
+	return &TaskRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // CreateTask adds a new task to the database.
 func (r *TaskRepository) CreateTask(task *models.Task) error {
 	return r.DB.Create(task).Error
@@ -34,6 +41,41 @@ func (r *TaskRepository) GetTasksByUserStoryID(userStoryID uint) ([]models.Task,
 	return tasks, err
 }
 
+// TaskFilter narrows down the tasks returned by the task lists.
+type TaskFilter struct {
+	Status     string
+	AssigneeID *uint  // Only tasks assigned to this user
+	Unassigned bool   // Only tasks without an assignee
+	Search     string // Only tasks whose title contains this text, ignoring case
+}
+
+// apply adds the filter's conditions to a query on the tasks table.
+func (f TaskFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Status != "" {
+		query = query.Where("tasks.status = ?", f.Status)
+	}
+	if f.AssigneeID != nil {
+		query = query.Where("tasks.assigned_to_id = ?", *f.AssigneeID)
+	}
+	if f.Unassigned {
+		query = query.Where("tasks.assigned_to_id IS NULL")
+	}
+	if f.Search != "" {
+		query = query.Where(`LOWER(tasks.title) LIKE ? ESCAPE '\'`, containsPattern(f.Search))
+	}
+	return query
+}
+
+// ListTasksByUserStoryID retrieves one page of a user story's tasks matching the
+// filter, together with the total number of matches.
+func (r *TaskRepository) ListTasksByUserStoryID(userStoryID uint, filter TaskFilter, opts ListOptions) ([]models.Task, int64, error) {
+	query := filter.apply(r.DB.Model(&models.Task{}).Where("tasks.user_story_id = ?", userStoryID))
+
+	var tasks []models.Task
+	total, err := findPage(query, opts, "tasks.id", &tasks, "CreatedBy", "AssignedTo")
+	return tasks, total, err
+}
+
 // UpdateTask is a robust method to save a task. It explicitly specifies which
 // fields should be updated, preventing GORM from accidentally nullifying associations.
 func (r *TaskRepository) UpdateTask(task *models.Task) error {
@@ -50,27 +92,13 @@ func (r *TaskRepository) UpdateTask(task *models.Task) error {
 	).Save(task).Error
 }
 
-// DeleteTask removes a task and its dependencies from the database by its ID.
+// DeleteTask moves a task to the trash. Its history and comments are kept so that
+// it can be restored; they are removed when the task is purged.
 func (r *TaskRepository) DeleteTask(id uint) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
-		// First, delete dependent records to avoid foreign key violations.
-		if err := tx.Where("task_id = ?", id).Delete(&models.TaskHistory{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Where("task_id = ?", id).Delete(&models.TaskComment{}).Error; err != nil {
-			return err
-		}
-
-		// Then, delete the task itself.
-		if err := tx.Delete(&models.Task{}, id).Error; err != nil {
-			return err
-		}
-
-		return nil
-	})
+	return r.DB.Delete(&models.Task{}, id).Error
 }
 
-// DeleteTasksByUserStoryIDs deletes all tasks associated with a list of user story IDs.
+// DeleteTasksByUserStoryIDs moves all tasks associated with a list of user story IDs to the trash.
 func (r *TaskRepository) DeleteTasksByUserStoryIDs(tx *gorm.DB, storyIDs []uint) error {
 	return tx.Where("user_story_id IN ?", storyIDs).Delete(&models.Task{}).Error
 }
@@ -80,6 +108,18 @@ func (r *TaskRepository) AddComment(comment *models.TaskComment) error {
 	return r.DB.Create(comment).Error
 }
 
+// GetDeliverableTasksByProjectID retrieves the deliverable tasks of a project, ordered by ID.
+func (r *TaskRepository) GetDeliverableTasksByProjectID(projectID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.DB.
+		Joins("JOIN user_stories ON user_stories.id = tasks.user_story_id").
+		Where("user_stories.project_id = ? AND tasks.is_deliverable = ?", projectID, true).
+		Preload("AssignedTo").
+		Order("tasks.id asc").
+		Find(&tasks).Error
+	return tasks, err
+}
+
 // GetProjectIDForTask finds the ProjectID for a given task by traversing up.
 func (r *TaskRepository) GetProjectIDForTask(taskID uint) (uint, error) {
 	var task models.Task
@@ -97,6 +137,18 @@ func (r *TaskRepository) GetProjectIDForTask(taskID uint) (uint, error) {
 	return userStory.ProjectID, nil
 }
 
+// GetSprintIDForTask finds the sprint of a task's user story, or nil when it is in the
+// backlog. Tasks in the trash are found too, so events about their deletion can be routed.
+func (r *TaskRepository) GetSprintIDForTask(taskID uint) (*uint, error) {
+	var sprintIDs []*uint
+	err := r.DB.Raw("SELECT us.sprint_id FROM tasks t JOIN user_stories us ON us.id = t.user_story_id WHERE t.id = ?", taskID).
+		Scan(&sprintIDs).Error
+	if err != nil || len(sprintIDs) == 0 {
+		return nil, err
+	}
+	return sprintIDs[0], nil
+}
+
 // GetCommentsByTaskID retrieves all comments for a given task, ordered by creation time.
 func (r *TaskRepository) GetCommentsByTaskID(taskID uint) ([]models.TaskComment, error) {
 	var comments []models.TaskComment
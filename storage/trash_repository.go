@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+)
+
+// TrashRepository handles the soft-deleted projects, user stories and tasks:
+// listing them, restoring them and purging them for good.
+type TrashRepository struct {
+	DB *gorm.DB
+}
+
+// NewTrashRepository creates a new instance of TrashRepository.
+func NewTrashRepository(db *gorm.DB) *TrashRepository {
+	return &TrashRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// restores and purges are attributed to the request's actor in the audit log.
+func (r *TrashRepository) WithContext(ctx context.Context) *TrashRepository {
+	return &TrashRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// trashed scopes a query to rows that are in the trash.
+func trashed(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+// GetTrashedProjects lists the projects in the trash, most recently deleted first.
+// A non-zero creatorID restricts the list to the projects created by that user.
+func (r *TrashRepository) GetTrashedProjects(creatorID uint) ([]models.Project, error) {
+	var projects []models.Project
+	query := r.DB.Scopes(trashed).Preload("CreatedBy")
+	if creatorID != 0 {
+		query = query.Where("created_by_id = ?", creatorID)
+	}
+	err := query.Order("deleted_at desc").Find(&projects).Error
+	return projects, err
+}
+
+// GetTrashedProject retrieves a project that is in the trash.
+func (r *TrashRepository) GetTrashedProject(id uint) (*models.Project, error) {
+	var project models.Project
+	err := r.DB.Scopes(trashed).First(&project, id).Error
+	return &project, err
+}
+
+// GetTrashedUserStories lists the user stories of a project that are in the trash.
+func (r *TrashRepository) GetTrashedUserStories(projectID uint) ([]models.UserStory, error) {
+	var stories []models.UserStory
+	err := r.DB.Scopes(trashed).
+		Where("project_id = ?", projectID).
+		Preload("CreatedBy").
+		Order("deleted_at desc").
+		Find(&stories).Error
+	return stories, err
+}
+
+// GetUserStory retrieves a user story whether or not it is in the trash.
+func (r *TrashRepository) GetUserStory(id uint) (*models.UserStory, error) {
+	var story models.UserStory
+	err := r.DB.Unscoped().First(&story, id).Error
+	return &story, err
+}
+
+// GetTrashedTasks lists the tasks of a project that are in the trash.
+func (r *TrashRepository) GetTrashedTasks(projectID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.DB.Scopes(trashed).
+		Where("user_story_id IN (?)", r.DB.Unscoped().Model(&models.UserStory{}).Select("id").Where("project_id = ?", projectID)).
+		Preload("CreatedBy").
+		Preload("AssignedTo").
+		Order("deleted_at desc").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// GetTask retrieves a task whether or not it is in the trash.
+func (r *TrashRepository) GetTask(id uint) (*models.Task, error) {
+	var task models.Task
+	err := r.DB.Unscoped().First(&task, id).Error
+	return &task, err
+}
+
+// RestoreProject takes a project out of the trash together with the user stories and
+// tasks that were deleted with it or after it. Items deleted earlier stay in the trash.
+func (r *TrashRepository) RestoreProject(projectID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		deletedAt := tx.Unscoped().Model(&models.Project{}).Select("deleted_at").Where("id = ?", projectID)
+
+		var storyIDs []uint
+		if err := tx.Scopes(trashed).Model(&models.UserStory{}).
+			Where("project_id = ? AND deleted_at >= (?)", projectID, deletedAt).
+			Pluck("id", &storyIDs).Error; err != nil {
+			return err
+		}
+		var taskIDs []uint
+		if err := tx.Scopes(trashed).Model(&models.Task{}).
+			Where("user_story_id IN (?) AND deleted_at >= (?)", tx.Unscoped().Model(&models.UserStory{}).Select("id").Where("project_id = ?", projectID), deletedAt).
+			Pluck("id", &taskIDs).Error; err != nil {
+			return err
+		}
+
+		if err := restoreRows(tx, &models.Task{}, taskIDs); err != nil {
+			return err
+		}
+		if err := restoreRows(tx, &models.UserStory{}, storyIDs); err != nil {
+			return err
+		}
+		if err := restoreRows(tx, &models.Project{}, []uint{projectID}); err != nil {
+			return err
+		}
+		return rebuildRelationships(tx, projectID, storyIDs, taskIDs)
+	})
+}
+
+// RestoreUserStory takes a user story out of the trash together with the tasks
+// that were deleted with it or after it.
+func (r *TrashRepository) RestoreUserStory(storyID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		story, err := (&TrashRepository{DB: tx}).GetUserStory(storyID)
+		if err != nil {
+			return err
+		}
+
+		var taskIDs []uint
+		if err := tx.Scopes(trashed).Model(&models.Task{}).
+			Where("user_story_id = ? AND deleted_at >= (?)", storyID, tx.Unscoped().Model(&models.UserStory{}).Select("deleted_at").Where("id = ?", storyID)).
+			Pluck("id", &taskIDs).Error; err != nil {
+			return err
+		}
+
+		if err := restoreRows(tx, &models.Task{}, taskIDs); err != nil {
+			return err
+		}
+		if err := restoreRows(tx, &models.UserStory{}, []uint{storyID}); err != nil {
+			return err
+		}
+		return rebuildRelationships(tx, story.ProjectID, []uint{storyID}, taskIDs)
+	})
+}
+
+// RestoreTask takes a task out of the trash. If its user story is in the trash too,
+// the story is restored on its own so the task has a parent again.
+func (r *TrashRepository) RestoreTask(taskID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		repo := &TrashRepository{DB: tx}
+		task, err := repo.GetTask(taskID)
+		if err != nil {
+			return err
+		}
+		story, err := repo.GetUserStory(task.UserStoryID)
+		if err != nil {
+			return err
+		}
+
+		var storyIDs []uint
+		if story.DeletedAt.Valid {
+			storyIDs = append(storyIDs, story.ID)
+			if err := restoreRows(tx, &models.UserStory{}, storyIDs); err != nil {
+				return err
+			}
+		}
+		if err := restoreRows(tx, &models.Task{}, []uint{taskID}); err != nil {
+			return err
+		}
+		return rebuildRelationships(tx, story.ProjectID, storyIDs, []uint{taskID})
+	})
+}
+
+func restoreRows(tx *gorm.DB, model interface{}, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Unscoped().Model(model).Where("id IN ?", ids).Update("deleted_at", nil).Error
+}
+
+// rebuildRelationships detaches restored items from whatever disappeared while they were
+// in the trash: sprints that were deleted and assignees that left the project.
+func rebuildRelationships(tx *gorm.DB, projectID uint, storyIDs, taskIDs []uint) error {
+	members := tx.Model(&models.ProjectMember{}).Select("user_id").Where("project_id = ?", projectID)
+	sprints := tx.Model(&models.Sprint{}).Select("id").Where("project_id = ?", projectID)
+
+	if len(storyIDs) > 0 {
+		if err := tx.Model(&models.UserStory{}).
+			Where("id IN ? AND sprint_id IS NOT NULL AND sprint_id NOT IN (?)", storyIDs, sprints).
+			Update("sprint_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.UserStory{}).
+			Where("id IN ? AND assigned_to_id IS NOT NULL AND assigned_to_id NOT IN (?)", storyIDs, members).
+			Update("assigned_to_id", nil).Error; err != nil {
+			return err
+		}
+	}
+	if len(taskIDs) > 0 {
+		if err := tx.Model(&models.Task{}).
+			Where("id IN ? AND assigned_to_id IS NOT NULL AND assigned_to_id NOT IN (?)", taskIDs, members).
+			Update("assigned_to_id", nil).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeProject permanently deletes a project with all its user stories, tasks, sprints and
+// members, and everything that refers to them: calendar events, rubrics, evaluation
+// rounds, evaluations and metrics. Saved reports and conversations are kept but detached.
+func (r *TrashRepository) PurgeProject(projectID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var storyIDs []uint
+		if err := tx.Unscoped().Model(&models.UserStory{}).Where("project_id = ?", projectID).Pluck("id", &storyIDs).Error; err != nil {
+			return err
+		}
+		if err := purgeTasks(tx, tx.Unscoped().Model(&models.Task{}).Select("id").Where("user_story_id IN ?", storyIDs)); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("project_id = ?", projectID).Delete(&models.UserStory{}).Error; err != nil {
+			return err
+		}
+		if err := purgeProjectDependents(tx, projectID); err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", projectID).Delete(&models.Sprint{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", projectID).Delete(&models.ProjectMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Project{}, projectID).Error
+	})
+}
+
+// purgeProjectDependents deletes or detaches the rows, other than stories and tasks, that
+// refer to a project or its sprints, so the foreign keys let them go.
+func purgeProjectDependents(tx *gorm.DB, projectID uint) error {
+	sprints := tx.Model(&models.Sprint{}).Select("id").Where("project_id = ?", projectID)
+	rounds := tx.Model(&models.EvaluationRound{}).Select("id").Where("project_id = ?", projectID)
+	rubrics := tx.Model(&models.Rubric{}).Select("id").Where("project_id = ?", projectID)
+	criteria := tx.Model(&models.RubricCriterion{}).Select("id").Where("rubric_id IN (?)", rubrics)
+
+	if err := purgeEvaluations(tx, tx.Model(&models.Evaluation{}).Select("id").Where("round_id IN (?)", rounds)); err != nil {
+		return err
+	}
+	if err := tx.Where("round_id IN (?)", rounds).Delete(&models.PeerReviewAssignment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ?", projectID).Delete(&models.EvaluationRound{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("criterion_id IN (?)", criteria).Delete(&models.RubricCriterionLevel{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("rubric_id IN (?)", rubrics).Delete(&models.RubricCriterion{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ?", projectID).Delete(&models.Rubric{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ?", projectID).Delete(&models.Event{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ?", projectID).Delete(&models.RiskFinding{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ?", projectID).Delete(&models.ProjectMetric{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ? OR sprint_id IN (?)", projectID, sprints).Delete(&models.SprintMetric{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ? OR sprint_id IN (?)", projectID, sprints).Delete(&models.UserMetric{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Report{}).Where("sprint_id IN (?)", sprints).Update("sprint_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Report{}).Where("project_id = ?", projectID).Update("project_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Conversation{}).Where("project_id = ?", projectID).Update("project_id", nil).Error
+}
+
+// PurgeUserStory permanently deletes a user story and all its tasks.
+func (r *TrashRepository) PurgeUserStory(storyID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := purgeTasks(tx, tx.Unscoped().Model(&models.Task{}).Select("id").Where("user_story_id = ?", storyID)); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.UserStory{}, storyID).Error
+	})
+}
+
+// PurgeTask permanently deletes a task with its history, comments and evaluations.
+func (r *TrashRepository) PurgeTask(taskID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return purgeTasks(tx, []uint{taskID})
+	})
+}
+
+// purgeTasks permanently deletes the given tasks (a list of IDs or a subquery) and their dependencies.
+func purgeTasks(tx *gorm.DB, taskIDs interface{}) error {
+	if err := tx.Where("task_id IN (?)", taskIDs).Delete(&models.TaskHistory{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("task_id IN (?)", taskIDs).Delete(&models.TaskComment{}).Error; err != nil {
+		return err
+	}
+	if err := purgeEvaluations(tx, tx.Model(&models.Evaluation{}).Select("id").Where("task_id IN (?)", taskIDs)); err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN (?)", taskIDs).Delete(&models.Task{}).Error
+}
+
+// purgeEvaluations permanently deletes the given evaluations (a subquery) with their scores.
+func purgeEvaluations(tx *gorm.DB, evaluationIDs interface{}) error {
+	if err := tx.Where("evaluation_id IN (?)", evaluationIDs).Delete(&models.CriterionEvaluation{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN (?)", evaluationIDs).Delete(&models.Evaluation{}).Error
+}
+
+// GetExpiredTrash returns the IDs of the projects, user stories and tasks that were
+// moved to the trash before the cutoff.
+func (r *TrashRepository) GetExpiredTrash(cutoff time.Time) (projectIDs, storyIDs, taskIDs []uint, err error) {
+	if err = r.DB.Scopes(trashed).Model(&models.Project{}).Where("deleted_at < ?", cutoff).Pluck("id", &projectIDs).Error; err != nil {
+		return
+	}
+	if err = r.DB.Scopes(trashed).Model(&models.UserStory{}).Where("deleted_at < ?", cutoff).Pluck("id", &storyIDs).Error; err != nil {
+		return
+	}
+	err = r.DB.Scopes(trashed).Model(&models.Task{}).Where("deleted_at < ?", cutoff).Pluck("id", &taskIDs).Error
+	return
+}
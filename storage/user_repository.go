@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"strings"
+
 	"github.com/buga/API_wrkf/models"
 	"gorm.io/gorm"
 )
@@ -36,6 +38,29 @@ func (r *UserRepository) GetAllUsers() ([]models.User, error) {
 	return users, err
 }
 
+// UserFilter narrows down the users returned by ListUsers.
+type UserFilter struct {
+	Role   string // Only users with this platform role
+	Search string // Only users whose name or email contains this text, ignoring case
+}
+
+// ListUsers retrieves one page of the users matching the filter, together with the
+// total number of matches.
+func (r *UserRepository) ListUsers(filter UserFilter, opts ListOptions) ([]models.User, int64, error) {
+	query := r.DB.Model(&models.User{})
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Search != "" {
+		pattern := containsPattern(filter.Search)
+		query = query.Where(`(LOWER(nombre || ' ' || apellido_paterno || ' ' || apellido_materno) LIKE ? ESCAPE '\' OR LOWER(correo) LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+
+	var users []models.User
+	total, err := findPage(query, opts, "id", &users)
+	return users, total, err
+}
+
 // UpdateUser saves the changes of a user model to the database.
 func (r *UserRepository) UpdateUser(user *models.User) error {
 	return r.DB.Save(user).Error
@@ -65,3 +90,27 @@ func (r *UserRepository) GetNonAdminUsersNotInProject(assignedUserIDs []uint) ([
 	err := db.Find(&users).Error
 	return users, err
 }
+
+// GetUsersByIDs retrieves the users with the given IDs.
+func (r *UserRepository) GetUsersByIDs(ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.DB.Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+// GetUsersByEmails retrieves the users whose email is in the list, ignoring case.
+func (r *UserRepository) GetUsersByEmails(emails []string) ([]models.User, error) {
+	var users []models.User
+	if len(emails) == 0 {
+		return users, nil
+	}
+	lowered := make([]string, len(emails))
+	for i, email := range emails {
+		lowered[i] = strings.ToLower(email)
+	}
+	err := r.DB.Where("LOWER(correo) IN ?", lowered).Find(&users).Error
+	return users, err
+}
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"github.com/buga/API_wrkf/models"
 	"gorm.io/gorm"
@@ -17,6 +19,12 @@ func NewUserStoryRepository(db *gorm.DB) *UserStoryRepository {
 	return &UserStoryRepository{DB: db}
 }
 
+// WithContext returns a copy of the repository whose queries carry ctx, so that
+// changes are attributed to the request's actor in the audit log.
+func (r *UserStoryRepository) WithContext(ctx context.Context) *UserStoryRepository {
+	return &UserStoryRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // CreateUserStory adds a new user story to the database.
 func (r *UserStoryRepository) CreateUserStory(userStory *models.UserStory) error {
 	return r.DB.Create(userStory).Error
@@ -29,6 +37,48 @@ func (r *UserStoryRepository) GetUserStoriesByProjectID(projectID uint) ([]model
 	return userStories, err
 }
 
+// UserStoryFilter narrows down the user stories returned by ListUserStoriesByProjectID.
+type UserStoryFilter struct {
+	Status     string
+	Priority   string
+	SprintID   *uint  // Only stories in this sprint
+	Backlog    bool   // Only stories without a sprint
+	AssigneeID *uint  // Only stories assigned to this user
+	Unassigned bool   // Only stories without an assignee
+	Search     string // Only stories whose title contains this text, ignoring case
+}
+
+// ListUserStoriesByProjectID retrieves one page of a project's user stories matching
+// the filter, together with the total number of matches.
+func (r *UserStoryRepository) ListUserStoriesByProjectID(projectID uint, filter UserStoryFilter, opts ListOptions) ([]models.UserStory, int64, error) {
+	query := r.DB.Model(&models.UserStory{}).Where("project_id = ?", projectID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Priority != "" {
+		query = query.Where("priority = ?", filter.Priority)
+	}
+	if filter.SprintID != nil {
+		query = query.Where("sprint_id = ?", *filter.SprintID)
+	}
+	if filter.Backlog {
+		query = query.Where("sprint_id IS NULL")
+	}
+	if filter.AssigneeID != nil {
+		query = query.Where("assigned_to_id = ?", *filter.AssigneeID)
+	}
+	if filter.Unassigned {
+		query = query.Where("assigned_to_id IS NULL")
+	}
+	if filter.Search != "" {
+		query = query.Where(`LOWER(title) LIKE ? ESCAPE '\'`, containsPattern(filter.Search))
+	}
+
+	var userStories []models.UserStory
+	total, err := findPage(query, opts, "id", &userStories, "CreatedBy")
+	return userStories, total, err
+}
+
 // GetUserStoryByID retrieves a single user story by its ID, preloading all related data.
 func (r *UserStoryRepository) GetUserStoryByID(id uint) (*models.UserStory, error) {
 	var userStory models.UserStory
@@ -50,9 +100,16 @@ func (r *UserStoryRepository) UpdateUserStory(userStory *models.UserStory) error
 	return r.DB.Model(userStory).Select("*").Updates(userStory).Error
 }
 
-// DeleteUserStory removes a user story from the database by its ID.
+// DeleteUserStory moves a user story and its tasks to the trash. Both share the same
+// deletion time, so restoring the story brings back the tasks deleted with it.
 func (r *UserStoryRepository) DeleteUserStory(id uint) error {
-	return r.DB.Delete(&models.UserStory{}, id).Error
+	now := time.Now()
+	return r.DB.Session(&gorm.Session{NowFunc: func() time.Time { return now }}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_story_id = ?", id).Delete(&models.Task{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.UserStory{}, id).Error
+	})
 }
 
 // GetUserStoryIDsByProjectID retrieves the IDs of all user stories for a given project.
@@ -62,7 +119,7 @@ func (r *UserStoryRepository) GetUserStoryIDsByProjectID(tx *gorm.DB, projectID
 	return ids, err
 }
 
-// DeleteUserStoriesByProjectID deletes all user stories associated with a project.
+// DeleteUserStoriesByProjectID moves all user stories associated with a project to the trash.
 func (r *UserStoryRepository) DeleteUserStoriesByProjectID(tx *gorm.DB, projectID uint) error {
 	return tx.Where("project_id = ?", projectID).Delete(&models.UserStory{}).Error
 }
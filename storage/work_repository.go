@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"gorm.io/gorm"
+)
+
+// WorkTaskRow is a task assigned to a user, with its story, project and sprint.
+type WorkTaskRow struct {
+	TaskID      uint
+	Title       string
+	Status      string
+	StoryID     uint
+	StoryTitle  string
+	ProjectID   uint
+	ProjectName string
+	SprintID    *uint
+	SprintName  *string
+	DueDate     *time.Time // End date of the sprint
+	UpdatedAt   time.Time
+}
+
+// WorkStoryRow is a user story assigned to a user, with its project, sprint and task counts.
+type WorkStoryRow struct {
+	StoryID     uint
+	Title       string
+	Status      string
+	Priority    string
+	Points      *int
+	ProjectID   uint
+	ProjectName string
+	SprintID    *uint
+	SprintName  *string
+	DueDate     *time.Time // End date of the sprint
+	TasksTotal  int
+	TasksDone   int
+}
+
+// WorkRepository reads the work assigned to a user across projects. Items in the trash
+// and in archived projects are left out.
+type WorkRepository struct {
+	DB *gorm.DB
+}
+
+// NewWorkRepository creates a new instance of WorkRepository.
+func NewWorkRepository(db *gorm.DB) *WorkRepository {
+	return &WorkRepository{DB: db}
+}
+
+// WithContext returns a copy of the repository whose queries carry ctx.
+func (r *WorkRepository) WithContext(ctx context.Context) *WorkRepository {
+	return &WorkRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// GetAssignedTasks lists the tasks assigned to the user in a single query, ordered by
+// project and due date.
+func (r *WorkRepository) GetAssignedTasks(userID uint) ([]WorkTaskRow, error) {
+	var rows []WorkTaskRow
+	err := r.DB.Table("tasks AS t").
+		Select(`t.id AS task_id, t.title, t.status, us.id AS story_id, us.title AS story_title,
+			p.id AS project_id, p.name AS project_name, s.id AS sprint_id, s.name AS sprint_name,
+			s.end_date AS due_date, t.updated_at`).
+		Joins("JOIN user_stories us ON us.id = t.user_story_id AND us.deleted_at IS NULL").
+		Joins("JOIN projects p ON p.id = us.project_id AND p.deleted_at IS NULL").
+		Joins("LEFT JOIN sprints s ON s.id = us.sprint_id").
+		Where("t.assigned_to_id = ? AND t.deleted_at IS NULL AND p.status <> ?", userID, models.ProjectStatusArchived).
+		Order("p.id, s.end_date, t.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetAssignedStories lists the user stories assigned to the user in a single query,
+// counting their tasks with subqueries.
+func (r *WorkRepository) GetAssignedStories(userID uint) ([]WorkStoryRow, error) {
+	var rows []WorkStoryRow
+	err := r.DB.Table("user_stories AS us").
+		Select(`us.id AS story_id, us.title, us.status, us.priority, us.points,
+			p.id AS project_id, p.name AS project_name, s.id AS sprint_id, s.name AS sprint_name, s.end_date AS due_date,
+			(SELECT COUNT(*) FROM tasks t WHERE t.user_story_id = us.id AND t.deleted_at IS NULL) AS tasks_total,
+			(SELECT COUNT(*) FROM tasks t WHERE t.user_story_id = us.id AND t.deleted_at IS NULL AND t.status = ?) AS tasks_done`,
+			models.StatusDone).
+		Joins("JOIN projects p ON p.id = us.project_id AND p.deleted_at IS NULL").
+		Joins("LEFT JOIN sprints s ON s.id = us.sprint_id").
+		Where("us.assigned_to_id = ? AND us.deleted_at IS NULL AND p.status <> ?", userID, models.ProjectStatusArchived).
+		Order("p.id, s.end_date, us.id").
+		Scan(&rows).Error
+	return rows, err
+}
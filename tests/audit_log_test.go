@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestAuditLog(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	admin := &models.User{Nombre: "Audit", ApellidoPaterno: "Admin", ApellidoMaterno: "User", Correo: "admin-audit@test.com", Contraseña: "secret123"}
+	require.NoError(t, testApp.UserService.CreateAdminUser(admin))
+	rec := doEvaluationRequest(testApp, http.MethodPost, "/login", "", map[string]string{"correo": admin.Correo, "contraseña": "secret123"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	adminToken := login["token"]
+
+	member, memberToken := CreateTestUser(t, testApp, "member-audit@test.com", "user")
+	project := CreateTestProject(t, testApp, "Audit Project", member.ID)
+	AddUserToProject(t, testApp, project.ID, member.ID, "product_owner")
+	story := CreateTestUserStory(t, testApp, "Audited Story", project.ID)
+
+	queryAudit := func(t *testing.T, query string) services.Page[models.AuditLog] {
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/admin/audit-logs?"+query, adminToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page services.Page[models.AuditLog]
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page
+	}
+
+	var taskID uint
+	t.Run("Records creations with actor, IP and request ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/userstories/%d/tasks", story.ID), strings.NewReader(`{"title":"Audited Task"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+memberToken)
+		req.Header.Set(echo.HeaderXRequestID, "req-audit-1")
+		req.Header.Set(echo.HeaderXRealIP, "203.0.113.7")
+		rec := httptest.NewRecorder()
+		testApp.Router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, "req-audit-1", rec.Header().Get(echo.HeaderXRequestID))
+
+		var task models.Task
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &task))
+		taskID = task.ID
+
+		page := queryAudit(t, fmt.Sprintf("entityType=task&entityId=%d", taskID))
+		require.Len(t, page.Items, 1)
+		entry := page.Items[0]
+		assert.Equal(t, models.AuditActionCreate, entry.Action)
+		require.NotNil(t, entry.ActorID)
+		assert.Equal(t, member.ID, *entry.ActorID)
+		assert.Equal(t, "203.0.113.7", entry.IPAddress)
+		assert.Equal(t, "req-audit-1", entry.RequestID)
+		assert.Equal(t, "null", string(entry.Before))
+		assert.Contains(t, string(entry.After), `"title":"Audited Task"`)
+	})
+
+	t.Run("Records updates with before and after state", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPut, fmt.Sprintf("/api/tasks/%d/status", taskID), memberToken, map[string]string{"status": "in_progress"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		page := queryAudit(t, fmt.Sprintf("entityType=task&entityId=%d", taskID))
+		require.Len(t, page.Items, 2)
+		entry := page.Items[0] // Newest first
+		assert.Equal(t, models.AuditActionUpdate, entry.Action)
+
+		var before, after map[string]interface{}
+		require.NoError(t, json.Unmarshal(entry.Before, &before))
+		require.NoError(t, json.Unmarshal(entry.After, &after))
+		assert.Equal(t, "todo", before["status"])
+		assert.Equal(t, "in_progress", after["status"])
+	})
+
+	t.Run("Records deletions", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/tasks/%d", taskID), memberToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+		page := queryAudit(t, fmt.Sprintf("entityType=task&entityId=%d", taskID))
+		require.Len(t, page.Items, 3)
+		assert.Equal(t, models.AuditActionDelete, page.Items[0].Action)
+		assert.Contains(t, string(page.Items[0].Before), `"status":"in_progress"`)
+		assert.Equal(t, "null", string(page.Items[0].After))
+	})
+
+	t.Run("Filters by actor and time range", func(t *testing.T) {
+		page := queryAudit(t, fmt.Sprintf("actorId=%d", member.ID))
+		assert.EqualValues(t, 3, page.Total)
+
+		future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		page = queryAudit(t, "from="+future)
+		assert.Zero(t, page.Total)
+
+		page = queryAudit(t, "entityType=task&limit=1")
+		assert.Len(t, page.Items, 1)
+		assert.EqualValues(t, 3, page.Total)
+	})
+
+	t.Run("Actors are listed without their password hash", func(t *testing.T) {
+		require.NoError(t, testApp.DB.Model(&models.User{}).Where("id = ?", member.ID).Update("contraseña", "$2a$10$member-audit-hash").Error)
+
+		rec := doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/admin/audit-logs?actorId=%d", member.ID), adminToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.NotContains(t, rec.Body.String(), "member-audit-hash")
+
+		var page services.Page[models.AuditLog]
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		require.NotEmpty(t, page.Items)
+		require.NotNil(t, page.Items[0].Actor)
+		assert.Equal(t, member.Correo, page.Items[0].Actor.Correo)
+		assert.Empty(t, page.Items[0].Actor.Contraseña)
+	})
+
+	t.Run("Sorts with the shared list parameters", func(t *testing.T) {
+		page := queryAudit(t, "entityType=task&sort=createdAt")
+		require.Len(t, page.Items, 3)
+		assert.Equal(t, models.AuditActionCreate, page.Items[0].Action, "oldest first")
+
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/admin/audit-logs?sort=actor", adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Only admins can read the audit log", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/admin/audit-logs", memberToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Entries share the transaction of the change", func(t *testing.T) {
+		err := testApp.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&models.Sprint{Name: "Rolled Back", ProjectID: project.ID, CreatedByID: member.ID}).Error; err != nil {
+				return err
+			}
+			return errors.New("abort")
+		})
+		require.Error(t, err)
+
+		var count int64
+		require.NoError(t, testApp.DB.Model(&models.AuditLog{}).Where("entity_type = ?", "sprint").Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Entries are append-only", func(t *testing.T) {
+		var entry models.AuditLog
+		require.NoError(t, testApp.DB.First(&entry).Error)
+
+		entry.EntityType = "tampered"
+		assert.ErrorIs(t, testApp.DB.Save(&entry).Error, models.ErrAuditLogAppendOnly)
+		assert.ErrorIs(t, testApp.DB.Delete(&entry).Error, models.ErrAuditLogAppendOnly)
+	})
+}
@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkOperations(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, ownerToken := CreateTestUser(t, testApp, "owner-bulk@test.com", "user")
+	dev, devToken := CreateTestUser(t, testApp, "dev-bulk@test.com", "user")
+	other, _ := CreateTestUser(t, testApp, "other-bulk@test.com", "user")
+	_, outsiderToken := CreateTestUser(t, testApp, "outsider-bulk@test.com", "user")
+	project := CreateTestProject(t, testApp, "Bulk Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+	AddUserToProject(t, testApp, project.ID, other.ID, "team_developer")
+	otherProject := CreateTestProject(t, testApp, "Other Bulk Project", owner.ID)
+	AddUserToProject(t, testApp, otherProject.ID, owner.ID, "product_owner")
+
+	sprint := &models.Sprint{Name: "Sprint 1", ProjectID: project.ID, CreatedByID: owner.ID}
+	require.NoError(t, testApp.DB.Create(sprint).Error)
+	foreignSprint := &models.Sprint{Name: "Foreign Sprint", ProjectID: otherProject.ID, CreatedByID: owner.ID}
+	require.NoError(t, testApp.DB.Create(foreignSprint).Error)
+
+	stories := []*models.UserStory{
+		CreateTestUserStory(t, testApp, "Story A", project.ID),
+		CreateTestUserStory(t, testApp, "Story B", project.ID),
+		CreateTestUserStory(t, testApp, "Story C", project.ID),
+	}
+	foreignStory := CreateTestUserStory(t, testApp, "Foreign Story", otherProject.ID)
+
+	var tasks []*models.Task
+	for i, story := range stories {
+		task := CreateTestTask(t, testApp, fmt.Sprintf("Task %d", i), story.ID, dev.ID)
+		require.NoError(t, testApp.DB.Model(task).Update("status", models.StatusTodo).Error)
+		tasks = append(tasks, task)
+	}
+	foreignTask := CreateTestTask(t, testApp, "Foreign Task", foreignStory.ID, owner.ID)
+
+	storyIDs := []uint{stories[0].ID, stories[1].ID, stories[2].ID}
+	taskIDs := []uint{tasks[0].ID, tasks[1].ID, tasks[2].ID}
+	tasksURL := fmt.Sprintf("/api/projects/%d/tasks/bulk", project.ID)
+	storiesURL := fmt.Sprintf("/api/projects/%d/userstories/bulk", project.ID)
+
+	decodeResult := func(t *testing.T, body []byte) services.BulkResult {
+		var result services.BulkResult
+		require.NoError(t, json.Unmarshal(body, &result))
+		return result
+	}
+	reloadTask := func(id uint) models.Task {
+		var task models.Task
+		require.NoError(t, testApp.DB.Unscoped().First(&task, id).Error)
+		return task
+	}
+
+	t.Run("Moving user stories to a sprint and back to the backlog", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, storiesURL, ownerToken, map[string]interface{}{
+			"ids": storyIDs, "action": "sprint", "sprintId": sprint.ID,
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		result := decodeResult(t, rec.Body.Bytes())
+		assert.True(t, result.Applied)
+		require.Len(t, result.Items, 3)
+
+		var count int64
+		require.NoError(t, testApp.DB.Model(&models.UserStory{}).Where("sprint_id = ?", sprint.ID).Count(&count).Error)
+		assert.EqualValues(t, 3, count)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, storiesURL, ownerToken, map[string]interface{}{
+			"ids": []uint{stories[2].ID}, "action": "sprint", "sprintId": nil,
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var story models.UserStory
+		require.NoError(t, testApp.DB.First(&story, stories[2].ID).Error)
+		assert.Nil(t, story.SprintID)
+	})
+
+	t.Run("A sprint of another project is rejected", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, storiesURL, ownerToken, map[string]interface{}{
+			"ids": storyIDs, "action": "sprint", "sprintId": foreignSprint.ID,
+		})
+		assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	})
+
+	t.Run("Developers cannot run bulk operations on user stories", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, storiesURL, devToken, map[string]interface{}{
+			"ids": storyIDs, "action": "status", "status": "done",
+		})
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, tasksURL, outsiderToken, map[string]interface{}{
+			"ids": taskIDs, "action": "status", "status": "done",
+		})
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	})
+
+	t.Run("Changing the status of tasks records one history entry per task and one event", func(t *testing.T) {
+		client := websocket.NewTestClient(testApp.WSManager, owner.ID, map[uint]bool{project.ID: true})
+		testApp.WSManager.RegisterTestClient(client)
+		time.Sleep(10 * time.Millisecond)
+
+		rec := doEvaluationRequest(testApp, http.MethodPost, tasksURL, devToken, map[string]interface{}{
+			"ids": taskIDs, "action": "status", "status": "in_progress",
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		for _, id := range taskIDs {
+			assert.Equal(t, models.StatusInProgress, reloadTask(id).Status)
+			var history []models.TaskHistory
+			require.NoError(t, testApp.DB.Where("task_id = ? AND field_name = ?", id, "status").Find(&history).Error)
+			require.Len(t, history, 1)
+			assert.Equal(t, "todo", history[0].OldValue)
+			assert.Equal(t, "in_progress", history[0].NewValue)
+			assert.Equal(t, dev.ID, history[0].ChangedByID)
+		}
+
+		select {
+		case msgBytes := <-client.Send:
+			var msg websocket.Message
+			require.NoError(t, json.Unmarshal(msgBytes, &msg))
+			assert.Equal(t, "bulk_update", msg.Type)
+			payload := msg.Payload.(map[string]interface{})
+			assert.Equal(t, "task", payload["entity"])
+			assert.Equal(t, "status", payload["action"])
+			assert.Len(t, payload["items"], 3)
+		case <-time.After(1 * time.Second):
+			t.Fatal("Timed out waiting for the bulk_update message")
+		}
+		select {
+		case msgBytes := <-client.Send:
+			t.Fatalf("expected a single event, got another: %s", msgBytes)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("Reassigning tasks notifies the new assignee once", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, tasksURL, ownerToken, map[string]interface{}{
+			"ids": taskIDs, "action": "assign", "assigneeId": other.ID,
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		for _, id := range taskIDs {
+			task := reloadTask(id)
+			require.NotNil(t, task.AssignedToID)
+			assert.Equal(t, other.ID, *task.AssignedToID)
+		}
+		notifications, err := testApp.NotificationService.GetUserNotifications(other.ID)
+		require.NoError(t, err)
+		assert.Len(t, notifications, 1)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, tasksURL, ownerToken, map[string]interface{}{
+			"ids": []uint{tasks[0].ID}, "action": "assign", "assigneeId": nil,
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Nil(t, reloadTask(tasks[0].ID).AssignedToID)
+	})
+
+	t.Run("Labels are added and removed without duplicates", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, tasksURL, devToken, map[string]interface{}{
+			"ids": taskIDs[:2], "action": "label", "addLabels": []string{"frontend", "blocked", "frontend"},
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		rec = doEvaluationRequest(testApp, http.MethodPost, tasksURL, devToken, map[string]interface{}{
+			"ids": taskIDs[:2], "action": "label", "addLabels": []string{"ux"}, "removeLabels": []string{"blocked"},
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, []string{"frontend", "ux"}, reloadTask(taskIDs[0]).Labels)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, storiesURL, ownerToken, map[string]interface{}{
+			"ids": storyIDs[:1], "action": "label", "addLabels": []string{"mvp"},
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var story models.UserStory
+		require.NoError(t, testApp.DB.First(&story, storyIDs[0]).Error)
+		assert.Equal(t, []string{"mvp"}, story.Labels)
+	})
+
+	t.Run("One foreign id rejects the whole operation", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, tasksURL, ownerToken, map[string]interface{}{
+			"ids": append(append([]uint{}, taskIDs...), foreignTask.ID), "action": "status", "status": "done",
+		})
+		require.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+		var body struct {
+			Result services.BulkResult `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.False(t, body.Result.Applied)
+		require.Len(t, body.Result.Items, 4)
+		assert.False(t, body.Result.Items[3].OK)
+		assert.NotEmpty(t, body.Result.Items[3].Error)
+
+		for _, id := range taskIDs {
+			assert.Equal(t, models.StatusInProgress, reloadTask(id).Status, "nothing is changed")
+		}
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, tasksURL, ownerToken, map[string]interface{}{
+			"ids": []uint{taskIDs[0], taskIDs[0]}, "action": "status", "status": "done",
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "duplicate ids are rejected")
+	})
+
+	t.Run("Deleting user stories moves them and their tasks to the trash", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, storiesURL, ownerToken, map[string]interface{}{
+			"ids": storyIDs[1:], "action": "delete",
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var count int64
+		require.NoError(t, testApp.DB.Model(&models.UserStory{}).Where("project_id = ?", project.ID).Count(&count).Error)
+		assert.EqualValues(t, 1, count)
+		assert.True(t, reloadTask(taskIDs[1]).DeletedAt.Valid)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/userstories/%d/restore", storyIDs[1]), ownerToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.False(t, reloadTask(taskIDs[1]).DeletedAt.Valid, "the tasks come back with their story")
+	})
+
+	t.Run("Invalid requests are rejected", func(t *testing.T) {
+		for name, body := range map[string]map[string]interface{}{
+			"no ids":         {"ids": []uint{}, "action": "delete"},
+			"unknown action": {"ids": taskIDs, "action": "archive"},
+			"bad status":     {"ids": taskIDs, "action": "status", "status": "finished"},
+			"sprint on task": {"ids": taskIDs, "action": "sprint"},
+			"non-member":     {"ids": taskIDs, "action": "assign", "assigneeId": 9999},
+		} {
+			rec := doEvaluationRequest(testApp, http.MethodPost, tasksURL, ownerToken, body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+		}
+	})
+}
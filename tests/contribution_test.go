@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemberContributions(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, _ := CreateTestUser(t, testApp, "owner-contrib@test.com", "user")
+	instructor, instructorToken := CreateTestUser(t, testApp, "instructor-contrib@test.com", "user")
+	alice, aliceToken := CreateTestUser(t, testApp, "alice-contrib@test.com", "user")
+	bob, _ := CreateTestUser(t, testApp, "bob-contrib@test.com", "user")
+	project := CreateTestProject(t, testApp, "Contribution Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, instructor.ID, "instructor")
+	AddUserToProject(t, testApp, project.ID, alice.ID, "team_developer")
+	AddUserToProject(t, testApp, project.ID, bob.ID, "team_developer")
+
+	sprint := &models.Sprint{Name: "Sprint 1", ProjectID: project.ID, CreatedByID: owner.ID}
+	require.NoError(t, testApp.DB.Create(sprint).Error)
+	sprintStory := CreateTestUserStory(t, testApp, "In Sprint", project.ID)
+	require.NoError(t, testApp.DB.Model(sprintStory).Updates(map[string]interface{}{"points": 8, "sprint_id": sprint.ID}).Error)
+	backlogStory := CreateTestUserStory(t, testApp, "In Backlog", project.ID)
+	require.NoError(t, testApp.DB.Model(backlogStory).Update("points", 3).Error)
+
+	createTask := func(title string, storyID, assigneeID uint, status models.TaskStatus, estimated, spent float32) *models.Task {
+		task := CreateTestTask(t, testApp, title, storyID, assigneeID)
+		require.NoError(t, testApp.DB.Model(task).Updates(map[string]interface{}{"status": status, "estimated_hours": estimated, "spent_hours": spent}).Error)
+		return task
+	}
+	reviewed := createTask("Reviewed", sprintStory.ID, alice.ID, models.StatusDone, 4, 5)
+	createTask("Ongoing", sprintStory.ID, bob.ID, models.StatusInProgress, 2, 2)
+	selfMerged := createTask("Self Merged", backlogStory.ID, alice.ID, models.StatusDone, 2, 1)
+
+	base := time.Now().Add(-48 * time.Hour)
+	move := func(task *models.Task, by uint, from, to models.TaskStatus, hours int) {
+		change := models.TaskHistory{TaskID: task.ID, ChangedByID: by, FieldName: "status", OldValue: string(from), NewValue: string(to), ChangedAt: base.Add(time.Duration(hours) * time.Hour)}
+		require.NoError(t, testApp.DB.Create(&change).Error)
+	}
+	move(reviewed, alice.ID, models.StatusTodo, models.StatusInProgress, 0)
+	move(reviewed, alice.ID, models.StatusInProgress, models.StatusInReview, 10)
+	move(reviewed, bob.ID, models.StatusInReview, models.StatusDone, 16)
+	move(selfMerged, alice.ID, models.StatusTodo, models.StatusInProgress, 0)
+	move(selfMerged, alice.ID, models.StatusInProgress, models.StatusInReview, 1)
+	move(selfMerged, alice.ID, models.StatusInReview, models.StatusDone, 4)
+
+	for _, comment := range []models.TaskComment{
+		{TaskID: reviewed.ID, AuthorID: alice.ID, Content: "Ready"},
+		{TaskID: reviewed.ID, AuthorID: alice.ID, Content: "Fixed"},
+		{TaskID: selfMerged.ID, AuthorID: bob.ID, Content: "Nice"},
+		{TaskID: selfMerged.ID, AuthorID: instructor.ID, Content: "Good job"},
+	} {
+		require.NoError(t, testApp.DB.Create(&comment).Error)
+	}
+
+	getReport := func(t *testing.T, path string) map[uint]services.MemberContribution {
+		rec := doEvaluationRequest(testApp, http.MethodGet, path, instructorToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var report services.ContributionReport
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		members := map[uint]services.MemberContribution{}
+		for _, member := range report.Members {
+			members[member.UserID] = member
+		}
+		assert.NotContains(t, members, instructor.ID, "instructors are not graded")
+		return members
+	}
+
+	t.Run("Project contributions", func(t *testing.T) {
+		members := getReport(t, fmt.Sprintf("/api/projects/%d/contributions", project.ID))
+
+		a := members[alice.ID]
+		assert.Equal(t, 2, a.TasksAssigned)
+		assert.Equal(t, 2, a.TasksCompleted)
+		assert.Equal(t, 7.0, a.PointsContributed, "half of the 8-point story plus the 3-point story")
+		assert.Equal(t, 6.0, a.HoursLogged)
+		require.NotNil(t, a.Efficiency)
+		assert.Equal(t, 100.0, *a.Efficiency)
+		require.NotNil(t, a.AverageCycleTimeHours)
+		assert.Equal(t, 10.0, *a.AverageCycleTimeHours)
+		assert.Equal(t, 0, a.ReviewsCompleted, "moving your own task out of review is not a review")
+		assert.Equal(t, 2, a.Comments)
+
+		b := members[bob.ID]
+		assert.Equal(t, 1, b.TasksAssigned)
+		assert.Equal(t, 0, b.TasksCompleted)
+		assert.Nil(t, b.Efficiency)
+		assert.Nil(t, b.AverageCycleTimeHours)
+		assert.Equal(t, 1, b.ReviewsCompleted)
+		require.NotNil(t, b.AverageReviewTurnaroundHours)
+		assert.Equal(t, 6.0, *b.AverageReviewTurnaroundHours)
+		assert.Equal(t, 1, b.Comments)
+	})
+
+	t.Run("Sprint contributions only count the sprint's tasks", func(t *testing.T) {
+		members := getReport(t, fmt.Sprintf("/api/sprints/%d/contributions", sprint.ID))
+
+		a := members[alice.ID]
+		assert.Equal(t, 1, a.TasksCompleted)
+		assert.Equal(t, 4.0, a.PointsContributed)
+		require.NotNil(t, a.Efficiency)
+		assert.Equal(t, 80.0, *a.Efficiency)
+		assert.Equal(t, 16.0, *a.AverageCycleTimeHours)
+		assert.Equal(t, 1, members[bob.ID].ReviewsCompleted)
+		assert.Equal(t, 0, members[bob.ID].Comments)
+	})
+
+	t.Run("User metrics are stored once per day and scope", func(t *testing.T) {
+		getReport(t, fmt.Sprintf("/api/projects/%d/contributions", project.ID))
+
+		var metrics []models.UserMetric
+		require.NoError(t, testApp.DB.Where("user_id = ?", alice.ID).Order("id").Find(&metrics).Error)
+		require.Len(t, metrics, 2, "one for the project and one for the sprint")
+		assert.Nil(t, metrics[0].SprintID)
+		assert.Equal(t, 2, *metrics[0].TasksCompleted)
+		assert.Equal(t, 7, *metrics[0].PointsContributed)
+		assert.Equal(t, 6, *metrics[0].HoursLogged)
+		assert.Equal(t, 100, *metrics[0].Efficiency)
+		require.NotNil(t, metrics[1].SprintID)
+		assert.Equal(t, sprint.ID, *metrics[1].SprintID)
+		assert.Equal(t, 80, *metrics[1].Efficiency)
+	})
+
+	t.Run("Only instructors, scrum masters and admins can see contributions", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/projects/%d/contributions", project.ID), aliceToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = doEvaluationRequest(testApp, http.MethodGet, "/api/sprints/9999/contributions", instructorToken, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
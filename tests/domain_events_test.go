@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := services.NewEventBus()
+	var received []services.PublishedEvent
+	bus.Subscribe(func(event services.PublishedEvent) { received = append(received, event) })
+
+	ctx := storage.WithAuditActor(context.Background(), storage.AuditActor{UserID: 42})
+	bus.WithContext(ctx).Publish(7, services.SprintDeleted{SprintID: 3})
+	bus.Publish(7, services.TaskDeleted{TaskID: 5})
+	bus.Publish(0, services.TaskDeleted{TaskID: 6}) // Not about a project: dropped
+
+	require.Len(t, received, 2)
+	assert.Equal(t, uint(7), received[0].ProjectID)
+	assert.Equal(t, uint(42), received[0].ActorID, "the scoped bus attributes events to the request's actor")
+	assert.Equal(t, services.SprintDeleted{SprintID: 3}, received[0].Event)
+	assert.Equal(t, uint(0), received[1].ActorID)
+	assert.Equal(t, "task_deleted", received[1].Event.EventName())
+
+	// Services work without a bus.
+	var none *services.EventBus
+	none.WithContext(ctx).Publish(7, services.SprintDeleted{SprintID: 3})
+}
+
+func TestDomainEventsReachWebSocketClients(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+	server := httptest.NewServer(testApp.Router)
+	defer server.Close()
+
+	// --- Create Test Data ---
+	admin := &models.User{Nombre: "Events", ApellidoPaterno: "Admin", ApellidoMaterno: "User", Correo: "admin-events@test.com", Contraseña: "secret123"}
+	require.NoError(t, testApp.UserService.CreateAdminUser(admin))
+	rec := doEvaluationRequest(testApp, http.MethodPost, "/login", "", map[string]string{"correo": admin.Correo, "contraseña": "secret123"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	adminToken := login["token"]
+
+	owner, ownerToken := CreateTestUser(t, testApp, "owner-events@test.com", "user")
+	dev, devToken := CreateTestUser(t, testApp, "dev-events@test.com", "user")
+	project := CreateTestProject(t, testApp, "Events Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+	story := CreateTestUserStory(t, testApp, "Story", project.ID)
+	task := CreateTestTask(t, testApp, "Task", story.ID, dev.ID)
+
+	// The developer follows the project from the start.
+	conn := dialWS(t, server, devToken)
+	expect := func(t *testing.T, kind string, by uint) map[string]interface{} {
+		t.Helper()
+		message := conn.nextRaw(t)
+		require.Equal(t, kind, message.Type)
+		assert.Equal(t, project.ID, message.ProjectID)
+		assert.NotZero(t, message.Seq)
+		payload := message.Payload.(map[string]interface{})
+		assert.NotEmpty(t, payload["timestamp"])
+		updatedBy := payload["updatedBy"].(map[string]interface{})
+		assert.Equal(t, float64(by), updatedBy["id"])
+		return payload
+	}
+
+	t.Run("Sprint changes", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/sprints", project.ID), ownerToken, map[string]string{"name": "Sprint A"})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		payload := expect(t, "sprint_created", owner.ID)
+		sprint := payload["sprint"].(map[string]interface{})
+		assert.Equal(t, "Sprint A", sprint["name"])
+
+		rec = doEvaluationRequest(testApp, http.MethodPut, fmt.Sprintf("/api/sprints/%v/status", sprint["id"]), ownerToken, map[string]string{"status": "active"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		payload = expect(t, "sprint_updated", owner.ID)
+		assert.Equal(t, "active", payload["sprint"].(map[string]interface{})["status"])
+	})
+
+	t.Run("User story changes", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/userstories", project.ID), ownerToken, map[string]string{"title": "Live Story"})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		payload := expect(t, "user_story_created", owner.ID)
+		created := payload["userStory"].(map[string]interface{})
+		assert.Equal(t, "Live Story", created["title"])
+
+		rec = doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/userstories/%v", created["id"]), ownerToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		payload = expect(t, "user_story_deleted", owner.ID)
+		assert.Equal(t, created["id"], payload["userStoryId"])
+	})
+
+	t.Run("Comments", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/tasks/%d/comments", task.ID), devToken, map[string]string{"content": "On it"})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		payload := expect(t, "task_comment_added", dev.ID)
+		assert.Equal(t, float64(task.ID), payload["taskId"])
+		assert.Equal(t, "On it", payload["comment"].(map[string]interface{})["content"])
+	})
+
+	t.Run("Calendar events", func(t *testing.T) {
+		start := time.Now().Add(24 * time.Hour)
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/events", project.ID), ownerToken, map[string]string{
+			"title":     "Review",
+			"startDate": start.Format(time.RFC3339),
+			"endDate":   start.Add(time.Hour).Format(time.RFC3339),
+		})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		payload := expect(t, "calendar_event_created", owner.ID)
+		assert.Equal(t, "Review", payload["event"].(map[string]interface{})["title"])
+	})
+
+	t.Run("Membership changes", func(t *testing.T) {
+		newcomer, _ := CreateTestUser(t, testApp, "newcomer-events@test.com", "user")
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/admin/projects/%d/members", project.ID), adminToken,
+			map[string]interface{}{"userId": newcomer.ID, "role": "team_developer"})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		payload := expect(t, "member_added", admin.ID)
+		assert.Equal(t, float64(newcomer.ID), payload["userId"])
+		assert.Equal(t, "team_developer", payload["role"])
+
+		rec = doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/admin/projects/%d/members/%d", project.ID, newcomer.ID), adminToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		payload = expect(t, "member_removed", admin.ID)
+		assert.Equal(t, float64(newcomer.ID), payload["userId"])
+	})
+
+	t.Run("Task events are published by the service", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPut, fmt.Sprintf("/api/tasks/%d/status", task.ID), ownerToken, map[string]string{"status": "in_progress"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		payload := expect(t, "task_status_updated", owner.ID)
+		assert.Equal(t, "todo", payload["oldStatus"])
+		assert.Equal(t, "in_progress", payload["newStatus"])
+		assert.Equal(t, owner.Nombre, payload["updatedBy"].(map[string]interface{})["name"])
+
+		// Setting the same status again changes nothing and announces nothing.
+		rec = doEvaluationRequest(testApp, http.MethodPut, fmt.Sprintf("/api/tasks/%d/status", task.ID), ownerToken, map[string]string{"status": "in_progress"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		conn.expectNothing(t)
+	})
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerEvaluationRounds(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, _ := CreateTestUser(t, testApp, "owner-round@test.com", "user")
+	instructor, instructorToken := CreateTestUser(t, testApp, "instructor-round@test.com", "user")
+	project := CreateTestProject(t, testApp, "Round Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, instructor.ID, "instructor")
+
+	// Five students: with three peers each, student 0 reviews students 1-3 but not student 4.
+	students := make([]*models.User, 5)
+	tokens := make([]string, 5)
+	for i := range students {
+		students[i], tokens[i] = CreateTestUser(t, testApp, fmt.Sprintf("student%d-round@test.com", i), "user")
+		AddUserToProject(t, testApp, project.ID, students[i].ID, "team_developer")
+	}
+
+	sprint := &models.Sprint{Name: "Sprint 1", ProjectID: project.ID, CreatedByID: owner.ID}
+	require.NoError(t, testApp.DB.Create(sprint).Error)
+
+	rubric := &models.Rubric{
+		Name:        "Teamwork",
+		ProjectID:   &project.ID,
+		CreatedByID: owner.ID,
+		Status:      models.RubricStatusActive,
+		Criteria:    []models.RubricCriterion{{Title: "Collaboration", MaxPoints: 10}},
+	}
+	require.NoError(t, testApp.DB.Create(rubric).Error)
+	criterionID := rubric.Criteria[0].ID
+
+	roundsPath := fmt.Sprintf("/api/sprints/%d/evaluation-rounds", sprint.ID)
+	roundReq := services.CreateEvaluationRoundRequest{RubricID: rubric.ID, Anonymous: true, PeersPerMember: 3}
+
+	// evaluate creates and submits a round evaluation, returning its ID.
+	var roundPath string
+	evaluate := func(t *testing.T, from int, evaluateeID uint, score float64) uint {
+		rec := doEvaluationRequest(testApp, http.MethodPost, roundPath+"/evaluations", tokens[from], services.CreateRoundEvaluationRequest{
+			EvaluateeID:          evaluateeID,
+			CriterionEvaluations: []services.CriterionEvaluationRequest{{CriterionID: criterionID, Score: score}},
+		})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var evaluation models.Evaluation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &evaluation))
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/evaluations/%d/submit", evaluation.ID), tokens[from], nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return evaluation.ID
+	}
+
+	t.Run("Students cannot open a round", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, roundsPath, tokens[0], roundReq)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Rejects more peers than participants", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, roundsPath, instructorToken, services.CreateEvaluationRoundRequest{RubricID: rubric.ID, PeersPerMember: 5})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	var round models.EvaluationRound
+	t.Run("Instructor opens a round", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, roundsPath, instructorToken, roundReq)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &round))
+		assert.Len(t, round.Assignments, 15, "Instructors do not participate")
+		assert.Equal(t, models.EvaluationRoundStatusOpen, round.Status)
+	})
+	roundPath = fmt.Sprintf("/api/evaluation-rounds/%d", round.ID)
+
+	t.Run("Only project members list the rounds of a sprint", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, roundsPath, tokens[0], nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var rounds []models.EvaluationRound
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rounds))
+		require.Len(t, rounds, 1)
+		assert.Equal(t, round.ID, rounds[0].ID)
+
+		_, outsiderToken := CreateTestUser(t, testApp, "outsider-round@test.com", "user")
+		rec = doEvaluationRequest(testApp, http.MethodGet, roundsPath, outsiderToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, "/api/sprints/99999/evaluation-rounds", tokens[0], nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Participants only see their own assignments", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, roundPath, tokens[0], nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var own models.EvaluationRound
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &own))
+		require.Len(t, own.Assignments, 3)
+		for _, assignment := range own.Assignments {
+			assert.Equal(t, students[0].ID, assignment.ReviewerID)
+		}
+	})
+
+	t.Run("Cannot review an unassigned member", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, roundPath+"/evaluations", tokens[0], services.CreateRoundEvaluationRequest{EvaluateeID: students[4].ID})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	var outlierID uint
+	t.Run("Peers and self evaluate student 4", func(t *testing.T) {
+		evaluate(t, 1, students[4].ID, 8)
+		evaluate(t, 2, students[4].ID, 8)
+		outlierID = evaluate(t, 3, students[4].ID, 1)
+		selfID := evaluate(t, 4, students[4].ID, 10)
+
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/evaluations/%d/publish", selfID), tokens[4], nil)
+		assert.Equal(t, http.StatusConflict, rec.Code, "Round evaluations are published by closing the round")
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/evaluations/%d", outlierID), tokens[4], nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "Reviewees cannot bypass anonymity")
+	})
+
+	t.Run("Results aggregate scores and flag outliers", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, roundPath+"/results", tokens[4], nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, roundPath+"/results", instructorToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var results services.EvaluationRoundResults
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+		require.Len(t, results.Members, 5)
+
+		member := results.Members[4]
+		assert.Equal(t, students[4].ID, member.UserID)
+		assert.Equal(t, 3, member.ExpectedPeerReviews)
+		assert.Equal(t, 3, member.PeerReviewCount)
+		require.NotNil(t, member.PeerAverage)
+		assert.InDelta(t, 17.0/3, *member.PeerAverage, 0.001)
+		require.NotNil(t, member.AdjustedPeerAverage)
+		assert.Equal(t, 8.0, *member.AdjustedPeerAverage)
+		require.NotNil(t, member.SelfScore)
+		assert.Equal(t, 10.0, *member.SelfScore)
+		require.Len(t, member.Outliers, 1)
+		assert.Equal(t, outlierID, member.Outliers[0].EvaluationID)
+
+		assert.Equal(t, 0, results.Members[0].PeerReviewCount)
+		assert.Nil(t, results.Members[0].PeerAverage)
+	})
+
+	t.Run("Closing publishes anonymously to the reviewee", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, roundPath+"/received", tokens[4], nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var received []services.ReceivedEvaluation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &received))
+		assert.Empty(t, received, "Nothing is visible before the round closes")
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, roundPath+"/close", instructorToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, roundPath+"/received", tokens[4], nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &received))
+		require.Len(t, received, 4)
+		for _, evaluation := range received {
+			if evaluation.Type == models.EvaluationTypePeer {
+				assert.Nil(t, evaluation.EvaluatorID)
+				assert.Empty(t, evaluation.EvaluatorName)
+			}
+		}
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, roundPath+"/evaluations", tokens[0], services.CreateRoundEvaluationRequest{EvaluateeID: students[1].ID})
+		assert.Equal(t, http.StatusConflict, rec.Code, "Closed rounds accept no evaluations")
+	})
+}
@@ -26,7 +26,7 @@ func TestCreateAndGetEvaluation(t *testing.T) {
 	studentUser, _ := CreateTestUser(t, testApp, "student-eval@test.com", "user")
 
 	project := CreateTestProject(t, testApp, "Eval Project", adminUser.ID)
-	AddUserToProject(t, testApp, project.ID, teacherUser.ID, "docente")
+	AddUserToProject(t, testApp, project.ID, teacherUser.ID, "instructor")
 	AddUserToProject(t, testApp, project.ID, studentUser.ID, "team_developer")
 
 	userStory := CreateTestUserStory(t, testApp, "Eval Story", project.ID)
@@ -61,13 +61,14 @@ func TestCreateAndGetEvaluation(t *testing.T) {
 		assert.Equal(t, "Excellent work!", createdEval.OverallFeedback)
 		assert.Equal(t, 9.0, createdEval.TotalScore) // 5 + 4
 		assert.Len(t, createdEval.CriterionEvaluations, 2)
-		assert.Equal(t, task.ID, createdEval.TaskID)
+		require.NotNil(t, createdEval.TaskID)
+		assert.Equal(t, task.ID, *createdEval.TaskID)
 		assert.Equal(t, teacherUser.ID, createdEval.EvaluatorID)
 
 		// --- Test Case 2: Get the created evaluation ---
 		t.Run("Get created evaluation", func(t *testing.T) {
 			reqGet := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/tasks/%d/evaluations", task.ID), nil)
-			reqGet.Header.Set(echo.HeaderAuthorization, "Bearer "+adminToken) // Any valid user can see it
+			reqGet.Header.Set(echo.HeaderAuthorization, "Bearer "+teacherToken) // Drafts are visible to their evaluator
 			recGet := httptest.NewRecorder()
 
 			testApp.Router.ServeHTTP(recGet, reqGet)
@@ -91,11 +92,11 @@ func TestCreateAndGetEvaluation(t *testing.T) {
 		reqBody, _ := json.Marshal(evalReq)
 		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/tasks/%d/evaluations", task.ID), bytes.NewBuffer(reqBody))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
-		req.Header.Set(echo.HeaderAuthorization, "Bearer "+adminToken) // Using admin for simplicity, any non-docente fails
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+adminToken) // Using admin for simplicity, any non-instructor fails
 		rec := httptest.NewRecorder()
 
 		testApp.Router.ServeHTTP(rec, req)
 
-		assert.Equal(t, http.StatusInternalServerError, rec.Code, "Expected error due to permissions")
+		assert.Equal(t, http.StatusForbidden, rec.Code, "Expected error due to permissions")
 	})
 }
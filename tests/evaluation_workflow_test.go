@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doEvaluationRequest sends an authenticated JSON request to the test router.
+func doEvaluationRequest(app *TestApp, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var reqBody bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&reqBody).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &reqBody)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestEvaluationWorkflow(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, _ := CreateTestUser(t, testApp, "owner-flow@test.com", "user")
+	teacher, teacherToken := CreateTestUser(t, testApp, "teacher-flow@test.com", "user")
+	student, studentToken := CreateTestUser(t, testApp, "student-flow@test.com", "user")
+	_, otherTeacherToken := CreateTestUser(t, testApp, "teacher2-flow@test.com", "user")
+	colleague, colleagueToken := CreateTestUser(t, testApp, "colleague-flow@test.com", "user")
+
+	project := CreateTestProject(t, testApp, "Workflow Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, teacher.ID, "instructor")
+	AddUserToProject(t, testApp, project.ID, colleague.ID, "instructor")
+	AddUserToProject(t, testApp, project.ID, student.ID, "team_developer")
+
+	userStory := CreateTestUserStory(t, testApp, "Workflow Story", project.ID)
+	task := CreateTestTask(t, testApp, "Workflow Task", userStory.ID, student.ID)
+
+	rubric := &models.Rubric{
+		Name:        "Leveled Rubric",
+		ProjectID:   &project.ID,
+		CreatedByID: owner.ID,
+		Status:      models.RubricStatusActive,
+		Criteria: []models.RubricCriterion{
+			{Title: "Design", MaxPoints: 10, Levels: []models.RubricCriterionLevel{
+				{Score: 0, Description: "Missing"},
+				{Score: 5, Description: "Partial"},
+				{Score: 10, Description: "Complete"},
+			}},
+			{Title: "Docs", MaxPoints: 4},
+		},
+	}
+	require.NoError(t, testApp.DB.Create(rubric).Error)
+	design, docs := rubric.Criteria[0], rubric.Criteria[1]
+
+	evaluationsPath := fmt.Sprintf("/api/tasks/%d/evaluations", task.ID)
+
+	t.Run("Rejects scores above MaxPoints", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, evaluationsPath, teacherToken, services.CreateEvaluationRequest{
+			RubricID:             rubric.ID,
+			CriterionEvaluations: []services.CriterionEvaluationRequest{{CriterionID: docs.ID, Score: 5}},
+		})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Rejects scores that do not match a level", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, evaluationsPath, teacherToken, services.CreateEvaluationRequest{
+			RubricID:             rubric.ID,
+			CriterionEvaluations: []services.CriterionEvaluationRequest{{CriterionID: design.ID, Score: 7}},
+		})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Rejects inactive rubrics", func(t *testing.T) {
+		draftRubric := &models.Rubric{Name: "Draft Rubric", ProjectID: &project.ID, CreatedByID: owner.ID, Status: models.RubricStatusDraft}
+		require.NoError(t, testApp.DB.Create(draftRubric).Error)
+
+		rec := doEvaluationRequest(testApp, http.MethodPost, evaluationsPath, teacherToken, services.CreateEvaluationRequest{RubricID: draftRubric.ID})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	var evaluation models.Evaluation
+
+	t.Run("Creates a partial draft", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, evaluationsPath, teacherToken, services.CreateEvaluationRequest{
+			RubricID:             rubric.ID,
+			CriterionEvaluations: []services.CriterionEvaluationRequest{{CriterionID: design.ID, Score: 5}},
+		})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &evaluation))
+		assert.Equal(t, models.EvaluationStatusDraft, evaluation.Status)
+		assert.Equal(t, 5.0, evaluation.TotalScore)
+	})
+
+	evaluationPath := fmt.Sprintf("/api/evaluations/%d", evaluation.ID)
+
+	// visibleTo reports whether a user sees the evaluation on both read endpoints.
+	visibleTo := func(t *testing.T, token string) bool {
+		t.Helper()
+		rec := doEvaluationRequest(testApp, http.MethodGet, evaluationsPath, token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var listed []models.Evaluation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, evaluationPath, token, nil)
+		if len(listed) == 0 {
+			assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+			return false
+		}
+		require.Len(t, listed, 1)
+		assert.Equal(t, evaluation.ID, listed[0].ID)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return true
+	}
+
+	t.Run("Drafts are only visible to the evaluator and those who may evaluate", func(t *testing.T) {
+		assert.True(t, visibleTo(t, teacherToken))
+		assert.True(t, visibleTo(t, colleagueToken))
+		assert.False(t, visibleTo(t, studentToken), "the student does not see their grade before it is published")
+		assert.False(t, visibleTo(t, otherTeacherToken))
+
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/tasks/99999/evaluations", teacherToken, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Submit requires every criterion", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, evaluationPath+"/submit", teacherToken, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Only the evaluator can edit the draft", func(t *testing.T) {
+		feedback := "Not mine"
+		rec := doEvaluationRequest(testApp, http.MethodPut, evaluationPath, otherTeacherToken, services.UpdateEvaluationRequest{OverallFeedback: &feedback})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Updates the draft", func(t *testing.T) {
+		feedback := "Solid work"
+		rec := doEvaluationRequest(testApp, http.MethodPut, evaluationPath, teacherToken, services.UpdateEvaluationRequest{
+			OverallFeedback: &feedback,
+			CriterionEvaluations: []services.CriterionEvaluationRequest{
+				{CriterionID: design.ID, Score: 10},
+				{CriterionID: docs.ID, Score: 3.5},
+			},
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var updated models.Evaluation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		assert.Equal(t, "Solid work", updated.OverallFeedback)
+		assert.Equal(t, 13.5, updated.TotalScore)
+		assert.Len(t, updated.CriterionEvaluations, 2)
+	})
+
+	t.Run("Publishing a draft is rejected", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, evaluationPath+"/publish", teacherToken, nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("Submit and publish notify the assignee", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, evaluationPath+"/submit", teacherToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = doEvaluationRequest(testApp, http.MethodPut, evaluationPath, teacherToken, services.UpdateEvaluationRequest{})
+		assert.Equal(t, http.StatusConflict, rec.Code, "Submitted evaluations are no longer editable")
+
+		assert.False(t, visibleTo(t, studentToken), "submitted evaluations are not published yet")
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, evaluationPath+"/publish", teacherToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.True(t, visibleTo(t, studentToken))
+		assert.True(t, visibleTo(t, otherTeacherToken), "published evaluations are visible to everyone")
+
+		var published models.Evaluation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &published))
+		assert.Equal(t, models.EvaluationStatusPublished, published.Status)
+		assert.NotNil(t, published.PublishedAt)
+
+		notifications, err := testApp.NotificationService.GetUserNotifications(student.ID)
+		require.NoError(t, err)
+		require.Len(t, notifications, 1)
+		assert.Equal(t, fmt.Sprintf("/tasks/%d", task.ID), notifications[0].Link)
+	})
+}
@@ -1,16 +1,23 @@
 package tests
 
 import (
+	"archive/zip"
+	"bytes"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func TestExportEndpoint(t *testing.T) {
@@ -68,3 +75,178 @@ func TestExportEndpoint(t *testing.T) {
 	assert.Equal(t, us2.Title, records[2][2])
 	assert.Equal(t, "", records[2][4], "Task ID should be empty for user story with no tasks")
 }
+
+func TestExportFormats(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	user, userToken := CreateTestUser(t, testApp, "export_formats@test.com", "user")
+	project := CreateTestProject(t, testApp, "Formats Project", user.ID)
+	sprint := &models.Sprint{Name: "Sprint <1>", Goal: "Ship it", ProjectID: project.ID, CreatedByID: user.ID}
+	require.NoError(t, testApp.DB.Create(sprint).Error)
+	points := 3
+	story := &models.UserStory{Title: "Planned | Story", ProjectID: project.ID, SprintID: &sprint.ID, Points: &points, Status: "done", CreatedByID: user.ID}
+	require.NoError(t, testApp.DB.Create(story).Error)
+	task := CreateTestTask(t, testApp, "Formats Task", story.ID, user.ID)
+	require.NoError(t, testApp.DB.Model(task).Update("status", models.StatusDone).Error)
+	CreateTestUserStory(t, testApp, "Backlog Story", project.ID)
+
+	export := func(format string) *httptest.ResponseRecorder {
+		return doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/projects/%d/export?format=%s", project.ID, format), userToken, nil)
+	}
+
+	t.Run("XLSX has one sheet each for stories, tasks and sprints", func(t *testing.T) {
+		rec := export("xlsx")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), ".xlsx")
+
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		require.NoError(t, err)
+		parts := map[string]string{}
+		for _, f := range zr.File {
+			r, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(r)
+			require.NoError(t, err)
+			parts[f.Name] = string(content)
+		}
+		assert.Contains(t, parts["xl/workbook.xml"], `name="User Stories"`)
+		assert.Contains(t, parts["xl/workbook.xml"], `name="Tasks"`)
+		assert.Contains(t, parts["xl/workbook.xml"], `name="Sprints"`)
+		assert.Contains(t, parts["xl/worksheets/sheet1.xml"], "Planned | Story")
+		assert.Contains(t, parts["xl/worksheets/sheet2.xml"], "Formats Task")
+		assert.Contains(t, parts["xl/worksheets/sheet3.xml"], "Sprint &lt;1&gt;")
+	})
+
+	t.Run("JSON includes sprint summaries and stories with tasks", func(t *testing.T) {
+		rec := export("json")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var body struct {
+			Sprints     []services.SprintSummary `json:"sprints"`
+			UserStories []struct {
+				Title string        `json:"title"`
+				Tasks []interface{} `json:"tasks"`
+			} `json:"userStories"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Sprints, 1)
+		assert.Equal(t, 3, body.Sprints[0].CompletedPoints)
+		assert.Equal(t, 1, body.Sprints[0].TasksDone)
+		require.Len(t, body.UserStories, 2)
+		assert.Len(t, body.UserStories[0].Tasks, 1)
+	})
+
+	t.Run("Markdown and HTML sprint reports", func(t *testing.T) {
+		rec := export("md")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "## Sprint <1> (planned)")
+		assert.Contains(t, rec.Body.String(), `Planned \| Story`)
+		assert.Contains(t, rec.Body.String(), "| Points | 3 | 3 |")
+
+		rec = export("html")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html"))
+		assert.Contains(t, rec.Body.String(), "Sprint &lt;1&gt;")
+		assert.Contains(t, rec.Body.String(), "@page")
+	})
+
+	t.Run("Rejects unknown formats", func(t *testing.T) {
+		rec := export("pdf")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Report export formats use the same writers", func(t *testing.T) {
+		report := &models.Report{Title: "Weekly", Type: "sprint", ProjectID: &project.ID, ExportFormats: []string{"md", "xlsx"}}
+		files, err := testApp.ExportService.ExportReport(report)
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		assert.Equal(t, "md", files[0].Format)
+		assert.True(t, strings.HasSuffix(files[1].FileName, ".xlsx"))
+
+		report.ExportFormats = []string{"docx"}
+		_, err = testApp.ExportService.ExportReport(report)
+		assert.Error(t, err)
+	})
+}
+
+func TestExportFiltersAndColumns(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	user, userToken := CreateTestUser(t, testApp, "export_filters@test.com", "user")
+	other, _ := CreateTestUser(t, testApp, "export_filters_other@test.com", "user")
+	project := CreateTestProject(t, testApp, "Filters Project", user.ID)
+	sprint := &models.Sprint{Name: "Sprint A", ProjectID: project.ID, CreatedByID: user.ID}
+	require.NoError(t, testApp.DB.Create(sprint).Error)
+	planned := &models.UserStory{Title: "Planned", ProjectID: project.ID, SprintID: &sprint.ID, CreatedByID: user.ID}
+	require.NoError(t, testApp.DB.Create(planned).Error)
+	mine := CreateTestTask(t, testApp, "Mine", planned.ID, user.ID)
+	theirs := CreateTestTask(t, testApp, "Theirs", planned.ID, other.ID)
+	require.NoError(t, testApp.DB.Model(theirs).Update("status", models.StatusDone).Error)
+	for i := 0; i < 5; i++ {
+		story := CreateTestUserStory(t, testApp, fmt.Sprintf("Backlog %d", i), project.ID)
+		CreateTestTask(t, testApp, fmt.Sprintf("Backlog task %d", i), story.ID, user.ID)
+	}
+
+	exportCSV := func(query string) [][]string {
+		rec := doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/projects/%d/export?%s", project.ID, query), userToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		return records
+	}
+
+	t.Run("Uses one query for the rows", func(t *testing.T) {
+		queries := 0
+		count := func(*gorm.DB) { queries++ }
+		require.NoError(t, testApp.DB.Callback().Query().After("gorm:query").Register("test:count_export_queries", count))
+		require.NoError(t, testApp.DB.Callback().Row().After("gorm:row").Register("test:count_export_rows", count))
+		defer testApp.DB.Callback().Query().Remove("test:count_export_queries")
+		defer testApp.DB.Callback().Row().Remove("test:count_export_rows")
+
+		data, err := testApp.ExportService.ExportProjectToCSV(project.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 8, strings.Count(string(data), "\n"), "header plus one row per task")
+		before := queries
+
+		story := CreateTestUserStory(t, testApp, "One more", project.ID)
+		CreateTestTask(t, testApp, "One more task", story.ID, user.ID)
+		queries = 0
+		_, err = testApp.ExportService.ExportProjectToCSV(project.ID)
+		require.NoError(t, err)
+		assert.Equal(t, before, queries, "the number of queries does not grow with the stories")
+		require.NoError(t, testApp.DB.Delete(story).Error)
+	})
+
+	t.Run("Filters by sprint, status and assignee", func(t *testing.T) {
+		records := exportCSV(fmt.Sprintf("sprintId=%d", sprint.ID))
+		assert.Len(t, records, 3)
+
+		records = exportCSV("status=done")
+		require.Len(t, records, 2)
+		assert.Equal(t, fmt.Sprintf("%d", theirs.ID), records[1][4])
+
+		records = exportCSV(fmt.Sprintf("sprintId=%d&assigneeId=%d", sprint.ID, user.ID))
+		require.Len(t, records, 2)
+		assert.Equal(t, fmt.Sprintf("%d", mine.ID), records[1][4])
+
+		records = exportCSV("assigneeId=none")
+		assert.Len(t, records, 1, "only the header")
+	})
+
+	t.Run("Selects columns", func(t *testing.T) {
+		records := exportCSV(fmt.Sprintf("sprintId=%d&columns=task_title,sprint,story_title", sprint.ID))
+		require.Len(t, records, 3)
+		assert.Equal(t, []string{"Task Title", "Sprint", "User Story Title"}, records[0])
+		assert.Equal(t, []string{"Mine", "Sprint A", "Planned"}, records[1])
+	})
+
+	t.Run("Rejects bad parameters", func(t *testing.T) {
+		for _, query := range []string{"columns=nope", "status=finished", "sprintId=x", "assigneeId=x"} {
+			rec := doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/projects/%d/export?%s", project.ID, query), userToken, nil)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		}
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/projects/9999/export", userToken, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
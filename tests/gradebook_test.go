@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGradebook(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, _ := CreateTestUser(t, testApp, "owner-grades@test.com", "user")
+	instructorA, instructorToken := CreateTestUser(t, testApp, "instructorA-grades@test.com", "user")
+	instructorB, _ := CreateTestUser(t, testApp, "instructorB-grades@test.com", "user")
+	scrumMaster, scrumMasterToken := CreateTestUser(t, testApp, "sm-grades@test.com", "user")
+	student1, student1Token := CreateTestUser(t, testApp, "student1-grades@test.com", "user")
+	student2, _ := CreateTestUser(t, testApp, "student2-grades@test.com", "user")
+
+	project := CreateTestProject(t, testApp, "Gradebook Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, instructorA.ID, "instructor")
+	AddUserToProject(t, testApp, project.ID, instructorB.ID, "instructor")
+	AddUserToProject(t, testApp, project.ID, scrumMaster.ID, "scrum_master")
+	AddUserToProject(t, testApp, project.ID, student1.ID, "team_developer")
+	AddUserToProject(t, testApp, project.ID, student2.ID, "team_developer")
+
+	userStory := CreateTestUserStory(t, testApp, "Gradebook Story", project.ID)
+	task1 := CreateTestTask(t, testApp, "Report", userStory.ID, student1.ID)
+	task2 := CreateTestTask(t, testApp, "Prototype", userStory.ID, student2.ID)
+	require.NoError(t, testApp.DB.Model(&models.Task{}).Where("id IN ?", []uint{task1.ID, task2.ID}).Update("is_deliverable", true).Error)
+
+	rubric := &models.Rubric{
+		Name:        "Deliverable Rubric",
+		ProjectID:   &project.ID,
+		CreatedByID: owner.ID,
+		Status:      models.RubricStatusActive,
+		Criteria:    []models.RubricCriterion{{Title: "Content", MaxPoints: 10}, {Title: "Format", MaxPoints: 10}},
+	}
+	require.NoError(t, testApp.DB.Create(rubric).Error)
+	content, format := rubric.Criteria[0].ID, rubric.Criteria[1].ID
+
+	// grade creates and submits a task evaluation with the given criterion scores.
+	grade := func(taskID, evaluatorID uint, contentScore, formatScore float64) uint {
+		evaluation, err := testApp.EvaluationService.CreateEvaluation(taskID, evaluatorID, services.CreateEvaluationRequest{
+			RubricID: rubric.ID,
+			CriterionEvaluations: []services.CriterionEvaluationRequest{
+				{CriterionID: content, Score: contentScore},
+				{CriterionID: format, Score: formatScore},
+			},
+		})
+		require.NoError(t, err)
+		_, err = testApp.EvaluationService.SubmitEvaluation(evaluation.ID, evaluatorID)
+		require.NoError(t, err)
+		return evaluation.ID
+	}
+	evaluationA := grade(task1.ID, instructorA.ID, 8, 8) // 16/20
+	evaluationB := grade(task1.ID, instructorB.ID, 6, 6) // 12/20 -> task1 mean 14/20
+
+	// A draft must not show up in the gradebook.
+	_, err := testApp.EvaluationService.CreateEvaluation(task2.ID, instructorA.ID, services.CreateEvaluationRequest{RubricID: rubric.ID})
+	require.NoError(t, err)
+
+	gradebookPath := fmt.Sprintf("/api/projects/%d/gradebook", project.ID)
+
+	t.Run("Students cannot view the gradebook", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, gradebookPath, student1Token, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Scrum master only sees published evaluations", func(t *testing.T) {
+		// The project's instructors_only policy keeps scrum masters from evaluating,
+		// so submitted grades stay hidden from them until they are published.
+		rec := doEvaluationRequest(testApp, http.MethodGet, gradebookPath, scrumMasterToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var gradebook services.Gradebook
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &gradebook))
+		assert.Len(t, gradebook.Tasks, 2)
+		for _, row := range gradebook.Rows {
+			assert.Empty(t, row.Entries)
+		}
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, gradebookPath+"/export", scrumMasterToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		for _, record := range records[1:] {
+			if record[1] == student1.Correo {
+				assert.Equal(t, []string{"", "", "0", "0", ""}, record[2:])
+			}
+		}
+
+		_, err = testApp.EvaluationService.PublishEvaluation(evaluationA, instructorA.ID)
+		require.NoError(t, err)
+		_, err = testApp.EvaluationService.PublishEvaluation(evaluationB, instructorB.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("Scrum master sees members by tasks", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, gradebookPath, scrumMasterToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var gradebook services.Gradebook
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &gradebook))
+		require.Len(t, gradebook.Tasks, 2)
+		require.Len(t, gradebook.Rows, 3, "Instructors are not graded")
+
+		rows := make(map[uint]services.GradebookRow)
+		for _, row := range gradebook.Rows {
+			rows[row.UserID] = row
+		}
+
+		row1 := rows[student1.ID]
+		require.Len(t, row1.Entries, 2)
+		assert.Equal(t, 1, row1.Entries[0].RubricVersion)
+		assert.Equal(t, 80.0, row1.Entries[0].Percentage)
+		assert.Len(t, row1.Entries[0].Criteria, 2)
+		assert.Equal(t, 14.0, row1.TotalScore)
+		assert.Equal(t, 20.0, row1.MaxScore)
+		require.NotNil(t, row1.WeightedAverage)
+		assert.Equal(t, 70.0, *row1.WeightedAverage)
+
+		row2 := rows[student2.ID]
+		assert.Empty(t, row2.Entries)
+		assert.Nil(t, row2.WeightedAverage)
+	})
+
+	t.Run("Exports CSV", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, gradebookPath+"/export", instructorToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 4)
+		assert.Equal(t, []string{
+			"Member", "Email", fmt.Sprintf("Report (#%d)", task1.ID), fmt.Sprintf("Prototype (#%d)", task2.ID),
+			"Total Score", "Max Score", "Weighted Average (%)",
+		}, records[0])
+
+		for _, record := range records[1:] {
+			if record[1] == student1.Correo {
+				assert.Equal(t, []string{"14", "", "14", "20", "70.00"}, record[2:])
+			}
+		}
+	})
+
+	t.Run("Exports XLSX with matrix and details sheets", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, gradebookPath+"/export?format=xlsx", instructorToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.True(t, strings.HasSuffix(rec.Header().Get("Content-Disposition"), ".xlsx\""))
+
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		require.NoError(t, err)
+		parts := make(map[string]string)
+		for _, file := range zr.File {
+			f, err := file.Open()
+			require.NoError(t, err)
+			body, _ := io.ReadAll(f)
+			f.Close()
+			parts[file.Name] = string(body)
+		}
+		assert.Contains(t, parts["xl/workbook.xml"], `name="Gradebook"`)
+		assert.Contains(t, parts["xl/workbook.xml"], `name="Details"`)
+		assert.Contains(t, parts["xl/worksheets/sheet1.xml"], student1.Correo)
+		assert.Contains(t, parts["xl/worksheets/sheet2.xml"], "Content")
+	})
+
+	t.Run("Rejects unknown formats", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, gradebookPath+"/export?format=pdf", instructorToken, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Tasks in the trash are left out", func(t *testing.T) {
+		extra := CreateTestTask(t, testApp, "Extra Credit", userStory.ID, student1.ID)
+		grade(extra.ID, instructorA.ID, 10, 10)
+		loadTasks := func() []services.GradebookTask {
+			rec := doEvaluationRequest(testApp, http.MethodGet, gradebookPath, instructorToken, nil)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var gradebook services.Gradebook
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &gradebook))
+			return gradebook.Tasks
+		}
+		require.Len(t, loadTasks(), 3, "evaluated tasks get a column")
+
+		rec := doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/tasks/%d", extra.ID), scrumMasterToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		assert.Len(t, loadTasks(), 2)
+		evaluations, err := storage.NewEvaluationRepository(testApp.DB).GetTaskEvaluationsByProjectID(project.ID)
+		require.NoError(t, err)
+		for _, evaluation := range evaluations {
+			assert.NotEqual(t, extra.ID, *evaluation.TaskID, "the evaluations of trashed tasks are not loaded")
+		}
+
+		projectID, err := testApp.ProjectService.GetProjectIDFor("task", extra.ID)
+		require.NoError(t, err)
+		assert.Zero(t, projectID, "trashed tasks belong to no project")
+	})
+}
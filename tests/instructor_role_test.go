@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstructorRoleAndEvaluationPolicy(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	owner, _ := CreateTestUser(t, testApp, "owner-policy@test.com", "user")
+	instructor, instructorToken := CreateTestUser(t, testApp, "instructor-policy@test.com", "user")
+	scrumMaster, scrumMasterToken := CreateTestUser(t, testApp, "sm-policy@test.com", "user")
+	developer, developerToken := CreateTestUser(t, testApp, "dev-policy@test.com", "user")
+
+	project := CreateTestProject(t, testApp, "Policy Project", owner.ID)
+	_, err := testApp.ProjectService.AddMemberToProject(project.ID, instructor.ID, string(models.RoleInstructor))
+	require.NoError(t, err, "instructor must be a valid project role")
+	AddUserToProject(t, testApp, project.ID, scrumMaster.ID, "scrum_master")
+	AddUserToProject(t, testApp, project.ID, developer.ID, "team_developer")
+
+	userStory := CreateTestUserStory(t, testApp, "Policy Story", project.ID)
+	task := CreateTestTask(t, testApp, "Policy Task", userStory.ID, developer.ID)
+	rubric := &models.Rubric{Name: "Policy Rubric", ProjectID: &project.ID, CreatedByID: owner.ID, Status: models.RubricStatusActive}
+	require.NoError(t, testApp.DB.Create(rubric).Error)
+
+	evaluationsPath := fmt.Sprintf("/api/tasks/%d/evaluations", task.ID)
+	policyPath := fmt.Sprintf("/api/projects/%d/evaluation-policy", project.ID)
+	evalReq := services.CreateEvaluationRequest{RubricID: rubric.ID}
+
+	t.Run("Defaults to instructors only", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, evaluationsPath, scrumMasterToken, evalReq)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, evaluationsPath, instructorToken, evalReq)
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	})
+
+	t.Run("Only privileged users can change the policy", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPut, policyPath, developerToken, map[string]string{"policy": "instructors_and_scrum_master"})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Rejects unknown policies", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPut, policyPath, instructorToken, map[string]string{"policy": "everyone"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Scrum master can evaluate once the policy allows it", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPut, policyPath, instructorToken, map[string]string{"policy": "instructors_and_scrum_master"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, evaluationsPath, scrumMasterToken, evalReq)
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, evaluationsPath, developerToken, evalReq)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPagination(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	admin := &models.User{Nombre: "List", ApellidoPaterno: "Admin", ApellidoMaterno: "User", Correo: "admin-list@test.com", Contraseña: "secret123"}
+	require.NoError(t, testApp.UserService.CreateAdminUser(admin))
+	rec := doEvaluationRequest(testApp, http.MethodPost, "/login", "", map[string]string{"correo": admin.Correo, "contraseña": "secret123"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	adminToken := login["token"]
+
+	owner, ownerToken := CreateTestUser(t, testApp, "owner-list@test.com", "user")
+	dev, _ := CreateTestUser(t, testApp, "dev-list@test.com", "user")
+	for i := 0; i < 4; i++ {
+		CreateTestProject(t, testApp, fmt.Sprintf("Project %c", 'A'+i), owner.ID)
+	}
+	project := CreateTestProject(t, testApp, "Listed Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+
+	sprint := &models.Sprint{Name: "Sprint 1", ProjectID: project.ID, CreatedByID: owner.ID}
+	require.NoError(t, testApp.DB.Create(sprint).Error)
+
+	var stories []*models.UserStory
+	for i, priority := range []string{"low", "high", "medium"} {
+		story := CreateTestUserStory(t, testApp, fmt.Sprintf("Story %d", i), project.ID)
+		require.NoError(t, testApp.DB.Model(story).Update("priority", priority).Error)
+		stories = append(stories, story)
+	}
+	require.NoError(t, testApp.DB.Model(stories[0]).Update("sprint_id", sprint.ID).Error)
+
+	for i, status := range []models.TaskStatus{models.StatusTodo, models.StatusDone, models.StatusTodo, models.StatusInProgress} {
+		task := CreateTestTask(t, testApp, fmt.Sprintf("Task %d", i), stories[0].ID, dev.ID)
+		require.NoError(t, testApp.DB.Model(task).Update("status", status).Error)
+		if i == 3 {
+			require.NoError(t, testApp.DB.Model(task).Update("assigned_to_id", nil).Error)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		_, err := testApp.NotificationService.CreateNotification(owner.ID, fmt.Sprintf("Notification %d", i), "")
+		require.NoError(t, err)
+	}
+	notifications, err := testApp.NotificationService.GetUserNotifications(owner.ID)
+	require.NoError(t, err)
+	require.NoError(t, testApp.NotificationService.MarkNotificationAsRead(notifications[0].ID, owner.ID))
+
+	getPage := func(t *testing.T, path, token string, items interface{}) (total int64, limit, offset int) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, path, token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page struct {
+			Items  json.RawMessage `json:"items"`
+			Total  int64           `json:"total"`
+			Limit  int             `json:"limit"`
+			Offset int             `json:"offset"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		require.NoError(t, json.Unmarshal(page.Items, items))
+		return page.Total, page.Limit, page.Offset
+	}
+
+	t.Run("Projects are paged with the total count", func(t *testing.T) {
+		var projects []models.Project
+		total, limit, offset := getPage(t, "/api/projects?limit=2&offset=2&sort=name", ownerToken, &projects)
+		assert.EqualValues(t, 5, total)
+		assert.Equal(t, 2, limit)
+		assert.Equal(t, 2, offset)
+		require.Len(t, projects, 2)
+		assert.Equal(t, "Project B", projects[0].Name, "\"Listed Project\" sorts first")
+		assert.Equal(t, "Project C", projects[1].Name)
+
+		total, limit, _ = getPage(t, "/api/projects?q=listed", ownerToken, &projects)
+		assert.EqualValues(t, 1, total)
+		assert.Equal(t, 50, limit, "the default limit is applied")
+		require.Len(t, projects, 1)
+		assert.Equal(t, project.ID, projects[0].ID)
+
+		_, limit, _ = getPage(t, "/api/projects?limit=1000", ownerToken, &projects)
+		assert.Equal(t, 200, limit, "the limit is capped")
+	})
+
+	t.Run("Users can be filtered, searched and sorted by admins", func(t *testing.T) {
+		var users []models.User
+		total, _, _ := getPage(t, "/api/admin/users?role=admin", adminToken, &users)
+		assert.EqualValues(t, 1, total)
+
+		total, _, _ = getPage(t, "/api/admin/users?q=DEV-LIST", adminToken, &users)
+		assert.EqualValues(t, 1, total)
+		require.Len(t, users, 1)
+		assert.Equal(t, dev.ID, users[0].ID)
+		assert.Empty(t, users[0].Contraseña)
+
+		getPage(t, "/api/admin/users?sort=-email", adminToken, &users)
+		require.Len(t, users, 3)
+		assert.Equal(t, owner.ID, users[0].ID)
+	})
+
+	t.Run("User stories filter by priority, sprint and backlog", func(t *testing.T) {
+		path := fmt.Sprintf("/api/projects/%d/userstories", project.ID)
+		var page []models.UserStory
+		total, _, _ := getPage(t, path+"?priority=high", ownerToken, &page)
+		assert.EqualValues(t, 1, total)
+		assert.Equal(t, stories[1].ID, page[0].ID)
+
+		total, _, _ = getPage(t, fmt.Sprintf("%s?sprintId=%d", path, sprint.ID), ownerToken, &page)
+		assert.EqualValues(t, 1, total)
+		total, _, _ = getPage(t, path+"?sprintId=none&sort=-id", ownerToken, &page)
+		assert.EqualValues(t, 2, total)
+		assert.Equal(t, stories[2].ID, page[0].ID)
+	})
+
+	t.Run("Task lists share the status and assignee filters", func(t *testing.T) {
+		var tasks []models.Task
+		storyPath := fmt.Sprintf("/api/userstories/%d/tasks", stories[0].ID)
+		total, _, _ := getPage(t, storyPath+"?status=todo", ownerToken, &tasks)
+		assert.EqualValues(t, 2, total)
+		total, _, _ = getPage(t, storyPath+"?assigneeId=none", ownerToken, &tasks)
+		assert.EqualValues(t, 1, total)
+		total, _, _ = getPage(t, fmt.Sprintf("%s?assigneeId=%d&limit=1", storyPath, dev.ID), ownerToken, &tasks)
+		assert.EqualValues(t, 3, total)
+		assert.Len(t, tasks, 1)
+
+		sprintPath := fmt.Sprintf("/api/sprints/%d/tasks", sprint.ID)
+		total, _, _ = getPage(t, sprintPath+"?status=done", ownerToken, &tasks)
+		assert.EqualValues(t, 1, total)
+		getPage(t, sprintPath+"?sort=title", ownerToken, &tasks)
+		require.Len(t, tasks, 4)
+		assert.Equal(t, "Task 0", tasks[0].Title)
+		assert.Equal(t, project.ID, tasks[0].UserStory.Project.ID, "relationships are still preloaded")
+	})
+
+	t.Run("Notifications filter by read state", func(t *testing.T) {
+		var page []models.Notification
+		total, _, _ := getPage(t, "/api/notifications?read=false", ownerToken, &page)
+		assert.EqualValues(t, 2, total)
+		total, _, _ = getPage(t, "/api/notifications?read=true", ownerToken, &page)
+		assert.EqualValues(t, 1, total)
+
+		var empty services.Page[models.Notification]
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/notifications?offset=10", ownerToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &empty))
+		assert.NotNil(t, empty.Items, "an empty page still lists its items as []")
+		assert.EqualValues(t, 3, empty.Total)
+	})
+
+	t.Run("Invalid list parameters return 400", func(t *testing.T) {
+		for _, path := range []string{
+			"/api/projects?sort=password",
+			"/api/projects?limit=ten",
+			fmt.Sprintf("/api/userstories/%d/tasks?status=finished", stories[0].ID),
+			fmt.Sprintf("/api/sprints/%d/tasks?assigneeId=someone", sprint.ID),
+			fmt.Sprintf("/api/projects/%d/userstories?sort=-unknown", project.ID),
+			"/api/notifications?read=maybe",
+		} {
+			rec := doEvaluationRequest(testApp, http.MethodGet, path, ownerToken, nil)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+		}
+	})
+}
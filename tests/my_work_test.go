@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMyWork(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, _ := CreateTestUser(t, testApp, "owner-work@test.com", "user")
+	dev, devToken := CreateTestUser(t, testApp, "dev-work@test.com", "user")
+	alpha := CreateTestProject(t, testApp, "Alpha", owner.ID)
+	beta := CreateTestProject(t, testApp, "Beta", owner.ID)
+	archived := CreateTestProject(t, testApp, "Archived", owner.ID)
+	foreign := CreateTestProject(t, testApp, "Foreign", owner.ID)
+	for _, project := range []*models.Project{alpha, beta, archived} {
+		AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+	}
+
+	now := time.Now()
+	lastWeek, nextWeek := now.AddDate(0, 0, -7), now.AddDate(0, 0, 7)
+	endedSprint := &models.Sprint{Name: "Ended", ProjectID: alpha.ID, CreatedByID: owner.ID, EndDate: &lastWeek}
+	require.NoError(t, testApp.DB.Create(endedSprint).Error)
+	currentSprint := &models.Sprint{Name: "Current", ProjectID: beta.ID, CreatedByID: owner.ID, EndDate: &nextWeek}
+	require.NoError(t, testApp.DB.Create(currentSprint).Error)
+
+	alphaStory := CreateTestUserStory(t, testApp, "Alpha Story", alpha.ID)
+	require.NoError(t, testApp.DB.Model(alphaStory).Updates(map[string]interface{}{"sprint_id": endedSprint.ID, "assigned_to_id": dev.ID}).Error)
+	betaStory := CreateTestUserStory(t, testApp, "Beta Story", beta.ID)
+	require.NoError(t, testApp.DB.Model(betaStory).Update("sprint_id", currentSprint.ID).Error)
+	archivedStory := CreateTestUserStory(t, testApp, "Archived Story", archived.ID)
+
+	createTask := func(title string, storyID uint, status models.TaskStatus) *models.Task {
+		task := CreateTestTask(t, testApp, title, storyID, dev.ID)
+		require.NoError(t, testApp.DB.Model(task).Update("status", status).Error)
+		return task
+	}
+	lateTask := createTask("Late", alphaStory.ID, models.StatusInProgress)
+	createTask("Finished", alphaStory.ID, models.StatusDone)
+	createTask("Planned", betaStory.ID, models.StatusTodo)
+	createTask("Reviewing", betaStory.ID, models.StatusInReview)
+	createTask("Frozen", archivedStory.ID, models.StatusTodo)
+	CreateTestTask(t, testApp, "Someone else's", betaStory.ID, owner.ID)
+	require.NoError(t, testApp.DB.Model(archived).Update("status", models.ProjectStatusArchived).Error)
+
+	for _, event := range []models.Event{
+		{Title: "Demo", StartDate: now.AddDate(0, 0, 3), EndDate: now.AddDate(0, 0, 3).Add(time.Hour), ProjectID: alpha.ID, CreatedByID: owner.ID},
+		{Title: "Far Away", StartDate: now.AddDate(0, 0, 30), EndDate: now.AddDate(0, 0, 30).Add(time.Hour), ProjectID: beta.ID, CreatedByID: owner.ID},
+		{Title: "Not Mine", StartDate: now.AddDate(0, 0, 2), EndDate: now.AddDate(0, 0, 2).Add(time.Hour), ProjectID: foreign.ID, CreatedByID: owner.ID},
+	} {
+		require.NoError(t, testApp.DB.Create(&event).Error)
+	}
+	for i := 0; i < 3; i++ {
+		_, err := testApp.NotificationService.CreateNotification(dev.ID, "Hello", "")
+		require.NoError(t, err)
+	}
+	notifications, err := testApp.NotificationService.GetUserNotifications(dev.ID)
+	require.NoError(t, err)
+	require.NoError(t, testApp.NotificationService.MarkNotificationAsRead(notifications[0].ID, dev.ID))
+
+	getWork := func(t *testing.T, query string) services.MyWork {
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/me/work"+query, devToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var work services.MyWork
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &work))
+		return work
+	}
+
+	t.Run("Tasks are grouped by project and status", func(t *testing.T) {
+		work := getWork(t, "")
+		require.Len(t, work.Projects, 2, "archived projects are left out")
+		assert.Equal(t, alpha.ID, work.Projects[0].ProjectID)
+		assert.Equal(t, 2, work.Projects[0].TaskCount)
+		assert.Len(t, work.Projects[0].TasksByStatus["in_progress"], 1)
+		assert.Len(t, work.Projects[0].TasksByStatus["done"], 1)
+		assert.Equal(t, "Beta", work.Projects[1].ProjectName)
+		assert.Len(t, work.Projects[1].TasksByStatus["todo"], 1)
+		assert.Len(t, work.Projects[1].TasksByStatus["in_review"], 1)
+	})
+
+	t.Run("Stories, events, notifications and overdue items", func(t *testing.T) {
+		work := getWork(t, "")
+		require.Len(t, work.Stories, 1)
+		assert.Equal(t, alphaStory.ID, work.Stories[0].ID)
+		assert.Equal(t, 2, work.Stories[0].TasksTotal)
+		assert.Equal(t, 1, work.Stories[0].TasksDone)
+		assert.True(t, work.Stories[0].Overdue)
+
+		require.Len(t, work.UpcomingEvents, 1, "only events of the caller's projects within 14 days")
+		assert.Equal(t, "Demo", work.UpcomingEvents[0].Title)
+		assert.Len(t, getWork(t, "?days=45").UpcomingEvents, 2)
+
+		assert.EqualValues(t, 2, work.UnreadNotifications)
+
+		require.Len(t, work.Overdue, 2, "the late task and its story; done tasks are not overdue")
+		kinds := map[string]uint{}
+		for _, item := range work.Overdue {
+			kinds[item.Kind] = item.ID
+			assert.Equal(t, 7, item.DaysOverdue)
+		}
+		assert.Equal(t, lateTask.ID, kinds["task"])
+		assert.Equal(t, alphaStory.ID, kinds["user_story"])
+	})
+
+	t.Run("The number of queries does not grow with the work", func(t *testing.T) {
+		queries := 0
+		count := func(*gorm.DB) { queries++ }
+		require.NoError(t, testApp.DB.Callback().Query().After("gorm:query").Register("test:count_work_queries", count))
+		require.NoError(t, testApp.DB.Callback().Row().After("gorm:row").Register("test:count_work_rows", count))
+		defer testApp.DB.Callback().Query().Remove("test:count_work_queries")
+		defer testApp.DB.Callback().Row().Remove("test:count_work_rows")
+
+		getWork(t, "")
+		before := queries
+		createTask("One more", betaStory.ID, models.StatusTodo)
+		queries = 0
+		getWork(t, "")
+		assert.Equal(t, before, queries)
+	})
+
+	t.Run("Invalid days are rejected", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/me/work?days=365", devToken, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
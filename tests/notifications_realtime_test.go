@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buga/API_wrkf/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationsArePushedOverWebSocket(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+	server := httptest.NewServer(testApp.Router)
+	defer server.Close()
+
+	// --- Create Test Data ---
+	user, userToken := CreateTestUser(t, testApp, "live-noti@test.com", "user")
+	_, otherToken := CreateTestUser(t, testApp, "live-noti-other@test.com", "user")
+
+	// The user has two devices connected; the other user one.
+	laptop := dialWS(t, server, userToken)
+	phone := dialWS(t, server, userToken)
+	otherConn := dialWS(t, server, otherToken)
+
+	expect := func(t *testing.T, conn *wsTestConn, kind string) map[string]interface{} {
+		t.Helper()
+		message := conn.nextRaw(t)
+		require.Equal(t, kind, message.Type)
+		assert.Zero(t, message.ProjectID, "user messages belong to no project")
+		assert.Zero(t, message.Seq, "user messages are not numbered")
+		payload := message.Payload.(map[string]interface{})
+		assert.NotEmpty(t, payload["timestamp"])
+		return payload
+	}
+
+	var first, second uint
+	t.Run("New notifications reach every connection of the recipient", func(t *testing.T) {
+		notification, err := testApp.NotificationService.CreateNotification(user.ID, "You were assigned a task", "/tasks/1")
+		require.NoError(t, err)
+		first = notification.ID
+		for _, conn := range []*wsTestConn{laptop, phone} {
+			payload := expect(t, conn, "notification_created")
+			assert.Equal(t, float64(1), payload["unreadCount"])
+			created := payload["notification"].(map[string]interface{})
+			assert.Equal(t, float64(notification.ID), created["ID"])
+			assert.Equal(t, "You were assigned a task", created["message"])
+		}
+
+		notification, err = testApp.NotificationService.CreateNotification(user.ID, "A comment was added", "/tasks/1")
+		require.NoError(t, err)
+		second = notification.ID
+		for _, conn := range []*wsTestConn{laptop, phone} {
+			assert.Equal(t, float64(2), expect(t, conn, "notification_created")["unreadCount"])
+		}
+	})
+
+	t.Run("Reading a notification syncs the other devices", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/notifications/%d/read", first), userToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		for _, conn := range []*wsTestConn{laptop, phone} {
+			payload := expect(t, conn, "notifications_read")
+			assert.Equal(t, []interface{}{float64(first)}, payload["notificationIds"])
+			assert.Equal(t, false, payload["all"])
+			assert.Equal(t, float64(1), payload["unreadCount"])
+		}
+
+		// Reading it again, or reading someone else's notification, changes nothing and
+		// announces nothing: the next message is the one of the next subtest.
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/notifications/%d/read", first), userToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/notifications/%d/read", second), otherToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	})
+
+	t.Run("Reading all notifications syncs the other devices", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, "/api/notifications/read/all", userToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		for _, conn := range []*wsTestConn{laptop, phone} {
+			payload := expect(t, conn, "notifications_read")
+			assert.Equal(t, true, payload["all"])
+			assert.Equal(t, float64(0), payload["unreadCount"])
+		}
+	})
+
+	t.Run("Deleting a notification updates the count", func(t *testing.T) {
+		notification, err := testApp.NotificationService.CreateNotification(user.ID, "Sprint starts tomorrow", "")
+		require.NoError(t, err)
+		expect(t, laptop, "notification_created")
+		expect(t, phone, "notification_created")
+
+		require.NoError(t, testApp.NotificationService.DeleteNotification(notification.ID, user.ID))
+		for _, conn := range []*wsTestConn{laptop, phone} {
+			payload := expect(t, conn, "notification_deleted")
+			assert.Equal(t, float64(notification.ID), payload["notificationId"])
+			assert.Equal(t, float64(0), payload["unreadCount"])
+		}
+	})
+
+	t.Run("User channels cannot be subscribed to", func(t *testing.T) {
+		otherConn.send(t, websocket.ClientMessage{Type: "subscribe", RequestID: "s1", Channel: fmt.Sprintf("user:%d", user.ID)})
+		kind, payload := otherConn.next(t)
+		assert.Equal(t, "error", kind)
+		assert.Equal(t, "s1", payload["requestId"])
+		assert.Contains(t, payload["error"], "invalid channel kind")
+
+		_, err := testApp.NotificationService.CreateNotification(user.ID, "Private", "")
+		require.NoError(t, err)
+		expect(t, laptop, "notification_created")
+		// The other user got none of the messages above.
+		otherConn.expectNothing(t)
+	})
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectArchive(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, ownerToken := CreateTestUser(t, testApp, "owner-archive@test.com", "user")
+	dev, devToken := CreateTestUser(t, testApp, "dev-archive@test.com", "user")
+	_, outsiderToken := CreateTestUser(t, testApp, "outsider-archive@test.com", "user")
+
+	project := CreateTestProject(t, testApp, "Archived Course", owner.ID)
+	AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+
+	sprint := &models.Sprint{Name: "Sprint 1", ProjectID: project.ID, CreatedByID: owner.ID}
+	require.NoError(t, testApp.DB.Create(sprint).Error)
+	story := &models.UserStory{Title: "Story", ProjectID: project.ID, SprintID: &sprint.ID, Status: "in_progress", CreatedByID: owner.ID}
+	require.NoError(t, testApp.DB.Create(story).Error)
+	task := CreateTestTask(t, testApp, "Task", story.ID, dev.ID)
+	changedAt := time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, testApp.DB.Create(&models.TaskHistory{TaskID: task.ID, ChangedByID: dev.ID, FieldName: "status", OldValue: "todo", NewValue: "in_progress", ChangedAt: changedAt}).Error)
+	require.NoError(t, testApp.DB.Create(&models.TaskComment{TaskID: task.ID, AuthorID: owner.ID, Content: "Looks good"}).Error)
+
+	// Two versions of the same rubric; the evaluation scores the first one.
+	rubricV1 := CreateTestRubric(t, testApp, project.ID, owner.ID, "Course Rubric")
+	now := time.Now()
+	require.NoError(t, testApp.DB.Model(rubricV1).Update("superseded_at", now).Error)
+	rubricV2 := &models.Rubric{Name: "Course Rubric", ProjectID: &project.ID, CreatedByID: owner.ID, Status: rubricV1.Status, Version: 2, RootRubricID: &rubricV1.ID}
+	for _, c := range rubricV1.Criteria {
+		origin := c.ID
+		rubricV2.Criteria = append(rubricV2.Criteria, models.RubricCriterion{Title: c.Title, MaxPoints: c.MaxPoints, OriginCriterionID: &origin})
+	}
+	require.NoError(t, testApp.DB.Create(rubricV2).Error)
+
+	evaluation := &models.Evaluation{TaskID: &task.ID, EvaluatorID: owner.ID, RubricID: rubricV1.ID, TotalScore: 8, Status: models.EvaluationStatusPublished,
+		CriterionEvaluations: []models.CriterionEvaluation{{CriterionID: rubricV1.Criteria[0].ID, Score: 8, Feedback: "Nice"}}}
+	require.NoError(t, testApp.DB.Create(evaluation).Error)
+
+	var archiveData []byte
+	t.Run("Exports the whole project graph", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/projects/%d/archive", project.ID), outsiderToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/projects/%d/archive", project.ID), devToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+		archiveData = rec.Body.Bytes()
+
+		var archive services.ProjectArchive
+		require.NoError(t, json.Unmarshal(archiveData, &archive))
+		assert.Equal(t, services.ProjectArchiveSchemaVersion, archive.SchemaVersion)
+		assert.Len(t, archive.Users, 2)
+		assert.NotContains(t, string(archiveData), "contrase", "passwords are never exported")
+		assert.Len(t, archive.Members, 2)
+		require.Len(t, archive.Tasks, 1)
+		assert.Len(t, archive.Tasks[0].History, 1)
+		assert.Len(t, archive.Tasks[0].Comments, 1)
+		require.Len(t, archive.Rubrics, 2)
+		assert.Equal(t, archive.Rubrics[0].ID, *archive.Rubrics[1].RootRubricID)
+		require.Len(t, archive.Evaluations, 1)
+		assert.Len(t, archive.Evaluations[0].Scores, 1)
+	})
+
+	var projectCount int64
+	testApp.DB.Model(&models.Project{}).Count(&projectCount)
+
+	t.Run("Dry run reports counts without writing", func(t *testing.T) {
+		rec := doRawRequest(testApp, http.MethodPost, "/api/projects/import", outsiderToken, "application/json", archiveData)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var result services.ProjectArchiveImportResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.True(t, result.DryRun)
+		assert.Nil(t, result.Project)
+		assert.Equal(t, 2, result.MatchedUsers)
+		assert.Empty(t, result.UnmatchedUsers)
+		assert.Equal(t, 1, result.Counts["tasks"])
+		assert.Equal(t, 3, result.Counts["members"], "the importer is added as a member")
+
+		var count int64
+		testApp.DB.Model(&models.Project{}).Count(&count)
+		assert.Equal(t, projectCount, count)
+	})
+
+	t.Run("Imports with remapped IDs", func(t *testing.T) {
+		rec := doRawRequest(testApp, http.MethodPost, "/api/projects/import?dryRun=false&name=Imported%20Course", ownerToken, "application/json", archiveData)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var result services.ProjectArchiveImportResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		require.NotNil(t, result.Project)
+		imported := result.Project
+		assert.NotEqual(t, project.ID, imported.ID)
+		assert.Equal(t, "Imported Course", imported.Name)
+
+		var members []models.ProjectMember
+		require.NoError(t, testApp.DB.Where("project_id = ?", imported.ID).Find(&members).Error)
+		assert.Len(t, members, 2, "the owner already was a member")
+
+		var stories []models.UserStory
+		require.NoError(t, testApp.DB.Where("project_id = ?", imported.ID).Find(&stories).Error)
+		require.Len(t, stories, 1)
+		require.NotNil(t, stories[0].SprintID)
+		assert.NotEqual(t, sprint.ID, *stories[0].SprintID)
+
+		var tasks []models.Task
+		require.NoError(t, testApp.DB.Preload("History").Preload("Comments").Where("user_story_id = ?", stories[0].ID).Find(&tasks).Error)
+		require.Len(t, tasks, 1)
+		require.NotNil(t, tasks[0].AssignedToID)
+		assert.Equal(t, dev.ID, *tasks[0].AssignedToID)
+		require.Len(t, tasks[0].History, 1)
+		assert.True(t, tasks[0].History[0].ChangedAt.Equal(changedAt))
+		assert.Len(t, tasks[0].Comments, 1)
+
+		var rubrics []models.Rubric
+		require.NoError(t, testApp.DB.Preload("Criteria").Where("project_id = ?", imported.ID).Order("id").Find(&rubrics).Error)
+		require.Len(t, rubrics, 2)
+		require.NotNil(t, rubrics[1].RootRubricID)
+		assert.Equal(t, rubrics[0].ID, *rubrics[1].RootRubricID)
+		require.NotNil(t, rubrics[1].Criteria[0].OriginCriterionID)
+		assert.Equal(t, rubrics[0].Criteria[0].ID, *rubrics[1].Criteria[0].OriginCriterionID)
+
+		var evaluations []models.Evaluation
+		require.NoError(t, testApp.DB.Preload("CriterionEvaluations").Where("task_id = ?", tasks[0].ID).Find(&evaluations).Error)
+		require.Len(t, evaluations, 1)
+		assert.Equal(t, rubrics[0].ID, evaluations[0].RubricID)
+		require.Len(t, evaluations[0].CriterionEvaluations, 1)
+		assert.Equal(t, rubrics[0].Criteria[0].ID, evaluations[0].CriterionEvaluations[0].CriterionID)
+	})
+
+	t.Run("Reports users without an account", func(t *testing.T) {
+		data := []byte(strings.ReplaceAll(string(archiveData), "dev-archive@test.com", "gone@elsewhere.com"))
+		rec := doRawRequest(testApp, http.MethodPost, "/api/projects/import", ownerToken, "application/json", data)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var result services.ProjectArchiveImportResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, []string{"gone@elsewhere.com"}, result.UnmatchedUsers)
+		assert.Equal(t, 1, result.Counts["members"])
+		assert.NotEmpty(t, result.Warnings)
+	})
+
+	t.Run("Rejects invalid archives", func(t *testing.T) {
+		var archive map[string]interface{}
+		require.NoError(t, json.Unmarshal(archiveData, &archive))
+		archive["schemaVersion"] = 99
+		data, _ := json.Marshal(archive)
+		rec := doRawRequest(testApp, http.MethodPost, "/api/projects/import", ownerToken, "application/json", data)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "schemaVersion")
+
+		archive["schemaVersion"] = services.ProjectArchiveSchemaVersion
+		tasks := archive["tasks"].([]interface{})
+		tasks[0].(map[string]interface{})["userStoryId"] = 999
+		data, _ = json.Marshal(archive)
+		rec = doRawRequest(testApp, http.MethodPost, "/api/projects/import", ownerToken, "application/json", data)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "tasks[0].userStoryId")
+	})
+}
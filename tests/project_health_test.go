@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectHealth(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, ownerToken := CreateTestUser(t, testApp, "owner-health@test.com", "user")
+	dev, _ := CreateTestUser(t, testApp, "dev-health@test.com", "user")
+	_, outsiderToken := CreateTestUser(t, testApp, "outsider-health@test.com", "user")
+	project := CreateTestProject(t, testApp, "Health Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+
+	now := time.Now()
+	createSprint := func(name, status string, start, end time.Time) *models.Sprint {
+		sprint := &models.Sprint{Name: name, ProjectID: project.ID, CreatedByID: owner.ID, Status: status, StartDate: &start, EndDate: &end}
+		require.NoError(t, testApp.DB.Create(sprint).Error)
+		return sprint
+	}
+	createStory := func(title string, points int, status string, sprintID *uint) *models.UserStory {
+		story := CreateTestUserStory(t, testApp, title, project.ID)
+		require.NoError(t, testApp.DB.Model(story).Updates(map[string]interface{}{"points": points, "status": status, "sprint_id": sprintID}).Error)
+		return story
+	}
+
+	// Two closed sprints: all 10 points completed, then 5 of 10.
+	first := createSprint("Sprint 1", "completed", now.AddDate(0, 0, -34), now.AddDate(0, 0, -20))
+	second := createSprint("Sprint 2", "completed", now.AddDate(0, 0, -20), now.AddDate(0, 0, -6))
+	createStory("First A", 5, "done", &first.ID)
+	createStory("First B", 5, "done", &first.ID)
+	createStory("Second A", 5, "done", &second.ID)
+	createStory("Second B", 5, "in_progress", &second.ID)
+
+	// The active sprint starts with four stories; then one is added and one removed.
+	active := createSprint("Sprint 3", "active", now, now.AddDate(0, 0, 14))
+	var planned []*models.UserStory
+	for i := 0; i < 4; i++ {
+		planned = append(planned, createStory(fmt.Sprintf("Planned %d", i), 3, "todo", &active.ID))
+	}
+	start := time.Now()
+	require.NoError(t, testApp.DB.Model(active).Update("start_date", start).Error)
+	time.Sleep(10 * time.Millisecond)
+	createStory("Late Addition", 2, "todo", &active.ID)
+	require.NoError(t, testApp.DB.Model(planned[3]).Update("sprint_id", nil).Error)
+
+	// Open sprint work: two unassigned tasks and one stale task in progress.
+	for i := 0; i < 2; i++ {
+		task := CreateTestTask(t, testApp, fmt.Sprintf("Unassigned %d", i), planned[0].ID, dev.ID)
+		require.NoError(t, testApp.DB.Model(task).Update("assigned_to_id", nil).Error)
+	}
+	stale := CreateTestTask(t, testApp, "Stale", planned[1].ID, dev.ID)
+	require.NoError(t, testApp.DB.Model(stale).Update("status", models.StatusInProgress).Error)
+	require.NoError(t, testApp.DB.Model(stale).UpdateColumn("updated_at", now.AddDate(0, 0, -10)).Error)
+	done := CreateTestTask(t, testApp, "Done", planned[1].ID, dev.ID)
+	require.NoError(t, testApp.DB.Model(done).Update("status", models.StatusDone).Error)
+
+	// A deadline missed two days ago with Sprint 2 still unfinished; an older one no longer counts.
+	for _, event := range []models.Event{
+		{Title: "Missed", Type: "deadline", StartDate: now.AddDate(0, 0, -2), EndDate: now.AddDate(0, 0, -2), ProjectID: project.ID, CreatedByID: owner.ID},
+		{Title: "Old", Type: "deadline", StartDate: now.AddDate(0, 0, -20), EndDate: now.AddDate(0, 0, -20), ProjectID: project.ID, CreatedByID: owner.ID},
+		{Title: "Meeting", Type: "meeting", StartDate: now.AddDate(0, 0, -1), EndDate: now.AddDate(0, 0, -1), ProjectID: project.ID, CreatedByID: owner.ID},
+	} {
+		require.NoError(t, testApp.DB.Create(&event).Error)
+	}
+
+	path := fmt.Sprintf("/api/projects/%d/health", project.ID)
+	getHealth := func(t *testing.T) services.ProjectHealth {
+		rec := doEvaluationRequest(testApp, http.MethodGet, path, ownerToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var health services.ProjectHealth
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &health))
+		return health
+	}
+
+	t.Run("Every factor contributes to the score", func(t *testing.T) {
+		health := getHealth(t)
+		factors := map[string]services.HealthFactor{}
+		for _, factor := range health.Factors {
+			assert.True(t, factor.Available, factor.Key)
+			assert.NotEmpty(t, factor.Detail, factor.Key)
+			factors[factor.Key] = factor
+		}
+		require.Len(t, factors, 6)
+		assert.Equal(t, 67, factors[services.HealthFactorCommitment].Score, "50%% last sprint weighs twice 100%% before")
+		assert.Equal(t, 67, factors[services.HealthFactorVelocity].Score, "5 and 10 points vary by a third")
+		assert.Equal(t, 0, factors[services.HealthFactorStaleTasks].Score)
+		assert.Equal(t, 33, factors[services.HealthFactorUnassigned].Score, "2 of 3 open tasks are unassigned")
+		assert.Equal(t, 75, factors[services.HealthFactorEvents].Score)
+		assert.EqualValues(t, 1, factors[services.HealthFactorEvents].Value)
+		assert.Equal(t, 50, factors[services.HealthFactorScopeChurn].Score, "1 added and 1 removed out of 4")
+
+		assert.Equal(t, 47, health.Score)
+		assert.Equal(t, services.HealthStatusCritical, health.Status)
+	})
+
+	t.Run("The score is persisted once per day", func(t *testing.T) {
+		yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+		score := 80
+		require.NoError(t, testApp.DB.Create(&models.ProjectMetric{ProjectID: project.ID, Date: yesterday, HealthScore: &score}).Error)
+
+		getHealth(t)
+		health := getHealth(t)
+		require.Len(t, health.History, 2)
+		assert.Equal(t, 80, health.History[0].Score)
+		assert.Equal(t, 47, health.History[1].Score)
+
+		var metrics []models.ProjectMetric
+		require.NoError(t, testApp.DB.Where("project_id = ?", project.ID).Order("date").Find(&metrics).Error)
+		require.Len(t, metrics, 2)
+		require.NotNil(t, metrics[1].TotalUserStories)
+		assert.Equal(t, 9, *metrics[1].TotalUserStories)
+		assert.Equal(t, 3, *metrics[1].CompletedUserStories)
+		require.NotNil(t, metrics[1].AverageVelocity)
+		assert.Equal(t, 8, *metrics[1].AverageVelocity)
+	})
+
+	t.Run("Projects without history only count the available factors", func(t *testing.T) {
+		fresh := CreateTestProject(t, testApp, "Fresh Project", owner.ID)
+		health, err := testApp.HealthService.ComputeProjectHealth(fresh.ID, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 100, health.Score)
+		assert.Equal(t, services.HealthStatusHealthy, health.Status)
+		for _, factor := range health.Factors {
+			switch factor.Key {
+			case services.HealthFactorStaleTasks, services.HealthFactorEvents:
+				assert.True(t, factor.Available, factor.Key)
+			default:
+				assert.False(t, factor.Available, factor.Key)
+			}
+		}
+	})
+
+	t.Run("Only members and admins can see the health", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, path, outsiderToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = doEvaluationRequest(testApp, http.MethodGet, "/api/projects/9999/health", ownerToken, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectLifecycle(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	admin := &models.User{Nombre: "Lifecycle", ApellidoPaterno: "Admin", ApellidoMaterno: "User", Correo: "admin-lifecycle@test.com", Contraseña: "secret123"}
+	require.NoError(t, testApp.UserService.CreateAdminUser(admin))
+	rec := doEvaluationRequest(testApp, http.MethodPost, "/login", "", map[string]string{"correo": admin.Correo, "contraseña": "secret123"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	adminToken := login["token"]
+
+	owner, ownerToken := CreateTestUser(t, testApp, "owner-lifecycle@test.com", "user")
+	project := CreateTestProject(t, testApp, "Lifecycle Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+	story := CreateTestUserStory(t, testApp, "Lifecycle Story", project.ID)
+	task := CreateTestTask(t, testApp, "Lifecycle Task", story.ID, owner.ID)
+	other := CreateTestProject(t, testApp, "Visible Project", owner.ID)
+
+	setStatus := func(status string) int {
+		rec := doEvaluationRequest(testApp, http.MethodPut, fmt.Sprintf("/api/projects/%d", project.ID), ownerToken, map[string]string{"Status": status})
+		return rec.Code
+	}
+	listProjects := func(query string) []models.Project {
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/projects"+query, ownerToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page services.Page[models.Project]
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page.Items
+	}
+
+	t.Run("Status follows the lifecycle", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, setStatus("finished"))
+		assert.Equal(t, http.StatusOK, setStatus("active"))
+		assert.Equal(t, http.StatusConflict, setStatus("planning"), "projects cannot go back to planning")
+		assert.Equal(t, http.StatusOK, setStatus("on_hold"))
+		assert.Equal(t, http.StatusOK, setStatus("archived"))
+
+		archived, err := testApp.ProjectService.GetProjectByID(project.ID)
+		require.NoError(t, err)
+		assert.True(t, archived.IsArchived())
+		assert.NotNil(t, archived.ArchivedAt)
+	})
+
+	t.Run("Archived projects are hidden from the project list by default", func(t *testing.T) {
+		projects := listProjects("")
+		require.Len(t, projects, 1)
+		assert.Equal(t, other.ID, projects[0].ID)
+
+		assert.Len(t, listProjects("?includeArchived=true"), 2)
+		projects = listProjects("?status=archived")
+		require.Len(t, projects, 1)
+		assert.Equal(t, project.ID, projects[0].ID)
+	})
+
+	t.Run("Writes under an archived project return 409", func(t *testing.T) {
+		writes := []struct {
+			method, path string
+			body         interface{}
+		}{
+			{http.MethodPut, fmt.Sprintf("/api/projects/%d", project.ID), map[string]string{"Name": "Renamed"}},
+			{http.MethodDelete, fmt.Sprintf("/api/projects/%d", project.ID), nil},
+			{http.MethodPost, fmt.Sprintf("/api/projects/%d/userstories", project.ID), map[string]string{"title": "New Story"}},
+			{http.MethodPost, fmt.Sprintf("/api/projects/%d/sprints", project.ID), map[string]string{"name": "New Sprint"}},
+			{http.MethodPut, fmt.Sprintf("/api/userstories/%d", story.ID), map[string]string{"title": "Changed"}},
+			{http.MethodPost, fmt.Sprintf("/api/userstories/%d/tasks", story.ID), map[string]string{"title": "New Task"}},
+			{http.MethodPut, fmt.Sprintf("/api/tasks/%d/status", task.ID), map[string]string{"status": "done"}},
+			{http.MethodPost, fmt.Sprintf("/api/tasks/%d/comments", task.ID), map[string]string{"content": "Hi"}},
+			{http.MethodPost, "/api/rubrics", map[string]interface{}{"name": "Late Rubric", "projectId": project.ID, "createdById": owner.ID}},
+		}
+		for _, w := range writes {
+			rec := doEvaluationRequest(testApp, w.method, w.path, ownerToken, w.body)
+			assert.Equal(t, http.StatusConflict, rec.Code, "%s %s: %s", w.method, w.path, rec.Body.String())
+		}
+
+		rec := doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/userstories/%d/tasks", story.ID), ownerToken, nil)
+		assert.Equal(t, http.StatusOK, rec.Code, "reads still work")
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/userstories", other.ID), ownerToken, map[string]string{"title": "Allowed", "description": "d", "acceptanceCriteria": "a"})
+		assert.NotEqual(t, http.StatusConflict, rec.Code, "other projects are not affected")
+	})
+
+	t.Run("Only admins can unarchive", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/admin/projects/%d/unarchive", project.ID), ownerToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/admin/projects/%d/unarchive", project.ID), adminToken, map[string]string{"status": "on_hold"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var unarchived models.Project
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &unarchived))
+		assert.Equal(t, string(models.ProjectStatusOnHold), unarchived.Status)
+		assert.Nil(t, unarchived.ArchivedAt)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/admin/projects/%d/unarchive", project.ID), adminToken, nil)
+		assert.Equal(t, http.StatusConflict, rec.Code, "the project is no longer archived")
+
+		rec = doEvaluationRequest(testApp, http.MethodPut, fmt.Sprintf("/api/tasks/%d/status", task.ID), ownerToken, map[string]string{"status": "done"})
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectTemplatesAndCloning(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, ownerToken := CreateTestUser(t, testApp, "owner-template@test.com", "user")
+	dev, devToken := CreateTestUser(t, testApp, "dev-template@test.com", "user")
+	_, outsiderToken := CreateTestUser(t, testApp, "outsider-template@test.com", "user")
+
+	day := func(month time.Month, d, hour int) *time.Time {
+		t := time.Date(2024, month, d, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+	project := &models.Project{Name: "Course Project", Description: "Term project", CreatedByID: owner.ID, StartDate: day(time.January, 8, 0), EndDate: day(time.March, 18, 0)}
+	require.NoError(t, testApp.DB.Create(project).Error)
+	AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+
+	sprint1 := &models.Sprint{Name: "Sprint 1", ProjectID: project.ID, CreatedByID: owner.ID, StartDate: day(time.January, 8, 0), EndDate: day(time.January, 21, 0), Status: "completed"}
+	sprint2 := &models.Sprint{Name: "Sprint 2", ProjectID: project.ID, CreatedByID: owner.ID, StartDate: day(time.January, 22, 0), EndDate: day(time.February, 4, 0)}
+	require.NoError(t, testApp.DB.Create(sprint1).Error)
+	require.NoError(t, testApp.DB.Create(sprint2).Error)
+
+	points := 5
+	planned := &models.UserStory{Title: "Planned Story", ProjectID: project.ID, SprintID: &sprint1.ID, Points: &points, Status: "done", CreatedByID: owner.ID}
+	require.NoError(t, testApp.DB.Create(planned).Error)
+	CreateTestUserStory(t, testApp, "Backlog Story", project.ID)
+	task := CreateTestTask(t, testApp, "Done Task", planned.ID, dev.ID)
+	require.NoError(t, testApp.DB.Model(task).Update("status", models.StatusDone).Error)
+
+	rubric := CreateTestRubric(t, testApp, project.ID, owner.ID, "Course Rubric")
+	require.NoError(t, testApp.DB.Create(&models.Event{Title: "Demo", ProjectID: project.ID, CreatedByID: owner.ID, StartDate: *day(time.January, 15, 10), EndDate: *day(time.January, 15, 11), Type: "meeting"}).Error)
+
+	var templateID uint
+	t.Run("Saves a project as a template with relative dates", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/save-as-template", project.ID), devToken, map[string]string{"name": "Nope"})
+		assert.Equal(t, http.StatusForbidden, rec.Code, "developers cannot save templates")
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/save-as-template", project.ID), ownerToken, map[string]string{"name": "Course Template"})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var template models.ProjectTemplate
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &template))
+		templateID = template.ID
+
+		content := template.Content
+		require.Len(t, content.Sprints, 2)
+		require.NotNil(t, content.Sprints[1].StartOffsetMinutes)
+		assert.EqualValues(t, 14*24*60, *content.Sprints[1].StartOffsetMinutes)
+		require.Len(t, content.UserStories, 2)
+		require.NotNil(t, content.UserStories[0].SprintIndex)
+		assert.Equal(t, 0, *content.UserStories[0].SprintIndex)
+		assert.Len(t, content.UserStories[0].Tasks, 1)
+		require.Len(t, content.Rubrics, 1)
+		assert.Len(t, content.Rubrics[0].Criteria, len(rubric.Criteria))
+		require.Len(t, content.Events, 1)
+		assert.EqualValues(t, 7*24*60+10*60, content.Events[0].StartOffsetMinutes)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, "/api/project-templates?q=course", outsiderToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var templates []models.ProjectTemplate
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &templates))
+		assert.Len(t, templates, 1)
+	})
+
+	t.Run("Creates a project from a template shifted to a new start date", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/project-templates/%d/instantiate", templateID), outsiderToken, map[string]string{"name": "Next Term", "startDate": "2025-02-03"})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created models.Project
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		assert.Equal(t, "Next Term", created.Name)
+		assert.Equal(t, string(models.ProjectStatusPlanning), created.Status)
+		require.NotNil(t, created.EndDate)
+		assert.True(t, created.EndDate.Equal(time.Date(2025, time.April, 14, 0, 0, 0, 0, time.UTC)))
+		require.Len(t, created.Members, 1, "the creator becomes the only member")
+
+		var sprints []models.Sprint
+		require.NoError(t, testApp.DB.Where("project_id = ?", created.ID).Order("start_date").Find(&sprints).Error)
+		require.Len(t, sprints, 2)
+		assert.True(t, sprints[1].StartDate.Equal(time.Date(2025, time.February, 17, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(t, "planned", sprints[0].Status)
+
+		var stories []models.UserStory
+		require.NoError(t, testApp.DB.Where("project_id = ?", created.ID).Order("id").Find(&stories).Error)
+		require.Len(t, stories, 2)
+		require.NotNil(t, stories[0].SprintID)
+		assert.Equal(t, sprints[0].ID, *stories[0].SprintID)
+		assert.Equal(t, "backlog", stories[0].Status)
+		assert.Nil(t, stories[1].SprintID)
+
+		var tasks []models.Task
+		require.NoError(t, testApp.DB.Where("user_story_id = ?", stories[0].ID).Find(&tasks).Error)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, models.StatusTodo, tasks[0].Status)
+		assert.Nil(t, tasks[0].AssignedToID)
+
+		var rubrics []models.Rubric
+		require.NoError(t, testApp.DB.Preload("Criteria.Levels").Where("project_id = ?", created.ID).Find(&rubrics).Error)
+		require.Len(t, rubrics, 1)
+		assert.Len(t, rubrics[0].Criteria, 2)
+
+		var events []models.Event
+		require.NoError(t, testApp.DB.Where("project_id = ?", created.ID).Find(&events).Error)
+		require.Len(t, events, 1)
+		assert.True(t, events[0].StartDate.Equal(time.Date(2025, time.February, 10, 10, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("Clones only the selected parts", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/clone", project.ID), outsiderToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/clone", project.ID), devToken, map[string]interface{}{
+			"include": map[string]bool{"userStories": true, "members": true},
+		})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var clone models.Project
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &clone))
+		assert.Equal(t, "Course Project (copy)", clone.Name)
+		assert.True(t, clone.StartDate.Equal(*project.StartDate), "dates are kept without a new start date")
+		assert.Len(t, clone.Members, 2)
+
+		var count int64
+		require.NoError(t, testApp.DB.Model(&models.Sprint{}).Where("project_id = ?", clone.ID).Count(&count).Error)
+		assert.Zero(t, count)
+		var stories []models.UserStory
+		require.NoError(t, testApp.DB.Where("project_id = ?", clone.ID).Find(&stories).Error)
+		require.Len(t, stories, 2)
+		assert.Nil(t, stories[0].SprintID)
+		require.NoError(t, testApp.DB.Model(&models.Task{}).Where("user_story_id IN ?", []uint{stories[0].ID, stories[1].ID}).Count(&count).Error)
+		assert.Zero(t, count)
+		require.NoError(t, testApp.DB.Model(&models.Rubric{}).Where("project_id = ?", clone.ID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Archived projects can still be cloned", func(t *testing.T) {
+		require.NoError(t, testApp.DB.Model(project).Update("status", models.ProjectStatusArchived).Error)
+
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/clone", project.ID), ownerToken, map[string]string{"name": "Reopened", "startDate": "2025-09-01"})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var clone models.Project
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &clone))
+		assert.Equal(t, string(models.ProjectStatusPlanning), clone.Status)
+	})
+
+	t.Run("Only the creator or an admin can delete a template", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/project-templates/%d", templateID), devToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/project-templates/%d", templateID), ownerToken, nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		rec = doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/project-templates/%d", templateID), ownerToken, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskDetection(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	admin := &models.User{Nombre: "Risk", ApellidoPaterno: "Admin", ApellidoMaterno: "User", Correo: "admin-risk@test.com", Contraseña: "secret123"}
+	require.NoError(t, testApp.UserService.CreateAdminUser(admin))
+	rec := doEvaluationRequest(testApp, http.MethodPost, "/login", "", map[string]string{"correo": admin.Correo, "contraseña": "secret123"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	adminToken := login["token"]
+
+	owner, _ := CreateTestUser(t, testApp, "owner-risk@test.com", "user")
+	scrumMaster, _ := CreateTestUser(t, testApp, "sm-risk@test.com", "user")
+	dev, devToken := CreateTestUser(t, testApp, "dev-risk@test.com", "user")
+	leaver, _ := CreateTestUser(t, testApp, "leaver-risk@test.com", "user")
+	_, outsiderToken := CreateTestUser(t, testApp, "outsider-risk@test.com", "user")
+	project := CreateTestProject(t, testApp, "Risky Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, scrumMaster.ID, "scrum_master")
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+	AddUserToProject(t, testApp, project.ID, leaver.ID, "team_developer")
+
+	now := time.Now()
+	start, end := now.AddDate(0, 0, -12), now.AddDate(0, 0, 2)
+	sprint := &models.Sprint{Name: "Closing Sprint", ProjectID: project.ID, CreatedByID: owner.ID, Status: "active", StartDate: &start, EndDate: &end}
+	require.NoError(t, testApp.DB.Create(sprint).Error)
+	createStory := func(title string, points int, status string) *models.UserStory {
+		story := CreateTestUserStory(t, testApp, title, project.ID)
+		require.NoError(t, testApp.DB.Model(story).Updates(map[string]interface{}{"points": points, "status": status, "sprint_id": sprint.ID}).Error)
+		return story
+	}
+	working := createStory("Working", 5, "in_progress")
+	empty := createStory("Empty", 3, "todo")
+	finished := createStory("Finished", 2, "done")
+
+	createTask := func(title string, storyID, assigneeID uint, status models.TaskStatus, lastChange time.Time) *models.Task {
+		task := CreateTestTask(t, testApp, title, storyID, assigneeID)
+		require.NoError(t, testApp.DB.Model(task).Update("status", status).Error)
+		change := models.TaskHistory{TaskID: task.ID, ChangedByID: assigneeID, FieldName: "status", OldValue: "todo", NewValue: string(status), ChangedAt: lastChange}
+		require.NoError(t, testApp.DB.Create(&change).Error)
+		return task
+	}
+	stale := createTask("Stuck", working.ID, dev.ID, models.StatusInProgress, now.AddDate(0, 0, -10))
+	createTask("Fresh", working.ID, dev.ID, models.StatusInReview, now)
+	orphan := createTask("Orphaned", working.ID, leaver.ID, models.StatusTodo, now)
+	createTask("Shipped", finished.ID, dev.ID, models.StatusDone, now.AddDate(0, 0, -10))
+	require.NoError(t, testApp.DB.Where("project_id = ? AND user_id = ?", project.ID, leaver.ID).Delete(&models.ProjectMember{}).Error)
+
+	// Archived projects are not checked.
+	archived := CreateTestProject(t, testApp, "Archived Risks", owner.ID)
+	archivedStory := CreateTestUserStory(t, testApp, "Frozen", archived.ID)
+	createTask("Frozen Task", archivedStory.ID, dev.ID, models.StatusInProgress, now.AddDate(0, 0, -30))
+	require.NoError(t, testApp.DB.Model(archived).Update("status", models.ProjectStatusArchived).Error)
+
+	scan := func(t *testing.T) services.RiskScanResult {
+		rec := doEvaluationRequest(testApp, http.MethodPost, "/api/admin/risks/scan", adminToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var result services.RiskScanResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return result
+	}
+	notificationsOf := func(userID uint) []models.Notification {
+		notifications, err := testApp.NotificationService.GetUserNotifications(userID)
+		require.NoError(t, err)
+		return notifications
+	}
+
+	t.Run("Every kind of finding is detected and notified once", func(t *testing.T) {
+		result := scan(t)
+		require.Equal(t, 4, result.Opened, "%+v", result.Findings)
+		found := map[models.RiskKind]models.RiskFinding{}
+		for _, finding := range result.Findings {
+			assert.Equal(t, project.ID, finding.ProjectID)
+			found[finding.Kind] = finding
+		}
+		assert.Equal(t, stale.ID, found[models.RiskStaleTask].EntityID)
+		assert.Equal(t, dev.ID, *found[models.RiskStaleTask].NotifiedUserID, "the assignee hears about their stale task")
+		assert.Equal(t, empty.ID, found[models.RiskStoryWithoutTasks].EntityID)
+		assert.Equal(t, orphan.ID, found[models.RiskDepartedAssignee].EntityID)
+		assert.Equal(t, sprint.ID, found[models.RiskSprintAtRisk].EntityID)
+		assert.Contains(t, found[models.RiskSprintAtRisk].Message, "80%")
+		for _, kind := range []models.RiskKind{models.RiskStoryWithoutTasks, models.RiskDepartedAssignee, models.RiskSprintAtRisk} {
+			assert.Equal(t, scrumMaster.ID, *found[kind].NotifiedUserID, kind)
+		}
+
+		assert.Len(t, notificationsOf(dev.ID), 1)
+		assert.Len(t, notificationsOf(scrumMaster.ID), 3)
+
+		again := scan(t)
+		assert.Equal(t, 0, again.Opened)
+		assert.Equal(t, 4, again.Open)
+		assert.Len(t, notificationsOf(scrumMaster.ID), 3, "open findings are not notified again")
+	})
+
+	t.Run("Findings are resolved when the problem goes away", func(t *testing.T) {
+		_, err := testApp.TaskService.UpdateTaskStatus(stale.ID, string(models.StatusInReview), dev.ID)
+		require.NoError(t, err)
+		result := scan(t)
+		assert.Equal(t, 1, result.Resolved)
+		assert.Equal(t, 3, result.Open)
+	})
+
+	t.Run("Project members can list the findings", func(t *testing.T) {
+		path := fmt.Sprintf("/api/projects/%d/risks", project.ID)
+		var findings []models.RiskFinding
+		rec := doEvaluationRequest(testApp, http.MethodGet, path, devToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &findings))
+		assert.Len(t, findings, 3)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, path+"?includeResolved=true", devToken, nil)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &findings))
+		require.Len(t, findings, 4)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, path, outsiderToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = doEvaluationRequest(testApp, http.MethodPost, "/api/admin/risks/scan", devToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("A problem has a single open finding", func(t *testing.T) {
+		open, err := testApp.RiskService.Repo.GetOpenFindings()
+		require.NoError(t, err)
+		require.NotEmpty(t, open)
+		duplicate := open[0]
+		duplicate.ID = 0
+
+		// A scan on another instance that found the same problem opens nothing and notifies nobody.
+		inserted, err := testApp.RiskService.Repo.ApplyScan([]models.RiskFinding{duplicate}, nil, nil, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, inserted)
+		assert.Error(t, testApp.DB.Create(&duplicate).Error, "the open finding key is unique")
+
+		// Resolved findings keep their history.
+		resolvedAt := time.Now()
+		resolved := open[0]
+		resolved.ID, resolved.ResolvedAt = 0, &resolvedAt
+		require.NoError(t, testApp.DB.Create(&resolved).Error)
+
+		again := scan(t)
+		assert.Equal(t, 0, again.Opened)
+		assert.Equal(t, 3, again.Open)
+		assert.Len(t, notificationsOf(scrumMaster.ID), 3)
+	})
+}
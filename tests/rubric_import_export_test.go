@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doRawRequest sends an authenticated request with a raw body and content type.
+func doRawRequest(app *TestApp, method, path, token, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+const presentationCSV = `rubric_name,rubric_description,criterion_title,criterion_description,max_points,level_score,level_description
+Oral Presentation,Final talk,Clarity,Message is understood,10,0,Confusing
+Oral Presentation,Final talk,Clarity,,10,5,Acceptable
+Oral Presentation,Final talk,Clarity,,,10,Excellent
+Oral Presentation,Final talk,Timing,,5,,
+`
+
+func TestRubricImportExport(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	member, memberToken := CreateTestUser(t, testApp, "member-import@test.com", "user")
+	_, outsiderToken := CreateTestUser(t, testApp, "outsider-import@test.com", "user")
+	project := CreateTestProject(t, testApp, "Import Project", member.ID)
+	AddUserToProject(t, testApp, project.ID, member.ID, "product_owner")
+
+	importPath := fmt.Sprintf("/api/projects/%d/rubrics/import", project.ID)
+
+	countRubrics := func() int {
+		rubrics, err := testApp.RubricService.GetRubricsByProjectID(project.ID)
+		require.NoError(t, err)
+		return len(rubrics)
+	}
+
+	t.Run("Preview validates without saving", func(t *testing.T) {
+		rec := doRawRequest(testApp, http.MethodPost, importPath+"?format=csv&preview=true", memberToken, "text/csv", []byte(presentationCSV))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var result services.RubricImportResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.True(t, result.Preview)
+		assert.Empty(t, result.Issues)
+		require.NotNil(t, result.Rubric)
+		assert.Zero(t, result.Rubric.ID)
+		assert.Equal(t, 0, countRubrics())
+	})
+
+	var imported models.Rubric
+	t.Run("Imports a CSV file as a draft", func(t *testing.T) {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("file", "presentation.csv")
+		require.NoError(t, err)
+		_, _ = part.Write([]byte(presentationCSV))
+		require.NoError(t, writer.Close())
+
+		rec := doRawRequest(testApp, http.MethodPost, importPath, memberToken, writer.FormDataContentType(), body.Bytes())
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var result services.RubricImportResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		require.NotNil(t, result.Rubric)
+		imported = *result.Rubric
+		assert.NotZero(t, imported.ID)
+		assert.Equal(t, "Oral Presentation", imported.Name)
+		assert.Equal(t, models.RubricStatusDraft, imported.Status)
+		require.Len(t, imported.Criteria, 2)
+		assert.Equal(t, "Clarity", imported.Criteria[0].Title)
+		assert.Equal(t, 10.0, imported.Criteria[0].MaxPoints)
+		assert.Len(t, imported.Criteria[0].Levels, 3)
+		assert.Empty(t, imported.Criteria[1].Levels)
+		assert.Equal(t, 1, countRubrics())
+	})
+
+	t.Run("Reports every problem and saves nothing", func(t *testing.T) {
+		invalid := strings.Replace(presentationCSV, "10,Excellent", "12,Excellent", 1)
+		invalid += "Oral Presentation,,,,,,\n"
+		rec := doRawRequest(testApp, http.MethodPost, importPath+"?format=csv", memberToken, "text/csv", []byte(invalid))
+		require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+		var body struct {
+			Error  string                       `json:"error"`
+			Issues []services.RubricImportIssue `json:"issues"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Issues, 2)
+		assert.Equal(t, "line 6", body.Issues[0].Location)
+		assert.Equal(t, "criteria[0].levels[2]", body.Issues[1].Location)
+		assert.Contains(t, body.Issues[1].Message, "exceeds maxPoints")
+		assert.Equal(t, 1, countRubrics())
+	})
+
+	t.Run("Imports the documented JSON schema", func(t *testing.T) {
+		doc := `{"schemaVersion":1,"name":"Code Review","category":"Software","criteria":[
+			{"title":"Readability","maxPoints":5,"levels":[{"score":0,"description":"Poor"},{"score":5,"description":"Clear"}]}]}`
+		rec := doRawRequest(testApp, http.MethodPost, importPath+"?name=Team+Review", memberToken, echo.MIMEApplicationJSON, []byte(doc))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var result services.RubricImportResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, "Team Review", result.Rubric.Name)
+		assert.Equal(t, "Software", result.Rubric.Category)
+
+		rec = doRawRequest(testApp, http.MethodPost, importPath, memberToken, echo.MIMEApplicationJSON, []byte(`{"name":"X","extra":true}`))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Exports round-trip in both formats", func(t *testing.T) {
+		for _, format := range []string{"json", "csv"} {
+			exportPath := fmt.Sprintf("/api/rubrics/%d/export?format=%s", imported.ID, format)
+			rec := doEvaluationRequest(testApp, http.MethodGet, exportPath, memberToken, nil)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), "rubric_")
+
+			rec = doRawRequest(testApp, http.MethodPost, importPath+"?preview=true&format="+format, memberToken, "text/plain", rec.Body.Bytes())
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			var result services.RubricImportResult
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+			require.Len(t, result.Rubric.Criteria, 2)
+			assert.Equal(t, imported.Name, result.Rubric.Name)
+			assert.Len(t, result.Rubric.Criteria[0].Levels, 3)
+			assert.Equal(t, 5.0, result.Rubric.Criteria[1].MaxPoints)
+		}
+	})
+
+	t.Run("Rejects non-members", func(t *testing.T) {
+		rec := doRawRequest(testApp, http.MethodPost, importPath+"?format=csv", outsiderToken, "text/csv", []byte(presentationCSV))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
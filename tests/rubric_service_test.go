@@ -22,7 +22,7 @@ func TestRubricService(t *testing.T) {
 		rubric := &models.Rubric{
 			Name:        "Test Rubric",
 			Description: "A rubric for testing.",
-			ProjectID:   project.ID,
+			ProjectID:   &project.ID,
 			CreatedByID: creator.ID,
 			Status:      models.RubricStatusDraft,
 			Criteria: []models.RubricCriterion{
@@ -31,7 +31,7 @@ func TestRubricService(t *testing.T) {
 			},
 		}
 
-		err := rubricService.CreateRubric(rubric)
+		err := rubricService.CreateRubric(rubric, creator.ID, "admin")
 		require.NoError(t, err)
 		assert.NotZero(t, rubric.ID)
 
@@ -46,15 +46,15 @@ func TestRubricService(t *testing.T) {
 	t.Run("Update Rubric", func(t *testing.T) {
 		rubric := &models.Rubric{
 			Name:        "Update Me Rubric",
-			ProjectID:   project.ID,
+			ProjectID:   &project.ID,
 			CreatedByID: creator.ID,
 		}
-		err := rubricService.CreateRubric(rubric)
+		err := rubricService.CreateRubric(rubric, creator.ID, "admin")
 		require.NoError(t, err)
 
 		// Update the name
 		rubric.Name = "Updated Rubric Name"
-		err = rubricService.UpdateRubric(rubric)
+		err = rubricService.UpdateRubric(rubric, creator.ID, "admin")
 		require.NoError(t, err)
 
 		found, err := rubricService.GetRubricByID(rubric.ID)
@@ -65,14 +65,14 @@ func TestRubricService(t *testing.T) {
 	t.Run("Delete Rubric", func(t *testing.T) {
 		rubric := &models.Rubric{
 			Name:        "Delete Me Rubric",
-			ProjectID:   project.ID,
+			ProjectID:   &project.ID,
 			CreatedByID: creator.ID,
 		}
-		err := rubricService.CreateRubric(rubric)
+		err := rubricService.CreateRubric(rubric, creator.ID, "admin")
 		require.NoError(t, err)
 
 		// Delete it
-		err = rubricService.DeleteRubric(rubric.ID)
+		err = rubricService.DeleteRubric(rubric.ID, creator.ID, "admin")
 		require.NoError(t, err)
 
 		// Try to find it again
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRubricTemplateLibrary(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	rubricService := testApp.RubricService
+
+	// --- Create Test Data ---
+	admin, _ := CreateTestUser(t, testApp, "admin-templates@test.com", "admin")
+	member, memberToken := CreateTestUser(t, testApp, "member-templates@test.com", "user")
+	_, outsiderToken := CreateTestUser(t, testApp, "outsider-templates@test.com", "user")
+	project := CreateTestProject(t, testApp, "Template Project", member.ID)
+	AddUserToProject(t, testApp, project.ID, member.ID, "product_owner")
+
+	codeReview := &models.Rubric{
+		Name:        "Software Code Review",
+		Description: "Assesses code quality",
+		CreatedByID: admin.ID,
+		Category:    "Software",
+		Status:      models.RubricStatusActive,
+		Criteria: []models.RubricCriterion{
+			{Title: "Readability", MaxPoints: 5, Levels: []models.RubricCriterionLevel{{Score: 0, Description: "Poor"}, {Score: 5, Description: "Clear"}}},
+			{Title: "Tests", MaxPoints: 5},
+		},
+	}
+	require.NoError(t, rubricService.CreateTemplate(codeReview, "admin"))
+	assert.Nil(t, codeReview.ProjectID)
+	assert.True(t, codeReview.IsTemplate)
+
+	projectRubric := CreateTestRubric(t, testApp, project.ID, member.ID, "Oral Presentation")
+	presentation, err := rubricService.SaveAsTemplate(projectRubric.ID, "Communication", "admin")
+	require.NoError(t, err)
+	assert.Nil(t, presentation.ProjectID)
+
+	t.Run("Only admins manage global templates", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, "/api/rubric-templates", memberToken, models.Rubric{Name: "Mine"})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		// Nor through the plain rubric routes.
+		rec = doEvaluationRequest(testApp, http.MethodPost, "/api/rubrics", memberToken, map[string]interface{}{"name": "Mine", "isTemplate": true})
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		templatePath := fmt.Sprintf("/api/rubrics/%d", codeReview.ID)
+		rec = doEvaluationRequest(testApp, http.MethodPut, templatePath, memberToken, map[string]interface{}{"name": "Hijacked", "isTemplate": true})
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		rec = doEvaluationRequest(testApp, http.MethodDelete, templatePath, memberToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+		template, err := rubricService.GetRubricByID(codeReview.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Software Code Review", template.Name)
+		assert.Nil(t, template.SupersededAt)
+	})
+
+	t.Run("Only project members manage project rubrics", func(t *testing.T) {
+		create := map[string]interface{}{"name": "Project Rubric", "projectId": project.ID}
+		rec := doEvaluationRequest(testApp, http.MethodPost, "/api/rubrics", outsiderToken, create)
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, "/api/rubrics", memberToken, create)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created models.Rubric
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		rubricPath := fmt.Sprintf("/api/rubrics/%d", created.ID)
+
+		rec = doEvaluationRequest(testApp, http.MethodPut, rubricPath, outsiderToken, map[string]interface{}{"name": "Renamed"})
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		rec = doEvaluationRequest(testApp, http.MethodDelete, rubricPath, outsiderToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		rec = doEvaluationRequest(testApp, http.MethodDelete, rubricPath, memberToken, nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	})
+
+	instantiatePath := fmt.Sprintf("/api/rubric-templates/%d/instantiate", codeReview.ID)
+	body := map[string]uint{"projectId": project.ID}
+
+	t.Run("Instantiates a deep copy into a project", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, instantiatePath, memberToken, body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var instance models.Rubric
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &instance))
+		assert.NotEqual(t, codeReview.ID, instance.ID)
+		require.NotNil(t, instance.ProjectID)
+		assert.Equal(t, project.ID, *instance.ProjectID)
+		assert.False(t, instance.IsTemplate)
+		assert.Equal(t, models.RubricStatusDraft, instance.Status)
+		require.NotNil(t, instance.SourceTemplateID)
+		assert.Equal(t, codeReview.ID, *instance.SourceTemplateID)
+		require.Len(t, instance.Criteria, 2)
+		assert.NotEqual(t, codeReview.Criteria[0].ID, instance.Criteria[0].ID)
+		assert.Len(t, instance.Criteria[0].Levels, 2)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, instantiatePath, memberToken, body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	})
+
+	t.Run("Rejects non-members and non-templates", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPost, instantiatePath, outsiderToken, body)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/rubric-templates/%d/instantiate", projectRubric.ID), memberToken, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Searches templates with usage counts", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/rubric-templates", memberToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var templates []services.RubricTemplateSummary
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &templates))
+		require.Len(t, templates, 2)
+		assert.Equal(t, codeReview.ID, templates[0].ID, "Most used templates come first")
+		assert.Equal(t, int64(2), templates[0].UsageCount)
+		assert.Equal(t, int64(0), templates[1].UsageCount)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, "/api/rubric-templates?q=CODE", memberToken, nil)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &templates))
+		require.Len(t, templates, 1)
+		assert.Equal(t, codeReview.ID, templates[0].ID)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, "/api/rubric-templates?category=Communication", memberToken, nil)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &templates))
+		require.Len(t, templates, 1)
+		assert.Equal(t, presentation.ID, templates[0].ID)
+	})
+
+	t.Run("Lists categories", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, "/api/rubric-templates/categories", memberToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var categories []storage.TemplateCategoryCount
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &categories))
+		assert.Equal(t, []storage.TemplateCategoryCount{{Category: "Communication", Count: 1}, {Category: "Software", Count: 1}}, categories)
+	})
+
+	t.Run("Templates are not listed as project rubrics", func(t *testing.T) {
+		rubrics, err := rubricService.GetRubricsByProjectID(project.ID)
+		require.NoError(t, err)
+		assert.Len(t, rubrics, 3, "The original rubric plus two instances")
+	})
+}
@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRubricVersioning(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	owner, ownerToken := CreateTestUser(t, testApp, "owner-versions@test.com", "user")
+	instructor, instructorToken := CreateTestUser(t, testApp, "instructor-versions@test.com", "user")
+	student, _ := CreateTestUser(t, testApp, "student-versions@test.com", "user")
+	project := CreateTestProject(t, testApp, "Versioning Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+	AddUserToProject(t, testApp, project.ID, instructor.ID, "instructor")
+	userStory := CreateTestUserStory(t, testApp, "Versioning Story", project.ID)
+	task := CreateTestTask(t, testApp, "Versioning Task", userStory.ID, student.ID)
+
+	rubric := &models.Rubric{
+		Name:        "Essay",
+		ProjectID:   &project.ID,
+		CreatedByID: owner.ID,
+		Status:      models.RubricStatusActive,
+		Criteria: []models.RubricCriterion{
+			{Title: "Argument", MaxPoints: 10},
+			{Title: "Spelling", MaxPoints: 5},
+		},
+	}
+	require.NoError(t, testApp.DB.Create(rubric).Error)
+	argument := rubric.Criteria[0]
+
+	evaluation, err := testApp.EvaluationService.CreateEvaluation(task.ID, instructor.ID, services.CreateEvaluationRequest{
+		RubricID:             rubric.ID,
+		CriterionEvaluations: []services.CriterionEvaluationRequest{{CriterionID: argument.ID, Score: 9}},
+	})
+	require.NoError(t, err)
+
+	rubricPath := fmt.Sprintf("/api/rubrics/%d", rubric.ID)
+
+	var v2 models.Rubric
+	t.Run("Editing a used rubric creates a new version", func(t *testing.T) {
+		edited := map[string]interface{}{
+			"name":   "Essay (revised)",
+			"status": models.RubricStatusActive,
+			"criteria": []map[string]interface{}{
+				{"id": argument.ID, "title": "Argument", "maxPoints": 8},
+				{"title": "Sources", "maxPoints": 2},
+			},
+		}
+		rec := doEvaluationRequest(testApp, http.MethodPut, rubricPath, ownerToken, edited)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &v2))
+		assert.NotEqual(t, rubric.ID, v2.ID)
+		assert.Equal(t, 2, v2.Version)
+		require.NotNil(t, v2.RootRubricID)
+		assert.Equal(t, rubric.ID, *v2.RootRubricID)
+
+		// The old version and the scores recorded against it are untouched.
+		old, err := testApp.RubricService.GetRubricByID(rubric.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, old.SupersededAt)
+		assert.Equal(t, "Essay", old.Name)
+		assert.Equal(t, 10.0, old.Criteria[0].MaxPoints)
+
+		stored, err := testApp.EvaluationService.EvalRepo.GetEvaluationByID(evaluation.ID)
+		require.NoError(t, err)
+		assert.Equal(t, rubric.ID, stored.RubricID)
+		assert.Equal(t, argument.ID, stored.CriterionEvaluations[0].CriterionID)
+		assert.Equal(t, 10.0, stored.CriterionEvaluations[0].Criterion.MaxPoints)
+	})
+
+	t.Run("Superseded versions are read-only and hidden from listings", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodPut, rubricPath, ownerToken, map[string]interface{}{"name": "Stale edit"})
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodDelete, rubricPath, ownerToken, nil)
+		assert.Equal(t, http.StatusConflict, rec.Code, "Used rubrics cannot be deleted")
+
+		rubrics, err := testApp.RubricService.GetRubricsByProjectID(project.ID)
+		require.NoError(t, err)
+		require.Len(t, rubrics, 1)
+		assert.Equal(t, v2.ID, rubrics[0].ID)
+	})
+
+	t.Run("Versions of a lineage cannot be deleted", func(t *testing.T) {
+		unused := &models.Rubric{
+			Name:        "Lab Report",
+			ProjectID:   &project.ID,
+			CreatedByID: owner.ID,
+			Status:      models.RubricStatusActive,
+			Criteria:    []models.RubricCriterion{{Title: "Method", MaxPoints: 10}},
+		}
+		require.NoError(t, testApp.DB.Create(unused).Error)
+		rec := doEvaluationRequest(testApp, http.MethodPut, fmt.Sprintf("/api/rubrics/%d", unused.ID), ownerToken, map[string]interface{}{
+			"name":     "Lab Report (revised)",
+			"status":   models.RubricStatusActive,
+			"criteria": []map[string]interface{}{{"title": "Method", "maxPoints": 5}},
+		})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var latest models.Rubric
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &latest))
+
+		// Neither version is used by an evaluation, yet deleting either would break the lineage.
+		for _, id := range []uint{unused.ID, latest.ID} {
+			rec = doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/rubrics/%d", id), ownerToken, nil)
+			assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+		}
+		versions, err := testApp.RubricService.GetRubricVersions(latest.ID)
+		require.NoError(t, err)
+		assert.Len(t, versions, 2)
+	})
+
+	t.Run("Lists the version history", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/rubrics/%d/versions", v2.ID), ownerToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var versions []models.Rubric
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &versions))
+		require.Len(t, versions, 2)
+		assert.Equal(t, 1, versions[0].Version)
+		assert.Equal(t, 2, versions[1].Version)
+	})
+
+	t.Run("Diffs two versions", func(t *testing.T) {
+		rec := doEvaluationRequest(testApp, http.MethodGet, rubricPath+"/diff?from=1&to=2", ownerToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var diff services.RubricDiff
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &diff))
+
+		require.Len(t, diff.Changes, 1)
+		assert.Equal(t, "name", diff.Changes[0].Field)
+
+		changes := make(map[string]services.RubricCriterionChange)
+		for _, change := range diff.Criteria {
+			changes[change.Title] = change
+		}
+		require.Len(t, changes, 3)
+		assert.Equal(t, "modified", changes["Argument"].Change)
+		assert.Equal(t, "maxPoints", changes["Argument"].Changes[0].Field)
+		assert.Equal(t, "removed", changes["Spelling"].Change)
+		assert.Equal(t, "added", changes["Sources"].Change)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, rubricPath+"/diff?from=1&to=7", ownerToken, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("New evaluations must use the latest version", func(t *testing.T) {
+		_, err := testApp.EvaluationService.CreateEvaluation(task.ID, instructor.ID, services.CreateEvaluationRequest{RubricID: rubric.ID})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "superseded")
+	})
+
+	t.Run("Drafts on a superseded version can no longer be edited or submitted", func(t *testing.T) {
+		feedback := "Late edit"
+		_, err := testApp.EvaluationService.UpdateEvaluation(evaluation.ID, instructor.ID, services.UpdateEvaluationRequest{OverallFeedback: &feedback})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "superseded")
+
+		_, err = testApp.EvaluationService.SubmitEvaluation(evaluation.ID, instructor.ID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "superseded")
+
+		stored, err := testApp.EvaluationService.EvalRepo.GetEvaluationByID(evaluation.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.EvaluationStatusDraft, stored.Status)
+	})
+
+	t.Run("Drafts on an archived rubric can no longer be edited or submitted", func(t *testing.T) {
+		other := CreateTestTask(t, testApp, "Other Versioning Task", userStory.ID, student.ID)
+		var scores []services.CriterionEvaluationRequest
+		for _, criterion := range v2.Criteria {
+			scores = append(scores, services.CriterionEvaluationRequest{CriterionID: criterion.ID, Score: 1})
+		}
+		draft, err := testApp.EvaluationService.CreateEvaluation(other.ID, instructor.ID, services.CreateEvaluationRequest{RubricID: v2.ID, CriterionEvaluations: scores})
+		require.NoError(t, err)
+		require.NoError(t, testApp.DB.Model(&models.Rubric{}).Where("id = ?", v2.ID).Update("status", models.RubricStatusArchived).Error)
+
+		rec := doEvaluationRequest(testApp, http.MethodPut, fmt.Sprintf("/api/evaluations/%d", draft.ID), instructorToken, map[string]string{"overallFeedback": "Late edit"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), "not active")
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/evaluations/%d/submit", draft.ID), instructorToken, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), "not active")
+	})
+}
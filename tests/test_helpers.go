@@ -3,6 +3,7 @@ package tests
 import (
 	"log"
 	"testing"
+	"time"
 
 	"github.com/buga/API_wrkf/config"
 	"github.com/buga/API_wrkf/handlers"
@@ -30,6 +31,11 @@ type TestApp struct {
 	EvaluationService   *services.EvaluationService
 	EventService        *services.EventService
 	ExportService       *services.ExportService
+	TrashService        *services.TrashService
+	TemplateService     *services.ProjectTemplateService
+	HealthService       *services.HealthService
+	RiskService         *services.RiskService
+	WSManager           *websocket.WebSocketManager
 }
 
 // SetupTestApp initializes a full application stack for integration testing.
@@ -55,6 +61,16 @@ func SetupTestApp() *TestApp {
 	reportingRepo := storage.NewReportingRepository(db)
 	evalRepo := storage.NewEvaluationRepository(db)
 	eventRepo := storage.NewEventRepository(db)
+	auditRepo := storage.NewAuditRepository(db)
+	trashRepo := storage.NewTrashRepository(db)
+	projectTemplateRepo := storage.NewProjectTemplateRepository(db)
+	projectArchiveRepo := storage.NewProjectArchiveRepository(db)
+	bulkRepo := storage.NewBulkRepository(db)
+	workRepo := storage.NewWorkRepository(db)
+	healthRepo := storage.NewHealthRepository(db)
+	contributionRepo := storage.NewContributionRepository(db)
+	riskRepo := storage.NewRiskRepository(db)
+	exportRepo := storage.NewExportRepository(db)
 
 	userService := services.NewUserService(userRepo, cfg.JWTSecret)
 	notificationService := services.NewNotificationService(notificationRepo)
@@ -62,31 +78,69 @@ func SetupTestApp() *TestApp {
 	sprintService := services.NewSprintService(sprintRepo)
 	taskService := services.NewTaskService(taskRepo, projectService, notificationService)
 	userStoryService := services.NewUserStoryService(userStoryRepo, projectService, sprintService)
-	rubricService := services.NewRubricService(rubricRepo)
+	rubricService := services.NewRubricService(rubricRepo, projectRepo)
 	reportingService := services.NewReportingService(reportingRepo, userStoryRepo, sprintRepo)
-	evaluationService := services.NewEvaluationService(evalRepo, taskRepo, rubricRepo, projectService)
+	evaluationService := services.NewEvaluationService(evalRepo, taskRepo, rubricRepo, sprintRepo, projectService, notificationService)
 	eventService := services.NewEventService(eventRepo, projectService)
-	exportService := services.NewExportService(projectRepo, userStoryRepo, taskRepo) // <-- NEW
+	exportService := services.NewExportService(projectRepo, exportRepo) // <-- NEW
+	gradebookService := services.NewGradebookService(evalRepo, taskRepo, projectService)
+	auditService := services.NewAuditService(auditRepo)
+	trashService := services.NewTrashService(trashRepo, projectService, 30*24*time.Hour)
+	projectTemplateService := services.NewProjectTemplateService(projectTemplateRepo, projectService)
+	projectArchiveService := services.NewProjectArchiveService(projectArchiveRepo, userRepo, projectService)
+	bulkService := services.NewBulkService(bulkRepo, projectService, sprintService, notificationService)
+	workService := services.NewWorkService(workRepo, eventRepo, notificationService)
+	healthService := services.NewHealthService(healthRepo, projectService)
+	contributionService := services.NewContributionService(contributionRepo, projectService, sprintService)
+	riskService := services.NewRiskService(riskRepo, projectService, notificationService, time.Duration(cfg.RiskStaleDays)*24*time.Hour)
+
+	// Domain events
+	eventBus := services.NewEventBus()
+	projectService.Events = eventBus
+	sprintService.Events = eventBus
+	taskService.Events = eventBus
+	userStoryService.Events = eventBus
+	evaluationService.Events = eventBus
+	eventService.Events = eventBus
+	bulkService.Events = eventBus
+	notificationService.Events = eventBus
+
+	// Background jobs run on one instance at a time
+	jobLock := storage.NewJobLock(db)
+	trashService.Jobs = jobLock
+	riskService.Jobs = jobLock
 
 	// WebSocket Manager
 	wsManager := websocket.NewWebSocketManager()
+	wsManager.SetAuthorizer(websocket.NewServiceAuthorizer(projectService, taskService))
+	eventBus.Subscribe(websocket.NewEventBroadcaster(wsManager, userService).Handle)
 	go wsManager.Run()
 
 	userHandler := handlers.NewUserHandler(userService)
 	projectHandler := handlers.NewProjectHandler(projectService)
 	sprintHandler := handlers.NewSprintHandler(sprintService)
 	userStoryHandler := handlers.NewUserStoryHandler(userStoryService)
-	taskHandler := handlers.NewTaskHandler(taskService, wsManager, userService)
+	taskHandler := handlers.NewTaskHandler(taskService)
 	notificationHandler := handlers.NewNotificationHandler(notificationService)
 	rubricHandler := handlers.NewRubricHandler(rubricService)
 	reportingHandler := handlers.NewReportingHandler(reportingService)
 	evaluationHandler := handlers.NewEvaluationHandler(evaluationService)
 	eventHandler := handlers.NewEventHandler(eventService)
 	exportHandler := handlers.NewExportHandler(exportService) // <-- NEW
+	gradebookHandler := handlers.NewGradebookHandler(gradebookService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	trashHandler := handlers.NewTrashHandler(trashService)
+	projectTemplateHandler := handlers.NewProjectTemplateHandler(projectTemplateService)
+	projectArchiveHandler := handlers.NewProjectArchiveHandler(projectArchiveService)
+	bulkHandler := handlers.NewBulkHandler(bulkService)
+	workHandler := handlers.NewWorkHandler(workService)
+	healthHandler := handlers.NewHealthHandler(healthService)
+	contributionHandler := handlers.NewContributionHandler(contributionService)
+	riskHandler := handlers.NewRiskHandler(riskService)
 	webSocketHandler := websocket.NewWebSocketHandler(wsManager, cfg.JWTSecret, userService, projectService)
 
 	router := echo.New()
-	routes.SetupRoutes(router, userHandler, projectHandler, sprintHandler, userStoryHandler, taskHandler, notificationHandler, rubricHandler, reportingHandler, evaluationHandler, eventHandler, exportHandler, cfg.JWTSecret)
+	routes.SetupRoutes(router, userHandler, projectHandler, sprintHandler, userStoryHandler, taskHandler, notificationHandler, rubricHandler, reportingHandler, evaluationHandler, eventHandler, exportHandler, gradebookHandler, auditHandler, trashHandler, projectTemplateHandler, projectArchiveHandler, bulkHandler, workHandler, healthHandler, contributionHandler, riskHandler, cfg.JWTSecret)
 	router.GET("/ws", webSocketHandler.HandleConnection)
 
 	return &TestApp{
@@ -102,6 +156,11 @@ func SetupTestApp() *TestApp {
 		EvaluationService:   evaluationService,
 		EventService:        eventService,
 		ExportService:       exportService, // <-- NEW
+		TrashService:        trashService,
+		TemplateService:     projectTemplateService,
+		HealthService:       healthService,
+		RiskService:         riskService,
+		WSManager:           wsManager,
 	}
 }
 
@@ -171,7 +230,7 @@ func CreateTestTask(t *testing.T, app *TestApp, title string, userStoryID uint,
 func CreateTestRubric(t *testing.T, app *TestApp, projectID, creatorID uint, name string) *models.Rubric {
 	rubric := &models.Rubric{
 		Name:        name,
-		ProjectID:   projectID,
+		ProjectID:   &projectID,
 		CreatedByID: creatorID,
 		Status:      models.RubricStatusActive,
 		Criteria: []models.RubricCriterion{
@@ -0,0 +1,299 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrash(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// --- Create Test Data ---
+	admin := &models.User{Nombre: "Trash", ApellidoPaterno: "Admin", ApellidoMaterno: "User", Correo: "admin-trash@test.com", Contraseña: "secret123"}
+	require.NoError(t, testApp.UserService.CreateAdminUser(admin))
+	rec := doEvaluationRequest(testApp, http.MethodPost, "/login", "", map[string]string{"correo": admin.Correo, "contraseña": "secret123"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	adminToken := login["token"]
+
+	owner, ownerToken := CreateTestUser(t, testApp, "owner-trash@test.com", "user")
+	dev, devToken := CreateTestUser(t, testApp, "dev-trash@test.com", "user")
+	_, outsiderToken := CreateTestUser(t, testApp, "outsider-trash@test.com", "user")
+	project := CreateTestProject(t, testApp, "Trash Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+
+	countUnscoped := func(model interface{}, id uint) int64 {
+		var count int64
+		require.NoError(t, testApp.DB.Unscoped().Model(model).Where("id = ?", id).Count(&count).Error)
+		return count
+	}
+
+	t.Run("Deleting a project moves it to the trash and restoring brings everything back", func(t *testing.T) {
+		story := CreateTestUserStory(t, testApp, "Project Story", project.ID)
+		task := CreateTestTask(t, testApp, "Project Task", story.ID, dev.ID)
+
+		rec := doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/projects/%d", project.ID), ownerToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/projects/%d", project.ID), ownerToken, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.EqualValues(t, 1, countUnscoped(&models.Task{}, task.ID), "the task is only soft-deleted")
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, "/api/projects/trash", ownerToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var trashed []models.Project
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &trashed))
+		require.Len(t, trashed, 1)
+		assert.Equal(t, project.ID, trashed[0].ID)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, "/api/projects/trash", devToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, "[]", rec.Body.String(), "only the creator sees the deleted project")
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/restore", project.ID), devToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/projects/%d/restore", project.ID), ownerToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		restoredTask, err := testApp.TaskService.GetTaskByID(task.ID)
+		require.NoError(t, err)
+		require.NotNil(t, restoredTask.AssignedToID)
+		assert.Equal(t, dev.ID, *restoredTask.AssignedToID)
+		_, err = testApp.UserStoryService.GetUserStoryByID(story.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Restoring a user story brings back its tasks", func(t *testing.T) {
+		story := CreateTestUserStory(t, testApp, "Deleted Story", project.ID)
+		task := CreateTestTask(t, testApp, "Deleted Story Task", story.ID, dev.ID)
+
+		rec := doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/userstories/%d", story.ID), ownerToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/projects/%d/trash", project.ID), devToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var trash services.ProjectTrash
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &trash))
+		require.Len(t, trash.UserStories, 1)
+		require.Len(t, trash.Tasks, 1)
+		assert.Equal(t, task.ID, trash.Tasks[0].ID)
+
+		rec = doEvaluationRequest(testApp, http.MethodGet, fmt.Sprintf("/api/projects/%d/trash", project.ID), outsiderToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/userstories/%d/restore", story.ID), devToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "developers cannot restore stories")
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/userstories/%d/restore", story.ID), ownerToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		_, err := testApp.TaskService.GetTaskByID(task.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Restoring a task restores its deleted story and drops assignees who left", func(t *testing.T) {
+		leaver, _ := CreateTestUser(t, testApp, "leaver-trash@test.com", "user")
+		AddUserToProject(t, testApp, project.ID, leaver.ID, "team_developer")
+		story := CreateTestUserStory(t, testApp, "Orphan Story", project.ID)
+		task := CreateTestTask(t, testApp, "Orphan Task", story.ID, leaver.ID)
+
+		rec := doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/userstories/%d", story.ID), ownerToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		require.NoError(t, testApp.DB.Where("project_id = ? AND user_id = ?", project.ID, leaver.ID).Delete(&models.ProjectMember{}).Error)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/tasks/%d/restore", task.ID), outsiderToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/tasks/%d/restore", task.ID), devToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		_, err := testApp.UserStoryService.GetUserStoryByID(story.ID)
+		assert.NoError(t, err, "the parent story is restored with the task")
+		restored, err := testApp.TaskService.GetTaskByID(task.ID)
+		require.NoError(t, err)
+		assert.Nil(t, restored.AssignedToID)
+	})
+
+	t.Run("Items of a deleted project cannot be restored on their own", func(t *testing.T) {
+		other := CreateTestProject(t, testApp, "Other Trash Project", owner.ID)
+		story := CreateTestUserStory(t, testApp, "Nested Story", other.ID)
+		require.NoError(t, testApp.ProjectService.DeleteProject(other.ID, owner.ID, ""))
+
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/userstories/%d/restore", story.ID), adminToken, nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("Only admins can purge and purged items are gone for good", func(t *testing.T) {
+		story := CreateTestUserStory(t, testApp, "Purged Story", project.ID)
+		task := CreateTestTask(t, testApp, "Purged Task", story.ID, dev.ID)
+		require.NoError(t, testApp.TaskService.DeleteTask(task.ID))
+
+		rec := doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/admin/trash/tasks/%d", task.ID), ownerToken, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/admin/trash/userstories/%d", story.ID), adminToken, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code, "the story is not in the trash")
+
+		rec = doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/admin/trash/tasks/%d", task.ID), adminToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		assert.Zero(t, countUnscoped(&models.Task{}, task.ID))
+	})
+
+	t.Run("Retention purge only removes expired items", func(t *testing.T) {
+		expired := CreateTestProject(t, testApp, "Expired Project", owner.ID)
+		expiredStory := CreateTestUserStory(t, testApp, "Expired Story", expired.ID)
+		fresh := CreateTestUserStory(t, testApp, "Fresh Story", project.ID)
+		require.NoError(t, testApp.ProjectService.DeleteProject(expired.ID, owner.ID, ""))
+		require.NoError(t, testApp.UserStoryService.DeleteUserStory(fresh.ID, owner.ID, ""))
+
+		old := time.Now().Add(-40 * 24 * time.Hour)
+		require.NoError(t, testApp.DB.Unscoped().Model(&models.Project{}).Where("id = ?", expired.ID).Update("deleted_at", old).Error)
+		require.NoError(t, testApp.DB.Unscoped().Model(&models.UserStory{}).Where("id = ?", expiredStory.ID).Update("deleted_at", old).Error)
+
+		rec := doEvaluationRequest(testApp, http.MethodPost, "/api/admin/trash/purge?olderThanDays=30", adminToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var result services.PurgeResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, 1, result.Projects)
+		assert.Zero(t, result.UserStories, "the expired story went with its project")
+
+		assert.Zero(t, countUnscoped(&models.Project{}, expired.ID))
+		assert.Zero(t, countUnscoped(&models.UserStory{}, expiredStory.ID))
+		assert.EqualValues(t, 1, countUnscoped(&models.UserStory{}, fresh.ID))
+	})
+}
+
+func TestTrashPurgeWithForeignKeys(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+
+	// SQLite only enforces foreign keys when asked to, and per connection, so the test
+	// runs on a single one, like Postgres would enforce them on every connection.
+	sqlDB, err := testApp.DB.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, testApp.DB.Exec("PRAGMA foreign_keys = ON").Error)
+	var enforced int
+	require.NoError(t, testApp.DB.Raw("PRAGMA foreign_keys").Scan(&enforced).Error)
+	require.Equal(t, 1, enforced)
+
+	// --- Create Test Data ---
+	owner, _ := CreateTestUser(t, testApp, "owner-purge-fk@test.com", "user")
+	dev, _ := CreateTestUser(t, testApp, "dev-purge-fk@test.com", "user")
+	create := func(value interface{}) {
+		t.Helper()
+		require.NoError(t, testApp.DB.Create(value).Error)
+	}
+
+	type seeded struct {
+		project *models.Project
+		task    *models.Task
+		rubric  *models.Rubric
+	}
+	// seed creates a project with one of everything that refers to it.
+	seed := func(name string) seeded {
+		project := CreateTestProject(t, testApp, name, owner.ID)
+		AddUserToProject(t, testApp, project.ID, owner.ID, "product_owner")
+		AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+		sprint := &models.Sprint{Name: "Sprint", ProjectID: project.ID, CreatedByID: owner.ID}
+		create(sprint)
+		story := &models.UserStory{Title: "Story", ProjectID: project.ID, SprintID: &sprint.ID, CreatedByID: owner.ID}
+		create(story)
+		task := &models.Task{Title: "Task", UserStoryID: story.ID, AssignedToID: &dev.ID, CreatedByID: owner.ID}
+		create(task)
+		create(&models.TaskComment{TaskID: task.ID, AuthorID: owner.ID, Content: "Looks good"})
+
+		rubric := &models.Rubric{Name: "Rubric", ProjectID: &project.ID, CreatedByID: owner.ID, Status: models.RubricStatusActive,
+			Criteria: []models.RubricCriterion{{Title: "Quality", MaxPoints: 10, Levels: []models.RubricCriterionLevel{{Score: 10, Description: "Great"}}}}}
+		create(rubric)
+		score := []models.CriterionEvaluation{{CriterionID: rubric.Criteria[0].ID, Score: 8}}
+		create(&models.Evaluation{TaskID: &task.ID, EvaluatorID: owner.ID, RubricID: rubric.ID, CriterionEvaluations: score})
+		round := &models.EvaluationRound{Name: "Round", SprintID: sprint.ID, ProjectID: project.ID, RubricID: rubric.ID, PeersPerMember: 1, CreatedByID: owner.ID,
+			Assignments: []models.PeerReviewAssignment{{ReviewerID: dev.ID, RevieweeID: owner.ID}}}
+		create(round)
+		create(&models.Evaluation{Type: models.EvaluationTypePeer, RoundID: &round.ID, EvaluateeID: &owner.ID, EvaluatorID: dev.ID, RubricID: rubric.ID,
+			CriterionEvaluations: []models.CriterionEvaluation{{CriterionID: rubric.Criteria[0].ID, Score: 9}}})
+
+		now := time.Now()
+		create(&models.Event{Title: "Review", StartDate: now, EndDate: now.Add(time.Hour), ProjectID: project.ID, CreatedByID: owner.ID})
+		create(&models.ProjectMetric{ProjectID: project.ID, Date: now})
+		create(&models.SprintMetric{SprintID: sprint.ID, ProjectID: &project.ID, Date: now})
+		create(&models.UserMetric{UserID: dev.ID, SprintID: &sprint.ID, ProjectID: &project.ID, Date: now})
+		create(&models.Report{Title: "Weekly", Type: "sprint", ProjectID: &project.ID, SprintID: &sprint.ID, CreatedByID: owner.ID})
+		create(&models.Conversation{Type: "project", ProjectID: &project.ID, CreatedByID: owner.ID})
+		return seeded{project: project, task: task, rubric: rubric}
+	}
+	count := func(model interface{}, query string, args ...interface{}) int64 {
+		t.Helper()
+		var n int64
+		require.NoError(t, testApp.DB.Unscoped().Model(model).Where(query, args...).Count(&n).Error)
+		return n
+	}
+	expire := func(project *models.Project) {
+		t.Helper()
+		require.NoError(t, testApp.ProjectService.DeleteProject(project.ID, owner.ID, ""))
+		old := time.Now().Add(-40 * 24 * time.Hour)
+		require.NoError(t, testApp.DB.Unscoped().Model(&models.Project{}).Where("id = ?", project.ID).Update("deleted_at", old).Error)
+	}
+
+	t.Run("Purging a task removes its evaluations", func(t *testing.T) {
+		data := seed("Task Purge")
+		require.NoError(t, testApp.TaskService.DeleteTask(data.task.ID))
+		require.NoError(t, testApp.TrashService.PurgeTask(data.task.ID, string(models.RoleAdmin)))
+		assert.Zero(t, count(&models.Task{}, "id = ?", data.task.ID))
+		assert.Zero(t, count(&models.Evaluation{}, "task_id = ?", data.task.ID))
+	})
+
+	t.Run("Purging a project removes or detaches everything that refers to it", func(t *testing.T) {
+		data := seed("Project Purge")
+		projectID := data.project.ID
+		expire(data.project)
+
+		result, err := testApp.TrashService.PurgeExpired(30 * 24 * time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, services.PurgeResult{Projects: 1}, *result)
+
+		assert.Zero(t, count(&models.Project{}, "id = ?", projectID))
+		assert.Zero(t, count(&models.Sprint{}, "project_id = ?", projectID))
+		assert.Zero(t, count(&models.Event{}, "project_id = ?", projectID))
+		assert.Zero(t, count(&models.Rubric{}, "project_id = ?", projectID))
+		assert.Zero(t, count(&models.EvaluationRound{}, "project_id = ?", projectID))
+		assert.Zero(t, count(&models.Evaluation{}, "rubric_id = ?", data.rubric.ID))
+		assert.Zero(t, count(&models.ProjectMetric{}, "project_id = ?", projectID))
+		assert.Zero(t, count(&models.UserMetric{}, "project_id = ?", projectID))
+		assert.EqualValues(t, 1, count(&models.Report{}, "title = ? AND project_id IS NULL AND sprint_id IS NULL", "Weekly"))
+		assert.EqualValues(t, 1, count(&models.Conversation{}, "project_id IS NULL"))
+	})
+
+	t.Run("A project that cannot be purged does not stop the others", func(t *testing.T) {
+		blocked := seed("Blocked Project")
+		other := seed("Other Project")
+		// An evaluation of the other project uses the first project's rubric, so the
+		// first one cannot go while the other one is there.
+		create(&models.Evaluation{TaskID: &other.task.ID, EvaluatorID: dev.ID, RubricID: blocked.rubric.ID})
+		expire(blocked.project)
+		expire(other.project)
+
+		result, err := testApp.TrashService.PurgeExpired(30 * 24 * time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, services.PurgeResult{Projects: 1, Failed: 1}, *result)
+		assert.EqualValues(t, 1, count(&models.Project{}, "id = ?", blocked.project.ID), "left in the trash")
+		assert.Zero(t, count(&models.Project{}, "id = ?", other.project.ID))
+
+		// Once the other project is gone, the next run purges it.
+		result, err = testApp.TrashService.PurgeExpired(30 * 24 * time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, services.PurgeResult{Projects: 1}, *result)
+	})
+}
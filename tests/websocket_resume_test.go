@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebSocketResume(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+	server := httptest.NewServer(testApp.Router)
+	defer server.Close()
+
+	// --- Create Test Data ---
+	owner, _ := CreateTestUser(t, testApp, "owner-resume@test.com", "user")
+	dev, devToken := CreateTestUser(t, testApp, "dev-resume@test.com", "user")
+	project := CreateTestProject(t, testApp, "Resume Project", owner.ID)
+	otherProject := CreateTestProject(t, testApp, "Other Resume Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+	story := CreateTestUserStory(t, testApp, "Story", project.ID)
+	task := CreateTestTask(t, testApp, "Task", story.ID, dev.ID)
+	updater := &models.User{ID: owner.ID, Nombre: owner.Nombre}
+
+	broadcast := func(n int) {
+		for i := 0; i < n; i++ {
+			testApp.WSManager.BroadcastTaskStatusUpdated(project.ID, task.ID, "todo", "in_progress", updater)
+		}
+	}
+	// Sequence numbers are checked relative to where the project's numbering starts,
+	// which the subscribe ack reports.
+	conn := dialWS(t, server, devToken)
+	conn.send(t, websocket.ClientMessage{Type: "subscribe", RequestID: "s0", Channel: fmt.Sprintf("project:%d", project.ID)})
+	kind, payload := conn.next(t)
+	require.Equal(t, "ack", kind)
+	base := uint64(payload["latestSeq"].(float64))
+	seq := func(n uint64) uint64 { return base + n }
+	expectEvent := func(t *testing.T, conn *wsTestConn, n uint64) {
+		raw := conn.nextRaw(t)
+		assert.Equal(t, "task_status_updated", raw.Type)
+		assert.Equal(t, project.ID, raw.ProjectID)
+		assert.Equal(t, seq(n), raw.Seq)
+	}
+
+	// Events are numbered in order.
+	broadcast(3)
+	for n := uint64(1); n <= 3; n++ {
+		expectEvent(t, conn, n)
+	}
+	require.NoError(t, conn.conn.Close())
+
+	// Events sent while the client is away are replayed when it resumes.
+	broadcast(2)
+	t.Run("Resume replays the missed events", func(t *testing.T) {
+		conn := dialWS(t, server, devToken)
+		conn.send(t, websocket.ClientMessage{Type: "resume", RequestID: "r1", ProjectID: project.ID, LastSeq: seq(3)})
+		expectEvent(t, conn, 4)
+		expectEvent(t, conn, 5)
+		kind, payload := conn.next(t)
+		assert.Equal(t, "ack", kind)
+		assert.Equal(t, "r1", payload["requestId"])
+		assert.Equal(t, "resume", payload["action"])
+		assert.Equal(t, float64(2), payload["replayed"])
+		assert.Equal(t, float64(seq(5)), payload["latestSeq"])
+
+		// Up to date: nothing to replay.
+		conn.send(t, websocket.ClientMessage{Type: "resume", RequestID: "r2", ProjectID: project.ID, LastSeq: seq(5)})
+		kind, payload = conn.next(t)
+		assert.Equal(t, "ack", kind)
+		assert.Equal(t, float64(0), payload["replayed"])
+	})
+
+	t.Run("Subscribing reports the latest sequence number", func(t *testing.T) {
+		conn := dialWS(t, server, devToken)
+		conn.send(t, websocket.ClientMessage{Type: "subscribe", RequestID: "s1", Channel: fmt.Sprintf("task:%d", task.ID)})
+		kind, payload := conn.next(t)
+		assert.Equal(t, "ack", kind)
+		assert.Equal(t, float64(project.ID), payload["projectId"])
+		assert.Equal(t, float64(seq(5)), payload["latestSeq"])
+	})
+
+	t.Run("A sequence number from before the server started asks for a resync", func(t *testing.T) {
+		conn := dialWS(t, server, devToken)
+		conn.send(t, websocket.ClientMessage{Type: "resume", RequestID: "r1", ProjectID: project.ID, LastSeq: 7})
+		kind, payload := conn.next(t)
+		assert.Equal(t, "resync_required", kind)
+		assert.Equal(t, websocket.ResyncGapTooLarge, payload["reason"])
+	})
+
+	t.Run("A sequence number ahead of the server asks for a resync", func(t *testing.T) {
+		conn := dialWS(t, server, devToken)
+		conn.send(t, websocket.ClientMessage{Type: "resume", RequestID: "r1", ProjectID: project.ID, LastSeq: seq(99)})
+		kind, payload := conn.next(t)
+		assert.Equal(t, "resync_required", kind)
+		assert.Equal(t, websocket.ResyncUnknownSequence, payload["reason"])
+		assert.Equal(t, float64(seq(5)), payload["latestSeq"])
+	})
+
+	t.Run("Resuming a project the client does not follow is an error", func(t *testing.T) {
+		conn := dialWS(t, server, devToken)
+		conn.send(t, websocket.ClientMessage{Type: "resume", RequestID: "r1", ProjectID: otherProject.ID})
+		kind, payload := conn.next(t)
+		assert.Equal(t, "error", kind)
+		assert.Contains(t, payload["error"], "not subscribed")
+	})
+
+	t.Run("A gap larger than the log asks for a resync", func(t *testing.T) {
+		broadcast(250)
+		conn := dialWS(t, server, devToken)
+		conn.send(t, websocket.ClientMessage{Type: "resume", RequestID: "r1", ProjectID: project.ID, LastSeq: seq(5)})
+		kind, payload := conn.next(t)
+		assert.Equal(t, "resync_required", kind)
+		assert.Equal(t, websocket.ResyncGapTooLarge, payload["reason"])
+		assert.Equal(t, float64(seq(255)), payload["latestSeq"])
+
+		// The latest events are still kept.
+		conn.send(t, websocket.ClientMessage{Type: "resume", RequestID: "r2", ProjectID: project.ID, LastSeq: seq(253)})
+		expectEvent(t, conn, 254)
+		expectEvent(t, conn, 255)
+		kind, _ = conn.next(t)
+		assert.Equal(t, "ack", kind)
+	})
+}
@@ -0,0 +1,220 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/websocket"
+	gws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsTestConn reads the server's messages one at a time; the server may batch several
+// of them, separated by newlines, into a single frame.
+type wsTestConn struct {
+	conn    *gws.Conn
+	pending [][]byte
+}
+
+func dialWS(t *testing.T, server *httptest.Server, token string) *wsTestConn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?token=" + token
+	conn, _, err := gws.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	// Give the manager time to register the client.
+	time.Sleep(20 * time.Millisecond)
+	return &wsTestConn{conn: conn}
+}
+
+func (c *wsTestConn) send(t *testing.T, message websocket.ClientMessage) {
+	require.NoError(t, c.conn.WriteJSON(message))
+}
+
+func (c *wsTestConn) next(t *testing.T) (string, map[string]interface{}) {
+	message := c.nextRaw(t)
+	payload, _ := message.Payload.(map[string]interface{})
+	return message.Type, payload
+}
+
+func (c *wsTestConn) nextRaw(t *testing.T) websocket.Message {
+	if len(c.pending) == 0 {
+		require.NoError(t, c.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, frame, err := c.conn.ReadMessage()
+		require.NoError(t, err)
+		c.pending = bytes.Split(frame, []byte("\n"))
+	}
+	raw := c.pending[0]
+	c.pending = c.pending[1:]
+	var message websocket.Message
+	require.NoError(t, json.Unmarshal(raw, &message))
+	return message
+}
+
+// expectNothing checks that no message arrives for a short while.
+func (c *wsTestConn) expectNothing(t *testing.T) {
+	require.Empty(t, c.pending)
+	require.NoError(t, c.conn.SetReadDeadline(time.Now().Add(150*time.Millisecond)))
+	_, frame, err := c.conn.ReadMessage()
+	require.Error(t, err, "unexpected message: %s", frame)
+}
+
+func TestWebSocketSubscriptions(t *testing.T) {
+	testApp := SetupTestApp()
+	defer TeardownTestApp(testApp)
+	server := httptest.NewServer(testApp.Router)
+	defer server.Close()
+
+	// --- Create Test Data ---
+	admin := &models.User{Nombre: "WS", ApellidoPaterno: "Admin", ApellidoMaterno: "User", Correo: "admin-ws@test.com", Contraseña: "secret123"}
+	require.NoError(t, testApp.UserService.CreateAdminUser(admin))
+	rec := doEvaluationRequest(testApp, http.MethodPost, "/login", "", map[string]string{"correo": admin.Correo, "contraseña": "secret123"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	adminToken := login["token"]
+
+	owner, _ := CreateTestUser(t, testApp, "owner-ws@test.com", "user")
+	dev, devToken := CreateTestUser(t, testApp, "dev-ws@test.com", "user")
+	newcomer, newcomerToken := CreateTestUser(t, testApp, "newcomer-ws@test.com", "user")
+	project := CreateTestProject(t, testApp, "Live Project", owner.ID)
+	otherProject := CreateTestProject(t, testApp, "Other Project", owner.ID)
+	AddUserToProject(t, testApp, project.ID, dev.ID, "team_developer")
+
+	sprint := &models.Sprint{Name: "Sprint 1", ProjectID: project.ID, CreatedByID: owner.ID}
+	require.NoError(t, testApp.DB.Create(sprint).Error)
+	story := CreateTestUserStory(t, testApp, "Sprint Story", project.ID)
+	require.NoError(t, testApp.DB.Model(story).Update("sprint_id", sprint.ID).Error)
+	sprintTask := CreateTestTask(t, testApp, "Sprint Task", story.ID, dev.ID)
+	backlogStory := CreateTestUserStory(t, testApp, "Backlog Story", project.ID)
+	backlogTask := CreateTestTask(t, testApp, "Backlog Task", backlogStory.ID, dev.ID)
+
+	t.Run("Ping is answered with a pong", func(t *testing.T) {
+		conn := dialWS(t, server, devToken)
+		conn.send(t, websocket.ClientMessage{Type: "ping", RequestID: "p1"})
+		kind, payload := conn.next(t)
+		assert.Equal(t, "pong", kind)
+		assert.Equal(t, "p1", payload["requestId"])
+		assert.NotEmpty(t, payload["timestamp"])
+	})
+
+	t.Run("Invalid requests are answered with an error", func(t *testing.T) {
+		conn := dialWS(t, server, devToken)
+		require.NoError(t, conn.conn.WriteMessage(gws.TextMessage, []byte("not json")))
+		kind, payload := conn.next(t)
+		assert.Equal(t, "error", kind)
+		assert.Contains(t, payload["error"], "invalid message")
+
+		conn.send(t, websocket.ClientMessage{Type: "subscribe", RequestID: "s1", Channel: "board:1"})
+		kind, payload = conn.next(t)
+		assert.Equal(t, "error", kind)
+		assert.Equal(t, "s1", payload["requestId"])
+		assert.Contains(t, payload["error"], "invalid channel kind")
+
+		conn.send(t, websocket.ClientMessage{Type: "shout", RequestID: "x1"})
+		kind, payload = conn.next(t)
+		assert.Equal(t, "error", kind)
+		assert.Contains(t, payload["error"], "unknown message type")
+	})
+
+	t.Run("Subscriptions are checked against membership", func(t *testing.T) {
+		conn := dialWS(t, server, devToken)
+		conn.send(t, websocket.ClientMessage{Type: "subscribe", RequestID: "s1", Channel: fmt.Sprintf("project:%d", otherProject.ID)})
+		kind, payload := conn.next(t)
+		assert.Equal(t, "error", kind)
+		assert.Contains(t, payload["error"], "forbidden")
+
+		conn.send(t, websocket.ClientMessage{Type: "subscribe", RequestID: "s2", Channel: "task:99999"})
+		kind, payload = conn.next(t)
+		assert.Equal(t, "error", kind)
+		assert.Equal(t, "task not found", payload["error"])
+
+		adminConn := dialWS(t, server, adminToken)
+		adminConn.send(t, websocket.ClientMessage{Type: "subscribe", RequestID: "a1", Channel: fmt.Sprintf("project:%d", otherProject.ID)})
+		kind, payload = adminConn.next(t)
+		assert.Equal(t, "ack", kind, "admins may follow any project")
+		assert.Equal(t, "subscribe", payload["action"])
+		assert.Equal(t, fmt.Sprintf("project:%d", otherProject.ID), payload["channel"])
+	})
+
+	t.Run("Sprint and task channels get the events of their tasks", func(t *testing.T) {
+		conn := dialWS(t, server, devToken)
+		for i, channel := range []string{
+			fmt.Sprintf("sprint:%d", sprint.ID),
+			fmt.Sprintf("task:%d", backlogTask.ID),
+		} {
+			conn.send(t, websocket.ClientMessage{Type: "subscribe", RequestID: fmt.Sprint(i), Channel: channel})
+			kind, _ := conn.next(t)
+			require.Equal(t, "ack", kind)
+		}
+		conn.send(t, websocket.ClientMessage{Type: "unsubscribe", RequestID: "u1", Channel: fmt.Sprintf("project:%d", project.ID)})
+		kind, payload := conn.next(t)
+		require.Equal(t, "ack", kind)
+		assert.Equal(t, "unsubscribe", payload["action"])
+
+		updater := &models.User{ID: owner.ID, Nombre: owner.Nombre}
+		testApp.WSManager.BroadcastTaskStatusUpdated(project.ID, sprintTask.ID, "todo", "in_progress", updater)
+		kind, payload = conn.next(t)
+		assert.Equal(t, "task_status_updated", kind)
+		assert.Equal(t, float64(sprintTask.ID), payload["taskId"])
+
+		testApp.WSManager.BroadcastTaskStatusUpdated(project.ID, backlogTask.ID, "todo", "in_progress", updater)
+		kind, payload = conn.next(t)
+		assert.Equal(t, "task_status_updated", kind)
+		assert.Equal(t, float64(backlogTask.ID), payload["taskId"])
+
+		// Project-wide events no longer reach the client.
+		testApp.WSManager.BroadcastBulkUpdate(project.ID, "task", "update", []uint{sprintTask.ID}, updater)
+		conn.expectNothing(t)
+	})
+
+	t.Run("Membership changes update subscriptions live", func(t *testing.T) {
+		conn := dialWS(t, server, newcomerToken)
+		updater := &models.User{ID: owner.ID, Nombre: owner.Nombre}
+
+		rec := doEvaluationRequest(testApp, http.MethodPost, fmt.Sprintf("/api/admin/projects/%d/members", project.ID), adminToken,
+			map[string]interface{}{"userId": newcomer.ID, "role": "team_developer"})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		kind, payload := conn.next(t)
+		assert.Equal(t, "subscribed", kind)
+		assert.Equal(t, fmt.Sprintf("project:%d", project.ID), payload["channel"])
+		assert.Equal(t, websocket.ReasonMemberAdded, payload["reason"])
+		kind, _ = conn.next(t)
+		assert.Equal(t, "notification_created", kind, "the newcomer is told they were added")
+
+		testApp.WSManager.BroadcastTaskStatusUpdated(project.ID, sprintTask.ID, "in_progress", "in_review", updater)
+		kind, _ = conn.next(t)
+		assert.Equal(t, "task_status_updated", kind)
+
+		conn.send(t, websocket.ClientMessage{Type: "subscribe", RequestID: "s1", Channel: fmt.Sprintf("task:%d", sprintTask.ID)})
+		kind, _ = conn.next(t)
+		require.Equal(t, "ack", kind)
+
+		rec = doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/admin/projects/%d/members/%d", project.ID, newcomer.ID), adminToken, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		channels := map[string]bool{}
+		for i := 0; i < 2; i++ {
+			kind, payload := conn.next(t)
+			assert.Equal(t, "unsubscribed", kind)
+			assert.Equal(t, websocket.ReasonMemberRemoved, payload["reason"])
+			channels[payload["channel"].(string)] = true
+		}
+		assert.Equal(t, map[string]bool{
+			fmt.Sprintf("project:%d", project.ID): true,
+			fmt.Sprintf("task:%d", sprintTask.ID): true,
+		}, channels)
+
+		testApp.WSManager.BroadcastTaskStatusUpdated(project.ID, sprintTask.ID, "in_review", "done", updater)
+		conn.expectNothing(t)
+
+		rec = doEvaluationRequest(testApp, http.MethodDelete, fmt.Sprintf("/api/admin/projects/%d/members/%d", project.ID, newcomer.ID), adminToken, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -2,10 +2,13 @@ package tests
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+	"github.com/buga/API_wrkf/storage"
 	"github.com/buga/API_wrkf/websocket"
 	"github.com/stretchr/testify/assert"
 )
@@ -62,3 +65,222 @@ func TestWebSocketManager_BroadcastTaskStatusUpdated(t *testing.T) {
 		t.Fatal("Test timed out: did not receive a message on the client's send channel")
 	}
 }
+
+// TestWebSocketManager_SlowClientIsDropped tests that a client that stops reading is
+// removed once its buffer is full, without breaking later broadcasts.
+func TestWebSocketManager_SlowClientIsDropped(t *testing.T) {
+	wsManager := websocket.NewWebSocketManager()
+	go wsManager.Run()
+
+	slow := websocket.NewTestClient(wsManager, 1, map[uint]bool{100: true})
+	wsManager.RegisterTestClient(slow)
+	time.Sleep(10 * time.Millisecond)
+
+	updater := &models.User{ID: 1, Nombre: "Test User"}
+	buffered := cap(slow.Send)
+	for i := 0; i < buffered+10; i++ {
+		wsManager.BroadcastTaskStatusUpdated(100, 200, "todo", "in_progress", updater)
+	}
+
+	received := 0
+	for range slow.Send {
+		received++
+	}
+	assert.Equal(t, buffered, received, "the client gets what fit in its buffer, then its channel is closed")
+
+	// The client is gone: sending to it again must not panic.
+	wsManager.BroadcastTaskStatusUpdated(100, 200, "in_progress", "done", updater)
+}
+
+// TestWebSocketManager_SequenceNumbers tests that project events are numbered per project.
+func TestWebSocketManager_SequenceNumbers(t *testing.T) {
+	wsManager := websocket.NewWebSocketManager()
+	go wsManager.Run()
+
+	client := websocket.NewTestClient(wsManager, 1, map[uint]bool{100: true, 101: true})
+	wsManager.RegisterTestClient(client)
+	time.Sleep(10 * time.Millisecond)
+
+	updater := &models.User{ID: 1, Nombre: "Test User"}
+	wsManager.BroadcastTaskStatusUpdated(100, 200, "todo", "in_progress", updater)
+	wsManager.BroadcastTaskDeleted(101, 300, updater)
+	wsManager.BroadcastTaskStatusUpdated(100, 200, "in_progress", "done", updater)
+
+	var got []websocket.Message
+	for i := 0; i < 3; i++ {
+		var msg websocket.Message
+		assert.NoError(t, json.Unmarshal(<-client.Send, &msg))
+		got = append(got, msg)
+	}
+	// Numbering starts at the manager's start time, the same for every project.
+	start := got[0].Seq
+	assert.Greater(t, start, uint64(time.Now().Add(-time.Minute).UnixMilli()))
+	assert.Equal(t, []string{"task_status_updated/100/0", "task_deleted/101/0", "task_status_updated/100/1"}, []string{
+		fmt.Sprintf("%s/%d/%d", got[0].Type, got[0].ProjectID, got[0].Seq-start),
+		fmt.Sprintf("%s/%d/%d", got[1].Type, got[1].ProjectID, got[1].Seq-start),
+		fmt.Sprintf("%s/%d/%d", got[2].Type, got[2].ProjectID, got[2].Seq-start),
+	})
+}
+
+// TestWebSocketManager_SharedBackplane tests that managers sharing a backplane, as API
+// instances do, reach each other's clients with the same sequence numbers.
+func TestWebSocketManager_SharedBackplane(t *testing.T) {
+	backplane := websocket.NewMemoryBackplane()
+	instances := make([]*websocket.WebSocketManager, 2)
+	clients := make([]*websocket.Client, 2)
+	for i := range instances {
+		instances[i] = websocket.NewWebSocketManager()
+		assert.NoError(t, instances[i].SetBackplane(backplane))
+		go instances[i].Run()
+		clients[i] = websocket.NewTestClient(instances[i], uint(i+1), map[uint]bool{100: true})
+		instances[i].RegisterTestClient(clients[i])
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	updater := &models.User{ID: 1, Nombre: "Test User"}
+	instances[0].BroadcastTaskStatusUpdated(100, 200, "todo", "in_progress", updater)
+	instances[1].BroadcastTaskDeleted(100, 200, updater)
+
+	seqs := make([][]uint64, len(clients))
+	for i, client := range clients {
+		var first, second websocket.Message
+		assert.NoError(t, json.Unmarshal(<-client.Send, &first))
+		assert.NoError(t, json.Unmarshal(<-client.Send, &second))
+		assert.Equal(t, "task_status_updated", first.Type, "instance %d", i)
+		assert.Equal(t, "task_deleted", second.Type, "instance %d", i)
+		assert.Equal(t, first.Seq+1, second.Seq, "instance %d", i)
+		seqs[i] = []uint64{first.Seq, second.Seq}
+	}
+	assert.Equal(t, seqs[0], seqs[1])
+}
+
+// TestMemoryBackplane_UserEventsAreNotNumbered tests that events meant for a single user
+// leave the sequence numbers alone.
+func TestMemoryBackplane_UserEventsAreNotNumbered(t *testing.T) {
+	backplane := websocket.NewMemoryBackplane()
+	var got []websocket.BackplaneEvent
+	assert.NoError(t, backplane.Start(func(event websocket.BackplaneEvent) { got = append(got, event) }))
+
+	before, err := backplane.LatestSeq(0)
+	assert.NoError(t, err)
+	assert.NoError(t, backplane.Publish(websocket.BackplaneEvent{Channels: []websocket.Channel{websocket.UserChannel(1)}, Message: []byte(`{}`)}))
+	after, err := backplane.LatestSeq(0)
+	assert.NoError(t, err)
+
+	if assert.Len(t, got, 1) {
+		assert.Zero(t, got[0].Seq)
+	}
+	assert.Equal(t, before, after)
+}
+
+// TestWebSocketManager_TaskCreatedCarriesNoUserRecords tests that a created task is
+// sent with references to its users rather than the users themselves.
+func TestWebSocketManager_TaskCreatedCarriesNoUserRecords(t *testing.T) {
+	wsManager := websocket.NewWebSocketManager()
+	go wsManager.Run()
+	client := websocket.NewTestClient(wsManager, 1, map[uint]bool{100: true})
+	wsManager.RegisterTestClient(client)
+	time.Sleep(10 * time.Millisecond)
+
+	creator := models.User{ID: 1, Nombre: "Creator", Correo: "creator@test.com", Contraseña: "$2a$10$creatorhash"}
+	assignee := &models.User{ID: 2, Nombre: "Assignee", Correo: "assignee@test.com", Contraseña: "$2a$10$assigneehash"}
+	task := &models.Task{ID: 200, Title: "Task", Status: models.StatusTodo, UserStoryID: 10,
+		AssignedToID: &assignee.ID, AssignedTo: assignee, CreatedByID: creator.ID, CreatedBy: creator}
+
+	wsManager.BroadcastTaskCreated(100, task)
+
+	select {
+	case msgBytes := <-client.Send:
+		assert.NotContains(t, string(msgBytes), "Contrase")
+		assert.NotContains(t, string(msgBytes), "hash")
+		assert.NotContains(t, string(msgBytes), "@test.com")
+
+		var receivedMsg websocket.Message
+		assert.NoError(t, json.Unmarshal(msgBytes, &receivedMsg))
+		payload := receivedMsg.Payload.(map[string]interface{})
+		taskPayload := payload["task"].(map[string]interface{})
+		assert.Equal(t, float64(200), taskPayload["id"])
+		assert.Equal(t, map[string]interface{}{"id": float64(1), "name": "Creator"}, taskPayload["createdBy"])
+		assert.Equal(t, map[string]interface{}{"id": float64(2), "name": "Assignee"}, taskPayload["assignedTo"])
+	case <-time.After(time.Second):
+		t.Fatal("Test timed out: did not receive the task_created message")
+	}
+}
+
+// TestRealtimeEventRepository_DeleteOlderThan tests that expired backplane events are
+// removed except for the latest one of each project, which keeps its numbering going.
+func TestRealtimeEventRepository_DeleteOlderThan(t *testing.T) {
+	testApp := SetupTestApp()
+	repo := storage.NewRealtimeEventRepository(testApp.DB)
+	old := time.Now().Add(-48 * time.Hour)
+
+	events := []models.RealtimeEvent{
+		{ProjectID: 1, Seq: 1, Message: "{}", CreatedAt: old},
+		{ProjectID: 1, Seq: 2, Message: "{}", CreatedAt: old},
+		{ProjectID: 1, Seq: 3, Message: "{}", CreatedAt: time.Now()},
+		{ProjectID: 2, Seq: 1, Message: "{}", CreatedAt: old},
+		{ProjectID: 2, Seq: 2, Message: "{}", CreatedAt: old},
+	}
+	assert.NoError(t, testApp.DB.Create(&events).Error)
+
+	removed, err := repo.DeleteOlderThan(time.Now().Add(-24 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), removed)
+
+	var left []models.RealtimeEvent
+	assert.NoError(t, testApp.DB.Order("project_id, seq").Find(&left).Error)
+	if assert.Len(t, left, 2) {
+		assert.Equal(t, []uint64{3, 2}, []uint64{left[0].Seq, left[1].Seq})
+	}
+	latest, err := repo.LatestSeq(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), latest)
+}
+
+// TestEventBroadcaster_PayloadsCarryNoPasswords tests that the events carrying models
+// reach clients with references to their users, never with the users' password hashes.
+func TestEventBroadcaster_PayloadsCarryNoPasswords(t *testing.T) {
+	wsManager := websocket.NewWebSocketManager()
+	go wsManager.Run()
+	client := websocket.NewTestClient(wsManager, 1, map[uint]bool{100: true})
+	wsManager.RegisterTestClient(client)
+	time.Sleep(10 * time.Millisecond)
+	broadcaster := websocket.NewEventBroadcaster(wsManager, nil)
+
+	user := models.User{ID: 1, Nombre: "Owner", Correo: "owner@test.com", Contraseña: "$2a$10$ownerhash"}
+	project := models.Project{ID: 100, Name: "Project", CreatedByID: user.ID, CreatedBy: user}
+	sprint := models.Sprint{ID: 5, Name: "Sprint", ProjectID: project.ID, Project: project, CreatedByID: user.ID, CreatedBy: user}
+	story := models.UserStory{ID: 10, Title: "Story", ProjectID: project.ID, Project: project, SprintID: &sprint.ID, Sprint: &sprint,
+		CreatedByID: user.ID, CreatedBy: user, AssignedToID: &user.ID, AssignedTo: &user}
+	task := models.Task{ID: 20, Title: "Task", UserStoryID: story.ID, UserStory: story, AssignedToID: &user.ID, AssignedTo: &user,
+		CreatedByID: user.ID, CreatedBy: user}
+	comment := models.TaskComment{ID: 30, TaskID: task.ID, AuthorID: user.ID, Author: user, Content: "Comment"}
+	task.Comments = []models.TaskComment{comment}
+	event := models.Event{ID: 40, Title: "Review", ProjectID: project.ID, Project: project, CreatedByID: user.ID, CreatedBy: user}
+
+	events := []services.DomainEvent{
+		services.TaskCreated{Task: &task},
+		services.TaskUpdated{Task: &task},
+		services.TaskAssigned{Task: &task},
+		services.TaskCommentAdded{TaskID: task.ID, Comment: &comment},
+		services.UserStoryCreated{UserStory: &story},
+		services.UserStoryUpdated{UserStory: &story},
+		services.SprintCreated{Sprint: &sprint},
+		services.SprintUpdated{Sprint: &sprint},
+		services.CalendarEventCreated{Event: &event},
+		services.CalendarEventUpdated{Event: &event},
+		services.ProjectUpdated{Project: &project},
+	}
+	for _, domainEvent := range events {
+		broadcaster.Handle(services.PublishedEvent{ProjectID: project.ID, ActorID: user.ID, OccurredAt: time.Now(), Event: domainEvent})
+
+		select {
+		case msgBytes := <-client.Send:
+			assert.NotContains(t, string(msgBytes), "Contrase", domainEvent.EventName())
+			assert.NotContains(t, string(msgBytes), user.Contraseña, domainEvent.EventName())
+			assert.NotContains(t, string(msgBytes), user.Correo, domainEvent.EventName())
+		case <-time.After(time.Second):
+			t.Fatalf("Test timed out: did not receive the %s message", domainEvent.EventName())
+		}
+	}
+}
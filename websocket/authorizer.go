@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"fmt"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+)
+
+// ChannelAuthorizer decides which channels a user may subscribe to and tells the
+// manager where task events belong.
+type ChannelAuthorizer interface {
+	// Authorize returns the project the channel belongs to when the user may subscribe
+	// to it, or an error saying why not.
+	Authorize(userID uint, userRole string, channel Channel) (uint, error)
+	// SprintOfTask returns the sprint of a task's user story, if it has one.
+	SprintOfTask(taskID uint) (uint, bool)
+}
+
+// ServiceAuthorizer authorizes channels against project membership: platform admins may
+// subscribe to any channel, other users only to the channels of their projects.
+type ServiceAuthorizer struct {
+	projectService *services.ProjectService
+	taskService    *services.TaskService
+}
+
+// NewServiceAuthorizer creates a new ServiceAuthorizer.
+func NewServiceAuthorizer(projectService *services.ProjectService, taskService *services.TaskService) *ServiceAuthorizer {
+	return &ServiceAuthorizer{projectService: projectService, taskService: taskService}
+}
+
+// Authorize implements ChannelAuthorizer.
+func (a *ServiceAuthorizer) Authorize(userID uint, userRole string, channel Channel) (uint, error) {
+	projectID, err := a.projectService.GetProjectIDFor(channel.Kind, channel.ID)
+	if err != nil {
+		return 0, fmt.Errorf("could not resolve channel: %w", err)
+	}
+	if projectID == 0 {
+		return 0, fmt.Errorf("%s not found", channel.Kind)
+	}
+	if _, err := a.projectService.GetProjectByID(projectID); err != nil {
+		return 0, fmt.Errorf("project not found")
+	}
+	if userRole != string(models.RoleAdmin) {
+		if _, err := a.projectService.GetUserRoleInProject(userID, projectID); err != nil {
+			return 0, fmt.Errorf("forbidden: you are not a member of this project")
+		}
+	}
+	return projectID, nil
+}
+
+// SprintOfTask implements ChannelAuthorizer.
+func (a *ServiceAuthorizer) SprintOfTask(taskID uint) (uint, bool) {
+	sprintID, err := a.taskService.GetSprintIDForTask(taskID)
+	if err != nil || sprintID == nil {
+		return 0, false
+	}
+	return *sprintID, true
+}
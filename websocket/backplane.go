@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// BackplaneEvent is a project event travelling between API instances. Events meant for a
+// single user have no project (ProjectID 0): they are not numbered, logged nor replayed.
+type BackplaneEvent struct {
+	ProjectID uint
+	Seq       uint64 // Assigned by the backplane
+	Channels  []Channel
+	Message   json.RawMessage // The Message to send, without its project and sequence number
+}
+
+// Backplane carries events from the API instance that raises them to every instance,
+// that one included, so each can hand them to its own clients. It numbers each project's
+// events and delivers them in that order; events meant for a single user are not numbered.
+type Backplane interface {
+	// Publish numbers a project's event and delivers it to every instance.
+	Publish(event BackplaneEvent) error
+	// Start begins handing this instance's events to handle, one at a time.
+	Start(handle func(BackplaneEvent)) error
+	// LatestSeq returns the sequence number of a project's latest event.
+	LatestSeq(projectID uint) (uint64, error)
+	// Close stops delivering events.
+	Close() error
+}
+
+// MemoryBackplane is a Backplane for a single process: events are delivered to the
+// handlers started on it, in the publisher's goroutine. Several managers sharing one
+// stand in for several instances in tests. Sequence numbers start at the backplane's
+// creation time in milliseconds, so they keep growing across server restarts and a
+// client resuming from before a restart is asked to resync instead of being replayed
+// the wrong events.
+type MemoryBackplane struct {
+	deliverMutex sync.Mutex // Held while an event is numbered and delivered, to keep the order
+	mutex        sync.Mutex // Guards the fields below
+	seqBase      uint64
+	seqs         map[uint]uint64
+	handlers     []func(BackplaneEvent)
+}
+
+// NewMemoryBackplane creates a new MemoryBackplane.
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{
+		seqBase: uint64(time.Now().UnixMilli()),
+		seqs:    make(map[uint]uint64),
+	}
+}
+
+// Publish implements Backplane.
+func (b *MemoryBackplane) Publish(event BackplaneEvent) error {
+	b.deliverMutex.Lock()
+	defer b.deliverMutex.Unlock()
+
+	b.mutex.Lock()
+	if event.ProjectID != 0 {
+		event.Seq = b.latestSeq(event.ProjectID) + 1
+		b.seqs[event.ProjectID] = event.Seq
+	}
+	handlers := b.handlers
+	b.mutex.Unlock()
+
+	for _, handle := range handlers {
+		handle(event)
+	}
+	return nil
+}
+
+// Start implements Backplane.
+func (b *MemoryBackplane) Start(handle func(BackplaneEvent)) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handlers = append(b.handlers, handle)
+	return nil
+}
+
+// LatestSeq implements Backplane.
+func (b *MemoryBackplane) LatestSeq(projectID uint) (uint64, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.latestSeq(projectID), nil
+}
+
+func (b *MemoryBackplane) latestSeq(projectID uint) uint64 {
+	if seq, ok := b.seqs[projectID]; ok {
+		return seq
+	}
+	return b.seqBase
+}
+
+// Close implements Backplane.
+func (b *MemoryBackplane) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handlers = nil
+	return nil
+}
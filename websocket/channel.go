@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kinds of channel. Clients subscribe to project, sprint and task channels; every
+// connection is on the channel of its own user, which carries their notifications.
+const (
+	ChannelProject = "project"
+	ChannelSprint  = "sprint"
+	ChannelTask    = "task"
+	ChannelUser    = "user"
+)
+
+// Channel is a stream of events about one project, sprint or task, or meant for one
+// user, written as "<kind>:<id>" (e.g. "project:12") on the wire.
+type Channel struct {
+	Kind string
+	ID   uint
+}
+
+// ProjectChannel returns the channel of a project.
+func ProjectChannel(projectID uint) Channel {
+	return Channel{Kind: ChannelProject, ID: projectID}
+}
+
+// UserChannel returns the channel of a user.
+func UserChannel(userID uint) Channel {
+	return Channel{Kind: ChannelUser, ID: userID}
+}
+
+// String returns the wire form of the channel.
+func (ch Channel) String() string {
+	return fmt.Sprintf("%s:%d", ch.Kind, ch.ID)
+}
+
+// ParseChannel parses the wire form of a channel.
+func ParseChannel(raw string) (Channel, error) {
+	kind, rawID, ok := strings.Cut(raw, ":")
+	if !ok {
+		return Channel{}, fmt.Errorf("invalid channel '%s'", raw)
+	}
+	switch kind {
+	case ChannelProject, ChannelSprint, ChannelTask, ChannelUser:
+	default:
+		return Channel{}, fmt.Errorf("invalid channel kind '%s'", kind)
+	}
+	id, err := strconv.ParseUint(rawID, 10, 32)
+	if err != nil || id == 0 {
+		return Channel{}, fmt.Errorf("invalid channel id '%s'", rawID)
+	}
+	return Channel{Kind: kind, ID: uint(id)}, nil
+}
@@ -23,20 +23,25 @@ const (
 
 // Client is a middleman between the WebSocket connection and the hub.
 type Client struct {
-	hub      *WebSocketManager
-	conn     *websocket.Conn
-	Send     chan []byte // Exported for testing
-	userID   uint
-	projects map[uint]bool // Set of project IDs the user is subscribed to
+	hub           *WebSocketManager
+	conn          *websocket.Conn
+	Send          chan []byte // Exported for testing
+	userID        uint
+	role          string           // Platform role of the user
+	subscriptions map[Channel]uint // Subscribed channels and their project, guarded by the hub's mutex
 }
 
-// NewTestClient creates a new client for testing purposes.
+// NewTestClient creates a new client for testing purposes, subscribed to the given projects.
 func NewTestClient(hub *WebSocketManager, userID uint, projects map[uint]bool) *Client {
+	subscriptions := make(map[Channel]uint, len(projects))
+	for projectID := range projects {
+		subscriptions[ProjectChannel(projectID)] = projectID
+	}
 	return &Client{
-		hub:      hub,
-		Send:     make(chan []byte, 256),
-		userID:   userID,
-		projects: projects,
+		hub:           hub,
+		Send:          make(chan []byte, 256),
+		userID:        userID,
+		subscriptions: subscriptions,
 	}
 }
 
@@ -58,9 +63,7 @@ func (c *Client) readPump() {
 			}
 			break
 		}
-		// For now, we don't process messages from the client, but this is where it would happen.
-		// We can use this to handle subscription requests, etc.
-		log.Printf("Received message from client %d: %s", c.userID, message)
+		c.hub.handleClientMessage(c, message)
 	}
 }
 
@@ -107,3 +110,17 @@ func (c *Client) writePump() {
 		}
 	}
 }
+
+// subscribedToAny reports whether the client follows any of the channels.
+// The caller must hold the hub's mutex.
+func (c *Client) subscribedToAny(channels []Channel) bool {
+	for _, channel := range channels {
+		if channel == UserChannel(c.userID) {
+			return true
+		}
+		if _, ok := c.subscriptions[channel]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package websocket
+
+// eventLogSize is how many of a project's latest events are kept for clients that
+// resume after a reconnect. A client that missed more must resync over the REST API.
+const eventLogSize = 200
+
+// loggedEvent is a broadcast event kept for replay.
+type loggedEvent struct {
+	seq      uint64
+	channels []Channel // Channels the event was published on
+	data     []byte
+}
+
+// projectLog numbers the events of a project and keeps the latest ones in a ring buffer.
+type projectLog struct {
+	seq    uint64 // Sequence number of the latest event
+	events []loggedEvent
+	next   int // Slot the next event is written to once the buffer is full
+}
+
+// append keeps an event, evicting the oldest one when the log is full. When the event
+// does not follow the latest one, because this instance missed some, the log starts over
+// so that no client is replayed across the gap.
+func (l *projectLog) append(event loggedEvent) {
+	if event.seq != l.seq+1 {
+		l.events, l.next = nil, 0
+	}
+	l.seq = event.seq
+	if len(l.events) < eventLogSize {
+		l.events = append(l.events, event)
+	} else {
+		l.events[l.next] = event
+		l.next = (l.next + 1) % eventLogSize
+	}
+}
+
+// oldest returns the sequence number of the oldest event kept, or of the next event
+// when there is none.
+func (l *projectLog) oldest() uint64 {
+	if len(l.events) == 0 {
+		return l.seq + 1
+	}
+	return l.events[l.next].seq
+}
+
+// since returns, oldest first, the events kept after the given sequence number. It
+// reports false when some of them were already evicted, or were sent before the server
+// started, or when the number is ahead of the log.
+func (l *projectLog) since(lastSeq uint64) ([]loggedEvent, bool) {
+	if lastSeq > l.seq {
+		return nil, false
+	}
+	if lastSeq == l.seq {
+		return nil, true
+	}
+	if lastSeq+1 < l.oldest() {
+		return nil, false
+	}
+	var missed []loggedEvent
+	for i := 0; i < len(l.events); i++ {
+		event := l.events[(l.next+i)%len(l.events)]
+		if event.seq > lastSeq {
+			missed = append(missed, event)
+		}
+	}
+	return missed, true
+}
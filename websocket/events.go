@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+)
+
+// EventBroadcaster announces the domain events published by the services to the clients
+// following the project, sprint or task they concern, and keeps the subscriptions of
+// the members that join or leave a project up to date.
+type EventBroadcaster struct {
+	manager     *WebSocketManager
+	userService *services.UserService // To name the user who made each change
+}
+
+// NewEventBroadcaster creates a new EventBroadcaster. Its Handle method is meant to be
+// subscribed to the services' EventBus.
+func NewEventBroadcaster(manager *WebSocketManager, userService *services.UserService) *EventBroadcaster {
+	return &EventBroadcaster{manager: manager, userService: userService}
+}
+
+// Handle broadcasts an event. Task events keep the payloads of the typed Broadcast
+// helpers; the others carry the event's fields, who made the change and when. Events
+// meant for a single user go to that user's connections only.
+func (b *EventBroadcaster) Handle(published services.PublishedEvent) {
+	m := b.manager
+	projectID := published.ProjectID
+	actor := b.actor(published.ActorID)
+
+	if published.UserID != 0 {
+		if message, ok := eventMessage(published, actor); ok {
+			m.SendToUser(published.UserID, message)
+		}
+		return
+	}
+
+	switch event := published.Event.(type) {
+	case services.TaskCreated:
+		m.BroadcastTaskCreated(projectID, event.Task)
+	case services.TaskAssigned:
+		assignee := event.Task.AssignedTo
+		if assignee == nil && event.Task.AssignedToID != nil {
+			assignee = &models.User{ID: *event.Task.AssignedToID}
+		}
+		m.BroadcastTaskAssigned(projectID, event.Task.ID, assignee, actor)
+	case services.TaskStatusChanged:
+		m.BroadcastTaskStatusUpdated(projectID, event.TaskID, string(event.OldStatus), string(event.NewStatus), actor)
+	case services.TaskDeleted:
+		m.BroadcastTaskDeleted(projectID, event.TaskID, actor)
+	case services.BulkUpdated:
+		m.BroadcastBulkUpdate(projectID, event.Entity, event.Action, event.Items, actor)
+
+	case services.TaskUpdated:
+		b.broadcastTaskEvent(published, event.Task.ID, actor)
+	case services.TaskCommentAdded:
+		b.broadcastTaskEvent(published, event.TaskID, actor)
+	case services.TaskEvaluationChanged:
+		b.broadcastTaskEvent(published, event.TaskID, actor)
+
+	case services.UserStoryCreated:
+		b.broadcast(published, actor, sprintChannels(event.UserStory.SprintID)...)
+	case services.UserStoryUpdated:
+		b.broadcast(published, actor, sprintChannels(event.UserStory.SprintID)...)
+	case services.UserStoryDeleted:
+		b.broadcast(published, actor, sprintChannels(event.SprintID)...)
+	case services.SprintCreated:
+		b.broadcast(published, actor, sprintChannels(&event.Sprint.ID)...)
+	case services.SprintUpdated:
+		b.broadcast(published, actor, sprintChannels(&event.Sprint.ID)...)
+	case services.SprintDeleted:
+		b.broadcast(published, actor, sprintChannels(&event.SprintID)...)
+	case services.EvaluationRoundOpened:
+		b.broadcast(published, actor, sprintChannels(&event.SprintID)...)
+	case services.EvaluationRoundClosed:
+		b.broadcast(published, actor, sprintChannels(&event.SprintID)...)
+
+	case services.MemberAdded:
+		// The new member is subscribed after the announcement; its own clients are told
+		// by the "subscribed" message instead.
+		b.broadcast(published, actor)
+		m.MemberAdded(projectID, event.UserID)
+	case services.MemberRemoved:
+		m.MemberRemoved(projectID, event.UserID)
+		b.broadcast(published, actor)
+
+	default:
+		b.broadcast(published, actor)
+	}
+}
+
+// actor returns the user who made a change, or a user with only an ID when it cannot be
+// loaded.
+func (b *EventBroadcaster) actor(userID uint) *models.User {
+	if userID != 0 && b.userService != nil {
+		if user, err := b.userService.GetUserByID(userID); err == nil {
+			return user
+		}
+	}
+	return &models.User{ID: userID}
+}
+
+// broadcast sends an event to the subscribers of its project and of the given channels.
+func (b *EventBroadcaster) broadcast(published services.PublishedEvent, actor *models.User, channels ...Channel) {
+	message, ok := eventMessage(published, actor)
+	if !ok {
+		return
+	}
+	channels = append([]Channel{ProjectChannel(published.ProjectID)}, channels...)
+	b.manager.publish(published.ProjectID, message, channels...)
+}
+
+// broadcastTaskEvent sends an event to the subscribers of a task, its sprint and its project.
+func (b *EventBroadcaster) broadcastTaskEvent(published services.PublishedEvent, taskID uint, actor *models.User) {
+	if message, ok := eventMessage(published, actor); ok {
+		b.manager.broadcastTaskEvent(published.ProjectID, taskID, message)
+	}
+}
+
+// eventMessage builds the message announcing an event: the event's fields, with its
+// models replaced by their payloads, plus who made the change, when known, and when.
+func eventMessage(published services.PublishedEvent, actor *models.User) (Message, bool) {
+	data, err := json.Marshal(eventPayload(published.Event))
+	if err != nil {
+		log.Printf("Error marshalling %s event: %v", published.Event.EventName(), err)
+		return Message{}, false
+	}
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		log.Printf("Error marshalling %s event: %v", published.Event.EventName(), err)
+		return Message{}, false
+	}
+	if actor.ID != 0 {
+		payload["updatedBy"] = map[string]interface{}{
+			"id":   actor.ID,
+			"name": actor.Nombre,
+		}
+	}
+	payload["timestamp"] = published.OccurredAt.Format(time.RFC3339)
+	return Message{Type: published.Event.EventName(), Payload: payload}, true
+}
+
+// sprintChannels returns the channel of a sprint, or none for the backlog.
+func sprintChannels(sprintID *uint) []Channel {
+	if sprintID == nil || *sprintID == 0 {
+		return nil
+	}
+	return []Channel{{Kind: ChannelSprint, ID: *sprintID}}
+}
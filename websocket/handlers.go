@@ -38,8 +38,9 @@ func NewWebSocketHandler(hub *WebSocketManager, jwtSecret string, userService *s
 	}
 }
 
-// validateTokenAndGetUserID parses a JWT token string, validates it, and returns the user ID.
-func (h *WebSocketHandler) validateTokenAndGetUserID(tokenString string) (uint, error) {
+// validateToken parses a JWT token string, validates it, and returns the user ID and
+// platform role. The role is empty for tokens that do not carry one.
+func (h *WebSocketHandler) validateToken(tokenString string) (uint, string, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
@@ -48,16 +49,17 @@ func (h *WebSocketHandler) validateTokenAndGetUserID(tokenString string) (uint,
 	})
 
 	if err != nil {
-		return 0, err
+		return 0, "", err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		if sub, ok := claims["sub"].(float64); ok {
-			return uint(sub), nil
+			role, _ := claims["rol"].(string)
+			return uint(sub), role, nil
 		}
 	}
 
-	return 0, errors.New("invalid token claims")
+	return 0, "", errors.New("invalid token claims")
 }
 
 // HandleConnection handles the WebSocket connection request.
@@ -67,7 +69,7 @@ func (h *WebSocketHandler) HandleConnection(c echo.Context) error {
 		return c.String(http.StatusUnauthorized, "Missing token")
 	}
 
-	userID, err := h.validateTokenAndGetUserID(token)
+	userID, role, err := h.validateToken(token)
 	if err != nil {
 		return c.String(http.StatusUnauthorized, "Invalid token")
 	}
@@ -86,17 +88,20 @@ func (h *WebSocketHandler) HandleConnection(c echo.Context) error {
 		// For now, we'll proceed with an empty list to avoid breaking the connection entirely.
 	}
 
-	projects := make(map[uint]bool)
+	// Start subscribed to the channels of the user's projects; the client can change
+	// its subscriptions afterwards (see protocol.go).
+	subscriptions := make(map[Channel]uint)
 	for _, p := range userProjects {
-		projects[p.ID] = true
+		subscriptions[ProjectChannel(p.ID)] = p.ID
 	}
 
 	client := &Client{
-		hub:      h.hub,
-		conn:     conn,
-		Send:     make(chan []byte, 256),
-		userID:   userID,
-		projects: projects,
+		hub:           h.hub,
+		conn:          conn,
+		Send:          make(chan []byte, 256),
+		userID:        userID,
+		role:          role,
+		subscriptions: subscriptions,
 	}
 
 	client.hub.register <- client
@@ -10,9 +10,13 @@ import (
 )
 
 // Message defines the structure for messages sent over WebSocket.
+// Events about a project carry the project and their position in its sequence, so a
+// client can tell when it missed some and resume from the last one it saw.
 type Message struct {
-	Type    string      `json:"type"`
-	Payload interface{} `json:"payload"`
+	Type      string      `json:"type"`
+	ProjectID uint        `json:"projectId,omitempty"`
+	Seq       uint64      `json:"seq,omitempty"`
+	Payload   interface{} `json:"payload"`
 }
 
 // WebSocketManager manages WebSocket clients, registration, unregistration, and message broadcasting.
@@ -21,17 +25,25 @@ type WebSocketManager struct {
 	broadcast  chan []byte
 	register   chan *Client
 	unregister chan *Client
+	authorizer ChannelAuthorizer    // Checks subscription requests; none are accepted until it is set
+	backplane  Backplane            // Carries events between API instances
+	logs       map[uint]*projectLog // Sequence and latest events of each project seen by this instance
 	mutex      sync.RWMutex
 }
 
-// NewWebSocketManager creates and returns a new WebSocketManager.
+// NewWebSocketManager creates and returns a new WebSocketManager. It reaches only the
+// clients of this process until it is given a shared backplane with SetBackplane.
 func NewWebSocketManager() *WebSocketManager {
-	return &WebSocketManager{
+	m := &WebSocketManager{
 		clients:    make(map[*Client]bool),
 		broadcast:  make(chan []byte),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		backplane:  NewMemoryBackplane(),
+		logs:       make(map[uint]*projectLog),
 	}
+	_ = m.backplane.Start(m.dispatch)
+	return m
 }
 
 // Run starts the WebSocketManager's event loop.
@@ -47,23 +59,17 @@ func (m *WebSocketManager) Run() {
 		case client := <-m.unregister:
 			m.mutex.Lock()
 			if _, ok := m.clients[client]; ok {
-				delete(m.clients, client)
-				close(client.Send)
+				m.removeClient(client)
 				log.Printf("Client unregistered: %d", client.userID)
 			}
 			m.mutex.Unlock()
 
 		case message := <-m.broadcast:
-			m.mutex.RLock()
+			m.mutex.Lock()
 			for client := range m.clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(m.clients, client)
-				}
+				m.deliver(client, message)
 			}
-			m.mutex.RUnlock()
+			m.mutex.Unlock()
 		}
 	}
 }
@@ -73,27 +79,163 @@ func (m *WebSocketManager) RegisterTestClient(client *Client) {
 	m.register <- client
 }
 
+// SetAuthorizer sets the authorizer that checks the clients' subscription requests.
+func (m *WebSocketManager) SetAuthorizer(authorizer ChannelAuthorizer) {
+	m.mutex.Lock()
+	m.authorizer = authorizer
+	m.mutex.Unlock()
+}
+
+// SetBackplane replaces the backplane events are published on, e.g. with a
+// PostgresBackplane to reach the clients of every API instance. It is meant to be
+// called once at startup.
+func (m *WebSocketManager) SetBackplane(backplane Backplane) error {
+	m.mutex.Lock()
+	previous := m.backplane
+	m.backplane = backplane
+	m.mutex.Unlock()
+
+	if err := previous.Close(); err != nil {
+		log.Printf("Error closing WebSocket backplane: %v", err)
+	}
+	return backplane.Start(m.dispatch)
+}
+
+// deliver queues a message for a client, unless the client is gone. A client whose
+// buffer is full is too slow to keep up: it is removed, which closes its connection,
+// and it can resume once it reconnects. The caller must hold the write lock.
+func (m *WebSocketManager) deliver(c *Client, messageBytes []byte) bool {
+	if _, ok := m.clients[c]; !ok {
+		return false
+	}
+	select {
+	case c.Send <- messageBytes:
+		return true
+	default:
+		log.Printf("Dropping client %d: send buffer full", c.userID)
+		m.removeClient(c)
+		return false
+	}
+}
+
+// removeClient forgets a client and closes its send channel, which ends its write pump.
+// The caller must hold the write lock and check that the client is still registered.
+func (m *WebSocketManager) removeClient(c *Client) {
+	delete(m.clients, c)
+	close(c.Send)
+}
+
+// seqHint asks the backplane for the sequence number of a project's latest event, for
+// when this instance has not seen any yet (see latestSeq). It must be called without
+// holding the mutex, since the backplane may have to query the database.
+func (m *WebSocketManager) seqHint(projectID uint) uint64 {
+	m.mutex.RLock()
+	backplane := m.backplane
+	m.mutex.RUnlock()
+
+	seq, err := backplane.LatestSeq(projectID)
+	if err != nil {
+		log.Printf("Could not get the latest WebSocket event of project %d: %v", projectID, err)
+	}
+	return seq
+}
+
+// latestSeq returns the sequence number of the latest event of a project seen by this
+// instance, or hint when it has seen none. The caller must hold the mutex.
+func (m *WebSocketManager) latestSeq(projectID uint, hint uint64) uint64 {
+	if l, ok := m.logs[projectID]; ok {
+		return l.seq
+	}
+	return hint
+}
+
 // BroadcastToProject sends a message to all clients subscribed to a specific project.
 func (m *WebSocketManager) BroadcastToProject(projectID uint, message Message) {
+	m.publish(projectID, message, ProjectChannel(projectID))
+}
+
+// SendToUser sends a message to every connection of a user, on any instance. It is not
+// numbered nor kept for replay: clients reload what they missed over the REST API.
+func (m *WebSocketManager) SendToUser(userID uint, message Message) {
+	m.publish(0, message, UserChannel(userID))
+}
+
+// broadcastTaskEvent sends a task event to the subscribers of the task's project, of the
+// task itself and of its sprint.
+func (m *WebSocketManager) broadcastTaskEvent(projectID, taskID uint, message Message) {
+	channels := []Channel{ProjectChannel(projectID), {Kind: ChannelTask, ID: taskID}}
+
 	m.mutex.RLock()
-	defer m.mutex.RUnlock()
+	authorizer := m.authorizer
+	m.mutex.RUnlock()
+
+	// The sprint is looked up even when nobody follows it yet, so that clients that
+	// subscribe to it later can replay the event.
+	if authorizer != nil {
+		if sprintID, ok := authorizer.SprintOfTask(taskID); ok {
+			channels = append(channels, Channel{Kind: ChannelSprint, ID: sprintID})
+		}
+	}
+	m.publish(projectID, message, channels...)
+}
 
+// publish hands an event of a project, or of no project for user messages, to the
+// backplane, which numbers it and brings it back to every instance, this one included
+// (see dispatch).
+func (m *WebSocketManager) publish(projectID uint, message Message, channels ...Channel) {
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("Error marshalling broadcast message: %v", err)
 		return
 	}
 
+	m.mutex.RLock()
+	backplane := m.backplane
+	m.mutex.RUnlock()
+
+	event := BackplaneEvent{ProjectID: projectID, Channels: channels, Message: messageBytes}
+	if err := backplane.Publish(event); err != nil {
+		log.Printf("Error publishing event of project %d: %v", projectID, err)
+	}
+}
+
+// dispatch keeps an event coming from the backplane in its project's log and sends it
+// once to every client subscribed to any of its channels. The backplane hands events
+// over one at a time, so every client sees a project's events in sequence order.
+// Events of no project are sent without a sequence number and are not logged.
+func (m *WebSocketManager) dispatch(event BackplaneEvent) {
+	if event.ProjectID == 0 {
+		event.Seq = 0
+	}
+	var message struct {
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(event.Message, &message); err != nil {
+		log.Printf("Ignoring malformed event %d of project %d: %v", event.Seq, event.ProjectID, err)
+		return
+	}
+	messageBytes, err := json.Marshal(Message{Type: message.Type, ProjectID: event.ProjectID, Seq: event.Seq, Payload: message.Payload})
+	if err != nil {
+		log.Printf("Error marshalling broadcast message: %v", err)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if event.ProjectID != 0 {
+		eventLog, ok := m.logs[event.ProjectID]
+		if !ok {
+			eventLog = &projectLog{seq: event.Seq - 1}
+			m.logs[event.ProjectID] = eventLog
+		}
+		eventLog.append(loggedEvent{seq: event.Seq, channels: event.Channels, data: messageBytes})
+	}
+
 	for client := range m.clients {
-		// Check if the client is subscribed to the project.
-		if _, ok := client.projects[projectID]; ok {
-			select {
-			case client.Send <- messageBytes:
-			default:
-				// If the send channel is blocked, assume the client is dead or stuck.
-				close(client.Send)
-				delete(m.clients, client)
-			}
+		if client.subscribedToAny(event.Channels) {
+			m.deliver(client, messageBytes)
 		}
 	}
 }
@@ -114,20 +256,20 @@ func (m *WebSocketManager) BroadcastTaskStatusUpdated(projectID, taskID uint, ol
 		Type:    "task_status_updated",
 		Payload: payload,
 	}
-	m.BroadcastToProject(projectID, message)
+	m.broadcastTaskEvent(projectID, taskID, message)
 }
 
 // BroadcastTaskCreated prepares and broadcasts a task creation event.
 func (m *WebSocketManager) BroadcastTaskCreated(projectID uint, task *models.Task) {
 	payload := map[string]interface{}{
-		"task":      task,
+		"task":      newTaskPayload(task),
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	}
 	message := Message{
 		Type:    "task_created",
 		Payload: payload,
 	}
-	m.BroadcastToProject(projectID, message)
+	m.broadcastTaskEvent(projectID, task.ID, message)
 }
 
 // BroadcastTaskAssigned prepares and broadcasts a task assignment event.
@@ -148,7 +290,7 @@ func (m *WebSocketManager) BroadcastTaskAssigned(projectID, taskID uint, assigne
 		Type:    "task_assigned",
 		Payload: payload,
 	}
-	m.BroadcastToProject(projectID, message)
+	m.broadcastTaskEvent(projectID, taskID, message)
 }
 
 // BroadcastTaskDeleted prepares and broadcasts a task deletion event.
@@ -165,5 +307,24 @@ func (m *WebSocketManager) BroadcastTaskDeleted(projectID, taskID uint, deletedB
 		Type:    "task_deleted",
 		Payload: payload,
 	}
+	m.broadcastTaskEvent(projectID, taskID, message)
+}
+
+// BroadcastBulkUpdate broadcasts the changed items of a bulk operation as a single event.
+func (m *WebSocketManager) BroadcastBulkUpdate(projectID uint, entity, action string, items interface{}, updatedBy *models.User) {
+	payload := map[string]interface{}{
+		"entity": entity,
+		"action": action,
+		"items":  items,
+		"updatedBy": map[string]interface{}{
+			"id":   updatedBy.ID,
+			"name": updatedBy.Nombre,
+		},
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}
+	message := Message{
+		Type:    "bulk_update",
+		Payload: payload,
+	}
 	m.BroadcastToProject(projectID, message)
 }
@@ -0,0 +1,278 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/services"
+)
+
+// The payloads below are what clients receive for the models an event carries. They
+// copy the fields a client needs instead of serializing the models, which would send
+// (and, on a shared backplane, store) whatever else is loaded on them, such as the
+// users' password hashes.
+
+// userRef identifies a user in a payload.
+type userRef struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// newUserRef returns the reference of user, nil if there is none.
+func newUserRef(user *models.User) *userRef {
+	if user == nil || user.ID == 0 {
+		return nil
+	}
+	return &userRef{ID: user.ID, Name: user.Nombre}
+}
+
+// taskPayload is the task of a task event.
+type taskPayload struct {
+	ID             uint      `json:"id"`
+	Title          string    `json:"title"`
+	Description    string    `json:"description"`
+	Status         string    `json:"status"`
+	UserStoryID    uint      `json:"userStoryId"`
+	SprintID       *uint     `json:"sprintId,omitempty"`
+	AssignedTo     *userRef  `json:"assignedTo"`
+	EstimatedHours *float32  `json:"estimatedHours"`
+	SpentHours     *float32  `json:"spentHours"`
+	IsDeliverable  bool      `json:"isDeliverable"`
+	Labels         []string  `json:"labels"`
+	CreatedBy      *userRef  `json:"createdBy"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// newTaskPayload returns the payload of task. The sprint is only known when the task's
+// user story is loaded.
+func newTaskPayload(task *models.Task) *taskPayload {
+	if task == nil {
+		return nil
+	}
+	payload := &taskPayload{
+		ID:             task.ID,
+		Title:          task.Title,
+		Description:    task.Description,
+		Status:         string(task.Status),
+		UserStoryID:    task.UserStoryID,
+		SprintID:       task.UserStory.SprintID,
+		AssignedTo:     newUserRef(task.AssignedTo),
+		EstimatedHours: task.EstimatedHours,
+		SpentHours:     task.SpentHours,
+		IsDeliverable:  task.IsDeliverable,
+		Labels:         task.Labels,
+		CreatedBy:      newUserRef(&task.CreatedBy),
+		CreatedAt:      task.CreatedAt,
+		UpdatedAt:      task.UpdatedAt,
+	}
+	if payload.AssignedTo == nil && task.AssignedToID != nil {
+		payload.AssignedTo = &userRef{ID: *task.AssignedToID}
+	}
+	if payload.CreatedBy == nil && task.CreatedByID != 0 {
+		payload.CreatedBy = &userRef{ID: task.CreatedByID}
+	}
+	return payload
+}
+
+// commentPayload is the comment of a task_comment_added event.
+type commentPayload struct {
+	ID        uint      `json:"id"`
+	TaskID    uint      `json:"taskId"`
+	Author    *userRef  `json:"author"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newCommentPayload(comment *models.TaskComment) *commentPayload {
+	if comment == nil {
+		return nil
+	}
+	author := newUserRef(&comment.Author)
+	if author == nil {
+		author = &userRef{ID: comment.AuthorID}
+	}
+	return &commentPayload{
+		ID:        comment.ID,
+		TaskID:    comment.TaskID,
+		Author:    author,
+		Content:   comment.Content,
+		CreatedAt: comment.CreatedAt,
+	}
+}
+
+// userStoryPayload is the user story of a user story event.
+type userStoryPayload struct {
+	ID                 uint      `json:"id"`
+	Title              string    `json:"title"`
+	Description        string    `json:"description"`
+	AcceptanceCriteria string    `json:"acceptanceCriteria"`
+	Priority           string    `json:"priority"`
+	Status             string    `json:"status"`
+	Points             *int      `json:"points"`
+	ProjectID          uint      `json:"projectId"`
+	SprintID           *uint     `json:"sprintId"`
+	AssignedTo         *userRef  `json:"assignedTo"`
+	Labels             []string  `json:"labels"`
+	CreatedBy          *userRef  `json:"createdBy"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+func newUserStoryPayload(story *models.UserStory) *userStoryPayload {
+	if story == nil {
+		return nil
+	}
+	payload := &userStoryPayload{
+		ID:                 story.ID,
+		Title:              story.Title,
+		Description:        story.Description,
+		AcceptanceCriteria: story.AcceptanceCriteria,
+		Priority:           story.Priority,
+		Status:             story.Status,
+		Points:             story.Points,
+		ProjectID:          story.ProjectID,
+		SprintID:           story.SprintID,
+		AssignedTo:         newUserRef(story.AssignedTo),
+		Labels:             story.Labels,
+		CreatedBy:          newUserRef(&story.CreatedBy),
+		CreatedAt:          story.CreatedAt,
+		UpdatedAt:          story.UpdatedAt,
+	}
+	if payload.AssignedTo == nil && story.AssignedToID != nil {
+		payload.AssignedTo = &userRef{ID: *story.AssignedToID}
+	}
+	if payload.CreatedBy == nil && story.CreatedByID != 0 {
+		payload.CreatedBy = &userRef{ID: story.CreatedByID}
+	}
+	return payload
+}
+
+// sprintPayload is the sprint of a sprint event.
+type sprintPayload struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Goal        string     `json:"goal"`
+	ProjectID   uint       `json:"projectId"`
+	Status      string     `json:"status"`
+	StartDate   *time.Time `json:"startDate"`
+	EndDate     *time.Time `json:"endDate"`
+	CreatedByID uint       `json:"createdById"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+func newSprintPayload(sprint *models.Sprint) *sprintPayload {
+	if sprint == nil {
+		return nil
+	}
+	return &sprintPayload{
+		ID:          sprint.ID,
+		Name:        sprint.Name,
+		Goal:        sprint.Goal,
+		ProjectID:   sprint.ProjectID,
+		Status:      sprint.Status,
+		StartDate:   sprint.StartDate,
+		EndDate:     sprint.EndDate,
+		CreatedByID: sprint.CreatedByID,
+		CreatedAt:   sprint.CreatedAt,
+		UpdatedAt:   sprint.UpdatedAt,
+	}
+}
+
+// calendarEventPayload is the event of a calendar event message.
+type calendarEventPayload struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	StartDate   time.Time `json:"startDate"`
+	EndDate     time.Time `json:"endDate"`
+	Type        string    `json:"type"`
+	ProjectID   uint      `json:"projectId"`
+	CreatedByID uint      `json:"createdById"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func newCalendarEventPayload(event *models.Event) *calendarEventPayload {
+	if event == nil {
+		return nil
+	}
+	return &calendarEventPayload{
+		ID:          event.ID,
+		Title:       event.Title,
+		Description: event.Description,
+		StartDate:   event.StartDate,
+		EndDate:     event.EndDate,
+		Type:        event.Type,
+		ProjectID:   event.ProjectID,
+		CreatedByID: event.CreatedByID,
+		CreatedAt:   event.CreatedAt,
+		UpdatedAt:   event.UpdatedAt,
+	}
+}
+
+// projectPayload is the project of a project_updated event.
+type projectPayload struct {
+	ID               uint       `json:"id"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	Status           string     `json:"status"`
+	ArchivedAt       *time.Time `json:"archivedAt"`
+	EvaluationPolicy string     `json:"evaluationPolicy"`
+	StartDate        *time.Time `json:"startDate"`
+	EndDate          *time.Time `json:"endDate"`
+	CreatedByID      uint       `json:"createdById"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+}
+
+func newProjectPayload(project *models.Project) *projectPayload {
+	if project == nil {
+		return nil
+	}
+	return &projectPayload{
+		ID:               project.ID,
+		Name:             project.Name,
+		Description:      project.Description,
+		Status:           project.Status,
+		ArchivedAt:       project.ArchivedAt,
+		EvaluationPolicy: string(project.EvaluationPolicy),
+		StartDate:        project.StartDate,
+		EndDate:          project.EndDate,
+		CreatedByID:      project.CreatedByID,
+		CreatedAt:        project.CreatedAt,
+		UpdatedAt:        project.UpdatedAt,
+	}
+}
+
+// eventPayload returns what a message announcing event carries: the event itself, with
+// the models it holds replaced by their payloads.
+func eventPayload(event services.DomainEvent) interface{} {
+	switch event := event.(type) {
+	case services.TaskCreated:
+		return map[string]interface{}{"task": newTaskPayload(event.Task)}
+	case services.TaskUpdated:
+		return map[string]interface{}{"task": newTaskPayload(event.Task)}
+	case services.TaskAssigned:
+		return map[string]interface{}{"task": newTaskPayload(event.Task)}
+	case services.TaskCommentAdded:
+		return map[string]interface{}{"taskId": event.TaskID, "comment": newCommentPayload(event.Comment)}
+	case services.UserStoryCreated:
+		return map[string]interface{}{"userStory": newUserStoryPayload(event.UserStory)}
+	case services.UserStoryUpdated:
+		return map[string]interface{}{"userStory": newUserStoryPayload(event.UserStory)}
+	case services.SprintCreated:
+		return map[string]interface{}{"sprint": newSprintPayload(event.Sprint)}
+	case services.SprintUpdated:
+		return map[string]interface{}{"sprint": newSprintPayload(event.Sprint)}
+	case services.CalendarEventCreated:
+		return map[string]interface{}{"event": newCalendarEventPayload(event.Event)}
+	case services.CalendarEventUpdated:
+		return map[string]interface{}{"event": newCalendarEventPayload(event.Event)}
+	case services.ProjectUpdated:
+		return map[string]interface{}{"project": newProjectPayload(event.Project)}
+	default:
+		return event
+	}
+}
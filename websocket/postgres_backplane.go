@@ -0,0 +1,215 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+	"github.com/buga/API_wrkf/storage"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+const (
+	// postgresChannel is the LISTEN/NOTIFY channel the instances share.
+	postgresChannel = "websocket_events"
+	// postgresRetryWait is how long the listener waits before reconnecting after an error.
+	postgresRetryWait = 5 * time.Second
+	// postgresPayloadLimit is the largest payload NOTIFY accepts, in bytes.
+	postgresPayloadLimit = 7999
+	// postgresEventRetention is how long stored events are kept for clients to resume from.
+	postgresEventRetention = 24 * time.Hour
+	// postgresCleanupInterval is how often the expired events are removed.
+	postgresCleanupInterval = time.Hour
+)
+
+// PostgresBackplane is a Backplane that shares events between API instances through the
+// application's Postgres database. Publishing stores the event in the realtime_events
+// table and sends its ID with NOTIFY; every instance LISTENs on a connection of its own
+// and loads the events it is told about. The table keeps the latest events of each
+// project for a day, and their sequence across restarts. Events meant for a single user
+// are neither numbered nor kept: they travel whole in the NOTIFY payload.
+type PostgresBackplane struct {
+	db     *gorm.DB
+	repo   *storage.RealtimeEventRepository
+	cancel context.CancelFunc
+}
+
+// NewPostgresBackplane creates a new PostgresBackplane on the application's database.
+func NewPostgresBackplane(db *gorm.DB) *PostgresBackplane {
+	return &PostgresBackplane{db: db, repo: storage.NewRealtimeEventRepository(db)}
+}
+
+// userNotification is the NOTIFY payload of an event meant for a single user. The events
+// of a project are sent as the ID of their stored row instead.
+type userNotification struct {
+	Channels string          `json:"channels"`
+	Message  json.RawMessage `json:"message"`
+}
+
+// Publish implements Backplane.
+func (b *PostgresBackplane) Publish(event BackplaneEvent) error {
+	channels := make([]string, len(event.Channels))
+	for i, channel := range event.Channels {
+		channels[i] = channel.String()
+	}
+	if event.ProjectID == 0 {
+		payload, err := json.Marshal(userNotification{Channels: strings.Join(channels, ","), Message: event.Message})
+		if err != nil {
+			return fmt.Errorf("could not publish event: %w", err)
+		}
+		if len(payload) > postgresPayloadLimit {
+			return fmt.Errorf("could not publish event: %d bytes is too large for a user event", len(payload))
+		}
+		if err := b.repo.Notify(postgresChannel, string(payload)); err != nil {
+			return fmt.Errorf("could not publish event: %w", err)
+		}
+		return nil
+	}
+	stored := &models.RealtimeEvent{
+		ProjectID: event.ProjectID,
+		Channels:  strings.Join(channels, ","),
+		Message:   string(event.Message),
+	}
+	if err := b.repo.Append(stored, eventLogSize, postgresChannel); err != nil {
+		return fmt.Errorf("could not publish event: %w", err)
+	}
+	return nil
+}
+
+// Start implements Backplane. It listens in its own goroutine, reconnecting after errors;
+// events published while it is disconnected are missed, and clients that resume from
+// before them are asked to resync. Another goroutine removes the expired events.
+func (b *PostgresBackplane) Start(handle func(BackplaneEvent)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	go b.cleanup(ctx)
+	go func() {
+		for {
+			err := b.listen(ctx, handle)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("WebSocket backplane listener stopped: %v; reconnecting in %s", err, postgresRetryWait)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(postgresRetryWait):
+			}
+		}
+	}()
+	return nil
+}
+
+// listen takes a connection out of the pool, LISTENs on it and hands every event it is
+// told about to handle until the connection fails or ctx is cancelled.
+func (b *PostgresBackplane) listen(ctx context.Context, handle func(BackplaneEvent)) error {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("the Postgres backplane needs the pgx driver, got %T", driverConn)
+		}
+		pgConn := stdlibConn.Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+postgresChannel); err != nil {
+			return err
+		}
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(notification.Payload, "{") {
+				var user userNotification
+				if err := json.Unmarshal([]byte(notification.Payload), &user); err != nil {
+					log.Printf("Ignoring WebSocket backplane notification '%s': %v", notification.Payload, err)
+					continue
+				}
+				handle(BackplaneEvent{Channels: parseChannels(user.Channels), Message: user.Message})
+				continue
+			}
+			id, err := strconv.ParseUint(notification.Payload, 10, 32)
+			if err != nil {
+				log.Printf("Ignoring WebSocket backplane notification '%s': %v", notification.Payload, err)
+				continue
+			}
+			event, err := b.repo.WithContext(ctx).GetEvent(uint(id))
+			if err != nil {
+				// Already dropped for newer events of its project; clients see the gap.
+				log.Printf("Could not load WebSocket event %d: %v", id, err)
+				continue
+			}
+			handle(toBackplaneEvent(event))
+		}
+	})
+}
+
+// cleanup removes the events older than postgresEventRetention every
+// postgresCleanupInterval until ctx is cancelled. Every instance runs it; removing the
+// same rows twice does no harm.
+func (b *PostgresBackplane) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(postgresCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := b.repo.WithContext(ctx).DeleteOlderThan(time.Now().Add(-postgresEventRetention))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Could not remove expired WebSocket events: %v", err)
+		} else if removed > 0 {
+			log.Printf("Removed %d expired WebSocket events", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func toBackplaneEvent(event *models.RealtimeEvent) BackplaneEvent {
+	return BackplaneEvent{
+		ProjectID: event.ProjectID,
+		Seq:       event.Seq,
+		Channels:  parseChannels(event.Channels),
+		Message:   json.RawMessage(event.Message),
+	}
+}
+
+// parseChannels parses a comma-separated list of channels, skipping invalid ones.
+func parseChannels(list string) []Channel {
+	var channels []Channel
+	for _, raw := range strings.Split(list, ",") {
+		if channel, err := ParseChannel(raw); err == nil {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// LatestSeq implements Backplane.
+func (b *PostgresBackplane) LatestSeq(projectID uint) (uint64, error) {
+	return b.repo.LatestSeq(projectID)
+}
+
+// Close implements Backplane.
+func (b *PostgresBackplane) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	return nil
+}
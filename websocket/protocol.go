@@ -0,0 +1,271 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/buga/API_wrkf/models"
+)
+
+// Types of the messages a client sends to the server.
+const (
+	ClientSubscribe   = "subscribe"
+	ClientUnsubscribe = "unsubscribe"
+	ClientPing        = "ping"
+	ClientResume      = "resume"
+)
+
+// ClientMessage is a request sent by a client over its WebSocket connection.
+// RequestID is echoed back in the reply so the client can match them up.
+type ClientMessage struct {
+	Type      string `json:"type"`
+	RequestID string `json:"requestId,omitempty"`
+	Channel   string `json:"channel,omitempty"`   // subscribe and unsubscribe
+	ProjectID uint   `json:"projectId,omitempty"` // resume
+	LastSeq   uint64 `json:"lastSeq,omitempty"`   // resume: the last sequence number the client saw
+}
+
+// Reasons the server gives when it changes a client's subscriptions on its own.
+const (
+	ReasonMemberAdded   = "member_added"
+	ReasonMemberRemoved = "member_removed"
+)
+
+// Reasons the server gives when a client must resync over the REST API instead of resuming.
+const (
+	ResyncGapTooLarge     = "gap_too_large"    // Some of the missed events are no longer kept
+	ResyncUnknownSequence = "unknown_sequence" // The sequence number is ahead of the server's, e.g. after a restart
+	ResyncTooManyEvents   = "too_many_events"  // The missed events do not fit in the client's buffer
+)
+
+// handleClientMessage answers a request read from the client's connection with an
+// "ack", "pong" or "error" message; a resume may replay events before its ack or get
+// "resync_required" instead.
+func (m *WebSocketManager) handleClientMessage(c *Client, raw []byte) {
+	var request ClientMessage
+	if err := json.Unmarshal(raw, &request); err != nil {
+		m.replyError(c, "", "invalid message: expected a JSON object")
+		return
+	}
+
+	switch request.Type {
+	case ClientPing:
+		m.sendToClient(c, Message{Type: "pong", Payload: map[string]interface{}{
+			"requestId": request.RequestID,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		}})
+	case ClientSubscribe, ClientUnsubscribe:
+		channel, err := ParseChannel(request.Channel)
+		if err != nil {
+			m.replyError(c, request.RequestID, err.Error())
+			return
+		}
+		if channel.Kind == ChannelUser {
+			m.replyError(c, request.RequestID, "invalid channel kind 'user': every connection gets its own user's events")
+			return
+		}
+		if request.Type == ClientUnsubscribe {
+			m.unsubscribe(c, channel)
+			m.sendToClient(c, Message{Type: "ack", Payload: map[string]interface{}{
+				"requestId": request.RequestID,
+				"action":    request.Type,
+				"channel":   channel.String(),
+			}})
+			return
+		}
+		if err := m.subscribe(c, request.RequestID, channel); err != nil {
+			m.replyError(c, request.RequestID, err.Error())
+		}
+	case ClientResume:
+		if request.ProjectID == 0 {
+			m.replyError(c, request.RequestID, "invalid message: projectId is required")
+			return
+		}
+		if err := m.resume(c, request); err != nil {
+			m.replyError(c, request.RequestID, err.Error())
+		}
+	default:
+		m.replyError(c, request.RequestID, fmt.Sprintf("unknown message type '%s'", request.Type))
+	}
+}
+
+func (m *WebSocketManager) replyError(c *Client, requestID, reason string) {
+	m.sendToClient(c, Message{Type: "error", Payload: map[string]interface{}{
+		"requestId": requestID,
+		"error":     reason,
+	}})
+}
+
+// subscribe checks that the client's user may follow the channel, subscribes them and
+// acknowledges with the latest sequence number of the channel's project, so the client
+// knows where the events it gets from then on start.
+func (m *WebSocketManager) subscribe(c *Client, requestID string, channel Channel) error {
+	m.mutex.RLock()
+	authorizer := m.authorizer
+	m.mutex.RUnlock()
+	if authorizer == nil {
+		return fmt.Errorf("subscriptions are not available")
+	}
+
+	projectID, err := authorizer.Authorize(c.userID, c.role, channel)
+	if err != nil {
+		return err
+	}
+
+	hint := m.seqHint(projectID)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	c.subscriptions[channel] = projectID
+	m.deliverMessage(c, Message{Type: "ack", Payload: map[string]interface{}{
+		"requestId": requestID,
+		"action":    ClientSubscribe,
+		"channel":   channel.String(),
+		"projectId": projectID,
+		"latestSeq": m.latestSeq(projectID, hint),
+	}})
+	return nil
+}
+
+// unsubscribe drops the channel from the client's subscriptions. Unsubscribing from a
+// channel the client does not follow is not an error.
+func (m *WebSocketManager) unsubscribe(c *Client, channel Channel) {
+	m.mutex.Lock()
+	delete(c.subscriptions, channel)
+	m.mutex.Unlock()
+}
+
+// resume replays the events of a project the client missed since request.LastSeq, on
+// the channels it is subscribed to, followed by an "ack". When they cannot all be
+// replayed it sends "resync_required" instead, and the client should reload the
+// project over the REST API and carry on from the latestSeq it is given.
+func (m *WebSocketManager) resume(c *Client, request ClientMessage) error {
+	hint := m.seqHint(request.ProjectID)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.clients[c]; !ok {
+		return nil
+	}
+
+	subscribed := false
+	for _, projectID := range c.subscriptions {
+		if projectID == request.ProjectID {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return fmt.Errorf("not subscribed to any channel of project %d", request.ProjectID)
+	}
+
+	eventLog, ok := m.logs[request.ProjectID]
+	if !ok {
+		eventLog = &projectLog{seq: hint}
+	}
+	var missed []loggedEvent
+	reason := ""
+	if events, ok := eventLog.since(request.LastSeq); !ok {
+		if request.LastSeq > eventLog.seq {
+			reason = ResyncUnknownSequence
+		} else {
+			reason = ResyncGapTooLarge
+		}
+	} else {
+		for _, event := range events {
+			if c.subscribedToAny(event.channels) {
+				missed = append(missed, event)
+			}
+		}
+		// Leave room for the ack; a partial replay would leave the client with a gap.
+		if len(missed)+1 > cap(c.Send)-len(c.Send) {
+			reason, missed = ResyncTooManyEvents, nil
+		}
+	}
+
+	latestSeq := eventLog.seq
+	if reason != "" {
+		m.deliverMessage(c, Message{Type: "resync_required", ProjectID: request.ProjectID, Payload: map[string]interface{}{
+			"requestId": request.RequestID,
+			"reason":    reason,
+			"latestSeq": latestSeq,
+		}})
+		return nil
+	}
+	for _, event := range missed {
+		m.deliver(c, event.data)
+	}
+	m.deliverMessage(c, Message{Type: "ack", ProjectID: request.ProjectID, Payload: map[string]interface{}{
+		"requestId": request.RequestID,
+		"action":    ClientResume,
+		"replayed":  len(missed),
+		"latestSeq": latestSeq,
+	}})
+	return nil
+}
+
+// sendToClient queues a message for a single client.
+func (m *WebSocketManager) sendToClient(c *Client, message Message) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.deliverMessage(c, message)
+}
+
+// deliverMessage marshals a message and queues it for a client (see deliver).
+// The caller must hold the write lock.
+func (m *WebSocketManager) deliverMessage(c *Client, message Message) {
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshalling message for client %d: %v", c.userID, err)
+		return
+	}
+	m.deliver(c, messageBytes)
+}
+
+// MemberAdded subscribes the user's open connections to the project's channel, so they
+// get its events without reconnecting. It is called by the EventBroadcaster.
+func (m *WebSocketManager) MemberAdded(projectID, userID uint) {
+	channel := ProjectChannel(projectID)
+	hint := m.seqHint(projectID)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for client := range m.clients {
+		if client.userID != userID {
+			continue
+		}
+		if _, ok := client.subscriptions[channel]; ok {
+			continue
+		}
+		client.subscriptions[channel] = projectID
+		m.deliverMessage(client, Message{Type: "subscribed", Payload: map[string]interface{}{
+			"channel":   channel.String(),
+			"reason":    ReasonMemberAdded,
+			"projectId": projectID,
+			"latestSeq": m.latestSeq(projectID, hint),
+		}})
+	}
+}
+
+// MemberRemoved drops every subscription the user's open connections hold on the
+// project, its sprints and its tasks. Platform admins keep theirs, since they may
+// follow any project. It is called by the EventBroadcaster.
+func (m *WebSocketManager) MemberRemoved(projectID, userID uint) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for client := range m.clients {
+		if client.userID != userID || client.role == string(models.RoleAdmin) {
+			continue
+		}
+		for channel, channelProjectID := range client.subscriptions {
+			if channelProjectID != projectID {
+				continue
+			}
+			delete(client.subscriptions, channel)
+			m.deliverMessage(client, Message{Type: "unsubscribed", Payload: map[string]interface{}{
+				"channel": channel.String(),
+				"reason":  ReasonMemberRemoved,
+			}})
+		}
+	}
+}